@@ -0,0 +1,734 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	adminDefaultSearchLimit = 50
+	adminMaxSearchLimit     = 500
+)
+
+var rideStatusValues = map[string]bool{
+	"MATCHING":  true,
+	"ENROUTE":   true,
+	"PICKUP":    true,
+	"CARRYING":  true,
+	"STOPOVER":  true,
+	"ARRIVED":   true,
+	"COMPLETED": true,
+}
+
+// 管理操作を監査ログに記録する。変更を伴う操作は、その変更と同じトランザクションの logs を渡す
+func recordAdminAudit(ctx context.Context, logs AuditLogRepository, action string, targetType string, targetID string, detail any) error {
+	actor, _ := ctx.Value("admin").(string)
+
+	var detailJSON *string
+	if detail != nil {
+		b, err := json.Marshal(detail)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		detailJSON = &s
+	}
+
+	return logs.Create(ctx, &AdminAuditLog{
+		ID:         ulid.Make().String(),
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Detail:     detailJSON,
+	})
+}
+
+func adminSearchLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return adminDefaultSearchLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit is invalid")
+	}
+	return min(limit, adminMaxSearchLimit), nil
+}
+
+func unixMilliOrNil(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
+
+type adminGetUsersResponse struct {
+	Users []adminUser `json:"users"`
+}
+
+type adminUser struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Firstname   string `json:"firstname"`
+	Lastname    string `json:"lastname"`
+	DateOfBirth string `json:"date_of_birth"`
+	CreatedAt   int64  `json:"created_at"`
+	SuspendedAt *int64 `json:"suspended_at,omitempty"`
+}
+
+func (h *Handler) adminGetUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query().Get("q")
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := h.store.Users.Search(ctx, q, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "search_users", "user", "", map[string]string{"q": q}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminUser{}
+	for _, u := range users {
+		res = append(res, adminUser{
+			ID:          u.ID,
+			Username:    u.Username,
+			Firstname:   u.Firstname,
+			Lastname:    u.Lastname,
+			DateOfBirth: u.DateOfBirth,
+			CreatedAt:   u.CreatedAt.UnixMilli(),
+			SuspendedAt: unixMilliOrNil(u.SuspendedAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, &adminGetUsersResponse{Users: res})
+}
+
+type adminGetOwnersResponse struct {
+	Owners []adminOwner `json:"owners"`
+}
+
+type adminOwner struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	CreatedAt   int64  `json:"created_at"`
+	SuspendedAt *int64 `json:"suspended_at,omitempty"`
+}
+
+func (h *Handler) adminGetOwners(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query().Get("q")
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	owners, err := h.store.Owners.Search(ctx, q, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "search_owners", "owner", "", map[string]string{"q": q}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminOwner{}
+	for _, o := range owners {
+		res = append(res, adminOwner{
+			ID:          o.ID,
+			Name:        o.Name,
+			CreatedAt:   o.CreatedAt.UnixMilli(),
+			SuspendedAt: unixMilliOrNil(o.SuspendedAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, &adminGetOwnersResponse{Owners: res})
+}
+
+type adminGetChairsResponse struct {
+	Chairs []adminChair `json:"chairs"`
+}
+
+type adminChair struct {
+	ID                string      `json:"id"`
+	OwnerID           string      `json:"owner_id"`
+	Name              string      `json:"name"`
+	Model             string      `json:"model"`
+	Active            bool        `json:"active"`
+	CurrentCoordinate *Coordinate `json:"current_coordinate,omitempty"`
+	CreatedAt         int64       `json:"created_at"`
+	SuspendedAt       *int64      `json:"suspended_at,omitempty"`
+}
+
+func (h *Handler) adminGetChairs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query().Get("q")
+	ownerID := r.URL.Query().Get("owner_id")
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	chairs, err := h.store.Chairs.Search(ctx, q, ownerID, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "search_chairs", "chair", "", map[string]string{"q": q, "owner_id": ownerID}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminChair{}
+	for _, c := range chairs {
+		item := adminChair{
+			ID:          c.ID,
+			OwnerID:     c.OwnerID,
+			Name:        c.Name,
+			Model:       c.Model,
+			Active:      c.IsActive,
+			CreatedAt:   c.CreatedAt.UnixMilli(),
+			SuspendedAt: unixMilliOrNil(c.SuspendedAt),
+		}
+		if c.Latitude.Valid && c.Longitude.Valid {
+			item.CurrentCoordinate = &Coordinate{Latitude: int(c.Latitude.Int64), Longitude: int(c.Longitude.Int64)}
+		}
+		res = append(res, item)
+	}
+	writeJSON(w, http.StatusOK, &adminGetChairsResponse{Chairs: res})
+}
+
+type adminGetRidesResponse struct {
+	Rides []adminRide `json:"rides"`
+}
+
+type adminRide struct {
+	ID                    string     `json:"id"`
+	UserID                string     `json:"user_id"`
+	ChairID               *string    `json:"chair_id,omitempty"`
+	PickupCoordinate      Coordinate `json:"pickup_coordinate"`
+	DestinationCoordinate Coordinate `json:"destination_coordinate"`
+	Pooled                bool       `json:"pooled"`
+	WaypointCount         int        `json:"waypoint_count"`
+	Evaluation            *int       `json:"evaluation,omitempty"`
+	CreatedAt             int64      `json:"created_at"`
+	UpdatedAt             int64      `json:"updated_at"`
+}
+
+func toAdminRide(ride Ride) adminRide {
+	res := adminRide{
+		ID:                    ride.ID,
+		UserID:                ride.UserID,
+		PickupCoordinate:      Coordinate{Latitude: ride.PickupLatitude, Longitude: ride.PickupLongitude},
+		DestinationCoordinate: Coordinate{Latitude: ride.DestinationLatitude, Longitude: ride.DestinationLongitude},
+		Pooled:                ride.Pooled,
+		WaypointCount:         ride.WaypointCount,
+		Evaluation:            ride.Evaluation,
+		CreatedAt:             ride.CreatedAt.UnixMilli(),
+		UpdatedAt:             ride.UpdatedAt.UnixMilli(),
+	}
+	if ride.ChairID.Valid {
+		res.ChairID = &ride.ChairID.String
+	}
+	return res
+}
+
+func (h *Handler) adminGetRides(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.URL.Query().Get("user_id")
+	chairID := r.URL.Query().Get("chair_id")
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rides, err := h.store.Rides.Search(ctx, userID, chairID, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "search_rides", "ride", "", map[string]string{"user_id": userID, "chair_id": chairID}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminRide{}
+	for _, ride := range rides {
+		res = append(res, toAdminRide(ride))
+	}
+	writeJSON(w, http.StatusOK, &adminGetRidesResponse{Rides: res})
+}
+
+type adminGetRideResponse struct {
+	Ride     adminRide               `json:"ride"`
+	Statuses []adminRideStatusDetail `json:"statuses"`
+}
+
+type adminRideStatusDetail struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"created_at"`
+	AppSentAt   *int64 `json:"app_sent_at,omitempty"`
+	ChairSentAt *int64 `json:"chair_sent_at,omitempty"`
+}
+
+func (h *Handler) adminGetRide(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+
+	ride, err := h.store.Rides.Get(ctx, rideID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("ride not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	statuses, err := h.store.Rides.Statuses(ctx, rideID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "view_ride", "ride", rideID, nil); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := adminGetRideResponse{
+		Ride:     toAdminRide(*ride),
+		Statuses: []adminRideStatusDetail{},
+	}
+	for _, s := range statuses {
+		res.Statuses = append(res.Statuses, adminRideStatusDetail{
+			ID:          s.ID,
+			Status:      s.Status,
+			CreatedAt:   s.CreatedAt.UnixMilli(),
+			AppSentAt:   unixMilliOrNil(s.AppSentAt),
+			ChairSentAt: unixMilliOrNil(s.ChairSentAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+type adminPostRideStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func (h *Handler) adminPostRideStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+
+	req := &adminPostRideStatusRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !rideStatusValues[req.Status] {
+		writeError(w, r, http.StatusBadRequest, errors.New("invalid status"))
+		return
+	}
+
+	var ride *Ride
+	err := h.store.InTx(ctx, func(tx Repositories) error {
+		var err error
+		ride, err = tx.Rides.GetForUpdate(ctx, rideID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("ride not found"))
+			}
+			return err
+		}
+
+		previous, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Rides.AddStatus(ctx, ride.ID, req.Status); err != nil {
+			return err
+		}
+
+		return recordAdminAudit(ctx, tx.AuditLogs, "force_ride_status", "ride", ride.ID, map[string]string{
+			"from":   previous,
+			"to":     req.Status,
+			"reason": req.Reason,
+		})
+	})
+	if err != nil {
+		writeStatusError(w, r, err)
+		return
+	}
+
+	rideStatusCache.Set(ctx, ride.ID, req.Status, rideStatusCacheTTL)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type adminPostRideChairRequest struct {
+	ChairID string `json:"chair_id"`
+	Reason  string `json:"reason"`
+}
+
+func (h *Handler) adminPostRideChair(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+
+	req := &adminPostRideChairRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.ChairID == "" {
+		writeError(w, r, http.StatusBadRequest, errors.New("chair_id is required"))
+		return
+	}
+
+	var ride *Ride
+	err := h.store.InTx(ctx, func(tx Repositories) error {
+		var err error
+		ride, err = tx.Rides.GetForUpdate(ctx, rideID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("ride not found"))
+			}
+			return err
+		}
+
+		chair, err := tx.Chairs.GetForUpdate(ctx, req.ChairID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("chair not found"))
+			}
+			return err
+		}
+		// マッチングと同じく、配車を受け付けていて他のライドを運んでいない椅子にだけ割り当てる
+		if !chair.IsActive || chair.SuspendedAt != nil {
+			return withStatus(http.StatusConflict, errors.New("chair is not accepting rides"))
+		}
+		free, err := tx.Rides.IsChairFree(ctx, chair.ID)
+		if err != nil {
+			return err
+		}
+		if !free {
+			return withStatus(http.StatusConflict, errors.New("chair is on another ride"))
+		}
+
+		// 乗車後に椅子を替えることはできない
+		status, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
+		if err != nil {
+			return err
+		}
+		if status != "MATCHING" && status != "ENROUTE" {
+			return withStatus(http.StatusConflict, errors.New("ride can only be reassigned before pickup"))
+		}
+
+		if err := tx.Rides.SetChair(ctx, ride.ID, chair.ID); err != nil {
+			return err
+		}
+		// 新しい椅子に配車依頼として通知し直す
+		if err := tx.Rides.AddStatus(ctx, ride.ID, "MATCHING"); err != nil {
+			return err
+		}
+
+		return recordAdminAudit(ctx, tx.AuditLogs, "reassign_chair", "ride", ride.ID, map[string]string{
+			"from":   ride.ChairID.String,
+			"to":     chair.ID,
+			"reason": req.Reason,
+		})
+	})
+	if err != nil {
+		writeStatusError(w, r, err)
+		return
+	}
+
+	rideStatusCache.Set(ctx, ride.ID, "MATCHING", rideStatusCacheTTL)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type adminPostCouponsRequest struct {
+	UserID   string `json:"user_id"`
+	Code     string `json:"code"`
+	Discount int    `json:"discount"`
+	Reason   string `json:"reason"`
+}
+
+func (h *Handler) adminPostCoupons(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := &adminPostCouponsRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.UserID == "" || req.Code == "" || req.Discount <= 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("required fields(user_id, code, discount) are empty"))
+		return
+	}
+
+	err := h.store.InTx(ctx, func(tx Repositories) error {
+		if _, err := tx.Users.Get(ctx, req.UserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("user not found"))
+			}
+			return err
+		}
+
+		if err := tx.Coupons.Create(ctx, &Coupon{UserID: req.UserID, Code: req.Code, Discount: req.Discount}); err != nil {
+			if isDuplicateEntry(err) {
+				return withStatus(http.StatusConflict, errors.New("coupon already exists"))
+			}
+			return err
+		}
+
+		return recordAdminAudit(ctx, tx.AuditLogs, "issue_coupon", "user", req.UserID, map[string]any{
+			"code":     req.Code,
+			"discount": req.Discount,
+			"reason":   req.Reason,
+		})
+	})
+	if err != nil {
+		writeStatusError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+type adminPostSuspensionRequest struct {
+	Suspended bool   `json:"suspended"`
+	Reason    string `json:"reason"`
+}
+
+// 利用停止を切り替える。対象が存在しなければ sql.ErrNoRows
+func setSuspended(ctx context.Context, tx Repositories, targetType string, id string, suspendedAt *time.Time) error {
+	switch targetType {
+	case "user":
+		if _, err := tx.Users.Get(ctx, id); err != nil {
+			return err
+		}
+		return tx.Users.SetSuspended(ctx, id, suspendedAt)
+	case "owner":
+		if _, err := tx.Owners.Get(ctx, id); err != nil {
+			return err
+		}
+		return tx.Owners.SetSuspended(ctx, id, suspendedAt)
+	case "chair":
+		if _, err := tx.Chairs.Get(ctx, id); err != nil {
+			return err
+		}
+		return tx.Chairs.SetSuspended(ctx, id, suspendedAt)
+	}
+	return fmt.Errorf("unknown target type: %s", targetType)
+}
+
+// 利用者・オーナー・椅子の利用停止と解除
+func (h *Handler) adminPostSuspension(targetType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := r.PathValue("id")
+
+		req := &adminPostSuspensionRequest{}
+		if err := bindJSON(r, req); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var suspendedAt *time.Time
+		if req.Suspended {
+			now := time.Now()
+			suspendedAt = &now
+		}
+		err := h.store.InTx(ctx, func(tx Repositories) error {
+			if err := setSuspended(ctx, tx, targetType, id, suspendedAt); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return withStatus(http.StatusNotFound, errors.New(targetType+" not found"))
+				}
+				return err
+			}
+
+			action := "unsuspend"
+			if req.Suspended {
+				action = "suspend"
+			}
+			return recordAdminAudit(ctx, tx.AuditLogs, action, targetType, id, map[string]string{"reason": req.Reason})
+		})
+		if err != nil {
+			writeStatusError(w, r, err)
+			return
+		}
+		invalidatePrincipalCache(ctx, targetType, id)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type adminGetIncidentsResponse struct {
+	Incidents []adminIncident `json:"incidents"`
+}
+
+type adminIncident struct {
+	ID         string      `json:"id"`
+	RideID     string      `json:"ride_id"`
+	UserID     string      `json:"user_id"`
+	ChairID    *string     `json:"chair_id,omitempty"`
+	Kind       string      `json:"kind"`
+	Message    *string     `json:"message,omitempty"`
+	Coordinate *Coordinate `json:"coordinate,omitempty"`
+	Status     string      `json:"status"`
+	CreatedAt  int64       `json:"created_at"`
+	ResolvedAt *int64      `json:"resolved_at,omitempty"`
+}
+
+func (h *Handler) adminGetIncidents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "OPEN"
+	}
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	incidents, err := h.store.Incidents.ListByStatus(ctx, status, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordAdminAudit(ctx, h.store.AuditLogs, "search_incidents", "incident", "", map[string]string{"status": status}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminIncident{}
+	for _, incident := range incidents {
+		item := adminIncident{
+			ID:         incident.ID,
+			RideID:     incident.RideID,
+			UserID:     incident.UserID,
+			Kind:       incident.Kind,
+			Message:    incident.Message,
+			Status:     incident.Status,
+			CreatedAt:  incident.CreatedAt.UnixMilli(),
+			ResolvedAt: unixMilliOrNil(incident.ResolvedAt),
+		}
+		if incident.ChairID.Valid {
+			item.ChairID = &incident.ChairID.String
+		}
+		if incident.Latitude.Valid && incident.Longitude.Valid {
+			item.Coordinate = &Coordinate{Latitude: int(incident.Latitude.Int64), Longitude: int(incident.Longitude.Int64)}
+		}
+		res = append(res, item)
+	}
+	writeJSON(w, http.StatusOK, &adminGetIncidentsResponse{Incidents: res})
+}
+
+type adminPostIncidentResolveRequest struct {
+	Note string `json:"note"`
+}
+
+func (h *Handler) adminPostIncidentResolve(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	incidentID := r.PathValue("incident_id")
+
+	req := &adminPostIncidentResolveRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.store.InTx(ctx, func(tx Repositories) error {
+		resolved, err := tx.Incidents.Resolve(ctx, incidentID)
+		if err != nil {
+			return err
+		}
+		if !resolved {
+			return withStatus(http.StatusNotFound, errors.New("open incident not found"))
+		}
+
+		return recordAdminAudit(ctx, tx.AuditLogs, "resolve_incident", "incident", incidentID, map[string]string{"note": req.Note})
+	})
+	if err != nil {
+		writeStatusError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type adminGetAuditLogsResponse struct {
+	AuditLogs []adminAuditLogItem `json:"audit_logs"`
+}
+
+type adminAuditLogItem struct {
+	ID         string          `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+}
+
+func (h *Handler) adminGetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	targetType := r.URL.Query().Get("target_type")
+	targetID := r.URL.Query().Get("target_id")
+	limit, err := adminSearchLimit(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	logs, err := h.store.AuditLogs.Search(ctx, targetType, targetID, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := []adminAuditLogItem{}
+	for _, l := range logs {
+		item := adminAuditLogItem{
+			ID:         l.ID,
+			Actor:      l.Actor,
+			Action:     l.Action,
+			TargetType: l.TargetType,
+			TargetID:   l.TargetID,
+			CreatedAt:  l.CreatedAt.UnixMilli(),
+		}
+		if l.Detail != nil {
+			item.Detail = json.RawMessage(*l.Detail)
+		}
+		res = append(res, item)
+	}
+	writeJSON(w, http.StatusOK, &adminGetAuditLogsResponse{AuditLogs: res})
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	apiKeyPrefix     = "isk"
+	maxAPIKeyNameLen = 255
+)
+
+// APIキーに付与できるスコープ
+var apiKeyScopes = map[string][]string{
+	"chair": {"chair:activity", "chair:coordinate", "chair:rides"},
+	"owner": {"owner:read", "owner:api_keys"},
+}
+
+// キーは isk_<id>_<secret> の形式で、id で引いて secret のハッシュを照合する
+func formatAPIKey(id string, secret string) string {
+	return apiKeyPrefix + "_" + id + "_" + secret
+}
+
+func parseAPIKey(key string) (id string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(h, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// キーのハッシュ → 検証したセッション情報。
+// 失効させたときは消すが、freecache や lru はサーバーごとに持つので、
+// 他のサーバーでは sessionCacheSeconds の間は失効前の検証結果で通ることがある。
+// すぐに止めたいときは、サーバー間で共有する redis を使う
+var apiKeyCache = newTypedCache[sessionContext]("apikey.")
+
+// APIキーを検証してセッション情報を返す。検証結果はしばらくキャッシュする
+func (h *Handler) lookupAPIKey(ctx context.Context, subjectType string, key string) (*sessionContext, error) {
+	keyHash := hashToken(key)
+	now := time.Now()
+
+	if s, ok := apiKeyCache.Get(ctx, keyHash); ok && s.SubjectType == subjectType && (s.ExpiresAt == 0 || now.UnixMilli() < s.ExpiresAt) {
+		return &s, nil
+	}
+
+	id, ok := parseAPIKey(key)
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	apiKey, err := h.store.APIKeys.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errSessionNotFound
+		}
+		return nil, err
+	}
+	if apiKey.SubjectType != subjectType {
+		return nil, errSessionNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(keyHash)) != 1 {
+		return nil, errSessionNotFound
+	}
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt)) {
+		return nil, errSessionNotFound
+	}
+
+	// 毎リクエスト書き込まないよう、キャッシュし直すときだけ記録する。書き込みは apiKeyUsage がまとめて行う
+	apiKeyUsage.record(apiKey.ID, now)
+
+	res := &sessionContext{
+		SubjectType: subjectType,
+		SubjectID:   apiKey.SubjectID,
+		APIKeyID:    apiKey.ID,
+		Scopes:      strings.Split(apiKey.Scopes, ","),
+	}
+	ttl := sessionCacheSeconds
+	if apiKey.ExpiresAt != nil {
+		res.ExpiresAt = apiKey.ExpiresAt.UnixMilli()
+		ttl = min(ttl, int(time.Until(*apiKey.ExpiresAt).Seconds()))
+	}
+	if ttl > 0 {
+		apiKeyCache.Set(ctx, keyHash, *res, time.Duration(ttl)*time.Second)
+	}
+	return res, nil
+}
+
+// APIキーで認証されたリクエストは、スコープを持つ場合のみ通す
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := r.Context().Value("session").(*sessionContext)
+			if !session.allows(scope) {
+				writeError(w, r, http.StatusForbidden, fmt.Errorf("scope %s is required", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// セッション管理はCookieのセッションでのみ許可する
+func requireCookieSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value("session").(*sessionContext)
+		if session.APIKeyID != "" {
+			writeError(w, r, http.StatusForbidden, errors.New("not allowed with api key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type ownerPostAPIKeysRequest struct {
+	Name      string   `json:"name"`
+	ChairID   *string  `json:"chair_id"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *int64   `json:"expires_at"`
+}
+
+type ownerPostAPIKeysResponse struct {
+	ID        string   `json:"id"`
+	Key       string   `json:"key"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *int64   `json:"expires_at,omitempty"`
+}
+
+// オーナー自身、または所有する椅子用のAPIキーを発行する。キーはこのレスポンスでしか返さない。
+// APIキーで発行するときは、そのキーが持つスコープと有効期限を超えるキーは作れない
+func (h *Handler) ownerPostAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := ctx.Value("owner").(*Owner)
+	session := ctx.Value("session").(*sessionContext)
+
+	req := &ownerPostAPIKeysRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == "" || len([]rune(req.Name)) > maxAPIKeyNameLen {
+		writeError(w, r, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	if req.ExpiresAt != nil && *req.ExpiresAt <= time.Now().UnixMilli() {
+		writeError(w, r, http.StatusBadRequest, errors.New("expires_at must be in the future"))
+		return
+	}
+
+	subjectType := "owner"
+	subjectID := owner.ID
+	if req.ChairID != nil {
+		chair, err := h.store.Chairs.Get(ctx, *req.ChairID)
+		if err == nil && chair.OwnerID != owner.ID {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, r, http.StatusNotFound, errors.New("chair not found"))
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		subjectType = "chair"
+		subjectID = chair.ID
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = apiKeyScopes[subjectType]
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(apiKeyScopes[subjectType], scope) {
+			writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid scope for %s: %s", subjectType, scope))
+			return
+		}
+		if !session.allows(scope) {
+			writeError(w, r, http.StatusForbidden, fmt.Errorf("scope %s is not granted to this api key", scope))
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := time.UnixMilli(*req.ExpiresAt)
+		expiresAt = &t
+	}
+	// 期限のあるキーから、それより長く使えるキーは作らせない
+	if session.APIKeyID != "" && session.ExpiresAt != 0 {
+		if expiresAt == nil || expiresAt.UnixMilli() > session.ExpiresAt {
+			t := time.UnixMilli(session.ExpiresAt)
+			expiresAt = &t
+		}
+	}
+
+	id := ulid.Make().String()
+	key := formatAPIKey(id, secureRandomStr(32))
+	if err := h.store.APIKeys.Create(ctx, &APIKey{
+		ID:          id,
+		OwnerID:     owner.ID,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		Name:        req.Name,
+		KeyHash:     hashToken(key),
+		Scopes:      strings.Join(scopes, ","),
+		ExpiresAt:   expiresAt,
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, &ownerPostAPIKeysResponse{
+		ID:        id,
+		Key:       key,
+		Scopes:    scopes,
+		ExpiresAt: unixMilliOrNil(expiresAt),
+	})
+}
+
+type ownerGetAPIKeysResponse struct {
+	APIKeys []ownerGetAPIKeysResponseKey `json:"api_keys"`
+}
+
+type ownerGetAPIKeysResponseKey struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	SubjectType string   `json:"subject_type"`
+	SubjectID   string   `json:"subject_id"`
+	Scopes      []string `json:"scopes"`
+	CreatedAt   int64    `json:"created_at"`
+	ExpiresAt   *int64   `json:"expires_at,omitempty"`
+	LastUsedAt  *int64   `json:"last_used_at,omitempty"`
+	RevokedAt   *int64   `json:"revoked_at,omitempty"`
+}
+
+func (h *Handler) ownerGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := ctx.Value("owner").(*Owner)
+
+	apiKeys, err := h.store.APIKeys.ListByOwner(ctx, owner.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := ownerGetAPIKeysResponse{APIKeys: []ownerGetAPIKeysResponseKey{}}
+	for _, k := range apiKeys {
+		res.APIKeys = append(res.APIKeys, ownerGetAPIKeysResponseKey{
+			ID:          k.ID,
+			Name:        k.Name,
+			SubjectType: k.SubjectType,
+			SubjectID:   k.SubjectID,
+			Scopes:      strings.Split(k.Scopes, ","),
+			CreatedAt:   k.CreatedAt.UnixMilli(),
+			ExpiresAt:   unixMilliOrNil(k.ExpiresAt),
+			LastUsedAt:  unixMilliOrNil(k.LastUsedAt),
+			RevokedAt:   unixMilliOrNil(k.RevokedAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) ownerDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := ctx.Value("owner").(*Owner)
+	apiKeyID := r.PathValue("api_key_id")
+
+	apiKey, err := h.store.APIKeys.Get(ctx, apiKeyID)
+	if err == nil && apiKey.OwnerID != owner.ID {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("api key not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.APIKeys.Revoke(ctx, apiKey.ID); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// このサーバーのキャッシュからは消える。他のサーバーについては apiKeyCache を参照
+	apiKeyCache.Del(ctx, apiKey.KeyHash)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIキーの最終利用日時をまとめて書き込む間隔
+const apiKeyUsageFlushInterval = 10 * time.Second
+
+// APIキーの最終利用日時をメモリに溜めて、認証とは別にまとめて書き込む。setup で作る。
+// nil なら記録しない
+var apiKeyUsage *apiKeyUsageRecorder
+
+type apiKeyUsageRecorder struct {
+	store *Store
+
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+func newAPIKeyUsageRecorder(store *Store) *apiKeyUsageRecorder {
+	return &apiKeyUsageRecorder{store: store, lastUsed: map[string]time.Time{}}
+}
+
+func (u *apiKeyUsageRecorder) record(id string, at time.Time) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if prev, ok := u.lastUsed[id]; !ok || at.After(prev) {
+		u.lastUsed[id] = at
+	}
+}
+
+// 溜まっている分を1つのトランザクションで書き込む。失敗したら次に書き込む分に戻す
+func (u *apiKeyUsageRecorder) flush(ctx context.Context) error {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	lastUsed := u.lastUsed
+	u.lastUsed = map[string]time.Time{}
+	u.mu.Unlock()
+
+	if len(lastUsed) == 0 {
+		return nil
+	}
+	err := u.store.InTx(ctx, func(tx Repositories) error {
+		for _, id := range sortedKeys(lastUsed) {
+			if err := tx.APIKeys.SetLastUsed(ctx, id, lastUsed[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for id, at := range lastUsed {
+			u.record(id, at)
+		}
+	}
+	return err
+}
+
+// ctx が切れるまで interval ごとに書き込む。停止時の最後の書き込みは shutdown で行う
+func (u *apiKeyUsageRecorder) start(ctx context.Context, interval time.Duration) {
+	startBackgroundWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := u.flush(ctx); err != nil {
+				slog.Error("failed to flush api key usage", "error", err)
+			}
+		}
+	})
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -25,114 +24,95 @@ type appPostUsersRequest struct {
 type appPostUsersResponse struct {
 	ID             string `json:"id"`
 	InvitationCode string `json:"invitation_code"`
+	// POST /api/app/session でセッションを発行し直すためのトークン。このレスポンスでしか返さない
+	AccessToken string `json:"access_token"`
 }
 
-func appPostUsers(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appPostUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &appPostUsersRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.Username == "" || req.FirstName == "" || req.LastName == "" || req.DateOfBirth == "" {
-		writeError(w, http.StatusBadRequest, errors.New("required fields(username, firstname, lastname, date_of_birth) are empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("required fields(username, firstname, lastname, date_of_birth) are empty"))
 		return
 	}
 
 	userID := ulid.Make().String()
-	accessToken := secureRandomStr(32)
 	invitationCode := secureRandomStr(15)
+	accessToken := secureRandomStr(32)
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
-
-	_, err = tx.ExecContext(
-		ctx,
-		"INSERT INTO users (id, username, firstname, lastname, date_of_birth, access_token, invitation_code) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		userID, req.Username, req.FirstName, req.LastName, req.DateOfBirth, accessToken, invitationCode,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	// 初回登録キャンペーンのクーポンを付与
-	_, err = tx.ExecContext(
-		ctx,
-		"INSERT INTO coupons (user_id, code, discount) VALUES (?, ?, ?)",
-		userID, "CP_NEW2024", 3000,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	// 招待コードを使った登録
-	if req.InvitationCode != nil && *req.InvitationCode != "" {
-		// 招待する側の招待数をチェック
-		var coupons []Coupon
-		err = tx.SelectContext(ctx, &coupons, "SELECT * FROM coupons WHERE code = ? FOR UPDATE", "INV_"+*req.InvitationCode)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-		if len(coupons) >= 3 {
-			writeError(w, http.StatusBadRequest, errors.New("この招待コードは使用できません。"))
-			return
-		}
+	var session issuedSession
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		if err := tx.Users.Create(ctx, &User{
+			ID:             userID,
+			Username:       req.Username,
+			Firstname:      req.FirstName,
+			Lastname:       req.LastName,
+			DateOfBirth:    req.DateOfBirth,
+			AccessToken:    hashedAccessToken(accessToken),
+			InvitationCode: invitationCode,
+		}); err != nil {
+			return err
+		}
+
+		// 初回登録キャンペーンのクーポンを付与
+		if err := tx.Coupons.Create(ctx, &Coupon{UserID: userID, Code: "CP_NEW2024", Discount: 3000}); err != nil {
+			return err
+		}
+
+		// 招待コードを使った登録
+		if req.InvitationCode != nil && *req.InvitationCode != "" {
+			// 招待する側の招待数をチェック
+			invited, err := tx.Coupons.CountByCodeForUpdate(ctx, "INV_"+*req.InvitationCode)
+			if err != nil {
+				return err
+			}
+			if invited >= 3 {
+				return withStatus(http.StatusBadRequest, errors.New("この招待コードは使用できません。"))
+			}
 
-		// ユーザーチェック
-		var inviter User
-		err = tx.GetContext(ctx, &inviter, "SELECT * FROM users WHERE invitation_code = ?", *req.InvitationCode)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusBadRequest, errors.New("この招待コードは使用できません。"))
-				return
+			// ユーザーチェック
+			inviter, err := tx.Users.GetByInvitationCode(ctx, *req.InvitationCode)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return withStatus(http.StatusBadRequest, errors.New("この招待コードは使用できません。"))
+				}
+				return err
 			}
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
 
-		// 招待クーポン付与
-		_, err = tx.ExecContext(
-			ctx,
-			"INSERT INTO coupons (user_id, code, discount) VALUES (?, ?, ?)",
-			userID, "INV_"+*req.InvitationCode, 1500,
-		)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-		// 招待した人にもRewardを付与
-		_, err = tx.ExecContext(
-			ctx,
-			"INSERT INTO coupons (user_id, code, discount) VALUES (?, CONCAT(?, '_', FLOOR(UNIX_TIMESTAMP(NOW(3))*1000)), ?)",
-			inviter.ID, "RWD_"+*req.InvitationCode, 1000,
-		)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			// 招待クーポン付与
+			if err := tx.Coupons.Create(ctx, &Coupon{UserID: userID, Code: "INV_" + *req.InvitationCode, Discount: 1500}); err != nil {
+				return err
+			}
+			// 招待した人にもRewardを付与
+			if err := tx.Coupons.Create(ctx, &Coupon{
+				UserID:   inviter.ID,
+				Code:     fmt.Sprintf("RWD_%s_%d", *req.InvitationCode, time.Now().UnixMilli()),
+				Discount: 1000,
+			}); err != nil {
+				return err
+			}
 		}
-	}
 
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		var err error
+		session, err = createSession(ctx, tx.Sessions, "user", userID, deviceName(r))
+		return err
+	}); err != nil {
+		writeStatusError(w, r, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Path:  "/",
-		Name:  "app_session",
-		Value: accessToken,
-	})
+	// 登録時はまだ ctx に主体がないので、作ったユーザーを直接固定する
+	replicas.pinPrincipal(ctx, "user."+userID)
+	setSessionCookies(w, "user", session)
 
 	writeJSON(w, http.StatusCreated, &appPostUsersResponse{
 		ID:             userID,
 		InvitationCode: invitationCode,
+		AccessToken:    accessToken,
 	})
 }
 
@@ -140,28 +120,22 @@ type appPostPaymentMethodsRequest struct {
 	Token string `json:"token"`
 }
 
-func appPostPaymentMethods(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appPostPaymentMethods(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &appPostPaymentMethodsRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.Token == "" {
-		writeError(w, http.StatusBadRequest, errors.New("token is required but was empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("token is required but was empty"))
 		return
 	}
 
 	user := ctx.Value("user").(*User)
 
-	_, err := db.ExecContext(
-		ctx,
-		`INSERT INTO payment_tokens (user_id, token) VALUES (?, ?)`,
-		user.ID,
-		req.Token,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	if err := h.store.Payments.SaveToken(ctx, user.ID, req.Token); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -190,78 +164,63 @@ type getAppRidesResponseItemChair struct {
 	Model string `json:"model"`
 }
 
-func appGetRides(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appGetRides(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value("user").(*User)
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
-
-	rides := []Ride{}
-	if err := tx.SelectContext(
-		ctx,
-		&rides,
-		`SELECT * FROM rides WHERE user_id = ? ORDER BY created_at DESC`,
-		user.ID,
-	); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
 	items := []getAppRidesResponseItem{}
-	for _, ride := range rides {
-		status, err := getLatestRideStatus(ctx, tx, ride.ID)
+	if err := h.store.InReadOnlyTx(ctx, func(tx Repositories) error {
+		rides, err := tx.Rides.ListByUser(ctx, user.ID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-		if status != "COMPLETED" {
-			continue
+			return err
 		}
 
-		fare, err := calculateDiscountedFare(ctx, tx, user.ID, &ride, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
+		// 新しい順に返す
+		for i := len(rides) - 1; i >= 0; i-- {
+			ride := rides[i]
+			status, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
+			if err != nil {
+				return err
+			}
+			if status != "COMPLETED" {
+				continue
+			}
 
-		item := getAppRidesResponseItem{
-			ID:                    ride.ID,
-			PickupCoordinate:      Coordinate{Latitude: ride.PickupLatitude, Longitude: ride.PickupLongitude},
-			DestinationCoordinate: Coordinate{Latitude: ride.DestinationLatitude, Longitude: ride.DestinationLongitude},
-			Fare:                  fare,
-			Evaluation:            *ride.Evaluation,
-			RequestedAt:           ride.CreatedAt.UnixMilli(),
-			CompletedAt:           ride.UpdatedAt.UnixMilli(),
-		}
+			fare, err := calculateDiscountedFare(ctx, tx, user.ID, &ride, nil, false, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
+			if err != nil {
+				return err
+			}
 
-		item.Chair = getAppRidesResponseItemChair{}
+			item := getAppRidesResponseItem{
+				ID:                    ride.ID,
+				PickupCoordinate:      Coordinate{Latitude: ride.PickupLatitude, Longitude: ride.PickupLongitude},
+				DestinationCoordinate: Coordinate{Latitude: ride.DestinationLatitude, Longitude: ride.DestinationLongitude},
+				Fare:                  fare,
+				Evaluation:            *ride.Evaluation,
+				RequestedAt:           ride.CreatedAt.UnixMilli(),
+				CompletedAt:           ride.UpdatedAt.UnixMilli(),
+			}
 
-		chair := &Chair{}
-		if err := tx.GetContext(ctx, chair, `SELECT * FROM chairs WHERE id = ?`, ride.ChairID); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-		item.Chair.ID = chair.ID
-		item.Chair.Name = chair.Name
-		item.Chair.Model = chair.Model
+			chair, err := tx.Chairs.Get(ctx, ride.ChairID.String)
+			if err != nil {
+				return err
+			}
+			owner, err := tx.Owners.Get(ctx, chair.OwnerID)
+			if err != nil {
+				return err
+			}
+			item.Chair = getAppRidesResponseItemChair{
+				ID:    chair.ID,
+				Owner: owner.Name,
+				Name:  chair.Name,
+				Model: chair.Model,
+			}
 
-		owner := &Owner{}
-		if err := tx.GetContext(ctx, owner, `SELECT * FROM owners WHERE id = ?`, chair.OwnerID); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			items = append(items, item)
 		}
-		item.Chair.Owner = owner.Name
-
-		items = append(items, item)
-	}
-
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		return nil
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -271,8 +230,10 @@ func appGetRides(w http.ResponseWriter, r *http.Request) {
 }
 
 type appPostRidesRequest struct {
-	PickupCoordinate      *Coordinate `json:"pickup_coordinate"`
-	DestinationCoordinate *Coordinate `json:"destination_coordinate"`
+	PickupCoordinate      *Coordinate  `json:"pickup_coordinate"`
+	Waypoints             []Coordinate `json:"waypoints"`
+	DestinationCoordinate *Coordinate  `json:"destination_coordinate"`
+	Pooled                bool         `json:"pooled"`
 }
 
 type appPostRidesResponse struct {
@@ -280,178 +241,159 @@ type appPostRidesResponse struct {
 	Fare   int    `json:"fare"`
 }
 
-type executableGet interface {
-	Get(dest interface{}, query string, args ...interface{}) error
-	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
-}
+// ステータスを書き込んだ処理は、コミットしたあとにこのキャッシュも書き換える
+var rideStatusCache = newTypedCache[string]("latest.ride.")
+
+const rideStatusCacheTTL = 10 * time.Second
 
-func getLatestRideStatus(ctx context.Context, tx executableGet, rideID string) (string, error) {
-	status := ""
-	if item, err := cache.Get([]byte("latest.ride." + rideID)); err == nil {
-		status = string(item)
+func getLatestRideStatus(ctx context.Context, rides RideRepository, rideID string) (string, error) {
+	if status, ok := rideStatusCache.Get(ctx, rideID); ok {
 		return status, nil
 	}
-	if err := tx.GetContext(ctx, &status, `SELECT status FROM ride_statuses WHERE ride_id = ? ORDER BY created_at DESC LIMIT 1`, rideID); err != nil {
-		return "", err
-	}
-	if err := cache.Set([]byte("latest.ride."+rideID), []byte(status), 10); err != nil {
+	status, err := rides.LatestStatus(ctx, rideID)
+	if err != nil {
 		return "", err
 	}
+	rideStatusCache.Set(ctx, rideID, status, rideStatusCacheTTL)
 	return status, nil
 }
 
-func appPostRides(w http.ResponseWriter, r *http.Request) {
+var errRideAlreadyExists = errors.New("ride already exists")
+
+func (h *Handler) appPostRides(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &appPostRidesRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.PickupCoordinate == nil || req.DestinationCoordinate == nil {
-		writeError(w, http.StatusBadRequest, errors.New("required fields(pickup_coordinate, destination_coordinate) are empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("required fields(pickup_coordinate, destination_coordinate) are empty"))
+		return
+	}
+	if len(req.Waypoints) > maxWaypoints {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("too many waypoints (max %d)", maxWaypoints))
+		return
+	}
+	if req.Pooled && len(req.Waypoints) > 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("waypoints cannot be used with pooled rides"))
 		return
 	}
 
 	user := ctx.Value("user").(*User)
 	rideID := ulid.Make().String()
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	fare := 0
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		continuing, err := hasContinuingRide(ctx, tx.Rides, user.ID)
+		if err != nil {
+			return err
+		}
+		if continuing {
+			return withStatus(http.StatusConflict, errRideAlreadyExists)
+		}
+
+		if err := insertRide(ctx, tx, rideID, user.ID, *req.PickupCoordinate, req.Waypoints, *req.DestinationCoordinate, req.Pooled); err != nil {
+			return err
+		}
+
+		ride, err := tx.Rides.Get(ctx, rideID)
+		if err != nil {
+			return err
+		}
+
+		fare, err = calculateDiscountedFare(ctx, tx, user.ID, ride, nil, false, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude)
+		return err
+	}); err != nil {
+		writeStatusError(w, r, err)
 		return
 	}
-	defer tx.Rollback()
 
-	rides := []Ride{}
-	if err := tx.SelectContext(ctx, &rides, `SELECT * FROM rides WHERE user_id = ?`, user.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	rideStatusCache.Set(ctx, rideID, "MATCHING", rideStatusCacheTTL)
+
+	writeJSON(w, http.StatusAccepted, &appPostRidesResponse{
+		RideID: rideID,
+		Fare:   fare,
+	})
+}
+
+// 完了していないライドがあるかどうか
+func hasContinuingRide(ctx context.Context, rides RideRepository, userID string) (bool, error) {
+	userRides, err := rides.ListByUser(ctx, userID)
+	if err != nil {
+		return false, err
 	}
 
-	continuingRideCount := 0
-	for _, ride := range rides {
-		status, err := getLatestRideStatus(ctx, tx, ride.ID)
+	for _, ride := range userRides {
+		status, err := getLatestRideStatus(ctx, rides, ride.ID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			return false, err
 		}
 		if status != "COMPLETED" {
-			continuingRideCount++
-		}
-	}
-
-	if continuingRideCount > 0 {
-		writeError(w, http.StatusConflict, errors.New("ride already exists"))
-		return
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ライドを作成してクーポンを紐づける。運賃はこの時点の設定で決まる
+func insertRide(ctx context.Context, tx Repositories, rideID string, userID string, pickup Coordinate, waypoints []Coordinate, destination Coordinate, pooled bool) error {
+	rates := currentFareRates()
+	if err := tx.Rides.Create(ctx, &Ride{
+		ID:                   rideID,
+		UserID:               userID,
+		PickupLatitude:       pickup.Latitude,
+		PickupLongitude:      pickup.Longitude,
+		DestinationLatitude:  destination.Latitude,
+		DestinationLongitude: destination.Longitude,
+		WaypointCount:        len(waypoints),
+		Pooled:               pooled,
+		InitialFare:          rates.InitialFare,
+		FarePerDistance:      rates.FarePerDistance,
+	}); err != nil {
+		return err
 	}
 
-	if _, err := tx.ExecContext(
-		ctx,
-		`INSERT INTO rides (id, user_id, pickup_latitude, pickup_longitude, destination_latitude, destination_longitude)
-				  VALUES (?, ?, ?, ?, ?, ?)`,
-		rideID, user.ID, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude,
-	); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	if err := tx.Rides.AddWaypoints(ctx, rideID, waypoints); err != nil {
+		return err
 	}
 
-	if _, err := tx.ExecContext(
-		ctx,
-		`INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)`,
-		ulid.Make().String(), rideID, "MATCHING",
-	); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	if err := tx.Rides.AddStatus(ctx, rideID, "MATCHING"); err != nil {
+		return err
 	}
 
-	var rideCount int
-	if err := tx.GetContext(ctx, &rideCount, `SELECT COUNT(*) FROM rides WHERE user_id = ? `, user.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	rideCount, err := tx.Rides.CountByUser(ctx, userID)
+	if err != nil {
+		return err
 	}
 
-	var coupon Coupon
+	var coupon *Coupon
 	if rideCount == 1 {
 		// 初回利用で、初回利用クーポンがあれば必ず使う
-		if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND code = 'CP_NEW2024' AND used_by IS NULL FOR UPDATE", user.ID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
-
+		coupon, err = tx.Coupons.GetUnusedForUpdate(ctx, userID, "CP_NEW2024")
+		if errors.Is(err, sql.ErrNoRows) {
 			// 無ければ他のクーポンを付与された順番に使う
-			if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1 FOR UPDATE", user.ID); err != nil {
-				if !errors.Is(err, sql.ErrNoRows) {
-					writeError(w, http.StatusInternalServerError, err)
-					return
-				}
-			} else {
-				if _, err := tx.ExecContext(
-					ctx,
-					"UPDATE coupons SET used_by = ? WHERE user_id = ? AND code = ?",
-					rideID, user.ID, coupon.Code,
-				); err != nil {
-					writeError(w, http.StatusInternalServerError, err)
-					return
-				}
-			}
-		} else {
-			if _, err := tx.ExecContext(
-				ctx,
-				"UPDATE coupons SET used_by = ? WHERE user_id = ? AND code = 'CP_NEW2024'",
-				rideID, user.ID,
-			); err != nil {
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
+			coupon, err = tx.Coupons.OldestUnusedForUpdate(ctx, userID)
 		}
 	} else {
 		// 他のクーポンを付与された順番に使う
-		if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1 FOR UPDATE", user.ID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
-		} else {
-			if _, err := tx.ExecContext(
-				ctx,
-				"UPDATE coupons SET used_by = ? WHERE user_id = ? AND code = ?",
-				rideID, user.ID, coupon.Code,
-			); err != nil {
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
-		}
-	}
-
-	ride := Ride{}
-	if err := tx.GetContext(ctx, &ride, "SELECT * FROM rides WHERE id = ?", rideID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+		coupon, err = tx.Coupons.OldestUnusedForUpdate(ctx, userID)
 	}
-
-	fare, err := calculateDiscountedFare(ctx, tx, user.ID, &ride, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
 	}
 
-	cache.Set([]byte("latest.ride."+rideID), []byte("MATCHING"), 10)
-
-	writeJSON(w, http.StatusAccepted, &appPostRidesResponse{
-		RideID: rideID,
-		Fare:   fare,
-	})
+	return tx.Coupons.Use(ctx, userID, coupon.Code, rideID)
 }
 
 type appPostRidesEstimatedFareRequest struct {
-	PickupCoordinate      *Coordinate `json:"pickup_coordinate"`
-	DestinationCoordinate *Coordinate `json:"destination_coordinate"`
+	PickupCoordinate      *Coordinate  `json:"pickup_coordinate"`
+	Waypoints             []Coordinate `json:"waypoints"`
+	DestinationCoordinate *Coordinate  `json:"destination_coordinate"`
+	Pooled                bool         `json:"pooled"`
 }
 
 type appPostRidesEstimatedFareResponse struct {
@@ -459,41 +401,37 @@ type appPostRidesEstimatedFareResponse struct {
 	Discount int `json:"discount"`
 }
 
-func appPostRidesEstimatedFare(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appPostRidesEstimatedFare(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &appPostRidesEstimatedFareRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.PickupCoordinate == nil || req.DestinationCoordinate == nil {
-		writeError(w, http.StatusBadRequest, errors.New("required fields(pickup_coordinate, destination_coordinate) are empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("required fields(pickup_coordinate, destination_coordinate) are empty"))
 		return
 	}
-
-	user := ctx.Value("user").(*User)
-
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	if len(req.Waypoints) > maxWaypoints {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("too many waypoints (max %d)", maxWaypoints))
 		return
 	}
-	defer tx.Rollback()
-
-	discounted, err := calculateDiscountedFare(ctx, tx, user.ID, nil, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	if req.Pooled && len(req.Waypoints) > 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("waypoints cannot be used with pooled rides"))
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	user := ctx.Value("user").(*User)
+
+	discounted, err := calculateDiscountedFare(ctx, h.store.Repositories, user.ID, nil, req.Waypoints, req.Pooled, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, &appPostRidesEstimatedFareResponse{
 		Fare:     discounted,
-		Discount: calculateFare(req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude) - discounted,
+		Discount: calculateRouteFare(*req.PickupCoordinate, req.Waypoints, *req.DestinationCoordinate) - discounted,
 	})
 }
 
@@ -510,134 +448,134 @@ func abs(a int) int {
 
 type appPostRideEvaluationRequest struct {
 	Evaluation int `json:"evaluation"`
+	reviewInput
 }
 
 type appPostRideEvaluationResponse struct {
 	CompletedAt int64 `json:"completed_at"`
 }
 
-func appPostRideEvaluatation(w http.ResponseWriter, r *http.Request) {
+func ridePaymentLockName(rideID string) string {
+	return "isuride_ride_payment_" + rideID
+}
+
+// 到着していて、まだ評価されていないライドだけ評価できる
+func requireRideArrived(ctx context.Context, rides RideRepository, rideID string) error {
+	status, err := rides.LatestStatus(ctx, rideID)
+	if err != nil {
+		return err
+	}
+	if status != "ARRIVED" {
+		return withStatus(http.StatusBadRequest, errors.New("not arrived yet"))
+	}
+	return nil
+}
+
+func (h *Handler) appPostRideEvaluatation(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	rideID := r.PathValue("ride_id")
 
 	req := &appPostRideEvaluationRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.Evaluation < 1 || req.Evaluation > 5 {
-		writeError(w, http.StatusBadRequest, errors.New("evaluation must be between 1 and 5"))
-		return
-	}
-
-	// tx, err := db.Beginx()
-	// if err != nil {
-	// 	writeError(w, http.StatusInternalServerError, err)
-	// 	return
-	// }
-	// defer tx.Rollback()
-
-	ride := &Ride{}
-	if err := db.GetContext(ctx, ride, `SELECT * FROM rides WHERE id = ?`, rideID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusNotFound, errors.New("ride not found"))
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	status, err := getLatestRideStatus(ctx, db, ride.ID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		writeError(w, r, http.StatusBadRequest, errors.New("evaluation must be between 1 and 5"))
 		return
 	}
-
-	if status != "ARRIVED" {
-		writeError(w, http.StatusBadRequest, errors.New("not arrived yet"))
+	if err := req.reviewInput.validate(); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	result, err := db.ExecContext(
-		ctx,
-		`UPDATE rides SET evaluation = ? WHERE id = ?`,
-		req.Evaluation, rideID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	if count, err := result.RowsAffected(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	} else if count == 0 {
-		writeError(w, http.StatusNotFound, errors.New("ride not found"))
-		return
-	}
+	// 同じライドへの評価が同時に来ても二重に決済しないよう、ライドごとのロックを決済から書き込みまで持つ。
+	// 決済サービスはリトライで時間がかかることがあるので、その間はトランザクションもライドの行のロックも持たない
+	var ride *Ride
+	err := h.store.WithLock(ctx, ridePaymentLockName(rideID), func() error {
+		var paymentToken *PaymentToken
+		var fare int
+		if err := h.store.InTx(ctx, func(tx Repositories) error {
+			var err error
+			ride, err = tx.Rides.GetForUpdate(ctx, rideID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return withStatus(http.StatusNotFound, errors.New("ride not found"))
+				}
+				return err
+			}
+			// キャッシュは別のリクエストが書き込む前の値のことがあるので、ロックしてからDBで確かめる
+			if err := requireRideArrived(ctx, tx.Rides, ride.ID); err != nil {
+				return err
+			}
 
-	_, err = db.ExecContext(
-		ctx,
-		`INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)`,
-		ulid.Make().String(), rideID, "COMPLETED")
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	status2 := "COMPLETED"
+			paymentToken, err = tx.Payments.GetToken(ctx, ride.UserID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return withStatus(http.StatusBadRequest, errors.New("payment token not registered"))
+				}
+				return err
+			}
 
-	if err := db.GetContext(ctx, ride, `SELECT * FROM rides WHERE id = ?`, rideID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusNotFound, errors.New("ride not found"))
-			return
+			fare, err = calculateDiscountedFare(ctx, tx, ride.UserID, ride, nil, false, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
+			return err
+		}); err != nil {
+			return err
 		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
 
-	paymentToken := &PaymentToken{}
-	if err := db.GetContext(ctx, paymentToken, `SELECT * FROM payment_tokens WHERE user_id = ?`, ride.UserID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusBadRequest, errors.New("payment token not registered"))
-			return
+		if err := requestPaymentGatewayPostPayment(ctx, currentSettings().PaymentGatewayURL, paymentToken.Token, &paymentGatewayPostPaymentRequest{
+			Amount: fare,
+		}, func() ([]Ride, error) {
+			return h.store.Rides.ListByUser(ctx, ride.UserID)
+		}); err != nil {
+			if errors.Is(err, erroredUpstream) {
+				return withStatus(http.StatusBadGateway, err)
+			}
+			return err
 		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
 
-	fare, err := calculateDiscountedFareButNoTx(ctx, db, ride.UserID, ride, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	paymentGatewayRequest := &paymentGatewayPostPaymentRequest{
-		Amount: fare,
-	}
+		// 決済に成功したら、評価・レビュー・評判の集計・完了ステータスをまとめて書き込む。
+		// 決済の間に管理APIなどでステータスが変わっていたら書き込まない
+		return h.store.InTx(ctx, func(tx Repositories) error {
+			if _, err := tx.Rides.GetForUpdate(ctx, rideID); err != nil {
+				return err
+			}
+			if err := requireRideArrived(ctx, tx.Rides, rideID); err != nil {
+				return err
+			}
 
-	var paymentGatewayURL string
-	if err := db.GetContext(ctx, &paymentGatewayURL, "SELECT value FROM settings WHERE name = 'payment_gateway_url'"); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
+			if err := tx.Rides.SetEvaluation(ctx, rideID, req.Evaluation); err != nil {
+				return err
+			}
+			if err := tx.Rides.AddStatus(ctx, rideID, "COMPLETED"); err != nil {
+				return err
+			}
 
-	if err := requestPaymentGatewayPostPayment(ctx, paymentGatewayURL, paymentToken.Token, paymentGatewayRequest, func() ([]Ride, error) {
-		rides := []Ride{}
-		if err := db.SelectContext(ctx, &rides, `SELECT * FROM rides WHERE user_id = ? ORDER BY created_at ASC`, ride.UserID); err != nil {
-			return nil, err
-		}
-		return rides, nil
-	}); err != nil {
-		if errors.Is(err, erroredUpstream) {
-			writeError(w, http.StatusBadGateway, err)
-			return
+			chair, err := tx.Chairs.Get(ctx, ride.ChairID.String)
+			if err != nil {
+				return err
+			}
+			if err := recordReview(ctx, tx.Reviews, rideID, "user", req.Evaluation, req.reviewInput, map[string]string{
+				"chair": chair.ID,
+				"owner": chair.OwnerID,
+			}); err != nil {
+				return err
+			}
+
+			// 完了日時として評価を書き込んだ後の updated_at を返す
+			ride, err = tx.Rides.Get(ctx, rideID)
+			return err
+		})
+	})
+	if err != nil {
+		if errors.Is(err, errStoreLockTimeout) {
+			err = withStatus(http.StatusConflict, errors.New("ride is being evaluated"))
 		}
-		writeError(w, http.StatusInternalServerError, err)
+		writeStatusError(w, r, err)
 		return
 	}
 
-	// if err := tx.Commit(); err != nil {
-	// 	writeError(w, http.StatusInternalServerError, err)
-	// 	return
-	// }
-
-	cache.Set([]byte("latest.ride."+rideID), []byte(status2), 10)
+	rideStatusCache.Set(ctx, rideID, "COMPLETED", rideStatusCacheTTL)
 
 	writeJSON(w, http.StatusOK, &appPostRideEvaluationResponse{
 		CompletedAt: ride.UpdatedAt.UnixMilli(),
@@ -653,6 +591,8 @@ type appGetNotificationResponseData struct {
 	RideID                string                           `json:"ride_id"`
 	PickupCoordinate      Coordinate                       `json:"pickup_coordinate"`
 	DestinationCoordinate Coordinate                       `json:"destination_coordinate"`
+	Waypoints             []Coordinate                     `json:"waypoints,omitempty"`
+	Pooled                bool                             `json:"pooled,omitempty"`
 	Fare                  int                              `json:"fare"`
 	Status                string                           `json:"status"`
 	Chair                 *appGetNotificationResponseChair `json:"chair,omitempty"`
@@ -684,178 +624,116 @@ func getRetryAfterMs() int {
 	return retryAfterMs
 }
 
-func appGetNotification(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appGetNotification(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := ctx.Value("user").(*User)
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
-
-	// 最新のライド情報を取得
-	ride := &Ride{}
-	if err := tx.GetContext(ctx, ride, `
-		SELECT id, pickup_latitude, pickup_longitude, destination_latitude, destination_longitude, created_at, updated_at, chair_id
-		FROM rides 
-		WHERE user_id = ? 
-		ORDER BY created_at DESC 
-		LIMIT 1`, user.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSON(w, http.StatusOK, &appGetNotificationResponse{
-				RetryAfterMs: getRetryAfterMs(),
-			})
-			return
+	var response *appGetNotificationResponse
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		// 最新のライド情報を取得
+		ride, err := tx.Rides.LatestByUser(ctx, user.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				response = &appGetNotificationResponse{
+					RetryAfterMs: getRetryAfterMs(),
+				}
+				return nil
+			}
+			return err
 		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
 
-	// 送信されていないステータス、または最新のステータスを取得
-	var yetSentRideStatus RideStatus
-	var status string
-	if err := tx.GetContext(ctx, &yetSentRideStatus, `
-		SELECT id, status 
-		FROM ride_statuses 
-		WHERE ride_id = ? AND app_sent_at IS NULL 
-		ORDER BY created_at ASC 
-		LIMIT 1`, ride.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		// 送信されていないステータス、または最新のステータスを取得
+		var status string
+		yetSentRideStatus, err := tx.Rides.NextUnsentAppStatus(ctx, ride.ID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
 			// 未送信のステータスがない場合、最新ステータスを取得
-			if err := tx.GetContext(ctx, &status, `
-				SELECT status 
-				FROM ride_statuses 
-				WHERE ride_id = ? 
-				ORDER BY created_at DESC 
-				LIMIT 1`, ride.ID); err != nil {
-				writeError(w, http.StatusInternalServerError, err)
-				return
+			yetSentRideStatus = nil
+			status, err = tx.Rides.LatestStatus(ctx, ride.ID)
+			if err != nil {
+				return err
 			}
 		} else {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			status = yetSentRideStatus.Status
 		}
-	} else {
-		status = yetSentRideStatus.Status
-	}
-
-	// 運賃計算（必要な場合のみ実行）
-	fare, err := calculateDiscountedFare(ctx, tx, user.ID, ride, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	response := &appGetNotificationResponse{
-		Data: &appGetNotificationResponseData{
-			RideID: ride.ID,
-			PickupCoordinate: Coordinate{
-				Latitude:  ride.PickupLatitude,
-				Longitude: ride.PickupLongitude,
-			},
-			DestinationCoordinate: Coordinate{
-				Latitude:  ride.DestinationLatitude,
-				Longitude: ride.DestinationLongitude,
-			},
-			Fare:      fare,
-			Status:    status,
-			CreatedAt: ride.CreatedAt.UnixMilli(),
-			UpdateAt:  ride.UpdatedAt.UnixMilli(),
-		},
-		RetryAfterMs: getRetryAfterMs(),
-	}
 
-	// チェア情報の取得（必要な場合のみ）
-	if ride.ChairID.Valid {
-		chair := &Chair{}
-		if err := tx.GetContext(ctx, chair, `
-			SELECT id, name, model 
-			FROM chairs 
-			WHERE id = ?`, ride.ChairID); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+		fare, err := calculateDiscountedFare(ctx, tx, user.ID, ride, nil, false, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
+		if err != nil {
+			return err
 		}
 
-		stats, err := getChairStats(ctx, tx, chair.ID)
+		waypoints, err := loadWaypointCoordinates(ctx, tx.Rides, ride)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			return err
 		}
 
-		response.Data.Chair = &appGetNotificationResponseChair{
-			ID:    chair.ID,
-			Name:  chair.Name,
-			Model: chair.Model,
-			Stats: stats,
+		response = &appGetNotificationResponse{
+			Data: &appGetNotificationResponseData{
+				RideID: ride.ID,
+				PickupCoordinate: Coordinate{
+					Latitude:  ride.PickupLatitude,
+					Longitude: ride.PickupLongitude,
+				},
+				DestinationCoordinate: Coordinate{
+					Latitude:  ride.DestinationLatitude,
+					Longitude: ride.DestinationLongitude,
+				},
+				Waypoints: waypoints,
+				Pooled:    ride.Pooled,
+				Fare:      fare,
+				Status:    status,
+				CreatedAt: ride.CreatedAt.UnixMilli(),
+				UpdateAt:  ride.UpdatedAt.UnixMilli(),
+			},
+			RetryAfterMs: getRetryAfterMs(),
 		}
-	}
 
-	// 未送信ステータスが存在する場合、app_sent_atを更新
-	if yetSentRideStatus.ID != "" {
-		if _, err := tx.ExecContext(ctx, `
-			UPDATE ride_statuses 
-			SET app_sent_at = CURRENT_TIMESTAMP(6) 
-			WHERE id = ?`, yetSentRideStatus.ID); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+		// チェア情報の取得（必要な場合のみ）
+		if ride.ChairID.Valid {
+			chair, err := tx.Chairs.Get(ctx, ride.ChairID.String)
+			if err != nil {
+				return err
+			}
+
+			stats, err := getChairStats(ctx, tx.Reviews, chair.ID)
+			if err != nil {
+				return err
+			}
+
+			response.Data.Chair = &appGetNotificationResponseChair{
+				ID:    chair.ID,
+				Name:  chair.Name,
+				Model: chair.Model,
+				Stats: stats,
+			}
 		}
-	}
 
-	// トランザクションをコミット
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		// 未送信ステータスが存在する場合、app_sent_atを更新
+		if yetSentRideStatus != nil {
+			return tx.Rides.MarkAppSent(ctx, yetSentRideStatus.ID)
+		}
+		return nil
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	// レスポンスを返却
 	writeJSON(w, http.StatusOK, response)
 }
 
-func getChairStats(ctx context.Context, tx *sqlx.Tx, chairID string) (appGetNotificationResponseChairStats, error) {
+func getChairStats(ctx context.Context, reviews ReviewRepository, chairID string) (appGetNotificationResponseChairStats, error) {
 	stats := appGetNotificationResponseChairStats{}
 
-	// クエリを1回で完結させる
-	query := `
-		SELECT 
-			COUNT(*) AS total_ride_count,
-			COALESCE(SUM(evaluation), 0) AS total_evaluation
-		FROM rides r
-		WHERE r.chair_id = ?
-		AND EXISTS (
-			SELECT 1
-			FROM ride_statuses rs
-			WHERE rs.ride_id = r.id
-			AND rs.status = 'COMPLETED'
-		)
-		AND EXISTS (
-			SELECT 1
-			FROM ride_statuses rs
-			WHERE rs.ride_id = r.id
-			AND rs.status = 'ARRIVED'
-		)
-		AND EXISTS (
-			SELECT 1
-			FROM ride_statuses rs
-			WHERE rs.ride_id = r.id
-			AND rs.status = 'CARRYING'
-		)
-	`
-
-	var totalRideCount int
-	var totalEvaluation float64
-
-	err := tx.QueryRowContext(ctx, query, chairID).Scan(&totalRideCount, &totalEvaluation)
+	// 評価のたびに加算している集計を参照する
+	reputation, err := reviews.GetReputation(ctx, "chair", chairID)
 	if err != nil {
 		return stats, err
 	}
 
-	// 結果を構造体にセット
-	stats.TotalRidesCount = totalRideCount
-	if totalRideCount > 0 {
-		stats.TotalEvaluationAvg = totalEvaluation / float64(totalRideCount)
-	}
+	stats.TotalRidesCount = reputation.RatingCount
+	stats.TotalEvaluationAvg = reputation.average()
 
 	return stats, nil
 }
@@ -872,61 +750,46 @@ type appGetNearbyChairsResponseChair struct {
 	CurrentCoordinate Coordinate `json:"current_coordinate"`
 }
 
-func fetchChairLocationFromCache(chairId string, tx *sqlx.Tx, ctx context.Context) (ChairLocation, error) {
-	var res ChairLocation
+// 椅子ID → 最後に記録した位置
+var chairLocationCache = newTypedCache[ChairLocation]("chair_location.")
 
-	if item, err := cache.Get([]byte(fmt.Sprintf("key.%s", chairId))); err == nil {
-		// item.Valueに[]byte型の値があるので、string(item.Value)としても良いし、
-		// 以下のように構造体をjson.Marshalで詰めて、json.Unmarshalで取り出しても良い
-		if err := json.Unmarshal(item, &res); err != nil {
-			return res, err
-		}
+func fetchChairLocationFromCache(ctx context.Context, tx *sqlx.Tx, chairID string) (ChairLocation, error) {
+	if res, ok := chairLocationCache.Get(ctx, chairID); ok {
 		return res, nil
-	} else {
-		// キャッシュヒットしなかった場合、DBなどにアクセスして値を得る。
-		// その後以下のように[]byteに詰めて、memcacheClient.Setを呼び出す
-		// 試した範囲では、structにjsonのアノテーションなどを書く必要はなさそう
-		err = tx.GetContext(
-			ctx,
-			&res,
-			`SELECT * FROM chair_locations WHERE chair_id = ? ORDER BY created_at DESC LIMIT 1`,
-			chairId,
-		)
-
-		if err != nil {
-			return res, err
-		}
-
-		byteData, _ := json.Marshal(res) // err処理割愛
-		cache.Set(
-			[]byte(fmt.Sprintf("key.%s", chairId)),
-			byteData,
-			1, // [sec] キャッシュの有効時間, 秒で指定
-		)
+	}
 
-		return res, nil
+	var res ChairLocation
+	if err := tx.GetContext(
+		ctx,
+		&res,
+		`SELECT * FROM chair_locations WHERE chair_id = ? ORDER BY created_at DESC LIMIT 1`,
+		chairID,
+	); err != nil {
+		return res, err
 	}
+	chairLocationCache.Set(ctx, chairID, res, time.Second)
+	return res, nil
 }
 
-func appGetNearbyChairs(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) appGetNearbyChairs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	latStr := r.URL.Query().Get("latitude")
 	lonStr := r.URL.Query().Get("longitude")
 	distanceStr := r.URL.Query().Get("distance")
 	if latStr == "" || lonStr == "" {
-		writeError(w, http.StatusBadRequest, errors.New("latitude or longitude is empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("latitude or longitude is empty"))
 		return
 	}
 
 	lat, err := strconv.Atoi(latStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, errors.New("latitude is invalid"))
+		writeError(w, r, http.StatusBadRequest, errors.New("latitude is invalid"))
 		return
 	}
 
 	lon, err := strconv.Atoi(lonStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, errors.New("longitude is invalid"))
+		writeError(w, r, http.StatusBadRequest, errors.New("longitude is invalid"))
 		return
 	}
 
@@ -934,103 +797,66 @@ func appGetNearbyChairs(w http.ResponseWriter, r *http.Request) {
 	if distanceStr != "" {
 		distance, err = strconv.Atoi(distanceStr)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, errors.New("distance is invalid"))
+			writeError(w, r, http.StatusBadRequest, errors.New("distance is invalid"))
 			return
 		}
 	}
 
 	coordinate := Coordinate{Latitude: lat, Longitude: lon}
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
-
-	chairs := []Chair{}
-	err = tx.SelectContext(
-		ctx,
-		&chairs,
-		`SELECT * FROM chairs WHERE is_active = 1 AND latitude <= ? AND latitude >= ? AND longitude <= ? AND longitude >= ?`,
-		lat+distance, lat-distance, lon+distance, lon-distance,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
 	nearbyChairs := []appGetNearbyChairsResponseChair{}
-	for _, chair := range chairs {
-		rides := []*Ride{}
-		if err := tx.SelectContext(ctx, &rides, `SELECT * FROM rides WHERE chair_id = ? ORDER BY created_at DESC`, chair.ID); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+	retrievedAt := time.Now()
+	if err := h.store.InReadOnlyTx(ctx, func(tx Repositories) error {
+		chairs, err := tx.Chairs.ListAvailableInArea(
+			ctx,
+			Coordinate{Latitude: lat - distance, Longitude: lon - distance},
+			Coordinate{Latitude: lat + distance, Longitude: lon + distance},
+		)
+		if err != nil {
+			return err
 		}
 
-		skip := false
-		for _, ride := range rides {
-			// 過去にライドが存在し、かつ、それが完了していない場合はスキップ
-			status, err := getLatestRideStatus(ctx, tx, ride.ID)
+		for _, chair := range chairs {
+			rides, err := tx.Rides.ListByChair(ctx, chair.ID)
 			if err != nil {
-				writeError(w, http.StatusInternalServerError, err)
-				return
+				return err
 			}
-			if status != "COMPLETED" {
-				skip = true
-				break
+
+			skip := false
+			for _, ride := range rides {
+				// 過去にライドが存在し、かつ、それが完了していない場合はスキップ
+				status, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
+				if err != nil {
+					return err
+				}
+				if status != "COMPLETED" {
+					skip = true
+					break
+				}
+			}
+			if skip {
+				continue
 			}
-		}
-		if skip {
-			continue
-		}
 
-		// 最新の位置情報を取得
-		// chairLocation := &ChairLocation{}
-		// err = tx.GetContext(
-		// 	ctx,
-		// 	chairLocation,
-		// 	`SELECT * FROM chair_locations WHERE chair_id = ? ORDER BY created_at DESC LIMIT 1`,
-		// 	chair.ID,
-		// )
-		// if err != nil {
-		// 	if errors.Is(err, sql.ErrNoRows) {
-		// 		continue
-		// 	}
-		// 	writeError(w, http.StatusInternalServerError, err)
-		// 	return
-		// }
-
-		if !chair.Latitude.Valid || !chair.Longitude.Valid {
-			continue
-		}
+			if !chair.Latitude.Valid || !chair.Longitude.Valid {
+				continue
+			}
 
-		if calculateDistance(coordinate.Latitude, coordinate.Longitude, int(chair.Latitude.Int64), int(chair.Longitude.Int64)) <= distance {
-			nearbyChairs = append(nearbyChairs, appGetNearbyChairsResponseChair{
-				ID:    chair.ID,
-				Name:  chair.Name,
-				Model: chair.Model,
-				CurrentCoordinate: Coordinate{
-					Latitude:  int(chair.Latitude.Int64),
-					Longitude: int(chair.Longitude.Int64),
-				},
-			})
+			if calculateDistance(coordinate.Latitude, coordinate.Longitude, int(chair.Latitude.Int64), int(chair.Longitude.Int64)) <= distance {
+				nearbyChairs = append(nearbyChairs, appGetNearbyChairsResponseChair{
+					ID:    chair.ID,
+					Name:  chair.Name,
+					Model: chair.Model,
+					CurrentCoordinate: Coordinate{
+						Latitude:  int(chair.Latitude.Int64),
+						Longitude: int(chair.Longitude.Int64),
+					},
+				})
+			}
 		}
-	}
-
-	retrievedAt := &time.Time{}
-	err = tx.GetContext(
-		ctx,
-		retrievedAt,
-		`SELECT CURRENT_TIMESTAMP(6)`,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		return nil
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1041,92 +867,52 @@ func appGetNearbyChairs(w http.ResponseWriter, r *http.Request) {
 }
 
 func calculateFare(pickupLatitude, pickupLongitude, destLatitude, destLongitude int) int {
-	meteredFare := farePerDistance * calculateDistance(pickupLatitude, pickupLongitude, destLatitude, destLongitude)
-	return initialFare + meteredFare
+	fares := currentSettings()
+	meteredFare := fares.FarePerDistance * calculateDistance(pickupLatitude, pickupLongitude, destLatitude, destLongitude)
+	return fares.InitialFare + meteredFare
 }
 
-func calculateDiscountedFare(ctx context.Context, tx *sqlx.Tx, userID string, ride *Ride, pickupLatitude, pickupLongitude, destLatitude, destLongitude int) (int, error) {
-	var coupon Coupon
-	discount := 0
+func calculateDiscountedFare(ctx context.Context, repo Repositories, userID string, ride *Ride, waypoints []Coordinate, pooled bool, pickupLatitude, pickupLongitude, destLatitude, destLongitude int) (int, error) {
+	var coupon *Coupon
+	var err error
+	rates := currentFareRates()
 	if ride != nil {
+		rates = ride.fareRates()
 		destLatitude = ride.DestinationLatitude
 		destLongitude = ride.DestinationLongitude
 		pickupLatitude = ride.PickupLatitude
 		pickupLongitude = ride.PickupLongitude
+		// 作成時には相乗りできるか分からないので、実際に相乗りしてから安くなる
+		pooled = ride.PoolShared
+		waypoints, err = loadWaypointCoordinates(ctx, repo.Rides, ride)
+		if err != nil {
+			return 0, err
+		}
 
 		// すでにクーポンが紐づいているならそれの割引額を参照
-		if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE used_by = ?", ride.ID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				return 0, err
-			}
-		} else {
-			discount = coupon.Discount
-		}
+		coupon, err = repo.Coupons.GetByRide(ctx, ride.ID)
 	} else {
 		// 初回利用クーポンを最優先で使う
-		if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND code = 'CP_NEW2024' AND used_by IS NULL", userID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				return 0, err
-			}
-
+		coupon, err = repo.Coupons.GetUnused(ctx, userID, "CP_NEW2024")
+		if errors.Is(err, sql.ErrNoRows) {
 			// 無いなら他のクーポンを付与された順番に使う
-			if err := tx.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1", userID); err != nil {
-				if !errors.Is(err, sql.ErrNoRows) {
-					return 0, err
-				}
-			} else {
-				discount = coupon.Discount
-			}
-		} else {
-			discount = coupon.Discount
+			coupon, err = repo.Coupons.OldestUnused(ctx, userID)
 		}
 	}
-
-	meteredFare := farePerDistance * calculateDistance(pickupLatitude, pickupLongitude, destLatitude, destLongitude)
-	discountedMeteredFare := max(meteredFare-discount, 0)
-
-	return initialFare + discountedMeteredFare, nil
-}
-
-func calculateDiscountedFareButNoTx(ctx context.Context, db *sqlx.DB, userID string, ride *Ride, pickupLatitude, pickupLongitude, destLatitude, destLongitude int) (int, error) {
-	var coupon Coupon
 	discount := 0
-	if ride != nil {
-		destLatitude = ride.DestinationLatitude
-		destLongitude = ride.DestinationLongitude
-		pickupLatitude = ride.PickupLatitude
-		pickupLongitude = ride.PickupLongitude
-
-		// すでにクーポンが紐づいているならそれの割引額を参照
-		if err := db.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE used_by = ?", ride.ID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				return 0, err
-			}
-		} else {
-			discount = coupon.Discount
-		}
-	} else {
-		// 初回利用クーポンを最優先で使う
-		if err := db.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND code = 'CP_NEW2024' AND used_by IS NULL", userID); err != nil {
-			if !errors.Is(err, sql.ErrNoRows) {
-				return 0, err
-			}
-
-			// 無いなら他のクーポンを付与された順番に使う
-			if err := db.GetContext(ctx, &coupon, "SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1", userID); err != nil {
-				if !errors.Is(err, sql.ErrNoRows) {
-					return 0, err
-				}
-			} else {
-				discount = coupon.Discount
-			}
-		} else {
-			discount = coupon.Discount
-		}
+	if err == nil {
+		discount = coupon.Discount
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
 	}
 
-	meteredFare := farePerDistance * calculateDistance(pickupLatitude, pickupLongitude, destLatitude, destLongitude)
+	meteredFare := rates.FarePerDistance * calculateRouteDistance(
+		Coordinate{Latitude: pickupLatitude, Longitude: pickupLongitude},
+		waypoints,
+		Coordinate{Latitude: destLatitude, Longitude: destLongitude},
+	)
+	meteredFare = applyPooledRate(meteredFare, pooled)
 	discountedMeteredFare := max(meteredFare-discount, 0)
 
-	return initialFare + discountedMeteredFare, nil
+	return rates.InitialFare + discountedMeteredFare, nil
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/coocood/freecache"
+)
+
+// 認証結果やライドの最新ステータスなどのキャッシュ。config.Cache.Backend で実装を選ぶ。
+// 複数のアプリケーションサーバーを並べるときは、共有できる redis を使う。
+// redis でもレート制限(rateLimiter)と溜めている椅子の位置(coordinateBuffer)はサーバーごとに持つ
+type Cache interface {
+	// 見つからなければ errCacheMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+	// すべて消す。redis なら選んだDBを丸ごと消す
+	Clear(ctx context.Context) error
+	Stats() CacheStats
+}
+
+type CacheStats struct {
+	Entries int64
+	Hits    int64
+	Misses  int64
+}
+
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+var errCacheMiss = errors.New("cache miss")
+
+var cache Cache
+
+func newCache(c Config) (Cache, error) {
+	switch c.Cache.Backend {
+	case "freecache":
+		return &freecacheCache{freecache.NewCache(c.CacheSizeMB * 1024 * 1024)}, nil
+	case "lru":
+		return newLRUCache(c.Cache.MaxEntries), nil
+	case "redis":
+		return newRedisCache(c.Cache), nil
+	case "none":
+		return &noopCache{}, nil
+	}
+	return nil, fmt.Errorf("unknown cache backend: %s", c.Cache.Backend)
+}
+
+// prefix ごとに値の型を決めて、JSON にして入れる
+type typedCache[T any] struct {
+	prefix string
+}
+
+func newTypedCache[T any](prefix string) typedCache[T] {
+	return typedCache[T]{prefix: prefix}
+}
+
+// キャッシュが使えないときも見つからなかったことにする
+func (c typedCache[T]) Get(ctx context.Context, key string) (T, bool) {
+	var v T
+	b, err := cacheGet(ctx, c.prefix+key)
+	if err != nil {
+		if !errors.Is(err, errCacheMiss) {
+			cacheErrors.WithLabelValues("get").Inc()
+		}
+		return v, false
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// 書き込めなくてもリクエストは失敗させない。次に読んだときにDBから取り直す
+func (c typedCache[T]) Set(ctx context.Context, key string, v T, ttl time.Duration) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = cache.Set(ctx, c.prefix+key, b, ttl)
+	}
+	if err != nil {
+		cacheErrors.WithLabelValues("set").Inc()
+	}
+}
+
+func (c typedCache[T]) Del(ctx context.Context, key string) {
+	if err := cache.Del(ctx, c.prefix+key); err != nil {
+		cacheErrors.WithLabelValues("del").Inc()
+	}
+}
+
+type freecacheCache struct {
+	c *freecache.Cache
+}
+
+func (f *freecacheCache) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := f.c.Get([]byte(key))
+	if errors.Is(err, freecache.ErrNotFound) {
+		return nil, errCacheMiss
+	}
+	return v, err
+}
+
+func (f *freecacheCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// freecache は秒単位で、0 だと期限なしになる
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	return f.c.Set([]byte(key), value, max(seconds, 1))
+}
+
+func (f *freecacheCache) Del(ctx context.Context, key string) error {
+	f.c.Del([]byte(key))
+	return nil
+}
+
+func (f *freecacheCache) Clear(ctx context.Context) error {
+	f.c.Clear()
+	return nil
+}
+
+func (f *freecacheCache) Stats() CacheStats {
+	return CacheStats{Entries: f.c.EntryCount(), Hits: f.c.HitCount(), Misses: f.c.MissCount()}
+}
+
+// 何も覚えない。キャッシュのせいで古い値が見えていないか確かめるときに使う
+type noopCache struct {
+	misses atomic.Int64
+}
+
+func (n *noopCache) Get(ctx context.Context, key string) ([]byte, error) {
+	n.misses.Add(1)
+	return nil, errCacheMiss
+}
+
+func (n *noopCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (n *noopCache) Del(ctx context.Context, key string) error { return nil }
+
+func (n *noopCache) Clear(ctx context.Context) error { return nil }
+
+func (n *noopCache) Stats() CacheStats {
+	return CacheStats{Misses: n.misses.Load()}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// 件数で上限を決める、プロセス内のキャッシュ。あふれたら最後に使ってから一番長いものを捨てる
+type lruCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// 前ほど最近使った
+	order  *list.List
+	hits   int64
+	misses int64
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (l *lruCache) Get(ctx context.Context, key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		l.misses++
+		return nil, errCacheMiss
+	}
+	e := el.Value.(*lruEntry)
+	if !time.Now().Before(e.expiresAt) {
+		l.remove(el)
+		l.misses++
+		return nil, errCacheMiss
+	}
+	l.order.MoveToFront(el)
+	l.hits++
+	return e.value, nil
+}
+
+func (l *lruCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 呼び出し側が渡した値を後で書き換えても影響しないようにする
+	value = append([]byte(nil), value...)
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := l.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value = value
+		e.expiresAt = expiresAt
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for l.order.Len() > l.maxEntries {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *lruCache) Del(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.remove(el)
+	}
+	return nil
+}
+
+func (l *lruCache) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).key)
+}
+
+func (l *lruCache) Clear(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = map[string]*list.Element{}
+	l.order.Init()
+	return nil
+}
+
+func (l *lruCache) Stats() CacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return CacheStats{Entries: int64(l.order.Len()), Hits: l.hits, Misses: l.misses}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/resp"
+)
+
+// Redis のプロトコルで話すサーバー(Redis, Valkey, KeyDB など)に置くキャッシュ。
+// 複数のアプリケーションサーバーから同じものを見られる
+type redisCache struct {
+	client *resp.Client
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newRedisCache(c CacheConfig) *redisCache {
+	return &redisCache{client: resp.NewClient(resp.Options{
+		Addr:     c.RedisAddr,
+		Password: c.RedisPassword,
+		DB:       c.RedisDB,
+		PoolSize: c.RedisPoolSize,
+		MaxOpen:  c.RedisMaxOpen,
+		Timeout:  time.Duration(c.RedisTimeoutMs) * time.Millisecond,
+	})}
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.client.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if v.Null {
+		r.misses.Add(1)
+		return nil, errCacheMiss
+	}
+	r.hits.Add(1)
+	return v.Str, nil
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.client.Do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (r *redisCache) Del(ctx context.Context, key string) error {
+	_, err := r.client.Do(ctx, "DEL", key)
+	return err
+}
+
+func (r *redisCache) Clear(ctx context.Context) error {
+	_, err := r.client.Do(ctx, "FLUSHDB")
+	return err
+}
+
+// 件数はサーバーに問い合わせる。問い合わせられなければ 0
+func (r *redisCache) Stats() CacheStats {
+	stats := CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if v, err := r.client.Do(ctx, "DBSIZE"); err == nil {
+		stats.Entries = v.Int
+	}
+	return stats
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 走行距離は椅子が位置を送ってくるたびに chairs と rides に足していく(addChairDistance)。
+// 位置の履歴は chair_locations に残しているので、そこから同じ規則で数え直せる(computeChairDistances)
+
+// 迎車中のライドには pickup_distance、乗車中のライドには loaded_distance として距離を足す。
+// 乗車中のライドが1つでもあれば、椅子の loaded_distance にも足す
+func addChairDistance(ctx context.Context, tx Repositories, chairID string, to Coordinate, distance int, rides []Ride) error {
+	loaded := false
+	for _, ride := range rides {
+		status, err := tx.Rides.LatestStatus(ctx, ride.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		pickup, carrying := rideDistanceKind(status)
+		if !pickup && !carrying {
+			continue
+		}
+		if carrying {
+			loaded = true
+			err = tx.Rides.AddDistance(ctx, ride.ID, 0, distance)
+		} else {
+			err = tx.Rides.AddDistance(ctx, ride.ID, distance, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Chairs.Move(ctx, chairID, to, distance, loaded)
+}
+
+// 迎車中か乗車中か
+func rideDistanceKind(status string) (pickup bool, carrying bool) {
+	switch status {
+	case "ENROUTE":
+		return true, false
+	case "CARRYING", "STOPOVER":
+		return false, true
+	}
+	return false, false
+}
+
+type chairDistance struct {
+	TotalDistance  int
+	LoadedDistance int
+	// 最後に動いた日時。一度も動いていなければゼロ値
+	UpdatedAt time.Time
+	Latitude  int
+	Longitude int
+}
+
+type rideDistance struct {
+	PickupDistance int
+	LoadedDistance int
+}
+
+type distanceLedger struct {
+	// 位置が一度でも記録された椅子だけを持つ
+	Chairs map[string]*chairDistance
+	// 距離が 0 でないライドだけを持つ
+	Rides map[string]*rideDistance
+}
+
+type rideStatusEvent struct {
+	ChairID   string    `db:"chair_id"`
+	RideID    string    `db:"ride_id"`
+	Status    string    `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// chair_locations と ride_statuses から走行距離を数え直す。
+// 位置が記録された時点で最新だったライドのステータスで、迎車中か乗車中かを決める
+func computeChairDistances(ctx context.Context, q sqlx.QueryerContext) (*distanceLedger, error) {
+	events := []rideStatusEvent{}
+	if err := sqlx.SelectContext(ctx, q, &events, `SELECT r.chair_id, rs.ride_id, rs.status, rs.created_at
+FROM ride_statuses rs
+       JOIN rides r ON r.id = rs.ride_id
+WHERE r.chair_id IS NOT NULL
+ORDER BY r.chair_id, rs.created_at, rs.id`); err != nil {
+		return nil, err
+	}
+	eventsByChair := map[string][]rideStatusEvent{}
+	for _, e := range events {
+		eventsByChair[e.ChairID] = append(eventsByChair[e.ChairID], e)
+	}
+
+	rows, err := q.QueryxContext(ctx, `SELECT chair_id, latitude, longitude, created_at FROM chair_locations ORDER BY chair_id, created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ledger := &distanceLedger{Chairs: map[string]*chairDistance{}, Rides: map[string]*rideDistance{}}
+	var (
+		chairID string
+		chair   *chairDistance
+		pending []rideStatusEvent
+		// 進行中のライドの最新ステータス
+		current map[string]string
+	)
+	for rows.Next() {
+		var loc ChairLocation
+		if err := rows.StructScan(&loc); err != nil {
+			return nil, err
+		}
+		if chair == nil || loc.ChairID != chairID {
+			chairID = loc.ChairID
+			chair = &chairDistance{Latitude: loc.Latitude, Longitude: loc.Longitude}
+			ledger.Chairs[chairID] = chair
+			pending = eventsByChair[chairID]
+			current = map[string]string{}
+			continue
+		}
+
+		for len(pending) > 0 && pending[0].CreatedAt.Before(loc.CreatedAt) {
+			e := pending[0]
+			pending = pending[1:]
+			if pickup, carrying := rideDistanceKind(e.Status); pickup || carrying {
+				current[e.RideID] = e.Status
+			} else {
+				delete(current, e.RideID)
+			}
+		}
+
+		distance := myAbs(chair.Latitude-loc.Latitude) + myAbs(chair.Longitude-loc.Longitude)
+		loaded := false
+		for rideID, status := range current {
+			ride, ok := ledger.Rides[rideID]
+			if !ok {
+				ride = &rideDistance{}
+				ledger.Rides[rideID] = ride
+			}
+			if _, carrying := rideDistanceKind(status); carrying {
+				loaded = true
+				ride.LoadedDistance += distance
+			} else {
+				ride.PickupDistance += distance
+			}
+		}
+		chair.TotalDistance += distance
+		if loaded {
+			chair.LoadedDistance += distance
+		}
+		chair.UpdatedAt = loc.CreatedAt
+		chair.Latitude = loc.Latitude
+		chair.Longitude = loc.Longitude
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for rideID, ride := range ledger.Rides {
+		if ride.PickupDistance == 0 && ride.LoadedDistance == 0 {
+			delete(ledger.Rides, rideID)
+		}
+	}
+	return ledger, nil
+}
+
+// chairs.loaded_distance や rides の走行距離の列を足したマイグレーション
+const rideDistancesMigrationVersion = 11
+
+// 初期データの走行距離。0014 の埋め戻しを初期データに流した結果と同じ
+const initialDistancesFile = "../sql/initial-distances.sql"
+
+// init.sh で作り直したDBに、初期データの走行距離を書き込む。列は 0011 で足すので、マイグレーションのあとに流す
+func loadInitialDistances(ctx context.Context, db *sqlx.DB) error {
+	script, err := os.ReadFile(initialDistancesFile)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return execMigrationScript(ctx, conn, string(script))
+}
+
+// 走行距離を数え直して chairs と rides に書き込む。位置が記録されていない椅子とそのライドは数え直せないので、そのままにする
+func rebuildChairDistances(ctx context.Context, db *sqlx.DB) (*distanceLedger, error) {
+	ledger, err := computeChairDistances(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE chairs SET total_distance = 0, loaded_distance = 0, total_distance_updated_at = NULL WHERE id IN (SELECT chair_id FROM chair_locations)`); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE rides SET pickup_distance = 0, loaded_distance = 0, updated_at = updated_at WHERE chair_id IN (SELECT chair_id FROM chair_locations)`); err != nil {
+		return nil, err
+	}
+
+	updateChair, err := tx.PreparexContext(ctx, `UPDATE chairs SET total_distance = ?, loaded_distance = ?, total_distance_updated_at = ?, latitude = ?, longitude = ? WHERE id = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateChair.Close()
+	for _, id := range sortedKeys(ledger.Chairs) {
+		c := ledger.Chairs[id]
+		var updatedAt *time.Time
+		if !c.UpdatedAt.IsZero() {
+			updatedAt = &c.UpdatedAt
+		}
+		if _, err := updateChair.ExecContext(ctx, c.TotalDistance, c.LoadedDistance, updatedAt, c.Latitude, c.Longitude, id); err != nil {
+			return nil, err
+		}
+	}
+
+	updateRide, err := tx.PreparexContext(ctx, `UPDATE rides SET pickup_distance = ?, loaded_distance = ?, updated_at = updated_at WHERE id = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer updateRide.Close()
+	for _, id := range sortedKeys(ledger.Rides) {
+		r := ledger.Rides[id]
+		if _, err := updateRide.ExecContext(ctx, r.PickupDistance, r.LoadedDistance, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type distanceMismatch struct {
+	// "chair" か "ride"
+	Kind    string
+	ID      string
+	Field   string
+	Stored  int64
+	Rebuilt int64
+}
+
+func (m distanceMismatch) String() string {
+	return fmt.Sprintf("%s %s %s: stored %d, rebuilt %d", m.Kind, m.ID, m.Field, m.Stored, m.Rebuilt)
+}
+
+// 積み上げてきた値が、履歴から数え直した値と一致するか確かめる。位置が記録されていない椅子とそのライドは数え直せないので比べない
+func verifyChairDistances(ctx context.Context, db *sqlx.DB) ([]distanceMismatch, error) {
+	ledger, err := computeChairDistances(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []distanceMismatch
+	check := func(kind, id, field string, stored, rebuilt int64) {
+		if stored != rebuilt {
+			mismatches = append(mismatches, distanceMismatch{Kind: kind, ID: id, Field: field, Stored: stored, Rebuilt: rebuilt})
+		}
+	}
+
+	chairs := []Chair{}
+	if err := db.SelectContext(ctx, &chairs, `SELECT * FROM chairs ORDER BY id`); err != nil {
+		return nil, err
+	}
+	for _, chair := range chairs {
+		rebuilt, ok := ledger.Chairs[chair.ID]
+		if !ok {
+			continue
+		}
+		check("chair", chair.ID, "total_distance", int64(chair.TotalDistance), int64(rebuilt.TotalDistance))
+		check("chair", chair.ID, "loaded_distance", int64(chair.LoadedDistance), int64(rebuilt.LoadedDistance))
+		check("chair", chair.ID, "latitude", chair.Latitude.Int64, int64(rebuilt.Latitude))
+		check("chair", chair.ID, "longitude", chair.Longitude.Int64, int64(rebuilt.Longitude))
+	}
+
+	rides := []struct {
+		ID             string         `db:"id"`
+		ChairID        sql.NullString `db:"chair_id"`
+		PickupDistance int            `db:"pickup_distance"`
+		LoadedDistance int            `db:"loaded_distance"`
+	}{}
+	if err := db.SelectContext(ctx, &rides, `SELECT id, chair_id, pickup_distance, loaded_distance FROM rides ORDER BY id`); err != nil {
+		return nil, err
+	}
+	for _, ride := range rides {
+		if _, ok := ledger.Chairs[ride.ChairID.String]; ride.ChairID.Valid && !ok {
+			continue
+		}
+		rebuilt, ok := ledger.Rides[ride.ID]
+		if !ok {
+			rebuilt = &rideDistance{}
+		}
+		check("ride", ride.ID, "pickup_distance", int64(ride.PickupDistance), int64(rebuilt.PickupDistance))
+		check("ride", ride.ID, "loaded_distance", int64(ride.LoadedDistance), int64(rebuilt.LoadedDistance))
+	}
+	return mismatches, nil
+}
+
+// isuride distances <verify|rebuild> [flags...]
+//
+// verify は積み上げてきた走行距離を履歴から数え直した値と比べ、違っていれば終了コード1で終わる。
+// rebuild は数え直した値で書き換えたあと、もう一度 verify する
+func runDistancesCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: isuride distances <verify|rebuild> [flags...]")
+	}
+	command, args := args[0], args[1:]
+	if command != "verify" && command != "rebuild" {
+		return fmt.Errorf("unknown distances command: %s", command)
+	}
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return err
+	}
+	distanceDB, err := sqlx.Connect("mysql", newDBConfig(cfg.DB).FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer distanceDB.Close()
+	ctx := context.Background()
+
+	if command == "rebuild" {
+		ledger, err := rebuildChairDistances(ctx, distanceDB)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rebuilt %d chairs and %d rides\n", len(ledger.Chairs), len(ledger.Rides))
+	}
+
+	mismatches, err := verifyChairDistances(ctx, distanceDB)
+	if err != nil {
+		return err
+	}
+	for _, m := range mismatches {
+		fmt.Fprintln(out, m)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d mismatches", len(mismatches))
+	}
+	fmt.Fprintln(out, "distances are consistent")
+	return nil
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 )
@@ -18,9 +20,11 @@ type chairPostChairsRequest struct {
 type chairPostChairsResponse struct {
 	ID      string `json:"id"`
 	OwnerID string `json:"owner_id"`
+	// POST /api/chair/session でセッションを発行し直すためのトークン。このレスポンスでしか返さない
+	AccessToken string `json:"access_token"`
 }
 
-func chairPostChairs(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) chairPostChairs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &chairPostChairsRequest{}
 	if err := bindJSON(r, req); err != nil {
@@ -28,42 +32,53 @@ func chairPostChairs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.Name == "" || req.Model == "" || req.ChairRegisterToken == "" {
-		writeError(w, http.StatusBadRequest, errors.New("some of required fields(name, model, chair_register_token) are empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("some of required fields(name, model, chair_register_token) are empty"))
 		return
 	}
 
-	owner := &Owner{}
-	if err := db.GetContext(ctx, owner, "SELECT * FROM owners WHERE chair_register_token = ?", req.ChairRegisterToken); err != nil {
+	owner, err := h.store.Owners.GetByChairRegisterToken(ctx, req.ChairRegisterToken)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusUnauthorized, errors.New("invalid chair_register_token"))
+			writeError(w, r, http.StatusUnauthorized, errors.New("invalid chair_register_token"))
 			return
 		}
-		writeError(w, http.StatusInternalServerError, err)
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	chairID := ulid.Make().String()
 	accessToken := secureRandomStr(32)
 
-	_, err := db.ExecContext(
-		ctx,
-		"INSERT INTO chairs (id, owner_id, name, model, is_active, access_token) VALUES (?, ?, ?, ?, ?, ?)",
-		chairID, owner.ID, req.Name, req.Model, false, accessToken,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	var session issuedSession
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		if err := tx.Chairs.Create(ctx, &Chair{
+			ID:          chairID,
+			OwnerID:     owner.ID,
+			Name:        req.Name,
+			Model:       req.Model,
+			IsActive:    false,
+			AccessToken: hashedAccessToken(accessToken),
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		session, err = createSession(ctx, tx.Sessions, "chair", chairID, deviceName(r))
+		return err
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Path:  "/",
-		Name:  "chair_session",
-		Value: accessToken,
-	})
+	// 椅子を登録したオーナーの売上・椅子一覧にもすぐ反映させる
+	replicas.pinPrincipal(ctx, "chair."+chairID)
+	replicas.pinPrincipal(ctx, "owner."+owner.ID)
+	setSessionCookies(w, "chair", session)
 
 	writeJSON(w, http.StatusCreated, &chairPostChairsResponse{
-		ID:      chairID,
-		OwnerID: owner.ID,
+		ID:          chairID,
+		OwnerID:     owner.ID,
+		AccessToken: accessToken,
 	})
 }
 
@@ -71,7 +86,7 @@ type postChairActivityRequest struct {
 	IsActive bool `json:"is_active"`
 }
 
-func chairPostActivity(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) chairPostActivity(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	chair := ctx.Value("chair").(*Chair)
 
@@ -81,9 +96,8 @@ func chairPostActivity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := db.ExecContext(ctx, "UPDATE chairs SET is_active = ? WHERE id = ?", req.IsActive, chair.ID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	if err := h.store.Chairs.SetActive(ctx, chair.ID, req.IsActive); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -101,7 +115,7 @@ func myAbs(x int) int {
 	return x
 }
 
-func chairPostCoordinate(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) chairPostCoordinate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &Coordinate{}
 	if err := bindJSON(r, req); err != nil {
@@ -110,108 +124,200 @@ func chairPostCoordinate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	chair := ctx.Value("chair").(*Chair)
+	if coordinateBuffer != nil {
+		h.chairPostCoordinateBuffered(w, r, chair, req)
+		return
+	}
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	var dbChair *Chair
+	updatedStatuses := map[string]string{}
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		var err error
+		dbChair, err = tx.Chairs.GetForUpdate(ctx, chair.ID)
+		if err != nil {
+			return err
+		}
+
+		// 相乗り中は乗客ごとに到着判定する
+		rides, err := getChairCurrentRides(ctx, tx.Rides, chair.ID)
+		if err != nil {
+			return err
+		}
+
+		if dbChair.Latitude.Valid && dbChair.Longitude.Valid {
+			distance := myAbs(int(dbChair.Latitude.Int64)-req.Latitude) + myAbs(int(dbChair.Longitude.Int64)-req.Longitude)
+			if err := addChairDistance(ctx, tx, chair.ID, *req, distance, rides); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Chairs.SetLocation(ctx, chair.ID, *req); err != nil {
+				return err
+			}
+		}
+
+		for _, ride := range rides {
+			status, err := updateRideStatusByCoordinate(ctx, tx.Rides, &ride, req)
+			if err != nil {
+				return err
+			}
+			if status != "" {
+				updatedStatuses[ride.ID] = status
+			}
+		}
+		return nil
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	defer tx.Rollback()
 
-	var dbChair Chair
-	if err := tx.GetContext(ctx, &dbChair, "SELECT * FROM chairs WHERE id = ? FOR UPDATE", chair.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	for rideID, status := range updatedStatuses {
+		rideStatusCache.Set(ctx, rideID, status, rideStatusCacheTTL)
+	}
+
+	writeJSON(w, http.StatusOK, &chairPostCoordinateResponse{
+		RecordedAt: dbChair.TotalDistanceUpdatedAt.Time.UnixMilli(),
+	})
+}
+
+// 位置はメモリに溜めて後でまとめて書き込み、ライドのステータスはキャッシュしたものから判定する。
+// ステータスが進むときだけ、その場でトランザクションを開く
+func (h *Handler) chairPostCoordinateBuffered(w http.ResponseWriter, r *http.Request, chair *Chair, req *Coordinate) {
+	ctx := r.Context()
+	batch, recordedAt, err := h.bufferChairCoordinate(ctx, chair, req)
+	if err != nil {
+		writeStatusError(w, r, err)
 		return
 	}
 
-	// beforeLocation := &ChairLocation{}
-	// tx.GetContext(ctx, beforeLocation, `SELECT * FROM chair_locations WHERE chair_id = ? ORDER BY created_at DESC LIMIT 1`, chair.ID)
-
-	// chairLocationID := ulid.Make().String()
-	// if _, err := tx.ExecContext(
-	// 	ctx,
-	// 	`INSERT INTO chair_locations (id, chair_id, latitude, longitude) VALUES (?, ?, ?, ?)`,
-	// 	chairLocationID, chair.ID, req.Latitude, req.Longitude,
-	// ); err != nil {
-	// 	writeError(w, http.StatusInternalServerError, err)
-	// 	return
-	// }
-
-	// location := &ChairLocation{}
-	// if err := tx.GetContext(ctx, location, `SELECT * FROM chair_locations WHERE id = ?`, chairLocationID); err != nil {
-	// 	writeError(w, http.StatusInternalServerError, err)
-	// 	return
-	// }
-
-	if dbChair.Latitude.Valid && dbChair.Longitude.Valid {
-		if _, err := tx.ExecContext(
-			ctx,
-			`UPDATE chairs SET total_distance = total_distance + ?, total_distance_updated_at = CURRENT_TIMESTAMP(6), latitude = ?, longitude = ? WHERE id = ?`,
-			myAbs(int(dbChair.Latitude.Int64)-req.Latitude)+myAbs(int(dbChair.Longitude.Int64)-req.Longitude),
-			req.Latitude,
-			req.Longitude,
-			chair.ID,
-		); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
-		}
-	} else {
-		if _, err := tx.ExecContext(
-			ctx,
-			`UPDATE chairs SET latitude = ?, longitude = ? WHERE id = ?`,
-			req.Latitude,
-			req.Longitude,
-			chair.ID,
-		); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
+	// 椅子のロックを放してから待つ。同じ椅子の次の位置は、書き込みを待たずに溜められる
+	if config.Coordinate.Durability == "sync" {
+		if err := coordinateBuffer.wait(ctx, batch); err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
 
-	ride := &Ride{}
-	status2 := ""
-	if err := tx.GetContext(ctx, ride, `SELECT * FROM rides WHERE chair_id = ? ORDER BY updated_at DESC LIMIT 1`, chair.ID); err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+	writeJSON(w, http.StatusOK, &chairPostCoordinateResponse{
+		RecordedAt: recordedAt.UnixMilli(),
+	})
+}
+
+// 椅子をロックして位置を溜め、ライドのステータスを進める。位置を溜めた分と受け付けた日時を返す
+func (h *Handler) bufferChairCoordinate(ctx context.Context, chair *Chair, req *Coordinate) (*coordinateBatch, time.Time, error) {
+	buffered, err := coordinateBuffer.lockChair(ctx, chair.ID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer buffered.mu.Unlock()
+
+	rides, err := getChairCurrentRides(ctx, h.store.Rides, chair.ID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	statuses := make(map[string]string, len(rides))
+	for _, ride := range rides {
+		status, err := getLatestRideStatus(ctx, h.store.Rides, ride.ID)
+		if err != nil {
+			return nil, time.Time{}, err
 		}
-	} else {
-		status, err := getLatestRideStatus(ctx, tx, ride.ID)
+		statuses[ride.ID] = status
+	}
+
+	// chair_locations には受け付けた日時で記録するので、あとで足すステータスより前になる
+	recordedAt := time.Now().Truncate(time.Microsecond)
+	batch, err := coordinateBuffer.add(buffered, chair.ID, *req, recordedAt, statuses)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	for _, ride := range rides {
+		status := statuses[ride.ID]
+		next, waypoint, err := nextRideStatusByCoordinate(ctx, h.store.Rides, &ride, status, req)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			return nil, time.Time{}, err
+		}
+		if next == "" {
+			continue
 		}
-		if status != "COMPLETED" && status != "CANCELED" {
-			if req.Latitude == ride.PickupLatitude && req.Longitude == ride.PickupLongitude && status == "ENROUTE" {
-				if _, err := tx.ExecContext(ctx, "INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)", ulid.Make().String(), ride.ID, "PICKUP"); err != nil {
-					writeError(w, http.StatusInternalServerError, err)
-					return
-				}
-				status2 = "PICKUP"
+		if err := h.store.InTx(ctx, func(tx Repositories) error {
+			// キャッシュが古かった場合は進めない
+			latest, err := tx.Rides.LatestStatus(ctx, ride.ID)
+			if err != nil {
+				return err
 			}
-
-			if req.Latitude == ride.DestinationLatitude && req.Longitude == ride.DestinationLongitude && status == "CARRYING" {
-				if _, err := tx.ExecContext(ctx, "INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)", ulid.Make().String(), ride.ID, "ARRIVED"); err != nil {
-					writeError(w, http.StatusInternalServerError, err)
-					return
-				}
-				status2 = "ARRIVED"
+			if latest != status {
+				next = ""
+				return nil
 			}
+			return addRideStatusByCoordinate(ctx, tx.Rides, ride.ID, next, waypoint)
+		}); err != nil {
+			return nil, time.Time{}, err
+		}
+		if next != "" {
+			rideStatusCache.Set(ctx, ride.ID, next, rideStatusCacheTTL)
 		}
 	}
+	return batch, recordedAt, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+// 椅子の現在地からライドのステータスを進める。進めた場合は新しいステータスを返す
+func updateRideStatusByCoordinate(ctx context.Context, rides RideRepository, ride *Ride, req *Coordinate) (string, error) {
+	status, err := getLatestRideStatus(ctx, rides, ride.ID)
+	if err != nil {
+		return "", err
+	}
+	next, waypoint, err := nextRideStatusByCoordinate(ctx, rides, ride, status, req)
+	if err != nil || next == "" {
+		return "", err
+	}
+	if err := addRideStatusByCoordinate(ctx, rides, ride.ID, next, waypoint); err != nil {
+		return "", err
 	}
+	return next, nil
+}
 
-	if status2 != "" {
-		cache.Set([]byte("latest.ride."+ride.ID), []byte(status2), 10)
+// status のライドが椅子の現在地で進む先のステータス。進まなければ空文字列。
+// 経由地に着いた場合はその経由地も返す
+func nextRideStatusByCoordinate(ctx context.Context, rides RideRepository, ride *Ride, status string, req *Coordinate) (string, *RideWaypoint, error) {
+	if status == "COMPLETED" || status == "CANCELED" {
+		return "", nil, nil
 	}
 
-	writeJSON(w, http.StatusOK, &chairPostCoordinateResponse{
-		RecordedAt: dbChair.TotalDistanceUpdatedAt.Time.UnixMilli(),
-	})
+	if req.Latitude == ride.PickupLatitude && req.Longitude == ride.PickupLongitude && status == "ENROUTE" {
+		return "PICKUP", nil, nil
+	}
+
+	if status != "CARRYING" {
+		return "", nil, nil
+	}
+
+	// 経由地が残っている間は目的地に着いても到着扱いにしない
+	if ride.WaypointCount > 0 {
+		waypoints, err := rides.Waypoints(ctx, ride.ID)
+		if err != nil {
+			return "", nil, err
+		}
+		if next := nextWaypoint(waypoints); next != nil {
+			if req.Latitude != next.Latitude || req.Longitude != next.Longitude {
+				return "", nil, nil
+			}
+			return "STOPOVER", next, nil
+		}
+	}
+
+	if req.Latitude == ride.DestinationLatitude && req.Longitude == ride.DestinationLongitude {
+		return "ARRIVED", nil, nil
+	}
+	return "", nil, nil
+}
+
+func addRideStatusByCoordinate(ctx context.Context, rides RideRepository, rideID string, status string, waypoint *RideWaypoint) error {
+	if waypoint != nil {
+		if err := rides.MarkWaypointArrived(ctx, rideID, waypoint.Seq); err != nil {
+			return err
+		}
+	}
+	return rides.AddStatus(ctx, rideID, status)
 }
 
 type simpleUser struct {
@@ -225,72 +331,96 @@ type chairGetNotificationResponse struct {
 }
 
 type chairGetNotificationResponseData struct {
-	RideID                string     `json:"ride_id"`
-	User                  simpleUser `json:"user"`
-	PickupCoordinate      Coordinate `json:"pickup_coordinate"`
-	DestinationCoordinate Coordinate `json:"destination_coordinate"`
-	Status                string     `json:"status"`
+	RideID                string       `json:"ride_id"`
+	User                  simpleUser   `json:"user"`
+	PickupCoordinate      Coordinate   `json:"pickup_coordinate"`
+	DestinationCoordinate Coordinate   `json:"destination_coordinate"`
+	Waypoints             []Coordinate `json:"waypoints,omitempty"`
+	NextWaypoint          *Coordinate  `json:"next_waypoint,omitempty"`
+	Stops                 []chairStop  `json:"stops,omitempty"`
+	Status                string       `json:"status"`
 }
 
-func chairGetNotification(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) chairGetNotification(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	chair := ctx.Value("chair").(*Chair)
 
-	ride := &Ride{}
-	yetSentRideStatus := RideStatus{}
-	status := ""
-
-	if err := db.GetContext(ctx, ride, `SELECT * FROM rides WHERE chair_id = ? ORDER BY updated_at DESC LIMIT 1`, chair.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSON(w, http.StatusOK, &chairGetNotificationResponse{
-				RetryAfterMs: getRetryAfterMs(),
-			})
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err)
+	rides, err := getChairCurrentRides(ctx, h.store.Rides, chair.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if len(rides) == 0 {
+		writeJSON(w, http.StatusOK, &chairGetNotificationResponse{
+			RetryAfterMs: getRetryAfterMs(),
+		})
 		return
 	}
+	ride := &rides[0]
 
-	if err := db.GetContext(ctx, &yetSentRideStatus, `SELECT * FROM ride_statuses WHERE ride_id = ? AND chair_sent_at IS NULL ORDER BY created_at ASC LIMIT 1`, ride.ID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			status, err = getLatestRideStatus(ctx, db, ride.ID)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
-		} else {
-			writeError(w, http.StatusInternalServerError, err)
+	// 相乗り中は全乗客の中で一番古い未送信のステータスから通知する
+	rideIDs := make([]string, 0, len(rides))
+	for _, cr := range rides {
+		rideIDs = append(rideIDs, cr.ID)
+	}
+	status := ""
+	yetSentRideStatus, err := h.store.Rides.NextUnsentChairStatus(ctx, rideIDs)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		yetSentRideStatus = nil
+		status, err = getLatestRideStatus(ctx, h.store.Rides, ride.ID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	} else {
 		status = yetSentRideStatus.Status
+		for i := range rides {
+			if rides[i].ID == yetSentRideStatus.RideID {
+				ride = &rides[i]
+				break
+			}
+		}
 	}
 
-	user := &User{}
-	err := db.GetContext(ctx, user, "SELECT * FROM users WHERE id = ? FOR SHARE", ride.UserID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	var stops []chairStop
+	if ride.Pooled {
+		current, err := getRidesWithStatus(ctx, h.store.Rides, rides)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		stops = planChairStops(current)
 	}
 
-	tx, err := db.Beginx()
+	user, err := h.store.Users.Get(ctx, ride.UserID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	defer tx.Rollback()
 
-	if yetSentRideStatus.ID != "" {
-		_, err := tx.ExecContext(ctx, `UPDATE ride_statuses SET chair_sent_at = CURRENT_TIMESTAMP(6) WHERE id = ?`, yetSentRideStatus.ID)
+	var waypoints []Coordinate
+	var next *Coordinate
+	if ride.WaypointCount > 0 {
+		rideWaypoints, err := h.store.Rides.Waypoints(ctx, ride.ID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
+			writeError(w, r, http.StatusInternalServerError, err)
 			return
 		}
+		waypoints = waypointCoordinates(rideWaypoints)
+		if nw := nextWaypoint(rideWaypoints); nw != nil {
+			next = &Coordinate{Latitude: nw.Latitude, Longitude: nw.Longitude}
+		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
+	if yetSentRideStatus != nil {
+		if err := h.store.Rides.MarkChairSent(ctx, yetSentRideStatus.ID); err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
 	}
 
 	writeJSON(w, http.StatusOK, &chairGetNotificationResponse{
@@ -308,7 +438,10 @@ func chairGetNotification(w http.ResponseWriter, r *http.Request) {
 				Latitude:  ride.DestinationLatitude,
 				Longitude: ride.DestinationLongitude,
 			},
-			Status: status,
+			Waypoints:    waypoints,
+			NextWaypoint: next,
+			Stops:        stops,
+			Status:       status,
 		},
 		RetryAfterMs: getRetryAfterMs(),
 	})
@@ -318,7 +451,7 @@ type postChairRidesRideIDStatusRequest struct {
 	Status string `json:"status"`
 }
 
-func chairPostRideStatus(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) chairPostRideStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	rideID := r.PathValue("ride_id")
 
@@ -326,69 +459,110 @@ func chairPostRideStatus(w http.ResponseWriter, r *http.Request) {
 
 	req := &postChairRidesRideIDStatusRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		ride, err := tx.Rides.GetForUpdate(ctx, rideID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("ride not found"))
+			}
+			return err
+		}
 
-	ride := &Ride{}
-	if err := tx.GetContext(ctx, ride, "SELECT * FROM rides WHERE id = ? FOR UPDATE", rideID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusNotFound, errors.New("ride not found"))
-			return
+		if ride.ChairID.String != chair.ID {
+			return withStatus(http.StatusBadRequest, errors.New("not assigned to this ride"))
+		}
+
+		switch req.Status {
+		// Acknowledge the ride
+		case "ENROUTE":
+			return tx.Rides.AddStatus(ctx, ride.ID, "ENROUTE")
+		// After Picking up user
+		case "CARRYING":
+			status, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
+			if err != nil {
+				return err
+			}
+			// 乗車時と経由地からの出発時
+			if status != "PICKUP" && status != "STOPOVER" {
+				return withStatus(http.StatusBadRequest, errors.New("chair has not arrived yet"))
+			}
+			return tx.Rides.AddStatus(ctx, ride.ID, "CARRYING")
+		default:
+			return withStatus(http.StatusBadRequest, errors.New("invalid status"))
 		}
-		writeError(w, http.StatusInternalServerError, err)
+	}); err != nil {
+		writeStatusError(w, r, err)
 		return
 	}
 
-	if ride.ChairID.String != chair.ID {
-		writeError(w, http.StatusBadRequest, errors.New("not assigned to this ride"))
+	rideStatusCache.Set(ctx, rideID, req.Status, rideStatusCacheTTL)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type chairPostRideEvaluationRequest struct {
+	Evaluation int `json:"evaluation"`
+	reviewInput
+}
+
+func (h *Handler) chairPostRideEvaluation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+	chair := ctx.Value("chair").(*Chair)
+
+	req := &chairPostRideEvaluationRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Evaluation < 1 || req.Evaluation > 5 {
+		writeError(w, r, http.StatusBadRequest, errors.New("evaluation must be between 1 and 5"))
+		return
+	}
+	if err := req.reviewInput.validate(); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	status2 := ""
 
-	switch req.Status {
-	// Acknowledge the ride
-	case "ENROUTE":
-		if _, err := tx.ExecContext(ctx, "INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)", ulid.Make().String(), ride.ID, "ENROUTE"); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		ride, err := tx.Rides.GetForUpdate(ctx, rideID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("ride not found"))
+			}
+			return err
 		}
-		status2 = "ENROUTE"
-	// After Picking up user
-	case "CARRYING":
-		status, err := getLatestRideStatus(ctx, tx, ride.ID)
+		if ride.ChairID.String != chair.ID {
+			return withStatus(http.StatusBadRequest, errors.New("not assigned to this ride"))
+		}
+
+		status, err := getLatestRideStatus(ctx, tx.Rides, ride.ID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+			return err
 		}
-		if status != "PICKUP" {
-			writeError(w, http.StatusBadRequest, errors.New("chair has not arrived yet"))
-			return
+		if status != "ARRIVED" && status != "COMPLETED" {
+			return withStatus(http.StatusBadRequest, errors.New("not arrived yet"))
 		}
-		if _, err := tx.ExecContext(ctx, "INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)", ulid.Make().String(), ride.ID, "CARRYING"); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+
+		evaluated, err := tx.Reviews.Exists(ctx, ride.ID, "chair")
+		if err != nil {
+			return err
+		}
+		if evaluated {
+			return withStatus(http.StatusConflict, errors.New("already evaluated"))
 		}
-		status2 = "CARRYING"
-	default:
-		writeError(w, http.StatusBadRequest, errors.New("invalid status"))
-	}
 
-	if err := tx.Commit(); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		return recordReview(ctx, tx.Reviews, ride.ID, "chair", req.Evaluation, req.reviewInput, map[string]string{
+			"user": ride.UserID,
+		})
+	}); err != nil {
+		writeStatusError(w, r, err)
 		return
 	}
 
-	if status2 != "" {
-		cache.Set([]byte("latest.ride."+rideID), []byte(status2), 10)
-	}
-
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errUnexpectedStatus = errors.New("unexpected status code")
+
+// 1人分(ユーザー、オーナー、椅子)のクライアント。セッションCookieを持ち回る
+type apiClient struct {
+	baseURL  string
+	http     *http.Client
+	recorder *recorder
+	// Secure 属性付きのCookieは http:// では cookiejar が送らないので自分で持つ
+	cookies map[string]*http.Cookie
+	// 内部APIの保護方式が secret のときに送る値
+	internalSecret string
+}
+
+func newAPIClient(baseURL string, httpClient *http.Client, rec *recorder, internalSecret string) *apiClient {
+	return &apiClient{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		http:           httpClient,
+		recorder:       rec,
+		cookies:        map[string]*http.Cookie{},
+		internalSecret: internalSecret,
+	}
+}
+
+// route は集計用のキー("POST /api/chair/rides/{ride_id}/status" など)。
+// wantStatus 以外が返ってきたらエラーにする。out が nil でなければレスポンスを読み込む
+func (c *apiClient) call(ctx context.Context, route string, path string, body any, wantStatus int, out any) error {
+	method, _, _ := strings.Cut(route, " ")
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	if strings.HasPrefix(path, "/api/internal/") || path == "/api/initialize" {
+		// アプリに直接送るとき用。nginx を通すと接続元のアドレスで上書きされる
+		req.Header.Set("X-Real-IP", "127.0.0.1")
+		if c.internalSecret != "" {
+			req.Header.Set("X-Isuride-Internal-Secret", c.internalSecret)
+		}
+	}
+
+	start := time.Now()
+	res, err := c.http.Do(req)
+	if err != nil {
+		// 計測の終了で打ち切ったものは数えない
+		if ctx.Err() == nil {
+			c.recorder.request(route, 0, time.Since(start))
+		}
+		return fmt.Errorf("%s: %w", route, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	elapsed := time.Since(start)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.recorder.request(route, 0, elapsed)
+		}
+		return fmt.Errorf("%s: %w", route, err)
+	}
+	c.recorder.request(route, res.StatusCode, elapsed)
+
+	for _, cookie := range res.Cookies() {
+		if strings.HasSuffix(cookie.Name, "_session") {
+			c.cookies[cookie.Name] = &http.Cookie{Name: cookie.Name, Value: cookie.Value}
+		}
+	}
+
+	if res.StatusCode != wantStatus {
+		return fmt.Errorf("%s: %w: %d: %s", route, errUnexpectedStatus, res.StatusCode, bytes.TrimSpace(b))
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("%s: %w", route, err)
+		}
+	}
+	return nil
+}
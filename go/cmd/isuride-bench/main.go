@@ -0,0 +1,159 @@
+// isuride-bench はローカルで負荷をかけて、レイテンシと応答の整合性を報告する。
+//
+// ユーザーは配車を依頼して通知をポーリングし、到着したら評価する。
+// 椅子は通知に従って、モデルの速度で迎車地点と目的地に向かう。
+// オーナーは定期的に売上と椅子の一覧を取得する。
+// 決済サーバーはこのプロセスの中で動かし、最後に売上と決済額をシナリオと突き合わせる。
+//
+//	go run ./cmd/isuride-bench -target http://127.0.0.1:8080 -duration 60s -riders 50
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/paymentmock"
+)
+
+type benchConfig struct {
+	Target         string
+	Duration       time.Duration
+	Initialize     bool
+	PaymentListen  string
+	PaymentURL     string
+	InternalSecret string
+	Seed           uint64
+
+	Owners         int
+	ChairsPerOwner int
+	Riders         int
+	// 座標は 0 から Area-1 の範囲に取る
+	Area int
+
+	ChairTick        time.Duration
+	PollInterval     time.Duration
+	OwnerInterval    time.Duration
+	RiderThinkTime   time.Duration
+	MatchingInterval time.Duration
+
+	// 売上の期待値の計算に使う。settings テーブルの値と合わせる
+	InitialFare     int
+	FarePerDistance int
+}
+
+func parseFlags(args []string) (benchConfig, error) {
+	var cfg benchConfig
+	fs := flag.NewFlagSet("isuride-bench", flag.ContinueOnError)
+	fs.StringVar(&cfg.Target, "target", "http://127.0.0.1:8080", "base URL of the app")
+	fs.DurationVar(&cfg.Duration, "duration", 60*time.Second, "how long to run the load")
+	fs.BoolVar(&cfg.Initialize, "initialize", true, "call POST /api/initialize before the load")
+	fs.StringVar(&cfg.PaymentListen, "payment-listen", "127.0.0.1:12346", "address of the in-process payment gateway")
+	fs.StringVar(&cfg.PaymentURL, "payment-url", "", "payment gateway URL told to the app (default http://<payment-listen>)")
+	fs.StringVar(&cfg.InternalSecret, "internal-secret", "", "value of X-Isuride-Internal-Secret for internal APIs")
+	fs.Uint64Var(&cfg.Seed, "seed", uint64(time.Now().Unix()), "random seed, also used to make names unique")
+	fs.IntVar(&cfg.Owners, "owners", 5, "number of owners")
+	fs.IntVar(&cfg.ChairsPerOwner, "chairs-per-owner", 4, "number of chairs per owner")
+	fs.IntVar(&cfg.Riders, "riders", 30, "number of riders")
+	fs.IntVar(&cfg.Area, "area", 100, "size of the square area where rides happen")
+	fs.DurationVar(&cfg.ChairTick, "chair-tick", 100*time.Millisecond, "interval at which chairs move by their speed")
+	fs.DurationVar(&cfg.PollInterval, "poll-interval", 500*time.Millisecond, "notification polling interval when retry_after_ms is missing")
+	fs.DurationVar(&cfg.OwnerInterval, "owner-interval", 5*time.Second, "interval of owner sales and chairs requests")
+	fs.DurationVar(&cfg.RiderThinkTime, "rider-think-time", 2*time.Second, "max pause between rides of a rider")
+	fs.DurationVar(&cfg.MatchingInterval, "matching-interval", 500*time.Millisecond, "interval of GET /api/internal/matching (0 if the app matches by itself)")
+	fs.IntVar(&cfg.InitialFare, "initial-fare", 500, "initial_fare setting of the app")
+	fs.IntVar(&cfg.FarePerDistance, "fare-per-distance", 100, "fare_per_distance setting of the app")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if cfg.PaymentURL == "" {
+		cfg.PaymentURL = "http://" + cfg.PaymentListen
+	}
+	if cfg.Owners <= 0 || cfg.ChairsPerOwner <= 0 || cfg.Riders <= 0 {
+		return cfg, errors.New("owners, chairs-per-owner and riders must be positive")
+	}
+	if cfg.Area < 2 {
+		return cfg, errors.New("area must be at least 2")
+	}
+	if cfg.ChairTick <= 0 || cfg.PollInterval <= 0 || cfg.OwnerInterval <= 0 {
+		return cfg, errors.New("chair-tick, poll-interval and owner-interval must be positive")
+	}
+	return cfg, nil
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg benchConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	payments := paymentmock.New()
+	l, err := net.Listen("tcp", cfg.PaymentListen)
+	if err != nil {
+		return fmt.Errorf("failed to start payment gateway: %w", err)
+	}
+	paymentServer := &http.Server{Handler: payments.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go paymentServer.Serve(l)
+	defer paymentServer.Close()
+
+	rec := newRecorder()
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        0,
+			MaxIdleConnsPerHost: 1024,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	s := newScenario(cfg, rec, payments, httpClient)
+
+	if cfg.Initialize {
+		slog.Info("initializing", "target", cfg.Target, "payment_server", cfg.PaymentURL)
+		if err := s.newClient().call(ctx, "POST /api/initialize", "/api/initialize", map[string]any{
+			"payment_server": cfg.PaymentURL,
+		}, http.StatusOK, nil); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("registering", "owners", cfg.Owners, "chairs", cfg.Owners*cfg.ChairsPerOwner, "riders", cfg.Riders)
+	if err := s.register(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("running", "duration", cfg.Duration)
+	loadCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	s.run(loadCtx)
+	elapsed := time.Since(start)
+
+	s.verify(context.Background())
+	rec.print(os.Stdout, elapsed)
+	if n := rec.violationCount(); n > 0 {
+		return fmt.Errorf("%d violations", n)
+	}
+	return nil
+}
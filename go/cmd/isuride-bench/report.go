@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 違反の例として表示する最大件数
+const maxViolationExamples = 20
+
+// レイテンシと整合性チェックの結果を集める
+type recorder struct {
+	mu         sync.Mutex
+	routes     map[string]*routeStats
+	violations []string
+	violated   int
+	counters   map[string]int
+	durations  map[string][]time.Duration
+}
+
+type routeStats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		routes:    map[string]*routeStats{},
+		counters:  map[string]int{},
+		durations: map[string][]time.Duration{},
+	}
+}
+
+// status が0のものは通信エラー
+func (r *recorder) request(route string, status int, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.routes[route]
+	if !ok {
+		s = &routeStats{statuses: map[int]int{}}
+		r.routes[route] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	s.statuses[status]++
+	if status == 0 || status >= 500 {
+		s.errors++
+	}
+}
+
+// APIの応答が仕様どおりでなかった
+func (r *recorder) violation(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.violated++
+	if len(r.violations) < maxViolationExamples {
+		r.violations = append(r.violations, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *recorder) count(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name]++
+}
+
+// 配車待ち時間など、シナリオ上の所要時間
+func (r *recorder) duration(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.durations[name] = append(r.durations[name], d)
+}
+
+func (r *recorder) violationCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.violated
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000)
+}
+
+func (r *recorder) print(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fmt.Fprintf(w, "elapsed: %s\n\n", elapsed.Round(time.Millisecond))
+
+	routes := make([]string, 0, len(r.routes))
+	for route := range r.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	fmt.Fprintf(w, "%-52s %7s %7s %6s %8s %8s %8s %8s  %s\n", "route", "count", "rps", "5xx", "p50(ms)", "p90(ms)", "p99(ms)", "max(ms)", "statuses")
+	for _, route := range routes {
+		s := r.routes[route]
+		latencies := slices.Clone(s.latencies)
+		slices.Sort(latencies)
+		statuses := make([]int, 0, len(s.statuses))
+		for status := range s.statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		statusSummary := ""
+		for _, status := range statuses {
+			statusSummary += fmt.Sprintf("%d:%d ", status, s.statuses[status])
+		}
+		fmt.Fprintf(w, "%-52s %7d %7.1f %6d %8s %8s %8s %8s  %s\n",
+			route,
+			len(latencies),
+			float64(len(latencies))/elapsed.Seconds(),
+			s.errors,
+			formatMs(percentile(latencies, 0.5)),
+			formatMs(percentile(latencies, 0.9)),
+			formatMs(percentile(latencies, 0.99)),
+			formatMs(percentile(latencies, 1)),
+			statusSummary,
+		)
+	}
+
+	fmt.Fprintln(w)
+	counters := make([]string, 0, len(r.counters))
+	for name := range r.counters {
+		counters = append(counters, name)
+	}
+	sort.Strings(counters)
+	for _, name := range counters {
+		fmt.Fprintf(w, "%-30s %d\n", name, r.counters[name])
+	}
+	names := make([]string, 0, len(r.durations))
+	for name := range r.durations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		durations := slices.Clone(r.durations[name])
+		slices.Sort(durations)
+		fmt.Fprintf(w, "%-30s p50=%sms p90=%sms max=%sms\n", name,
+			formatMs(percentile(durations, 0.5)),
+			formatMs(percentile(durations, 0.9)),
+			formatMs(percentile(durations, 1)),
+		)
+	}
+
+	fmt.Fprintf(w, "\nviolations: %d\n", r.violated)
+	for _, v := range r.violations {
+		fmt.Fprintf(w, "  - %s\n", v)
+	}
+}
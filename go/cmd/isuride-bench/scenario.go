@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/paymentmock"
+)
+
+type coordinate struct {
+	Latitude  int `json:"latitude"`
+	Longitude int `json:"longitude"`
+}
+
+func distance(a, b coordinate) int {
+	return abs(a.Latitude-b.Latitude) + abs(a.Longitude-b.Longitude)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// from から to に向かって、マンハッタン距離で speed だけ進む
+func moveToward(from, to coordinate, speed int) coordinate {
+	next := from
+	for i := 0; i < speed && next != to; i++ {
+		switch {
+		case next.Latitude < to.Latitude:
+			next.Latitude++
+		case next.Latitude > to.Latitude:
+			next.Latitude--
+		case next.Longitude < to.Longitude:
+			next.Longitude++
+		case next.Longitude > to.Longitude:
+			next.Longitude--
+		}
+	}
+	return next
+}
+
+// sql/2-master-data.sql の chair_models から速度の違うものを選んでいる
+var chairModels = []struct {
+	Name  string
+	Speed int
+}{
+	{"リラックスシート NEO", 2},
+	{"エルゴクレスト II", 3},
+	{"AeroSeat", 3},
+	{"アルティマシート X", 5},
+	{"LuxeThrone", 5},
+	{"ナイトシート ブラックエディション", 7},
+}
+
+// ユーザーから見えるライドのステータスの順番
+var rideStatusOrder = map[string]int{
+	"MATCHING":  0,
+	"ENROUTE":   1,
+	"PICKUP":    2,
+	"CARRYING":  3,
+	"ARRIVED":   4,
+	"COMPLETED": 5,
+}
+
+type owner struct {
+	client *apiClient
+	id     string
+}
+
+type chair struct {
+	client *apiClient
+	id     string
+	speed  int
+	pos    coordinate
+}
+
+type rider struct {
+	client       *apiClient
+	id           string
+	paymentToken string
+	rand         *rand.Rand
+	// 評価して決済されたはずの金額。決済サーバーに届いたものと突き合わせる
+	payments []int
+}
+
+type scenario struct {
+	cfg      benchConfig
+	rec      *recorder
+	payments *paymentmock.Gateway
+	http     *http.Client
+
+	owners []*owner
+	chairs []*chair
+	riders []*rider
+
+	mu          sync.Mutex
+	chairOwners map[string]string
+	// オーナーごとの、完了させたライドの売上
+	sales map[string]int
+}
+
+func newScenario(cfg benchConfig, rec *recorder, payments *paymentmock.Gateway, httpClient *http.Client) *scenario {
+	return &scenario{
+		cfg:         cfg,
+		rec:         rec,
+		payments:    payments,
+		http:        httpClient,
+		chairOwners: map[string]string{},
+		sales:       map[string]int{},
+	}
+}
+
+func (s *scenario) newClient() *apiClient {
+	return newAPIClient(s.cfg.Target, s.http, s.rec, s.cfg.InternalSecret)
+}
+
+func (s *scenario) newRand(stream uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(s.cfg.Seed, stream))
+}
+
+func (s *scenario) randomCoordinate(r *rand.Rand) coordinate {
+	return coordinate{Latitude: r.IntN(s.cfg.Area), Longitude: r.IntN(s.cfg.Area)}
+}
+
+// 計測前にオーナー、椅子、ユーザーを登録する
+func (s *scenario) register(ctx context.Context) error {
+	for i := range s.cfg.Owners {
+		o := &owner{client: s.newClient()}
+		res := struct {
+			ID                 string `json:"id"`
+			ChairRegisterToken string `json:"chair_register_token"`
+		}{}
+		if err := o.client.call(ctx, "POST /api/owner/owners", "/api/owner/owners", map[string]any{
+			"name": fmt.Sprintf("bench-owner-%d-%d", s.cfg.Seed, i),
+		}, http.StatusCreated, &res); err != nil {
+			return err
+		}
+		o.id = res.ID
+		s.owners = append(s.owners, o)
+
+		for j := range s.cfg.ChairsPerOwner {
+			r := s.newRand(uint64(len(s.chairs) + 1))
+			model := chairModels[r.IntN(len(chairModels))]
+			c := &chair{client: s.newClient(), speed: model.Speed, pos: s.randomCoordinate(r)}
+			chairRes := struct {
+				ID      string `json:"id"`
+				OwnerID string `json:"owner_id"`
+			}{}
+			if err := c.client.call(ctx, "POST /api/chair/chairs", "/api/chair/chairs", map[string]any{
+				"name":                 fmt.Sprintf("bench-chair-%d-%d-%d", s.cfg.Seed, i, j),
+				"model":                model.Name,
+				"chair_register_token": res.ChairRegisterToken,
+			}, http.StatusCreated, &chairRes); err != nil {
+				return err
+			}
+			if chairRes.OwnerID != o.id {
+				s.rec.violation("chair %s: owner_id = %s, want %s", chairRes.ID, chairRes.OwnerID, o.id)
+			}
+			c.id = chairRes.ID
+			s.chairOwners[c.id] = o.id
+			s.chairs = append(s.chairs, c)
+		}
+	}
+
+	for i := range s.cfg.Riders {
+		r := &rider{
+			client:       s.newClient(),
+			paymentToken: fmt.Sprintf("bench-token-%d-%d", s.cfg.Seed, i),
+			rand:         s.newRand(uint64(1_000_000 + i)),
+		}
+		res := struct {
+			ID string `json:"id"`
+		}{}
+		if err := r.client.call(ctx, "POST /api/app/users", "/api/app/users", map[string]any{
+			"username":      fmt.Sprintf("bench-user-%d-%d", s.cfg.Seed, i),
+			"firstname":     "Bench",
+			"lastname":      fmt.Sprintf("User%d", i),
+			"date_of_birth": "2000-01-01",
+		}, http.StatusCreated, &res); err != nil {
+			return err
+		}
+		r.id = res.ID
+		if err := r.client.call(ctx, "POST /api/app/payment-methods", "/api/app/payment-methods", map[string]any{
+			"token": r.paymentToken,
+		}, http.StatusNoContent, nil); err != nil {
+			return err
+		}
+		s.riders = append(s.riders, r)
+	}
+	return nil
+}
+
+// ctx が切れるまで全員を動かす
+func (s *scenario) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	spawn := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	for _, c := range s.chairs {
+		spawn(func() { s.runChair(ctx, c) })
+	}
+	for _, r := range s.riders {
+		spawn(func() { s.runRider(ctx, r) })
+	}
+	for _, o := range s.owners {
+		spawn(func() { s.runOwner(ctx, o) })
+	}
+	if s.cfg.MatchingInterval > 0 {
+		spawn(func() { s.runMatching(ctx) })
+	}
+	wg.Wait()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+func retryAfter(ms int, fallback time.Duration) time.Duration {
+	if ms <= 0 {
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// アプリ側でマッチングを回していない場合は、ベンチマーカーと同じように外から呼ぶ
+func (s *scenario) runMatching(ctx context.Context) {
+	client := s.newClient()
+	for ctx.Err() == nil {
+		if err := client.call(ctx, "GET /api/internal/matching", "/api/internal/matching", nil, http.StatusNoContent, nil); err != nil && ctx.Err() == nil {
+			slog.Warn("matching failed", "error", err)
+		}
+		sleepCtx(ctx, s.cfg.MatchingInterval)
+	}
+}
+
+type chairNotification struct {
+	Data *struct {
+		RideID                string     `json:"ride_id"`
+		PickupCoordinate      coordinate `json:"pickup_coordinate"`
+		DestinationCoordinate coordinate `json:"destination_coordinate"`
+		Status                string     `json:"status"`
+	} `json:"data"`
+	RetryAfterMs int `json:"retry_after_ms"`
+}
+
+// 通知を見て目的地に向かって進み、迎車・乗車を報告する
+func (s *scenario) runChair(ctx context.Context, c *chair) {
+	if err := c.client.call(ctx, "POST /api/chair/activity", "/api/chair/activity", map[string]any{"is_active": true}, http.StatusNoContent, nil); err != nil {
+		slog.Warn("failed to activate chair", "chair", c.id, "error", err)
+		return
+	}
+
+	var (
+		rideID    string
+		target    *coordinate
+		enroute   bool
+		carrying  bool
+		reported  bool
+		nextPoll  time.Time
+		lastRoute string
+	)
+	postCoordinate := func() bool {
+		if err := c.client.call(ctx, "POST /api/chair/coordinate", "/api/chair/coordinate", c.pos, http.StatusOK, nil); err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("failed to post coordinate", "chair", c.id, "error", err)
+			}
+			return false
+		}
+		return true
+	}
+	reported = postCoordinate()
+
+	ticker := time.NewTicker(s.cfg.ChairTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if now := time.Now(); !now.Before(nextPoll) {
+			res := &chairNotification{}
+			if err := c.client.call(ctx, "GET /api/chair/notification", "/api/chair/notification", nil, http.StatusOK, res); err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("failed to get chair notification", "chair", c.id, "error", err)
+				}
+				nextPoll = now.Add(s.cfg.PollInterval)
+				continue
+			}
+			nextPoll = now.Add(retryAfter(res.RetryAfterMs, s.cfg.PollInterval))
+
+			if d := res.Data; d != nil {
+				if d.RideID != rideID {
+					rideID, enroute, carrying = d.RideID, false, false
+				}
+				if _, ok := rideStatusOrder[d.Status]; !ok {
+					s.rec.violation("chair %s: unknown ride status %q", c.id, d.Status)
+				}
+				statusPath := "/api/chair/rides/" + d.RideID + "/status"
+				var next *coordinate
+				switch d.Status {
+				case "MATCHING", "ENROUTE":
+					if !enroute {
+						if err := c.client.call(ctx, "POST /api/chair/rides/{ride_id}/status", statusPath, map[string]any{"status": "ENROUTE"}, http.StatusNoContent, nil); err != nil {
+							if ctx.Err() == nil {
+								s.rec.violation("chair %s: %v", c.id, err)
+							}
+							continue
+						}
+						enroute = true
+					}
+					next = &d.PickupCoordinate
+				case "PICKUP", "CARRYING":
+					if !carrying {
+						if err := c.client.call(ctx, "POST /api/chair/rides/{ride_id}/status", statusPath, map[string]any{"status": "CARRYING"}, http.StatusNoContent, nil); err != nil {
+							if ctx.Err() == nil {
+								s.rec.violation("chair %s: %v", c.id, err)
+							}
+							continue
+						}
+						carrying = true
+					}
+					next = &d.DestinationCoordinate
+				}
+				// 行き先が変わったら、着いた地点を必ず一度は報告する
+				route := rideID
+				if next != nil {
+					route += fmt.Sprint(*next)
+				}
+				if route != lastRoute {
+					lastRoute = route
+					reported = false
+				}
+				target = next
+			}
+		}
+
+		if target == nil {
+			continue
+		}
+		moved := moveToward(c.pos, *target, c.speed)
+		if moved == c.pos && reported {
+			continue
+		}
+		c.pos = moved
+		reported = postCoordinate()
+	}
+}
+
+type appNotification struct {
+	Data *struct {
+		RideID string `json:"ride_id"`
+		Fare   int    `json:"fare"`
+		Status string `json:"status"`
+		Chair  *struct {
+			ID string `json:"id"`
+		} `json:"chair"`
+	} `json:"data"`
+	RetryAfterMs int `json:"retry_after_ms"`
+}
+
+func (s *scenario) runRider(ctx context.Context, r *rider) {
+	for ctx.Err() == nil {
+		if err := s.ride(ctx, r); err != nil && ctx.Err() == nil {
+			slog.Warn("ride failed", "user", r.id, "error", err)
+			s.rec.count("rides_failed")
+			sleepCtx(ctx, time.Second)
+		}
+		sleepCtx(ctx, time.Duration(r.rand.Int64N(int64(s.cfg.RiderThinkTime)+1)))
+	}
+}
+
+// 配車を依頼し、到着したら評価して完了まで見届ける
+func (s *scenario) ride(ctx context.Context, r *rider) error {
+	pickup := s.randomCoordinate(r.rand)
+	destination := s.randomCoordinate(r.rand)
+	for destination == pickup {
+		destination = s.randomCoordinate(r.rand)
+	}
+	body := map[string]any{
+		"pickup_coordinate":      pickup,
+		"destination_coordinate": destination,
+	}
+
+	estimated := struct {
+		Fare int `json:"fare"`
+	}{}
+	if err := r.client.call(ctx, "POST /api/app/rides/estimated-fare", "/api/app/rides/estimated-fare", body, http.StatusOK, &estimated); err != nil {
+		return err
+	}
+	ride := struct {
+		RideID string `json:"ride_id"`
+		Fare   int    `json:"fare"`
+	}{}
+	if err := r.client.call(ctx, "POST /api/app/rides", "/api/app/rides", body, http.StatusAccepted, &ride); err != nil {
+		return err
+	}
+	requestedAt := time.Now()
+	s.rec.count("rides_requested")
+	if ride.Fare != estimated.Fare {
+		s.rec.violation("ride %s: fare = %d, estimated %d", ride.RideID, ride.Fare, estimated.Fare)
+	}
+
+	lastStatus := 0
+	evaluated := false
+	for {
+		res := &appNotification{}
+		if err := r.client.call(ctx, "GET /api/app/notification", "/api/app/notification", nil, http.StatusOK, res); err != nil {
+			return err
+		}
+		d := res.Data
+		if d == nil {
+			s.rec.violation("user %s: no notification for ride %s", r.id, ride.RideID)
+			return nil
+		}
+		if d.RideID != ride.RideID {
+			s.rec.violation("user %s: notified ride %s, want the latest ride %s", r.id, d.RideID, ride.RideID)
+			return nil
+		}
+		status, ok := rideStatusOrder[d.Status]
+		if !ok {
+			s.rec.violation("ride %s: unknown status %q", ride.RideID, d.Status)
+			return nil
+		}
+		if status < lastStatus {
+			s.rec.violation("ride %s: status went back to %s", ride.RideID, d.Status)
+		}
+		if d.Fare != ride.Fare {
+			s.rec.violation("ride %s: notified fare = %d, want %d", ride.RideID, d.Fare, ride.Fare)
+		}
+		if lastStatus < rideStatusOrder["ENROUTE"] && status >= rideStatusOrder["ENROUTE"] {
+			s.rec.duration("matching_wait", time.Since(requestedAt))
+		}
+		lastStatus = status
+
+		switch d.Status {
+		case "ARRIVED":
+			if evaluated {
+				break
+			}
+			if d.Chair == nil {
+				s.rec.violation("ride %s: arrived without chair", ride.RideID)
+				return nil
+			}
+			// 計測の終了で評価を打ち切ると、決済と売上の突き合わせがずれる
+			if err := r.client.call(context.WithoutCancel(ctx), "POST /api/app/rides/{ride_id}/evaluation", "/api/app/rides/"+ride.RideID+"/evaluation", map[string]any{
+				"evaluation": 1 + r.rand.IntN(5),
+			}, http.StatusOK, nil); err != nil {
+				return err
+			}
+			evaluated = true
+			r.payments = append(r.payments, ride.Fare)
+			s.addSales(d.Chair.ID, s.cfg.InitialFare+s.cfg.FarePerDistance*distance(pickup, destination))
+			s.rec.count("rides_completed")
+			s.rec.duration("ride_total", time.Since(requestedAt))
+		case "COMPLETED":
+			if !evaluated {
+				s.rec.violation("ride %s: completed before evaluation", ride.RideID)
+			}
+			return nil
+		}
+		sleepCtx(ctx, retryAfter(res.RetryAfterMs, s.cfg.PollInterval))
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (s *scenario) addSales(chairID string, sale int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ownerID, ok := s.chairOwners[chairID]
+	if !ok {
+		s.rec.violation("chair %s is not registered by this benchmark", chairID)
+		return
+	}
+	s.sales[ownerID] += sale
+}
+
+type ownerSales struct {
+	TotalSales int `json:"total_sales"`
+}
+
+func (s *scenario) runOwner(ctx context.Context, o *owner) {
+	for ctx.Err() == nil {
+		sleepCtx(ctx, s.cfg.OwnerInterval)
+		if err := o.client.call(ctx, "GET /api/owner/sales", "/api/owner/sales", nil, http.StatusOK, &ownerSales{}); err != nil && ctx.Err() == nil {
+			slog.Warn("failed to get sales", "owner", o.id, "error", err)
+		}
+		if err := o.client.call(ctx, "GET /api/owner/chairs", "/api/owner/chairs", nil, http.StatusOK, nil); err != nil && ctx.Err() == nil {
+			slog.Warn("failed to get chairs", "owner", o.id, "error", err)
+		}
+	}
+}
+
+// 計測後に、売上と決済がシナリオで完了させたライドと一致するか確かめる
+func (s *scenario) verify(ctx context.Context) {
+	for _, o := range s.owners {
+		res := &ownerSales{}
+		if err := o.client.call(ctx, "GET /api/owner/sales", "/api/owner/sales", nil, http.StatusOK, res); err != nil {
+			s.rec.violation("owner %s: %v", o.id, err)
+			continue
+		}
+		if want := s.sales[o.id]; res.TotalSales != want {
+			s.rec.violation("owner %s: total_sales = %d, want %d", o.id, res.TotalSales, want)
+		}
+	}
+	for _, r := range s.riders {
+		got := s.payments.Payments(r.paymentToken)
+		if fmt.Sprint(got) != fmt.Sprint(r.payments) {
+			s.rec.violation("user %s: payments = %v, want %v", r.id, got, r.payments)
+		}
+	}
+}
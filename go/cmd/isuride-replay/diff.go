@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// インスタンスごとに値が変わるキー。違っていたら対応を覚える
+func isIdentifierKey(key string) bool {
+	return key == "id" ||
+		strings.HasSuffix(key, "_id") ||
+		strings.HasSuffix(key, "_token") ||
+		strings.HasSuffix(key, "_code")
+}
+
+// want(記録時)と got(再生時)を比べて、差分を diffs に足す。key は直近のオブジェクトのキー
+func (rp *replayer) compare(key string, path string, want, got any, diffs *[]string) {
+	if rp.ignore[key] || strings.HasSuffix(key, "_at") {
+		return
+	}
+
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want object, got %s", path, describe(got)))
+			return
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok {
+				if !rp.ignore[k] && !strings.HasSuffix(k, "_at") {
+					*diffs = append(*diffs, fmt.Sprintf("%s.%s: missing", path, k))
+				}
+				continue
+			}
+			rp.compare(k, path+"."+k, wv, gv, diffs)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok && !rp.ignore[k] && !strings.HasSuffix(k, "_at") {
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+			}
+		}
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want array, got %s", path, describe(got)))
+			return
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %d items, got %d", path, len(w), len(g)))
+		}
+		for i := 0; i < len(w) && i < len(g); i++ {
+			rp.compare(key, fmt.Sprintf("%s[%d]", path, i), w[i], g[i], diffs)
+		}
+	case string:
+		g, ok := got.(string)
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %q, got %s", path, w, describe(got)))
+			return
+		}
+		// 記録時に伏せた個人情報は比べない
+		if w == redactedValue {
+			return
+		}
+		if mapped, ok := rp.rewrites[w]; ok {
+			if mapped != g {
+				*diffs = append(*diffs, fmt.Sprintf("%s: want %q (recorded %q), got %q", path, mapped, w, g))
+			}
+			return
+		}
+		if w == g {
+			return
+		}
+		// 初めて見るIDや、伏せて記録したAPIキーは再生先の値を覚える
+		if isIdentifierKey(key) || strings.HasPrefix(w, "secret:") {
+			rp.rewrites[w] = g
+			return
+		}
+		*diffs = append(*diffs, fmt.Sprintf("%s: want %q, got %q", path, w, g))
+	default:
+		if !reflect.DeepEqual(want, got) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %v, got %s", path, want, describe(got)))
+		}
+	}
+}
+
+func describe(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,321 @@
+// isuride-replay は -record-file で記録したトラフィックを、別のインスタンスに送り直してレスポンスを比べる。
+//
+// 記録時と同じ間隔で1件ずつ順に送る。ライドの評価には決済サーバーが要るので、-payment-listen で一緒に動かせる。
+// セッションCookieとAPIキーは、記録時のプレースホルダごとに再生先で発行されたものに差し替えるので、誰のリクエストかは保たれる。
+// IDやトークンはレスポンスを突き合わせたときに記録時の値との対応を覚え、後のリクエストのパスとボディを書き換える。
+//
+//	go run ./cmd/isuride-replay -target http://127.0.0.1:8080 -input traffic.jsonl -diff-paths /api/owner/sales
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/paymentmock"
+)
+
+// go/record.go の trafficRecord と同じ形式
+type trafficRecord struct {
+	Time         time.Time         `json:"time"`
+	Method       string            `json:"method"`
+	Path         string            `json:"path"`
+	Sessions     map[string]string `json:"sessions,omitempty"`
+	Bearer       string            `json:"bearer,omitempty"`
+	RequestBody  json.RawMessage   `json:"request_body,omitempty"`
+	Status       int               `json:"status"`
+	SetSessions  map[string]string `json:"set_sessions,omitempty"`
+	ResponseBody json.RawMessage   `json:"response_body,omitempty"`
+	DurationMs   float64           `json:"duration_ms"`
+}
+
+// 1件の差分として表示する最大行数
+const maxDiffLines = 10
+
+// go/record.go で個人情報を伏せたときの値
+const redactedValue = "[redacted]"
+
+type replayer struct {
+	target        string
+	http          *http.Client
+	paymentServer string
+	ignore        map[string]bool
+	diffPaths     []string
+	out           io.Writer
+
+	// 記録時の値 → 再生先での値。ID、トークン、セッションCookie、APIキー
+	rewrites map[string]string
+
+	replayed   int
+	compared   int
+	mismatched int
+}
+
+func main() {
+	target := flag.String("target", "http://127.0.0.1:8080", "base URL of the instance to replay against")
+	input := flag.String("input", "", "JSONL file written by -record-file")
+	speed := flag.Float64("speed", 1, "replay speed relative to the recording (0 sends as fast as possible)")
+	paymentServer := flag.String("payment-server", "", "payment_server to send with POST /api/initialize instead of the recorded one")
+	paymentListen := flag.String("payment-listen", "", "address to run a payment gateway on while replaying (disabled if empty)")
+	ignore := flag.String("ignore", "retry_after_ms", "comma separated JSON keys not to compare (keys ending with _at are always ignored)")
+	diffPaths := flag.String("diff-paths", "", "comma separated path prefixes to compare (all if empty)")
+	flag.Parse()
+
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "-input is required")
+		os.Exit(2)
+	}
+	records, err := readRecords(*input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *paymentListen != "" {
+		l, err := net.Listen("tcp", *paymentListen)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		go http.Serve(l, paymentmock.New().Handler())
+	}
+
+	rp := &replayer{
+		target:        strings.TrimSuffix(*target, "/"),
+		http:          &http.Client{Timeout: 30 * time.Second},
+		paymentServer: *paymentServer,
+		ignore:        splitSet(*ignore),
+		diffPaths:     splitList(*diffPaths),
+		out:           os.Stdout,
+		rewrites:      map[string]string{},
+	}
+	if err := rp.run(records, *speed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(rp.out, "replayed %d requests, compared %d, mismatched %d\n", rp.replayed, rp.compared, rp.mismatched)
+	if rp.mismatched > 0 {
+		os.Exit(1)
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func splitSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range splitList(s) {
+		set[v] = true
+	}
+	return set
+}
+
+func readRecords(path string) ([]trafficRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []trafficRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec trafficRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no records in " + path)
+	}
+	return records, nil
+}
+
+func (rp *replayer) run(records []trafficRecord, speed float64) error {
+	start := time.Now()
+	base := records[0].Time
+	for i, rec := range records {
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(rec.Time.Sub(base)) / speed))
+			time.Sleep(time.Until(at))
+		}
+		if err := rp.replay(i+1, &rec); err != nil {
+			return fmt.Errorf("#%d %s %s: %w", i+1, rec.Method, rec.Path, err)
+		}
+	}
+	return nil
+}
+
+func (rp *replayer) replay(n int, rec *trafficRecord) error {
+	path := rp.rewritePath(rec.Path)
+
+	var body io.Reader
+	if len(rec.RequestBody) > 0 {
+		var v any
+		if err := json.Unmarshal(rec.RequestBody, &v); err != nil {
+			return err
+		}
+		v = rp.rewriteValue(v)
+		if m, ok := v.(map[string]any); ok && rp.paymentServer != "" && rec.Method == http.MethodPost && path == "/api/initialize" {
+			m["payment_server"] = rp.paymentServer
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(rec.Method, rp.target+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// 再生先でまだ発行されていないセッションは送らない(401になって差分に出る)
+	for name, placeholder := range rec.Sessions {
+		if v, ok := rp.rewrites[placeholder]; ok {
+			req.AddCookie(&http.Cookie{Name: name, Value: v})
+		}
+	}
+	if rec.Bearer != "" {
+		if v, ok := rp.rewrites[rec.Bearer]; ok {
+			req.Header.Set("Authorization", "Bearer "+v)
+		}
+	}
+
+	res, err := rp.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	rp.replayed++
+
+	for name, placeholder := range rec.SetSessions {
+		for _, c := range res.Cookies() {
+			if c.Name == name && c.Value != "" {
+				rp.rewrites[placeholder] = c.Value
+			}
+		}
+	}
+
+	var diffs []string
+	if res.StatusCode != rec.Status {
+		diffs = append(diffs, fmt.Sprintf("status: want %d, got %d", rec.Status, res.StatusCode))
+	}
+	if len(rec.ResponseBody) > 0 {
+		var want, got any
+		if err := json.Unmarshal(rec.ResponseBody, &want); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(resBody, &got); err != nil {
+			diffs = append(diffs, fmt.Sprintf("body: not JSON: %s", bytes.TrimSpace(resBody)))
+		} else {
+			rp.compare("", "$", want, got, &diffs)
+		}
+	}
+
+	if !rp.shouldDiff(rec.Path) {
+		return nil
+	}
+	rp.compared++
+	if len(diffs) == 0 {
+		return nil
+	}
+	rp.mismatched++
+	fmt.Fprintf(rp.out, "#%d %s %s\n", n, rec.Method, rec.Path)
+	for i, d := range diffs {
+		if i == maxDiffLines {
+			fmt.Fprintf(rp.out, "  ... and %d more\n", len(diffs)-maxDiffLines)
+			break
+		}
+		fmt.Fprintf(rp.out, "  %s\n", d)
+	}
+	return nil
+}
+
+func (rp *replayer) shouldDiff(path string) bool {
+	if len(rp.diffPaths) == 0 {
+		return true
+	}
+	for _, prefix := range rp.diffPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// パスの要素とクエリの値を、記録時の値から再生先での値に置き換える
+func (rp *replayer) rewritePath(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if v, ok := rp.rewrites[s]; ok {
+			segments[i] = v
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	q := u.Query()
+	for k, values := range q {
+		for i, s := range values {
+			if v, ok := rp.rewrites[s]; ok {
+				values[i] = v
+			}
+		}
+		q[k] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+func (rp *replayer) rewriteValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = rp.rewriteValue(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = rp.rewriteValue(child)
+		}
+	case string:
+		if rewritten, ok := rp.rewrites[v]; ok {
+			return rewritten
+		}
+	}
+	return v
+}
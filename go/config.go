@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// 起動時に決まる設定。デフォルト値、設定ファイル(JSON)、環境変数、コマンドライン引数の順に上書きする
+type Config struct {
+	Listen     string `json:"listen"`
+	DebugAddr  string `json:"debug_addr"`
+	Production bool   `json:"production"`
+	// freecache の大きさ
+	CacheSizeMB int `json:"cache_size_mb"`
+	// settingsテーブルを読み直す間隔
+	SettingsReloadIntervalMs int `json:"settings_reload_interval_ms"`
+	// /metrics で返すライド数などを集計し直す間隔
+	BusinessMetricsIntervalMs int `json:"business_metrics_interval_ms"`
+	// 停止時、readyzを落としてから受付をやめるまでの時間と、処理中のリクエストを待つ時間
+	ShutdownDelayMs   int `json:"shutdown_delay_ms"`
+	ShutdownTimeoutMs int `json:"shutdown_timeout_ms"`
+
+	DB       DBConfig       `json:"db"`
+	Admin    AdminConfig    `json:"admin"`
+	Internal InternalConfig `json:"internal"`
+	TLS      TLSConfig      `json:"tls"`
+	Session  SessionConfig  `json:"session"`
+	Trace    TraceConfig    `json:"trace"`
+	Cache    CacheConfig    `json:"cache"`
+	// POST /api/chair/coordinate の書き込み方
+	Coordinate CoordinateConfig `json:"coordinate"`
+
+	// "chair_coordinate=10:20,app_notification=0" の形式
+	RateLimits         string  `json:"rate_limits"`
+	ErrorLogSampleRate float64 `json:"error_log_sample_rate"`
+	// APIのリクエストとレスポンスをJSONLで書き出すファイル。cmd/isuride-replay で再生できる
+	RecordFile string `json:"record_file"`
+	// 起動時に未適用のマイグレーションを適用する。しない場合は isuride migrate up で適用する
+	AutoMigrate bool `json:"auto_migrate"`
+}
+
+type DBConfig struct {
+	Host         string `json:"host"`
+	Port         int    `json:"port"`
+	User         string `json:"user"`
+	Password     string `json:"password"`
+	Name         string `json:"name"`
+	MaxIdleConns int    `json:"max_idle_conns"`
+	MaxOpenConns int    `json:"max_open_conns"`
+	// 読み取り専用のトランザクションを振り分けるレプリカ。カンマ区切りの go-sql-driver 形式のDSN
+	ReplicaDSN string `json:"replica_dsn"`
+	// 遅延がこれを超えたレプリカには振り分けない
+	ReplicaMaxLagSeconds   int `json:"replica_max_lag_seconds"`
+	ReplicaCheckIntervalMs int `json:"replica_check_interval_ms"`
+}
+
+type AdminConfig struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+type InternalConfig struct {
+	Guard  string `json:"guard"`
+	Secret string `json:"secret"`
+}
+
+type TLSConfig struct {
+	// cert と key を設定したときに、TLSで待ち受けるアドレス
+	Listen   string `json:"listen"`
+	Cert     string `json:"cert"`
+	Key      string `json:"key"`
+	ClientCA string `json:"client_ca"`
+}
+
+type SessionConfig struct {
+	LegacyAccessToken bool `json:"legacy_access_token"`
+	InsecureCookie    bool `json:"insecure_cookie"`
+}
+
+type TraceConfig struct {
+	Exporter    string  `json:"exporter"`
+	File        string  `json:"file"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+type CacheConfig struct {
+	// freecache, lru, redis, none
+	Backend string `json:"backend"`
+	// lru に入れておく件数
+	MaxEntries int `json:"max_entries"`
+	// redis の接続先(host:port)。Redis のプロトコルで話せれば Redis でなくてもよい
+	RedisAddr      string `json:"redis_addr"`
+	RedisPassword  string `json:"redis_password"`
+	RedisDB        int    `json:"redis_db"`
+	RedisPoolSize  int    `json:"redis_pool_size"`
+	RedisMaxOpen   int    `json:"redis_max_open"`
+	RedisTimeoutMs int    `json:"redis_timeout_ms"`
+}
+
+type CoordinateConfig struct {
+	// 受け取った位置をメモリに溜めて、この間隔でまとめて書き込む。0 なら受け取るたびに書き込む。
+	// 溜めている間は、マッチングや近くの椅子の検索には前に書き込んだ位置が使われる
+	FlushIntervalMs int `json:"flush_interval_ms"`
+	// async は溜めたら応答する。停止処理を経ずに落ちると、書き込む前の位置と走行距離を失う。
+	// sync は溜めた位置が書き込まれてから応答する
+	Durability string `json:"durability"`
+	// 溜まった位置がこれに達したら、間隔を待たずに書き込む。書き込めずに溜まったままなら、新しい位置は 503 で断る
+	MaxBufferedLocations int `json:"max_buffered_locations"`
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Listen:                    ":8080",
+		CacheSizeMB:               100,
+		SettingsReloadIntervalMs:  5000,
+		BusinessMetricsIntervalMs: 15000,
+		ShutdownTimeoutMs:         10000,
+		DB: DBConfig{
+			Host:                   "127.0.0.1",
+			Port:                   3306,
+			User:                   "isucon",
+			Password:               "isucon",
+			Name:                   "isuride",
+			MaxIdleConns:           8,
+			MaxOpenConns:           16,
+			ReplicaMaxLagSeconds:   1,
+			ReplicaCheckIntervalMs: 1000,
+		},
+		Internal:           InternalConfig{Guard: "auto"},
+		TLS:                TLSConfig{Listen: ":8443"},
+		Trace:              TraceConfig{SampleRatio: 1},
+		Cache:              CacheConfig{Backend: "freecache", MaxEntries: 100000, RedisPoolSize: 16, RedisMaxOpen: 64, RedisTimeoutMs: 100},
+		Coordinate:         CoordinateConfig{Durability: "async", MaxBufferedLocations: 1000},
+		ErrorLogSampleRate: 1,
+	}
+}
+
+// 環境変数・コマンドライン引数と設定項目の対応
+type configOption struct {
+	Env   string
+	Flag  string
+	Usage string
+	Set   func(c *Config, v string) error
+}
+
+func stringOption(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func intOption(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func floatOption(field func(c *Config) *float64) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func boolOption(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+var configOptions = []configOption{
+	{"ISUCON_LISTEN", "listen", "address to listen on", stringOption(func(c *Config) *string { return &c.Listen })},
+	{"ISUCON_DEBUG_ADDR", "debug-addr", "address of the debug server (disabled if empty)", stringOption(func(c *Config) *string { return &c.DebugAddr })},
+	{"ISUCON_PRODUCTION", "production", "disable /api/initialize", boolOption(func(c *Config) *bool { return &c.Production })},
+	{"ISUCON_CACHE_SIZE_MB", "cache-size-mb", "freecache size in MiB", intOption(func(c *Config) *int { return &c.CacheSizeMB })},
+	{"ISUCON_SETTINGS_RELOAD_INTERVAL_MS", "settings-reload-interval-ms", "interval to reload the settings table", intOption(func(c *Config) *int { return &c.SettingsReloadIntervalMs })},
+	{"ISUCON_BUSINESS_METRICS_INTERVAL_MS", "business-metrics-interval-ms", "interval to recount rides and chairs for /metrics", intOption(func(c *Config) *int { return &c.BusinessMetricsIntervalMs })},
+	{"ISUCON_SHUTDOWN_DELAY_MS", "shutdown-delay-ms", "delay before draining after readyz starts failing", intOption(func(c *Config) *int { return &c.ShutdownDelayMs })},
+	{"ISUCON_SHUTDOWN_TIMEOUT_MS", "shutdown-timeout-ms", "max time to wait for in-flight requests", intOption(func(c *Config) *int { return &c.ShutdownTimeoutMs })},
+	{"ISUCON_DB_HOST", "db-host", "database host", stringOption(func(c *Config) *string { return &c.DB.Host })},
+	{"ISUCON_DB_PORT", "db-port", "database port", intOption(func(c *Config) *int { return &c.DB.Port })},
+	{"ISUCON_DB_USER", "db-user", "database user", stringOption(func(c *Config) *string { return &c.DB.User })},
+	{"ISUCON_DB_PASSWORD", "db-password", "database password", stringOption(func(c *Config) *string { return &c.DB.Password })},
+	{"ISUCON_DB_NAME", "db-name", "database name", stringOption(func(c *Config) *string { return &c.DB.Name })},
+	{"ISUCON_DB_MAX_IDLE_CONNS", "db-max-idle-conns", "max idle connections", intOption(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"ISUCON_DB_MAX_OPEN_CONNS", "db-max-open-conns", "max open connections", intOption(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"ISUCON_DB_REPLICA_DSN", "db-replica-dsn", "comma separated DSNs of read replicas", stringOption(func(c *Config) *string { return &c.DB.ReplicaDSN })},
+	{"ISUCON_DB_REPLICA_MAX_LAG_SECONDS", "db-replica-max-lag-seconds", "max replication lag to read from a replica", intOption(func(c *Config) *int { return &c.DB.ReplicaMaxLagSeconds })},
+	{"ISUCON_DB_REPLICA_CHECK_INTERVAL_MS", "db-replica-check-interval-ms", "interval of replication lag checks", intOption(func(c *Config) *int { return &c.DB.ReplicaCheckIntervalMs })},
+	{"ISUCON_ADMIN_USER", "admin-user", "admin API user (disabled if empty)", stringOption(func(c *Config) *string { return &c.Admin.User })},
+	{"ISUCON_ADMIN_PASSWORD", "admin-password", "admin API password", stringOption(func(c *Config) *string { return &c.Admin.Password })},
+	{"ISUCON_INTERNAL_GUARD", "internal-guard", "guard for internal routes (auto, loopback, secret, mtls, none)", stringOption(func(c *Config) *string { return &c.Internal.Guard })},
+	{"ISUCON_INTERNAL_SECRET", "internal-secret", "shared secret for internal routes", stringOption(func(c *Config) *string { return &c.Internal.Secret })},
+	{"ISUCON_TLS_LISTEN", "tls-listen", "address to listen on with TLS when tls-cert is set", stringOption(func(c *Config) *string { return &c.TLS.Listen })},
+	{"ISUCON_TLS_CERT", "tls-cert", "TLS certificate file", stringOption(func(c *Config) *string { return &c.TLS.Cert })},
+	{"ISUCON_TLS_KEY", "tls-key", "TLS key file", stringOption(func(c *Config) *string { return &c.TLS.Key })},
+	{"ISUCON_TLS_CLIENT_CA", "tls-client-ca", "CA file to verify client certificates", stringOption(func(c *Config) *string { return &c.TLS.ClientCA })},
+	{"ISUCON_LEGACY_ACCESS_TOKEN", "legacy-access-token", "accept access_token columns as sessions", boolOption(func(c *Config) *bool { return &c.Session.LegacyAccessToken })},
+	{"ISUCON_INSECURE_COOKIE", "insecure-cookie", "set session cookies without Secure", boolOption(func(c *Config) *bool { return &c.Session.InsecureCookie })},
+	{"ISUCON_TRACE_EXPORTER", "trace-exporter", "trace exporter (otlp, stdout, file; disabled if empty)", stringOption(func(c *Config) *string { return &c.Trace.Exporter })},
+	{"ISUCON_TRACE_FILE", "trace-file", "file to write traces to", stringOption(func(c *Config) *string { return &c.Trace.File })},
+	{"ISUCON_TRACE_SAMPLE_RATIO", "trace-sample-ratio", "ratio of traced requests", floatOption(func(c *Config) *float64 { return &c.Trace.SampleRatio })},
+	{"ISUCON_CACHE_BACKEND", "cache-backend", "cache implementation (freecache, lru, redis, none)", stringOption(func(c *Config) *string { return &c.Cache.Backend })},
+	{"ISUCON_CACHE_MAX_ENTRIES", "cache-max-entries", "max entries of the lru cache", intOption(func(c *Config) *int { return &c.Cache.MaxEntries })},
+	{"ISUCON_CACHE_REDIS_ADDR", "cache-redis-addr", "host:port of the redis cache", stringOption(func(c *Config) *string { return &c.Cache.RedisAddr })},
+	{"ISUCON_CACHE_REDIS_PASSWORD", "cache-redis-password", "password of the redis cache", stringOption(func(c *Config) *string { return &c.Cache.RedisPassword })},
+	{"ISUCON_CACHE_REDIS_DB", "cache-redis-db", "database number of the redis cache (cleared on initialize)", intOption(func(c *Config) *int { return &c.Cache.RedisDB })},
+	{"ISUCON_CACHE_REDIS_POOL_SIZE", "cache-redis-pool-size", "idle connections kept to the redis cache", intOption(func(c *Config) *int { return &c.Cache.RedisPoolSize })},
+	{"ISUCON_CACHE_REDIS_MAX_OPEN", "cache-redis-max-open", "max open connections to the redis cache", intOption(func(c *Config) *int { return &c.Cache.RedisMaxOpen })},
+	{"ISUCON_CACHE_REDIS_TIMEOUT_MS", "cache-redis-timeout-ms", "timeout of a redis cache command", intOption(func(c *Config) *int { return &c.Cache.RedisTimeoutMs })},
+	{"ISUCON_COORDINATE_FLUSH_INTERVAL_MS", "coordinate-flush-interval-ms", "interval to write buffered chair coordinates (write each one if 0)", intOption(func(c *Config) *int { return &c.Coordinate.FlushIntervalMs })},
+	{"ISUCON_COORDINATE_DURABILITY", "coordinate-durability", "when to respond to buffered chair coordinates (async, sync)", stringOption(func(c *Config) *string { return &c.Coordinate.Durability })},
+	{"ISUCON_COORDINATE_MAX_BUFFERED_LOCATIONS", "coordinate-max-buffered-locations", "buffered chair coordinates to write without waiting for the interval", intOption(func(c *Config) *int { return &c.Coordinate.MaxBufferedLocations })},
+	{"ISUCON_RATE_LIMITS", "rate-limits", "per-route rate limits", stringOption(func(c *Config) *string { return &c.RateLimits })},
+	{"ISUCON_ERROR_LOG_SAMPLE_RATE", "error-log-sample-rate", "ratio of logged 4xx responses", floatOption(func(c *Config) *float64 { return &c.ErrorLogSampleRate })},
+	{"ISUCON_RECORD_FILE", "record-file", "JSONL file to record API traffic to (disabled if empty)", stringOption(func(c *Config) *string { return &c.RecordFile })},
+	{"ISUCON_AUTO_MIGRATE", "auto-migrate", "apply pending schema migrations on startup", boolOption(func(c *Config) *bool { return &c.AutoMigrate })},
+}
+
+// args は os.Args[1:]
+func loadConfig(args []string) (Config, error) {
+	c := defaultConfig()
+
+	fs := flag.NewFlagSet("isuride", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("ISUCON_CONFIG_FILE"), "path to a JSON config file")
+	flagValues := map[string]*string{}
+	for _, opt := range configOptions {
+		flagValues[opt.Flag] = fs.String(opt.Flag, "", opt.Usage+" (env "+opt.Env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *configFile != "" {
+		b, err := os.ReadFile(*configFile)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return c, fmt.Errorf("failed to parse %s: %w", *configFile, err)
+		}
+	}
+
+	for _, opt := range configOptions {
+		v, ok := os.LookupEnv(opt.Env)
+		if !ok || v == "" {
+			continue
+		}
+		if err := opt.Set(&c, v); err != nil {
+			return c, fmt.Errorf("invalid %s: %w", opt.Env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range configOptions {
+			if opt.Flag == f.Name && flagErr == nil {
+				if err := opt.Set(&c, *flagValues[opt.Flag]); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", opt.Flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return c, flagErr
+	}
+
+	return c, c.validate()
+}
+
+func (c Config) validate() error {
+	var errs []error
+	if c.Listen == "" {
+		errs = append(errs, errors.New("listen is required"))
+	}
+	if c.CacheSizeMB <= 0 {
+		errs = append(errs, errors.New("cache_size_mb must be positive"))
+	}
+	if c.SettingsReloadIntervalMs < 0 {
+		errs = append(errs, errors.New("settings_reload_interval_ms must not be negative"))
+	}
+	if c.BusinessMetricsIntervalMs <= 0 {
+		errs = append(errs, errors.New("business_metrics_interval_ms must be positive"))
+	}
+	if c.ShutdownDelayMs < 0 || c.ShutdownTimeoutMs < 0 {
+		errs = append(errs, errors.New("shutdown_delay_ms and shutdown_timeout_ms must not be negative"))
+	}
+	if c.DB.Port <= 0 || c.DB.Port > 65535 {
+		errs = append(errs, fmt.Errorf("db.port is out of range: %d", c.DB.Port))
+	}
+	if c.DB.MaxOpenConns <= 0 {
+		errs = append(errs, errors.New("db.max_open_conns must be positive"))
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("db.max_idle_conns must be between 0 and db.max_open_conns"))
+	}
+	if c.DB.ReplicaMaxLagSeconds < 0 {
+		errs = append(errs, errors.New("db.replica_max_lag_seconds must not be negative"))
+	}
+	if c.DB.ReplicaCheckIntervalMs <= 0 {
+		errs = append(errs, errors.New("db.replica_check_interval_ms must be positive"))
+	}
+	if (c.Admin.User == "") != (c.Admin.Password == "") {
+		errs = append(errs, errors.New("admin.user and admin.password must be set together"))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls.cert and tls.key must be set together"))
+	}
+	if c.TLS.Cert != "" && (c.TLS.Listen == "" || c.TLS.Listen == c.Listen) {
+		errs = append(errs, errors.New("tls.listen must be set and differ from listen when tls.cert is set"))
+	}
+	switch c.Internal.Guard {
+	case "auto", "loopback", "none":
+	case "secret":
+		if c.Internal.Secret == "" {
+			errs = append(errs, errors.New("internal.secret is required when internal.guard is secret"))
+		}
+	case "mtls":
+		if c.TLS.Cert == "" || c.TLS.ClientCA == "" {
+			errs = append(errs, errors.New("tls.cert, tls.key and tls.client_ca are required when internal.guard is mtls"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown internal.guard: %s", c.Internal.Guard))
+	}
+	switch c.Cache.Backend {
+	case "freecache", "none":
+	case "lru":
+		if c.Cache.MaxEntries <= 0 {
+			errs = append(errs, errors.New("cache.max_entries must be positive when cache.backend is lru"))
+		}
+	case "redis":
+		if c.Cache.RedisAddr == "" {
+			errs = append(errs, errors.New("cache.redis_addr is required when cache.backend is redis"))
+		}
+		if c.Cache.RedisTimeoutMs <= 0 {
+			errs = append(errs, errors.New("cache.redis_timeout_ms must be positive"))
+		}
+		if c.Cache.RedisMaxOpen < c.Cache.RedisPoolSize {
+			errs = append(errs, errors.New("cache.redis_max_open must not be less than cache.redis_pool_size"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown cache.backend: %s", c.Cache.Backend))
+	}
+	if c.Coordinate.FlushIntervalMs < 0 {
+		errs = append(errs, errors.New("coordinate.flush_interval_ms must not be negative"))
+	}
+	switch c.Coordinate.Durability {
+	case "async", "sync":
+	default:
+		errs = append(errs, fmt.Errorf("unknown coordinate.durability: %s", c.Coordinate.Durability))
+	}
+	if c.Coordinate.MaxBufferedLocations <= 0 {
+		errs = append(errs, errors.New("coordinate.max_buffered_locations must be positive"))
+	}
+	switch c.Trace.Exporter {
+	case "", "otlp", "stdout":
+	case "file":
+		if c.Trace.File == "" {
+			errs = append(errs, errors.New("trace.file is required when trace.exporter is file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace.exporter: %s", c.Trace.Exporter))
+	}
+	if c.Trace.SampleRatio < 0 || c.Trace.SampleRatio > 1 {
+		errs = append(errs, errors.New("trace.sample_ratio must be between 0 and 1"))
+	}
+	if c.ErrorLogSampleRate < 0 || c.ErrorLogSampleRate > 1 {
+		errs = append(errs, errors.New("error_log_sample_rate must be between 0 and 1"))
+	}
+	for _, entry := range splitRateLimits(c.RateLimits) {
+		if _, err := parseRateLimit(entry); err != nil {
+			errs = append(errs, fmt.Errorf("invalid rate_limits entry %q: %w", entry, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// 椅子の位置をメモリに溜めてまとめて書き込む。setup で config.Coordinate.FlushIntervalMs が 0 でなければ作る。
+// nil なら POST /api/chair/coordinate は受け取るたびに書き込む。
+// 溜めた位置はプロセスごとに持つので、アプリケーションサーバーを並べるときは同じ椅子のリクエストが
+// 同じサーバーに届くようにするか、flush_interval_ms を 0 にする
+var coordinateBuffer *chairCoordinateBuffer
+
+var errCoordinateBufferReset = errors.New("buffered coordinates were discarded by initialize")
+
+var errCoordinateBufferFull = errors.New("too many buffered coordinates")
+
+// 溜めている間はメモリ上の位置が正しい。DBの位置は最後に書き込んだ時点のもの
+type bufferedChair struct {
+	// 同じ椅子の位置は1つずつ処理する
+	mu     sync.Mutex
+	loaded bool
+	// まだ一度も位置を送ってきていなければ nil
+	position *Coordinate
+}
+
+// 次にまとめて書き込む分
+type coordinateBatch struct {
+	moves map[string]*ChairMove
+	// 最初に溜めた順。書き込む順を毎回同じにする
+	chairIDs  []string
+	rides     map[string]*rideDistance
+	locations int
+	// 書き込み終わると閉じる。err と mergedInto はそのあとに読む
+	done chan struct{}
+	err  error
+	// 書き込めなかった分と合わせて、この分に入れ直された。位置は失われていない
+	mergedInto *coordinateBatch
+}
+
+func newCoordinateBatch() *coordinateBatch {
+	return &coordinateBatch{
+		moves: map[string]*ChairMove{},
+		rides: map[string]*rideDistance{},
+		done:  make(chan struct{}),
+	}
+}
+
+// 書き込めなかった b のあとに next を続ける。b と next を待っている側は、合わせた分の完了を待つ
+func (b *coordinateBatch) merge(next *coordinateBatch) *coordinateBatch {
+	merged := newCoordinateBatch()
+	merged.done = next.done
+	b.mergedInto = merged
+	next.mergedInto = merged
+	for _, batch := range []*coordinateBatch{b, next} {
+		for _, chairID := range batch.chairIDs {
+			move := batch.moves[chairID]
+			m, ok := merged.moves[chairID]
+			if !ok {
+				m = &ChairMove{ChairID: chairID}
+				merged.moves[chairID] = m
+				merged.chairIDs = append(merged.chairIDs, chairID)
+			}
+			m.Locations = append(m.Locations, move.Locations...)
+			m.Distance += move.Distance
+			m.LoadedDistance += move.LoadedDistance
+			if !move.MovedAt.IsZero() {
+				m.MovedAt = move.MovedAt
+			}
+		}
+		for rideID, ride := range batch.rides {
+			r, ok := merged.rides[rideID]
+			if !ok {
+				r = &rideDistance{}
+				merged.rides[rideID] = r
+			}
+			r.PickupDistance += ride.PickupDistance
+			r.LoadedDistance += ride.LoadedDistance
+		}
+		merged.locations += batch.locations
+	}
+	return merged
+}
+
+type chairCoordinateBuffer struct {
+	store        *Store
+	maxLocations int
+
+	mu      sync.Mutex
+	chairs  map[string]*bufferedChair
+	pending *coordinateBatch
+
+	// 書き込みと reset が重ならないようにする
+	flushMu sync.Mutex
+	full    chan struct{}
+}
+
+func newChairCoordinateBuffer(store *Store, maxLocations int) *chairCoordinateBuffer {
+	return &chairCoordinateBuffer{
+		store:        store,
+		maxLocations: maxLocations,
+		chairs:       map[string]*bufferedChair{},
+		pending:      newCoordinateBatch(),
+		full:         make(chan struct{}, 1),
+	}
+}
+
+// 椅子をロックして返す。呼び出し側で mu.Unlock する。初めて触る椅子は位置をDBから読む
+func (b *chairCoordinateBuffer) lockChair(ctx context.Context, chairID string) (*bufferedChair, error) {
+	b.mu.Lock()
+	c, ok := b.chairs[chairID]
+	if !ok {
+		c = &bufferedChair{}
+		b.chairs[chairID] = c
+	}
+	b.mu.Unlock()
+
+	c.mu.Lock()
+	if c.loaded {
+		return c, nil
+	}
+	chair, err := b.store.Chairs.Get(ctx, chairID)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	if chair.Latitude.Valid && chair.Longitude.Valid {
+		c.position = &Coordinate{Latitude: int(chair.Latitude.Int64), Longitude: int(chair.Longitude.Int64)}
+	}
+	c.loaded = true
+	return c, nil
+}
+
+// このサーバーが受け取った椅子の最新の位置。DBにはまだ書き込まれていないことがある。
+// このサーバーに位置を送ってきていない椅子は false を返すので、DBの位置を使う
+func (b *chairCoordinateBuffer) position(chairID string) (Coordinate, bool) {
+	if b == nil {
+		return Coordinate{}, false
+	}
+	b.mu.Lock()
+	c, ok := b.chairs[chairID]
+	b.mu.Unlock()
+	if !ok {
+		return Coordinate{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded || c.position == nil {
+		return Coordinate{}, false
+	}
+	return *c.position, true
+}
+
+// ロックした椅子の位置を溜める。statuses は進行中のライドの、位置を受け取った時点のステータス。
+// 走行距離の数え方は addChairDistance と同じ。
+// 書き込めずに溜まり続けないよう、maxLocations に達していれば書き込めるまで断る
+func (b *chairCoordinateBuffer) add(c *bufferedChair, chairID string, to Coordinate, now time.Time, statuses map[string]string) (*coordinateBatch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := b.pending
+	if batch.locations >= b.maxLocations {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+		return nil, withStatus(http.StatusServiceUnavailable, errCoordinateBufferFull)
+	}
+	move, ok := batch.moves[chairID]
+	if !ok {
+		move = &ChairMove{ChairID: chairID}
+		batch.moves[chairID] = move
+		batch.chairIDs = append(batch.chairIDs, chairID)
+	}
+	move.Locations = append(move.Locations, ChairLocation{
+		ID:        ulid.Make().String(),
+		ChairID:   chairID,
+		Latitude:  to.Latitude,
+		Longitude: to.Longitude,
+		CreatedAt: now,
+	})
+	batch.locations++
+
+	if c.position != nil {
+		distance := myAbs(c.position.Latitude-to.Latitude) + myAbs(c.position.Longitude-to.Longitude)
+		loaded := false
+		for rideID, status := range statuses {
+			pickup, carrying := rideDistanceKind(status)
+			if !pickup && !carrying {
+				continue
+			}
+			ride, ok := batch.rides[rideID]
+			if !ok {
+				ride = &rideDistance{}
+				batch.rides[rideID] = ride
+			}
+			if carrying {
+				loaded = true
+				ride.LoadedDistance += distance
+			} else {
+				ride.PickupDistance += distance
+			}
+		}
+		move.Distance += distance
+		if loaded {
+			move.LoadedDistance += distance
+		}
+		move.MovedAt = now
+	}
+	c.position = &to
+
+	if batch.locations >= b.maxLocations {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return batch, nil
+}
+
+// batch の位置が書き込まれるまで待つ。書き込めずに次の分へ戻されたときは、戻した先の完了を待つ。
+// 呼び出し側が送り直すと、戻された位置と合わせて二重に記録されるため、ここではエラーにしない
+func (b *chairCoordinateBuffer) wait(ctx context.Context, batch *coordinateBatch) error {
+	for {
+		select {
+		case <-batch.done:
+			if batch.mergedInto == nil {
+				return batch.err
+			}
+			batch = batch.mergedInto
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// 溜まっている分を1つのトランザクションで書き込む。失敗したら次に書き込む分の前に戻す
+func (b *chairCoordinateBuffer) flush(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = newCoordinateBatch()
+	b.mu.Unlock()
+
+	if batch.locations == 0 {
+		close(batch.done)
+		return nil
+	}
+
+	err := b.store.InTx(ctx, func(tx Repositories) error {
+		moves := make([]ChairMove, 0, len(batch.chairIDs))
+		for _, chairID := range batch.chairIDs {
+			moves = append(moves, *batch.moves[chairID])
+		}
+		if err := tx.Chairs.ApplyMoves(ctx, moves); err != nil {
+			return err
+		}
+		for _, rideID := range sortedKeys(batch.rides) {
+			ride := batch.rides[rideID]
+			if err := tx.Rides.AddDistance(ctx, rideID, ride.PickupDistance, ride.LoadedDistance); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.mu.Lock()
+		b.pending = batch.merge(b.pending)
+		b.mu.Unlock()
+	}
+	batch.err = err
+	close(batch.done)
+	return err
+}
+
+// ctx が切れるまで、interval ごとか溜まりすぎたときに書き込む。
+// 停止時の最後の書き込みは、リクエストを受け付け終わってから shutdown で行う
+func (b *chairCoordinateBuffer) start(ctx context.Context, interval time.Duration) {
+	startBackgroundWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-b.full:
+			}
+			if err := b.flush(ctx); err != nil {
+				slog.Error("failed to flush chair coordinates", "error", err)
+			}
+		}
+	})
+}
+
+// DBを作り直したときに、溜めている位置とメモリ上の位置を捨てる
+func (b *chairCoordinateBuffer) reset() {
+	if b == nil {
+		return
+	}
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending.err = errCoordinateBufferReset
+	close(b.pending.done)
+	b.pending = newCoordinateBatch()
+	b.chairs = map[string]*bufferedChair{}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+
+	"github.com/felixge/fgprof"
+)
+
+// config.DebugAddr(例: localhost:6060)を設定すると、プロファイリング用のサーバーを別ポートで立てる。
+// 外部に公開しないよう、ループバックアドレスで待ち受けること
+func startDebugServer(addr string) *http.Server {
+	if addr == "" {
+		return nil
+	}
+
+	expvar.Publish("db", expvar.Func(func() any { return db.Stats() }))
+	expvar.Publish("cache", expvar.Func(func() any {
+		stats := cache.Stats()
+		return map[string]any{
+			"backend":  config.Cache.Backend,
+			"entries":  stats.Entries,
+			"hits":     stats.Hits,
+			"misses":   stats.Misses,
+			"hit_rate": stats.HitRate(),
+		}
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	// runtime/traceの取得。?seconds=5 のように指定する
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/fgprof", fgprof.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rpprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	mux.HandleFunc("/debug/db", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, db.Stats())
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		slog.Info("Debug server listening on " + addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("debug server stopped", "error", err)
+		}
+	}()
+	return server
+}
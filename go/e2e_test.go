@@ -0,0 +1,1190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/isucon/isucon14/webapp/go/internal/paymentmock"
+	"github.com/isucon/isucon14/webapp/go/internal/redismock"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// MySQL互換のインメモリDB(go-mysql-server)をプロセス内で起動する。テーブルは作らない
+func startEmptyTestDB(t *testing.T) *net.TCPAddr {
+	t.Helper()
+
+	pro := memory.NewDBProvider(memory.NewDatabase("isuride"))
+	engine := sqle.NewDefault(pro)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.NewServer(server.Config{Protocol: "tcp", Listener: l}, engine, sql.NewContext, memory.NewSessionBuilder(pro), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(func() { s.Close() })
+
+	return l.Addr().(*net.TCPAddr)
+}
+
+// MySQL互換のインメモリDB(go-mysql-server)をプロセス内で起動して、スキーマを作る
+func startTestDB(t *testing.T) (host string, port int) {
+	t.Helper()
+
+	addr := startEmptyTestDB(t)
+	conn, err := sqlx.Open("mysql", fmt.Sprintf("root@tcp(%s)/isuride?parseTime=true", addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 本番の初期化と同じ順にスキーマを作る。3-initial-data.sql.gz は大きいので使わない
+	ctx := context.Background()
+	if _, err := migrateUp(ctx, conn, baselineMigrationVersion); err != nil {
+		t.Fatal(err)
+	}
+	execSQLFile(t, conn, "../sql/2-master-data.sql")
+	if _, err := migrateUp(ctx, conn, latestMigrationVersion()); err != nil {
+		t.Fatal(err)
+	}
+	return addr.IP.String(), addr.Port
+}
+
+func execSQLFile(t *testing.T, conn *sqlx.DB, path string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range splitSQLStatements(string(b)) {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v\n%s", path, err, stmt)
+		}
+	}
+}
+
+// payment_mock と同じAPIを持つ決済サーバーを起動して、URLと一緒に返す
+func startPaymentMock(t *testing.T) (*paymentmock.Gateway, string) {
+	t.Helper()
+	pg := paymentmock.New()
+	server := httptest.NewServer(pg.Handler())
+	t.Cleanup(server.Close)
+	return pg, server.URL
+}
+
+// DBと決済サーバーを用意して setup() したアプリを起動する
+// configure でテストごとに設定を変えられる
+func startTestApp(t *testing.T, configure func(c *Config)) (*httptest.Server, *paymentmock.Gateway) {
+	t.Helper()
+
+	host, port := startTestDB(t)
+	pg, pgURL := startPaymentMock(t)
+
+	prevConfig := config
+	t.Cleanup(func() { config = prevConfig })
+	config = defaultConfig()
+	config.DB.Host = host
+	config.DB.Port = port
+	config.DB.User = "root"
+	config.DB.Password = ""
+	config.DB.Name = "isuride"
+	// 同じサーバーをレプリカとしても使い、読み取り専用のトランザクションの振り分けを通す
+	config.DB.ReplicaDSN = fmt.Sprintf("root@tcp(%s)/isuride", net.JoinHostPort(host, fmt.Sprint(port)))
+	// httptest はTLSなしなので、Secure属性を付けるとCookieが送られない
+	config.Session.InsecureCookie = true
+	if configure != nil {
+		configure(&config)
+	}
+
+	var err error
+	cache, err = newCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sqlx.Open("mysql", fmt.Sprintf("root@tcp(%s)/isuride", net.JoinHostPort(host, fmt.Sprint(port))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("UPDATE settings SET value = ? WHERE name = 'payment_gateway_url'", pgURL); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := httptest.NewServer(setup(ctx))
+	startedTime = time.Now()
+	t.Cleanup(func() {
+		app.Close()
+		cancel()
+		waitBackgroundWorkers(context.Background())
+		db.Close()
+		replicas.Close()
+		replicas = nil
+		coordinateBuffer = nil
+		apiKeyUsage = nil
+	})
+	return app, pg
+}
+
+// セッションCookieを保持するクライアント
+type testClient struct {
+	t       *testing.T
+	baseURL string
+	client  *http.Client
+	// すべてのリクエストに付けるヘッダ
+	header http.Header
+}
+
+func newTestClient(t *testing.T, app *httptest.Server) *testClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, baseURL: app.URL, client: &http.Client{Jar: jar}, header: http.Header{}}
+}
+
+// wantStatus 以外が返ってきたらテストを失敗させる。out が nil でなければレスポンスを読み込む
+func (c *testClient) do(method, path string, body any, wantStatus int, out any) {
+	c.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if res.StatusCode != wantStatus {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", method, path, res.StatusCode, wantStatus, b)
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			c.t.Fatalf("%s %s: %v: %s", method, path, err, b)
+		}
+	}
+}
+
+func (c *testClient) appNotificationStatus() string {
+	c.t.Helper()
+	res := &appGetNotificationResponse{}
+	c.do(http.MethodGet, "/api/app/notification", nil, http.StatusOK, res)
+	if res.Data == nil {
+		c.t.Fatal("app notification has no ride")
+	}
+	return res.Data.Status
+}
+
+func (c *testClient) chairNotification() *chairGetNotificationResponseData {
+	c.t.Helper()
+	res := &chairGetNotificationResponse{}
+	c.do(http.MethodGet, "/api/chair/notification", nil, http.StatusOK, res)
+	if res.Data == nil {
+		c.t.Fatal("chair notification has no ride")
+	}
+	return res.Data
+}
+
+func TestRideScenario(t *testing.T) {
+	t.Run("write-through", func(t *testing.T) {
+		testRideScenario(t, nil)
+	})
+	// 位置を溜めても、書き込まれてから応答すれば同じ結果になる
+	t.Run("buffered", func(t *testing.T) {
+		testRideScenario(t, func(c *Config) {
+			c.Coordinate.FlushIntervalMs = 10
+			c.Coordinate.Durability = "sync"
+		})
+	})
+	// 複数のアプリケーションサーバーで共有するキャッシュを、代役のサーバーで動かす
+	t.Run("redis cache", func(t *testing.T) {
+		addr := startTestRedis(t)
+		testRideScenario(t, func(c *Config) {
+			c.Cache.Backend = "redis"
+			c.Cache.RedisAddr = addr
+		})
+		if stats := cache.Stats(); stats.Hits == 0 || stats.Entries == 0 {
+			t.Errorf("cache stats = %+v, want hits and entries", stats)
+		}
+	})
+}
+
+func startTestRedis(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go redismock.New().Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+func testRideScenario(t *testing.T, configure func(c *Config)) {
+	app, pg := startTestApp(t, configure)
+
+	owner := newTestClient(t, app)
+	chair := newTestClient(t, app)
+	user := newTestClient(t, app)
+
+	// 登録
+	ownerRes := &ownerPostOwnersResponse{}
+	owner.do(http.MethodPost, "/api/owner/owners", &ownerPostOwnersRequest{Name: "e2e-owner"}, http.StatusCreated, ownerRes)
+	chairRes := &chairPostChairsResponse{}
+	chair.do(http.MethodPost, "/api/chair/chairs", &chairPostChairsRequest{
+		Name:               "e2e-chair",
+		Model:              "AeroSeat",
+		ChairRegisterToken: ownerRes.ChairRegisterToken,
+	}, http.StatusCreated, chairRes)
+	if chairRes.OwnerID != ownerRes.ID {
+		t.Fatalf("chair owner = %s, want %s", chairRes.OwnerID, ownerRes.ID)
+	}
+	user.do(http.MethodPost, "/api/app/users", &appPostUsersRequest{
+		Username:    "e2e-user",
+		FirstName:   "Taro",
+		LastName:    "Isucon",
+		DateOfBirth: "2000-01-01",
+	}, http.StatusCreated, nil)
+
+	const paymentToken = "e2e-payment-token"
+	user.do(http.MethodPost, "/api/app/payment-methods", &appPostPaymentMethodsRequest{Token: paymentToken}, http.StatusNoContent, nil)
+
+	// 椅子が配車を受け付ける
+	chair.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: true}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 0, Longitude: 0}, http.StatusOK, nil)
+
+	// 配車リクエスト
+	pickup := Coordinate{Latitude: 3, Longitude: 4}
+	destination := Coordinate{Latitude: 13, Longitude: 14}
+	rideRes := &appPostRidesResponse{}
+	user.do(http.MethodPost, "/api/app/rides", &appPostRidesRequest{
+		PickupCoordinate:      &pickup,
+		DestinationCoordinate: &destination,
+	}, http.StatusAccepted, rideRes)
+	// 初回登録クーポン(3000円)で初乗り運賃だけになる
+	if want := currentSettings().InitialFare; rideRes.Fare != want {
+		t.Errorf("fare = %d, want %d", rideRes.Fare, want)
+	}
+	if status := user.appNotificationStatus(); status != "MATCHING" {
+		t.Errorf("status = %s, want MATCHING", status)
+	}
+
+	// マッチング
+	internal := newTestInternalClient(t, app)
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	notification := chair.chairNotification()
+	if notification.RideID != rideRes.RideID || notification.Status != "MATCHING" {
+		t.Fatalf("chair notification = %+v, want ride %s MATCHING", notification, rideRes.RideID)
+	}
+
+	// 迎えに行く
+	chair.do(http.MethodPost, "/api/chair/rides/"+rideRes.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 2, Longitude: 2}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &pickup, http.StatusOK, nil)
+
+	// 到着前は評価できない
+	user.do(http.MethodPost, "/api/app/rides/"+rideRes.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusBadRequest, nil)
+
+	// 乗せて目的地まで運ぶ
+	chair.do(http.MethodPost, "/api/chair/rides/"+rideRes.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 8, Longitude: 9}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &destination, http.StatusOK, nil)
+
+	// 通知は取りこぼさずに順番に届く
+	for _, want := range []string{"ENROUTE", "PICKUP", "CARRYING", "ARRIVED"} {
+		if status := user.appNotificationStatus(); status != want {
+			t.Errorf("app status = %s, want %s", status, want)
+		}
+		if n := chair.chairNotification(); n.Status != want {
+			t.Errorf("chair status = %s, want %s", n.Status, want)
+		}
+	}
+
+	// 評価すると決済される
+	comment := "快適でした"
+	user.do(http.MethodPost, "/api/app/rides/"+rideRes.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5, reviewInput: reviewInput{Comment: &comment}}, http.StatusOK, nil)
+	if payments := pg.Payments(paymentToken); len(payments) != 1 || payments[0] != rideRes.Fare {
+		t.Errorf("payments = %v, want [%d]", payments, rideRes.Fare)
+	}
+	if status := user.appNotificationStatus(); status != "COMPLETED" {
+		t.Errorf("status = %s, want COMPLETED", status)
+	}
+	// 二重に評価・決済はできない
+	user.do(http.MethodPost, "/api/app/rides/"+rideRes.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 1}, http.StatusBadRequest, nil)
+	if payments := pg.Payments(paymentToken); len(payments) != 1 {
+		t.Errorf("payments = %v, want 1 payment", payments)
+	}
+
+	// 評判は決済と同時に集計され、ログインしていれば見られる
+	reputation := &getReputationResponse{}
+	user.do(http.MethodGet, "/api/app/reputations/chair/"+chairRes.ID, nil, http.StatusOK, reputation)
+	if reputation.RatingCount != 1 || reputation.AverageRating != 5 || len(reputation.RecentReviews) != 1 || reputation.RecentReviews[0].Comment != comment {
+		t.Errorf("chair reputation = %+v, want one review with rating 5", reputation)
+	}
+	owner.do(http.MethodGet, "/api/owner/reputations/chair/"+chairRes.ID, nil, http.StatusOK, nil)
+	owner.do(http.MethodGet, "/api/owner/reputations/user/"+chairRes.ID, nil, http.StatusForbidden, nil)
+	newTestClient(t, app).do(http.MethodGet, "/api/app/reputations/chair/"+chairRes.ID, nil, http.StatusUnauthorized, nil)
+
+	// 売上はクーポン割引前の運賃で計上される
+	wantSales := calculateFare(pickup.Latitude, pickup.Longitude, destination.Latitude, destination.Longitude)
+	sales := &ownerGetSalesResponse{}
+	owner.do(http.MethodGet, "/api/owner/sales", nil, http.StatusOK, sales)
+	if sales.TotalSales != wantSales {
+		t.Errorf("total sales = %d, want %d", sales.TotalSales, wantSales)
+	}
+	if len(sales.Chairs) != 1 || sales.Chairs[0].ID != chairRes.ID || sales.Chairs[0].Sales != wantSales {
+		t.Errorf("chair sales = %+v, want %s: %d", sales.Chairs, chairRes.ID, wantSales)
+	}
+}
+
+func TestReadReplicaRouting(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+	owner, ownerRes := newTestOwner(t, app, "e2e-owner")
+	newTestChair(t, app, ownerRes, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+
+	// 椅子を登録したばかりのオーナーはプライマリから読む
+	primaryReads := testutil.ToFloat64(readRoutes.WithLabelValues("primary"))
+	owner.do(http.MethodGet, "/api/owner/sales", nil, http.StatusOK, nil)
+	if got := testutil.ToFloat64(readRoutes.WithLabelValues("primary")); got != primaryReads+1 {
+		t.Errorf("primary reads = %v, want %v", got, primaryReads+1)
+	}
+	// 固定が切れればレプリカから読み、Store を通らない書き込みのあとはまたプライマリから読む
+	replicas.pinned.Clear()
+	replicas.pinnedAllUntil.Store(0)
+	if err := cache.Clear(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	replicaReads := testutil.ToFloat64(readRoutes.WithLabelValues("replica"))
+	owner.do(http.MethodGet, "/api/owner/sales", nil, http.StatusOK, nil)
+	if got := testutil.ToFloat64(readRoutes.WithLabelValues("replica")); got != replicaReads+1 {
+		t.Errorf("replica reads = %v, want %v", got, replicaReads+1)
+	}
+	owner.do(http.MethodPost, "/api/owner/api-keys", &ownerPostAPIKeysRequest{Name: "pin", Scopes: []string{"owner:read"}}, http.StatusCreated, nil)
+	owner.do(http.MethodGet, "/api/owner/sales", nil, http.StatusOK, nil)
+	if got := testutil.ToFloat64(readRoutes.WithLabelValues("replica")); got != replicaReads+1 {
+		t.Errorf("replica reads after write = %v, want %v", got, replicaReads+1)
+	}
+}
+
+func TestChairDistances(t *testing.T) {
+	t.Run("write-through", func(t *testing.T) {
+		testChairDistances(t, nil)
+	})
+	t.Run("buffered", func(t *testing.T) {
+		testChairDistances(t, func(c *Config) {
+			c.Coordinate.FlushIntervalMs = 10
+			c.Coordinate.Durability = "sync"
+		})
+	})
+}
+
+func testChairDistances(t *testing.T, configure func(c *Config)) {
+	app, _ := startTestApp(t, configure)
+	owner, ownerRes := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, ownerRes, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	ride := newTestUser(t, app, "e2e-user").requestRide(Coordinate{Latitude: 3, Longitude: 4}, Coordinate{Latitude: 13, Longitude: 14})
+	newTestInternalClient(t, app).do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+
+	// (0,0) → (2,2) → (3,4) → (8,9) → (13,14)
+	chair.do(http.MethodPost, "/api/chair/rides/"+ride.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 2, Longitude: 2}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 3, Longitude: 4}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/rides/"+ride.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 8, Longitude: 9}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 13, Longitude: 14}, http.StatusOK, nil)
+
+	chairs := &ownerGetChairResponse{}
+	owner.do(http.MethodGet, "/api/owner/chairs", nil, http.StatusOK, chairs)
+	if len(chairs.Chairs) != 1 || chairs.Chairs[0].TotalDistance != 4+3+10+10 {
+		t.Fatalf("owner chairs = %+v, want total_distance %d", chairs.Chairs, 4+3+10+10)
+	}
+	// ENROUTE のあとの2区間が迎車、CARRYING のあとの2区間が乗車
+	if c := chairs.Chairs[0]; c.LoadedDistance != 10+10 || c.EmptyDistance != 4+3 {
+		t.Errorf("loaded_distance = %d, empty_distance = %d, want %d, %d", c.LoadedDistance, c.EmptyDistance, 10+10, 4+3)
+	}
+
+	// 積み上げた走行距離は、位置の履歴から数え直した値と一致する
+	mismatches, err := verifyChairDistances(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range mismatches {
+		t.Errorf("distance mismatch: %s", m)
+	}
+	// 数え直して書き込んでも値は変わらない
+	ledger, err := rebuildChairDistances(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := &ownerGetChairResponse{}
+	owner.do(http.MethodGet, "/api/owner/chairs", nil, http.StatusOK, rebuilt)
+	if rebuilt.Chairs[0].TotalDistance != chairs.Chairs[0].TotalDistance || rebuilt.Chairs[0].LoadedDistance != chairs.Chairs[0].LoadedDistance {
+		t.Errorf("rebuilt chairs = %+v, want %+v", rebuilt.Chairs, chairs.Chairs)
+	}
+	if r := ledger.Rides[ride.RideID]; r == nil || r.PickupDistance != 4+3 || r.LoadedDistance != 10+10 {
+		t.Errorf("ride distance = %+v, want pickup %d, loaded %d", r, 4+3, 10+10)
+	}
+}
+
+func TestBackfillChairDistances(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+	_, ownerRes := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, ownerRes, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	user := newTestUser(t, app, "e2e-user")
+	pickup := Coordinate{Latitude: 3, Longitude: 4}
+	destination := Coordinate{Latitude: 13, Longitude: 14}
+	ride := user.requestRide(pickup, destination)
+	newTestInternalClient(t, app).do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	chair.driveRide(ride.RideID, pickup, destination)
+
+	// 初期データ向けのマイグレーションも、集合演算で同じ値を埋める
+	if _, err := db.Exec("UPDATE chairs SET total_distance = 0, loaded_distance = 0, latitude = NULL, longitude = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE rides SET pickup_distance = 0, loaded_distance = 0"); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Connx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, m := range migrations {
+		if m.Name == "backfill_chair_distances" {
+			if err := execMigrationScript(context.Background(), conn, m.Up); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	mismatches, err := verifyChairDistances(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range mismatches {
+		t.Errorf("distance mismatch after backfill: %s", m)
+	}
+	var distance int
+	if err := db.Get(&distance, "SELECT total_distance FROM chairs"); err != nil || distance != 7+20 {
+		t.Errorf("backfilled total_distance = %d, %v, want %d", distance, err, 7+20)
+	}
+}
+
+func TestPaymentGatewayFailure(t *testing.T) {
+	app, pg := startTestApp(t, nil)
+	_, ownerRes := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, ownerRes, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	user := newTestUser(t, app, "e2e-user")
+	internal := newTestInternalClient(t, app)
+	pickup := Coordinate{Latitude: 0, Longitude: 0}
+	destination := Coordinate{Latitude: 10, Longitude: 0}
+	arrive := func() *appPostRidesResponse {
+		t.Helper()
+		ride := user.requestRide(pickup, destination)
+		internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+		chair.driveRide(ride.RideID, pickup, destination)
+		return ride
+	}
+	// 椅子が完了の通知を受け取るまでは、次のライドに割り当てられない
+	waitCompleted := func() {
+		t.Helper()
+		for range 10 {
+			if chair.chairNotification().Status == "COMPLETED" {
+				return
+			}
+		}
+		t.Fatal("chair was not notified of the completion")
+	}
+
+	// 決済されずにエラーが返ったら、リトライして1回だけ決済する
+	first := arrive()
+	pg.FailNext(2, false)
+	user.do(http.MethodPost, "/api/app/rides/"+first.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusOK, nil)
+	if payments := pg.Payments("e2e-user"); len(payments) != 1 || payments[0] != first.Fare {
+		t.Errorf("payments = %v, want [%d]", payments, first.Fare)
+	}
+	waitCompleted()
+
+	// エラーが返っても決済が済んでいれば、送り直さない
+	second := arrive()
+	pg.FailNext(1, true)
+	user.do(http.MethodPost, "/api/app/rides/"+second.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusOK, nil)
+	if payments := pg.Payments("e2e-user"); len(payments) != 2 {
+		t.Errorf("payments = %v, want 2", payments)
+	}
+	waitCompleted()
+
+	// リトライしても決済できなければ完了させず、あとで評価し直せる
+	third := arrive()
+	pg.FailNext(currentSettings().PaymentRetryCount+1, false)
+	user.do(http.MethodPost, "/api/app/rides/"+third.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusBadGateway, nil)
+	if status := user.appNotificationStatus(); status == "COMPLETED" {
+		t.Errorf("status = %s after the failed payment", status)
+	}
+	user.do(http.MethodPost, "/api/app/rides/"+third.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusOK, nil)
+	if payments := pg.Payments("e2e-user"); len(payments) != 3 {
+		t.Errorf("payments = %v, want 3", payments)
+	}
+}
+
+func TestOwnerAPIKeys(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+
+	owner := newTestClient(t, app)
+	owner.do(http.MethodPost, "/api/owner/owners", &ownerPostOwnersRequest{Name: "e2e-owner"}, http.StatusCreated, nil)
+
+	expiresAt := time.Now().Add(time.Hour).UnixMilli()
+	parent := &ownerPostAPIKeysResponse{}
+	owner.do(http.MethodPost, "/api/owner/api-keys", &ownerPostAPIKeysRequest{
+		Name:      "key-manager",
+		Scopes:    []string{"owner:api_keys"},
+		ExpiresAt: &expiresAt,
+	}, http.StatusCreated, parent)
+
+	bot := newTestClient(t, app)
+	bot.header.Set("Authorization", "Bearer "+parent.Key)
+	// 付与されていないスコープは使えず、付けたキーも作れない
+	bot.do(http.MethodGet, "/api/owner/sales", nil, http.StatusForbidden, nil)
+	bot.do(http.MethodPost, "/api/owner/api-keys", &ownerPostAPIKeysRequest{
+		Name:   "reader",
+		Scopes: []string{"owner:read"},
+	}, http.StatusForbidden, nil)
+	// 期限なしで頼んでも、発行したキーの期限までになる
+	child := &ownerPostAPIKeysResponse{}
+	bot.do(http.MethodPost, "/api/owner/api-keys", &ownerPostAPIKeysRequest{
+		Name:   "sub-manager",
+		Scopes: []string{"owner:api_keys"},
+	}, http.StatusCreated, child)
+	if child.ExpiresAt == nil || *child.ExpiresAt != expiresAt {
+		t.Errorf("child expires_at = %v, want %d", child.ExpiresAt, expiresAt)
+	}
+	// セッション管理はCookieでしかできない
+	bot.do(http.MethodGet, "/api/owner/sessions", nil, http.StatusForbidden, nil)
+}
+
+// 登録して、Cookieを持ったクライアントを返す
+func newTestOwner(t *testing.T, app *httptest.Server, name string) (*testClient, *ownerPostOwnersResponse) {
+	t.Helper()
+	c := newTestClient(t, app)
+	res := &ownerPostOwnersResponse{}
+	c.do(http.MethodPost, "/api/owner/owners", &ownerPostOwnersRequest{Name: name}, http.StatusCreated, res)
+	return c, res
+}
+
+// 登録して at で配車を受け付けている椅子
+func newTestChair(t *testing.T, app *httptest.Server, owner *ownerPostOwnersResponse, name string, at Coordinate) (*testClient, *chairPostChairsResponse) {
+	t.Helper()
+	c := newTestClient(t, app)
+	res := &chairPostChairsResponse{}
+	c.do(http.MethodPost, "/api/chair/chairs", &chairPostChairsRequest{
+		Name:               name,
+		Model:              "AeroSeat",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, res)
+	c.do(http.MethodPost, "/api/chair/coordinate", &at, http.StatusOK, nil)
+	c.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: true}, http.StatusNoContent, nil)
+	return c, res
+}
+
+// 登録して支払い方法も登録したユーザー。決済のトークンはユーザー名と同じ
+func newTestUser(t *testing.T, app *httptest.Server, username string) *testClient {
+	t.Helper()
+	c := newTestClient(t, app)
+	c.do(http.MethodPost, "/api/app/users", &appPostUsersRequest{
+		Username:    username,
+		FirstName:   "Taro",
+		LastName:    "Isucon",
+		DateOfBirth: "2000-01-01",
+	}, http.StatusCreated, nil)
+	c.do(http.MethodPost, "/api/app/payment-methods", &appPostPaymentMethodsRequest{Token: username}, http.StatusNoContent, nil)
+	return c
+}
+
+func (c *testClient) requestRide(pickup Coordinate, destination Coordinate) *appPostRidesResponse {
+	c.t.Helper()
+	res := &appPostRidesResponse{}
+	c.do(http.MethodPost, "/api/app/rides", &appPostRidesRequest{
+		PickupCoordinate:      &pickup,
+		DestinationCoordinate: &destination,
+	}, http.StatusAccepted, res)
+	return res
+}
+
+func newTestInternalClient(t *testing.T, app *httptest.Server) *testClient {
+	c := newTestClient(t, app)
+	c.header.Set("X-Real-IP", "127.0.0.1")
+	return c
+}
+
+func TestAdminReassignChair(t *testing.T) {
+	app, _ := startTestApp(t, func(c *Config) {
+		c.Admin.User = "admin"
+		c.Admin.Password = "secret"
+	})
+	admin := newTestClient(t, app)
+	admin.header.Set("Authorization", "Basic YWRtaW46c2VjcmV0")
+	internal := newTestInternalClient(t, app)
+
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	newTestChair(t, app, owner, "chair-a", Coordinate{Latitude: 0, Longitude: 0})
+	_, chairB := newTestChair(t, app, owner, "chair-b", Coordinate{Latitude: 100, Longitude: 100})
+	ride1 := newTestUser(t, app, "user-1").requestRide(Coordinate{Latitude: 1, Longitude: 1}, Coordinate{Latitude: 5, Longitude: 5})
+	newTestUser(t, app, "user-2").requestRide(Coordinate{Latitude: 99, Longitude: 99}, Coordinate{Latitude: 95, Longitude: 95})
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+
+	// 他のライドを運んでいる椅子と、配車を受け付けていない椅子には割り当てられない
+	admin.do(http.MethodPost, "/api/admin/rides/"+ride1.RideID+"/chair", &adminPostRideChairRequest{ChairID: chairB.ID}, http.StatusConflict, nil)
+	chairC, chairCRes := newTestChair(t, app, owner, "chair-c", Coordinate{Latitude: 2, Longitude: 2})
+	chairC.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: false}, http.StatusNoContent, nil)
+	admin.do(http.MethodPost, "/api/admin/rides/"+ride1.RideID+"/chair", &adminPostRideChairRequest{ChairID: chairCRes.ID}, http.StatusConflict, nil)
+
+	chairC.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: true}, http.StatusNoContent, nil)
+	admin.do(http.MethodPost, "/api/admin/rides/"+ride1.RideID+"/chair", &adminPostRideChairRequest{ChairID: chairCRes.ID, Reason: "test"}, http.StatusNoContent, nil)
+	if n := chairC.chairNotification(); n.RideID != ride1.RideID || n.Status != "MATCHING" {
+		t.Errorf("chair notification = %+v, want ride %s MATCHING", n, ride1.RideID)
+	}
+
+	// 割り当ての変更と監査ログは一緒に書き込まれる
+	logs := &adminGetAuditLogsResponse{}
+	admin.do(http.MethodGet, "/api/admin/audit-logs?target_type=ride&target_id="+ride1.RideID, nil, http.StatusOK, logs)
+	if len(logs.AuditLogs) != 1 || logs.AuditLogs[0].Action != "reassign_chair" || logs.AuditLogs[0].Actor != "admin" {
+		t.Errorf("audit logs = %+v, want one reassign_chair by admin", logs.AuditLogs)
+	}
+}
+
+// マッチング済みのライドを、迎えに行って目的地まで運ぶ
+func (c *testClient) driveRide(rideID string, pickup Coordinate, destination Coordinate) {
+	c.t.Helper()
+	c.do(http.MethodPost, "/api/chair/rides/"+rideID+"/status", &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil)
+	c.do(http.MethodPost, "/api/chair/coordinate", &pickup, http.StatusOK, nil)
+	c.do(http.MethodPost, "/api/chair/rides/"+rideID+"/status", &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil)
+	c.do(http.MethodPost, "/api/chair/coordinate", &destination, http.StatusOK, nil)
+}
+
+func TestFareFixedAtRideCreation(t *testing.T) {
+	app, pg := startTestApp(t, func(c *Config) {
+		c.Admin.User = "admin"
+		c.Admin.Password = "secret"
+	})
+	admin := newTestClient(t, app)
+	admin.header.Set("Authorization", "Basic YWRtaW46c2VjcmV0")
+	t.Cleanup(func() { runtimeSettings.Store(nil) })
+
+	owner, ownerRes := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, ownerRes, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	user := newTestUser(t, app, "e2e-user")
+	pickup := Coordinate{Latitude: 0, Longitude: 0}
+	destination := Coordinate{Latitude: 10, Longitude: 0}
+	ride := user.requestRide(pickup, destination)
+	newTestInternalClient(t, app).do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+
+	// 乗車中に運賃を上げても、このライドの請求額と売上は変わらない
+	admin.do(http.MethodPost, "/api/admin/settings", map[string]int{"initial_fare": 900, "fare_per_distance": 300}, http.StatusOK, nil)
+	chair.driveRide(ride.RideID, pickup, destination)
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 4}, http.StatusOK, nil)
+	if payments := pg.Payments("e2e-user"); len(payments) != 1 || payments[0] != ride.Fare {
+		t.Errorf("payments = %v, want [%d]", payments, ride.Fare)
+	}
+	sales := &ownerGetSalesResponse{}
+	owner.do(http.MethodGet, "/api/owner/sales", nil, http.StatusOK, sales)
+	if want := 500 + 100*10; sales.TotalSales != want {
+		t.Errorf("total sales = %d, want %d", sales.TotalSales, want)
+	}
+
+	// 新しいライドには変更後の運賃が使われる
+	estimate := &appPostRidesEstimatedFareResponse{}
+	user.do(http.MethodPost, "/api/app/rides/estimated-fare", &appPostRidesEstimatedFareRequest{
+		PickupCoordinate:      &pickup,
+		DestinationCoordinate: &destination,
+	}, http.StatusOK, estimate)
+	if want := 900 + 300*10; estimate.Fare != want {
+		t.Errorf("estimated fare = %d, want %d", estimate.Fare, want)
+	}
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	app, _ := startTestApp(t, func(c *Config) {
+		c.BusinessMetricsIntervalMs = 50
+	})
+	newTestUser(t, app, "e2e-user").requestRide(Coordinate{Latitude: 0, Longitude: 0}, Coordinate{Latitude: 10, Longitude: 0})
+
+	// スクレイプでは集計せず、定期的に集計した結果を返す
+	scrape := func() string {
+		req, err := http.NewRequest(http.MethodGet, app.URL+"/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Real-IP", "127.0.0.1")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(scrape(), "isuride_waiting_rides 1\n") {
+		if time.Now().After(deadline) {
+			t.Fatal("isuride_waiting_rides was not refreshed to 1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	// 本番モードでは /api/initialize を呼ばないので、初期化されたかどうかには頼らない
+	app, _ := startTestApp(t, func(c *Config) { c.Production = true })
+	startedTime = time.Time{}
+	client := newTestClient(t, app)
+
+	res := &healthResponse{}
+	client.do("GET", "/readyz", nil, http.StatusOK, res)
+	if res.Checks["schema"] != "ok" {
+		t.Errorf("schema check = %q", res.Checks["schema"])
+	}
+
+	// 最新のマイグレーションが適用されていなければ受けない
+	if _, err := migrateDown(context.Background(), db, latestMigrationVersion()-1); err != nil {
+		t.Fatal(err)
+	}
+	client.do("GET", "/readyz", nil, http.StatusServiceUnavailable, res)
+	if res.Checks["schema"] == "ok" {
+		t.Error("schema check passed with a pending migration")
+	}
+}
+
+func TestScheduledRideDispatch(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+	internal := newTestInternalClient(t, app)
+
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	chair, chairRes := newTestChair(t, app, owner, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	user := newTestUser(t, app, "e2e-user")
+	pickup := Coordinate{Latitude: 0, Longitude: 0}
+	destination := Coordinate{Latitude: 10, Longitude: 0}
+	scheduled := &appPostScheduledRidesResponse{}
+	user.do(http.MethodPost, "/api/app/scheduled-rides", &appPostScheduledRidesRequest{
+		PickupCoordinate:      &pickup,
+		DestinationCoordinate: &destination,
+		ScheduledAt:           time.Now().Add(11 * time.Minute).UnixMilli(),
+	}, http.StatusCreated, scheduled)
+
+	// 椅子がすぐ近くにいるうちは、まだ配車しない
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	list := &appGetScheduledRidesResponse{}
+	user.do(http.MethodGet, "/api/app/scheduled-rides", nil, http.StatusOK, list)
+	if len(list.ScheduledRides) != 1 || list.ScheduledRides[0].ID != scheduled.ScheduledRideID {
+		t.Fatalf("scheduled rides = %+v, want %s only", list.ScheduledRides, scheduled.ScheduledRideID)
+	}
+
+	// 一番近い椅子が迎えに来るまで11分以上かかるなら、予約時刻に間に合うように配車する
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 1000, Longitude: 1000}, http.StatusOK, nil)
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	user.do(http.MethodGet, "/api/app/scheduled-rides", nil, http.StatusOK, list)
+	if len(list.ScheduledRides) != 0 {
+		t.Errorf("scheduled rides = %+v, want none after dispatch", list.ScheduledRides)
+	}
+	notification := &appGetNotificationResponse{}
+	user.do(http.MethodGet, "/api/app/notification", nil, http.StatusOK, notification)
+	if notification.Data == nil || notification.Data.PickupCoordinate != pickup || notification.Data.DestinationCoordinate != destination {
+		t.Fatalf("app notification = %+v, want the scheduled ride", notification.Data)
+	}
+	if n := chair.chairNotification(); n.RideID != notification.Data.RideID {
+		t.Errorf("chair %s was not matched to the dispatched ride %s", chairRes.ID, notification.Data.RideID)
+	}
+
+	// 配車した予約は取り消せない
+	user.do(http.MethodDelete, "/api/app/scheduled-rides/"+scheduled.ScheduledRideID, nil, http.StatusConflict, nil)
+}
+
+func TestScheduledRideBusyChair(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+	internal := newTestInternalClient(t, app)
+
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, owner, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	other := newTestUser(t, app, "e2e-other")
+	ride := other.requestRide(Coordinate{Latitude: 0, Longitude: 0}, Coordinate{Latitude: 10, Longitude: 0})
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	if n := chair.chairNotification(); n.RideID != ride.RideID {
+		t.Fatalf("chair notification = %+v, want ride %s", n, ride.RideID)
+	}
+
+	// すぐ近くの椅子は他のライドを担当しているので、到着見込みが分からないものとして配車する
+	user := newTestUser(t, app, "e2e-user")
+	user.do(http.MethodPost, "/api/app/scheduled-rides", &appPostScheduledRidesRequest{
+		PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+		DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 0},
+		ScheduledAt:           time.Now().Add(11 * time.Minute).UnixMilli(),
+	}, http.StatusCreated, nil)
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+	list := &appGetScheduledRidesResponse{}
+	user.do(http.MethodGet, "/api/app/scheduled-rides", nil, http.StatusOK, list)
+	if len(list.ScheduledRides) != 0 {
+		t.Errorf("scheduled rides = %+v, want none after dispatch", list.ScheduledRides)
+	}
+}
+
+func TestScheduledRideOverlap(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+
+	user := newTestUser(t, app, "e2e-user")
+	schedule := func(at time.Time, wantStatus int) {
+		t.Helper()
+		user.do(http.MethodPost, "/api/app/scheduled-rides", &appPostScheduledRidesRequest{
+			PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+			DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 0},
+			ScheduledAt:           at.UnixMilli(),
+		}, wantStatus, nil)
+	}
+
+	base := time.Now().Add(2 * time.Hour)
+	schedule(base, http.StatusCreated)
+	schedule(base.Add(scheduledRideMinInterval-time.Minute), http.StatusConflict)
+	schedule(base.Add(-scheduledRideMinInterval+time.Minute), http.StatusConflict)
+	schedule(base.Add(scheduledRideMinInterval), http.StatusCreated)
+
+	// 走行中のライドがあれば、すぐ後の予約は受けないが、十分先なら受ける
+	user.requestRide(Coordinate{Latitude: 0, Longitude: 0}, Coordinate{Latitude: 10, Longitude: 0})
+	schedule(time.Now().Add(scheduledRideMinLead+time.Minute), http.StatusConflict)
+	schedule(time.Now().Add(time.Hour), http.StatusCreated)
+
+	list := &appGetScheduledRidesResponse{}
+	user.do(http.MethodGet, "/api/app/scheduled-rides", nil, http.StatusOK, list)
+	if len(list.ScheduledRides) != 3 {
+		t.Errorf("scheduled rides = %+v, want 3", list.ScheduledRides)
+	}
+}
+
+func TestRideShare(t *testing.T) {
+	// 位置を溜めたまま書き込まないようにして、共有リンクがメモリ上の位置を返すか確かめる
+	app, _ := startTestApp(t, func(c *Config) {
+		c.Coordinate.FlushIntervalMs = int(time.Hour / time.Millisecond)
+	})
+	internal := newTestInternalClient(t, app)
+	viewer := newTestClient(t, app)
+
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	chair, chairRes := newTestChair(t, app, owner, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	if err := coordinateBuffer.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	user := newTestUser(t, app, "e2e-user")
+	other := newTestUser(t, app, "e2e-other")
+	pickup := Coordinate{Latitude: 0, Longitude: 0}
+	destination := Coordinate{Latitude: 10, Longitude: 0}
+	ride := user.requestRide(pickup, destination)
+	internal.do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+
+	// 共有できるのは本人だけで、トークンはハッシュにして保存する
+	other.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusNotFound, nil)
+	share := &appPostRideShareResponse{}
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusCreated, share)
+	var stored int
+	if err := db.Get(&stored, "SELECT COUNT(*) FROM ride_shares WHERE token_hash = ?", hashToken(share.Token)); err != nil || stored != 1 {
+		t.Errorf("shares stored with the token hash = %d, %v", stored, err)
+	}
+	if err := db.Get(&stored, "SELECT COUNT(*) FROM ride_shares WHERE token_hash = ?", share.Token); err != nil || stored != 0 {
+		t.Errorf("shares stored with the plain token = %d, %v", stored, err)
+	}
+	viewer.do(http.MethodGet, "/api/share/unknown", nil, http.StatusNotFound, nil)
+
+	// まだ書き込んでいない位置も見える
+	chair.do(http.MethodPost, "/api/chair/rides/"+ride.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &Coordinate{Latitude: 0, Longitude: 3}, http.StatusOK, nil)
+	var dbLongitude int
+	if err := db.Get(&dbLongitude, "SELECT longitude FROM chairs WHERE id = ?", chairRes.ID); err != nil || dbLongitude != 0 {
+		t.Fatalf("chair longitude in db = %d, %v, want the flushed one", dbLongitude, err)
+	}
+	shared := &getSharedRideResponse{}
+	viewer.do(http.MethodGet, "/api/share/"+share.Token, nil, http.StatusOK, shared)
+	if shared.RideID != ride.RideID || shared.Status != "ENROUTE" || shared.Chair == nil || shared.Chair.Name != "e2e-chair" {
+		t.Errorf("shared ride = %+v", shared)
+	}
+	if shared.Chair != nil && (shared.Chair.CurrentCoordinate == nil || *shared.Chair.CurrentCoordinate != (Coordinate{Latitude: 0, Longitude: 3})) {
+		t.Errorf("shared chair coordinate = %+v, want the buffered one", shared.Chair.CurrentCoordinate)
+	}
+
+	// 止めたリンクは見られず、止めた後に発行したリンクは見られる
+	other.do(http.MethodDelete, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusNotFound, nil)
+	user.do(http.MethodDelete, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusNoContent, nil)
+	viewer.do(http.MethodGet, "/api/share/"+share.Token, nil, http.StatusGone, nil)
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusCreated, share)
+	viewer.do(http.MethodGet, "/api/share/"+share.Token, nil, http.StatusOK, nil)
+
+	// 通報はインシデントとして割り当て中の椅子と一緒に記録する
+	other.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/sos", &appPostRideSOSRequest{}, http.StatusNotFound, nil)
+	tooLong := strings.Repeat("a", maxIncidentMessageLength+1)
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/sos", &appPostRideSOSRequest{Message: &tooLong}, http.StatusBadRequest, nil)
+	message := "help"
+	sos := &appPostRideSOSResponse{}
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/sos", &appPostRideSOSRequest{
+		Message:    &message,
+		Coordinate: &Coordinate{Latitude: 0, Longitude: 3},
+	}, http.StatusCreated, sos)
+	incident := Incident{}
+	if err := db.Get(&incident, "SELECT * FROM incidents WHERE id = ?", sos.IncidentID); err != nil {
+		t.Fatal(err)
+	}
+	if incident.RideID != ride.RideID || incident.ChairID.String != chairRes.ID || incident.Kind != "SOS" || incident.Status != "OPEN" ||
+		incident.Message == nil || *incident.Message != "help" || incident.Longitude.Int64 != 3 {
+		t.Errorf("incident = %+v", incident)
+	}
+
+	// 完了したライドのリンクは見られず、新しく共有もできない
+	chair.do(http.MethodPost, "/api/chair/coordinate", &pickup, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/rides/"+ride.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/coordinate", &destination, http.StatusOK, nil)
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/evaluation", &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusOK, nil)
+	viewer.do(http.MethodGet, "/api/share/"+share.Token, nil, http.StatusGone, nil)
+	user.do(http.MethodPost, "/api/app/rides/"+ride.RideID+"/share", nil, http.StatusBadRequest, nil)
+}
+
+func TestRideWaypointOrder(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	chair, _ := newTestChair(t, app, owner, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	user := newTestUser(t, app, "e2e-user")
+	pickup := Coordinate{Latitude: 0, Longitude: 0}
+	first := Coordinate{Latitude: 5, Longitude: 0}
+	second := Coordinate{Latitude: 5, Longitude: 5}
+	destination := Coordinate{Latitude: 10, Longitude: 10}
+	ride := &appPostRidesResponse{}
+	user.do(http.MethodPost, "/api/app/rides", &appPostRidesRequest{
+		PickupCoordinate:      &pickup,
+		Waypoints:             []Coordinate{first, second},
+		DestinationCoordinate: &destination,
+	}, http.StatusAccepted, ride)
+	newTestInternalClient(t, app).do(http.MethodGet, "/api/internal/matching", nil, http.StatusNoContent, nil)
+
+	// 通知はステータスを1つずつ返すので、遷移のたびに読む
+	expect := func(status string, next *Coordinate) {
+		t.Helper()
+		n := chair.chairNotification()
+		if n.Status != status {
+			t.Fatalf("chair notification status = %s, want %s", n.Status, status)
+		}
+		if (n.NextWaypoint == nil) != (next == nil) || (next != nil && *n.NextWaypoint != *next) {
+			t.Fatalf("next waypoint = %v, want %v", n.NextWaypoint, next)
+		}
+	}
+	postStatus := func(status string) {
+		t.Helper()
+		chair.do(http.MethodPost, "/api/chair/rides/"+ride.RideID+"/status", &postChairRidesRideIDStatusRequest{Status: status}, http.StatusNoContent, nil)
+	}
+	moveTo := func(c Coordinate) {
+		t.Helper()
+		chair.do(http.MethodPost, "/api/chair/coordinate", &c, http.StatusOK, nil)
+	}
+
+	expect("MATCHING", &first)
+	postStatus("ENROUTE")
+	expect("ENROUTE", &first)
+	moveTo(pickup)
+	expect("PICKUP", &first)
+	postStatus("CARRYING")
+	expect("CARRYING", &first)
+
+	// 順番を飛ばした経由地や、経由地が残っている間の目的地では止まらない
+	moveTo(second)
+	moveTo(destination)
+	expect("CARRYING", &first)
+
+	moveTo(first)
+	expect("STOPOVER", &second)
+	// 経由地では出発するまで次の地点に着いたことにならない
+	moveTo(second)
+	expect("STOPOVER", &second)
+	postStatus("CARRYING")
+	expect("CARRYING", &second)
+	moveTo(second)
+	expect("STOPOVER", nil)
+	postStatus("CARRYING")
+	expect("CARRYING", nil)
+	moveTo(destination)
+	expect("ARRIVED", nil)
+}
+
+// jar に入っている Cookie の値
+func (c *testClient) cookie(path string, name string) string {
+	c.t.Helper()
+	u, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	c.t.Fatalf("cookie %s is not set for %s", name, path)
+	return ""
+}
+
+// Cookie を jar に入れず、指定したアクセストークンだけで呼ぶクライアント
+func newTestTokenClient(t *testing.T, app *httptest.Server, name string, token string) *testClient {
+	c := newTestClient(t, app)
+	c.header.Set("Cookie", name+"="+token)
+	return c
+}
+
+func TestSessionRefreshAndRevoke(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+
+	user := newTestUser(t, app, "e2e-user")
+	sessions := &getSessionsResponse{}
+	user.do(http.MethodGet, "/api/app/sessions", nil, http.StatusOK, sessions)
+	if len(sessions.Sessions) != 1 || !sessions.Sessions[0].Current {
+		t.Fatalf("sessions = %+v, want the current one only", sessions.Sessions)
+	}
+	sessionID := sessions.Sessions[0].ID
+
+	// リフレッシュするとアクセストークンもリフレッシュトークンも入れ替わり、古いものは使えなくなる
+	oldToken := user.cookie("/api/app/", "app_session")
+	oldRefreshToken := user.cookie("/api/app/session/refresh", "app_refresh")
+	user.do(http.MethodPost, "/api/app/session/refresh", nil, http.StatusOK, &postSessionRefreshResponse{})
+	newToken := user.cookie("/api/app/", "app_session")
+	if newToken == oldToken {
+		t.Fatal("access token was not rotated")
+	}
+	newTestTokenClient(t, app, "app_session", oldToken).do(http.MethodGet, "/api/app/sessions", nil, http.StatusUnauthorized, nil)
+	newTestTokenClient(t, app, "app_refresh", oldRefreshToken).do(http.MethodPost, "/api/app/session/refresh", nil, http.StatusUnauthorized, nil)
+	user.do(http.MethodGet, "/api/app/sessions", nil, http.StatusOK, sessions)
+	if len(sessions.Sessions) != 1 || sessions.Sessions[0].ID != sessionID {
+		t.Fatalf("sessions = %+v, want %s to be kept", sessions.Sessions, sessionID)
+	}
+
+	// 失効させたセッションは、リフレッシュもできない
+	refreshToken := user.cookie("/api/app/session/refresh", "app_refresh")
+	user.do(http.MethodDelete, "/api/app/sessions/"+sessionID, nil, http.StatusNoContent, nil)
+	user.do(http.MethodGet, "/api/app/sessions", nil, http.StatusUnauthorized, nil)
+	newTestTokenClient(t, app, "app_refresh", refreshToken).do(http.MethodPost, "/api/app/session/refresh", nil, http.StatusUnauthorized, nil)
+
+	// ログアウトしたアクセストークンも使えない
+	owner, _ := newTestOwner(t, app, "e2e-owner")
+	ownerToken := owner.cookie("/api/owner/", "owner_session")
+	owner.do(http.MethodPost, "/api/owner/session/logout", nil, http.StatusNoContent, nil)
+	newTestTokenClient(t, app, "owner_session", ownerToken).do(http.MethodGet, "/api/owner/chairs", nil, http.StatusUnauthorized, nil)
+}
+
+func TestSessionLogin(t *testing.T) {
+	app, _ := startTestApp(t, nil)
+
+	// ログアウトしても、登録時に返された access_token でセッションを取り直せる
+	user := newTestClient(t, app)
+	signup := &appPostUsersResponse{}
+	user.do(http.MethodPost, "/api/app/users", &appPostUsersRequest{
+		Username:    "e2e-user",
+		FirstName:   "Taro",
+		LastName:    "Isucon",
+		DateOfBirth: "2000-01-01",
+	}, http.StatusCreated, signup)
+	if signup.AccessToken == "" {
+		t.Fatal("access_token is not returned on signup")
+	}
+	user.do(http.MethodPost, "/api/app/session/logout", nil, http.StatusNoContent, nil)
+	user.do(http.MethodGet, "/api/app/sessions", nil, http.StatusUnauthorized, nil)
+	user.do(http.MethodPost, "/api/app/session", &postSessionLoginRequest{AccessToken: signup.AccessToken}, http.StatusOK, &postSessionLoginResponse{})
+	sessions := &getSessionsResponse{}
+	user.do(http.MethodGet, "/api/app/sessions", nil, http.StatusOK, sessions)
+	if len(sessions.Sessions) != 1 || !sessions.Sessions[0].Current {
+		t.Errorf("sessions after login = %+v", sessions.Sessions)
+	}
+	user.do(http.MethodPost, "/api/app/session", &postSessionLoginRequest{AccessToken: "wrong"}, http.StatusUnauthorized, nil)
+	// 他の種別の access_token では発行しない
+	newTestClient(t, app).do(http.MethodPost, "/api/owner/session", &postSessionLoginRequest{AccessToken: signup.AccessToken}, http.StatusUnauthorized, nil)
+
+	// 保存してあるハッシュそのものではログインできない
+	stored := ""
+	if err := db.Get(&stored, "SELECT access_token FROM users WHERE id = ?", signup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stored == signup.AccessToken {
+		t.Fatal("access_token is stored in plain text")
+	}
+	newTestClient(t, app).do(http.MethodPost, "/api/app/session", &postSessionLoginRequest{AccessToken: stored}, http.StatusUnauthorized, nil)
+
+	// 椅子もログアウト後に Cookie のセッションを取り直せる
+	_, owner := newTestOwner(t, app, "e2e-owner")
+	chair, registered := newTestChair(t, app, owner, "e2e-chair", Coordinate{Latitude: 0, Longitude: 0})
+	chair.do(http.MethodPost, "/api/chair/session/logout", nil, http.StatusNoContent, nil)
+	chair.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: false}, http.StatusUnauthorized, nil)
+	chair.do(http.MethodPost, "/api/chair/session", &postSessionLoginRequest{AccessToken: registered.AccessToken}, http.StatusOK, nil)
+	chair.do(http.MethodPost, "/api/chair/activity", &postChairActivityRequest{IsActive: false}, http.StatusNoContent, nil)
+
+	// 初期データの平文の access_token でも発行する。access_token をそのまま Cookie にするのはデフォルトでは受け付けない
+	if _, err := db.Exec(
+		"INSERT INTO owners (id, name, access_token, chair_register_token) VALUES (?, ?, ?, ?)",
+		"01JDFEDF00B09BNMV8MP0RB34G", "initial-owner", "initial-owner-token", "initial-owner-register-token",
+	); err != nil {
+		t.Fatal(err)
+	}
+	newTestTokenClient(t, app, "owner_session", "initial-owner-token").do(http.MethodGet, "/api/owner/chairs", nil, http.StatusUnauthorized, nil)
+	initialOwner := newTestClient(t, app)
+	initialOwner.do(http.MethodPost, "/api/owner/session", &postSessionLoginRequest{AccessToken: "initial-owner-token"}, http.StatusOK, nil)
+	initialOwner.do(http.MethodGet, "/api/owner/chairs", nil, http.StatusOK, nil)
+}
+
+func TestRateLimit(t *testing.T) {
+	app, _ := startTestApp(t, func(c *Config) {
+		c.RateLimits = "app_estimated_fare=0.001:2"
+	})
+	req := &appPostRidesEstimatedFareRequest{
+		PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+		DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 0},
+	}
+
+	user := newTestUser(t, app, "e2e-user")
+	for range 2 {
+		user.do(http.MethodPost, "/api/app/rides/estimated-fare", req, http.StatusOK, nil)
+	}
+	res := &rateLimitedResponse{}
+	user.do(http.MethodPost, "/api/app/rides/estimated-fare", req, http.StatusTooManyRequests, res)
+	if res.Code != "rate_limited" || res.RetryAfterMs <= 0 {
+		t.Errorf("rate limited response = %+v, want code rate_limited and positive retry_after_ms", res)
+	}
+
+	// 制限は利用者ごとにかかる
+	newTestUser(t, app, "e2e-user-2").do(http.MethodPost, "/api/app/rides/estimated-fare", req, http.StatusOK, nil)
+}
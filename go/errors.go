@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// クライアントが分岐に使うエラーコード。文言は変わってもこちらは変えない
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusGone:                "gone",
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+func errorCode(statusCode int) string {
+	if code, ok := errorCodes[statusCode]; ok {
+		return code
+	}
+	if statusCode >= 500 {
+		return "internal_error"
+	}
+	return "error"
+}
+
+type errorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func newErrorResponse(r *http.Request, statusCode int, message string) errorResponse {
+	return errorResponse{
+		Code:      errorCode(statusCode),
+		Message:   message,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// 認証ミドルウェアが積んだ主体
+func principalAttrs(r *http.Request) []any {
+	ctx := r.Context()
+	if user, ok := ctx.Value("user").(*User); ok {
+		return []any{"principal_type", "user", "principal_id", user.ID}
+	}
+	if chair, ok := ctx.Value("chair").(*Chair); ok {
+		return []any{"principal_type", "chair", "principal_id", chair.ID}
+	}
+	if owner, ok := ctx.Value("owner").(*Owner); ok {
+		return []any{"principal_type", "owner", "principal_id", owner.ID}
+	}
+	if admin, ok := ctx.Value("admin").(string); ok {
+		return []any{"principal_type", "admin", "principal_id", admin}
+	}
+	return nil
+}
+
+func handlerName(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return r.Method + " " + pattern
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	// 5xxの詳細はログにだけ残す
+	message := err.Error()
+	if statusCode >= 500 {
+		message = http.StatusText(statusCode)
+	}
+	res := newErrorResponse(r, statusCode, message)
+	if res.RequestID != "" {
+		w.Header().Set("X-Request-Id", res.RequestID)
+	}
+	writeJSON(w, statusCode, res)
+
+	// 4xxは config.ErrorLogSampleRate の割合だけログに残す
+	if statusCode < 500 && (config.ErrorLogSampleRate <= 0 || rand.Float64() >= config.ErrorLogSampleRate) {
+		return
+	}
+	attrs := []any{
+		"status", statusCode,
+		"code", res.Code,
+		"handler", handlerName(r),
+		"request_id", res.RequestID,
+		"error", err.Error(),
+	}
+	attrs = append(attrs, principalAttrs(r)...)
+	if statusCode >= 500 {
+		slog.Error("error response wrote", attrs...)
+	} else {
+		slog.Warn("error response wrote", attrs...)
+	}
+}
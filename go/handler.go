@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 永続化を Store 経由で行うハンドラー。Store を差し替えればDBなしでも動かせる
+type Handler struct {
+	store *Store
+}
+
+func newHandler(store *Store) *Handler {
+	return &Handler{store: store}
+}
+
+// トランザクションの中から返して、レスポンスのステータスコードを指定する
+type statusError struct {
+	statusCode int
+	err        error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func withStatus(statusCode int, err error) error {
+	return &statusError{statusCode: statusCode, err: err}
+}
+
+// ステータスコードの指定がないエラーは500にする
+func writeStatusError(w http.ResponseWriter, r *http.Request, err error) {
+	var se *statusError
+	if errors.As(err, &se) {
+		writeError(w, r, se.statusCode, se.err)
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,784 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/paymentmock"
+)
+
+// DBなしで、メモリ上の Store に対してハンドラーを直接呼ぶ
+func newTestHandler(t *testing.T) (*Handler, *paymentmock.Gateway) {
+	t.Helper()
+
+	prevCache := cache
+	cache = &noopCache{}
+	t.Cleanup(func() { cache = prevCache })
+
+	pg, pgURL := startPaymentMock(t)
+	settings := defaultRuntimeSettings()
+	settings.PaymentGatewayURL = pgURL
+	runtimeSettings.Store(&settings)
+	t.Cleanup(func() { runtimeSettings.Store(nil) })
+
+	return newHandler(newMemoryStore()), pg
+}
+
+// ルーティングとミドルウェアを通さないので、パスパラメータと認証済みの主体はリクエストに直接入れる
+type testRequestOption func(r *http.Request) *http.Request
+
+func withPathValue(name string, value string) testRequestOption {
+	return func(r *http.Request) *http.Request {
+		r.SetPathValue(name, value)
+		return r
+	}
+}
+
+func withContextValue(key string, value any) testRequestOption {
+	return func(r *http.Request) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), key, value))
+	}
+}
+
+func callHandler(t *testing.T, handler http.HandlerFunc, method string, body any, wantStatus int, out any, opts ...testRequestOption) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, "/", &buf)
+	r.Header.Set("Content-Type", "application/json")
+	for _, opt := range opts {
+		r = opt(r)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != wantStatus {
+		t.Fatalf("%s: status = %d, want %d: %s", method, w.Code, wantStatus, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func signupTestUser(t *testing.T, h *Handler, username string, invitationCode *string, wantStatus int) *User {
+	t.Helper()
+	res := &appPostUsersResponse{}
+	callHandler(t, h.appPostUsers, http.MethodPost, &appPostUsersRequest{
+		Username:       username,
+		FirstName:      "Taro",
+		LastName:       "Isu",
+		DateOfBirth:    "2000-01-01",
+		InvitationCode: invitationCode,
+	}, wantStatus, res)
+	if wantStatus != http.StatusCreated {
+		return nil
+	}
+	user, err := h.store.Users.Get(context.Background(), res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestHandlerSignup(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	inviter := signupTestUser(t, h, "inviter", nil, http.StatusCreated)
+	if coupon, err := h.store.Coupons.GetUnused(ctx, inviter.ID, "CP_NEW2024"); err != nil || coupon.Discount != 3000 {
+		t.Fatalf("signup coupon = %+v, %v", coupon, err)
+	}
+
+	// 招待コードは3人まで使え、招待した側にも報酬のクーポンが付く
+	for _, name := range []string{"invitee1", "invitee2", "invitee3"} {
+		// 報酬のクーポンコードはミリ秒単位の時刻で区別している
+		time.Sleep(time.Millisecond)
+		invitee := signupTestUser(t, h, name, &inviter.InvitationCode, http.StatusCreated)
+		if _, err := h.store.Coupons.GetUnused(ctx, invitee.ID, "INV_"+inviter.InvitationCode); err != nil {
+			t.Fatalf("invitation coupon for %s: %v", name, err)
+		}
+	}
+	signupTestUser(t, h, "invitee4", &inviter.InvitationCode, http.StatusBadRequest)
+	unknown := "unknown"
+	signupTestUser(t, h, "invitee5", &unknown, http.StatusBadRequest)
+
+	rewards := 0
+	for {
+		coupon, err := h.store.Coupons.OldestUnused(ctx, inviter.ID)
+		if err != nil {
+			break
+		}
+		if coupon.Code != "CP_NEW2024" {
+			rewards++
+		}
+		if err := h.store.Coupons.Use(ctx, inviter.ID, coupon.Code, "ride"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rewards != 3 {
+		t.Errorf("reward coupons = %d, want 3", rewards)
+	}
+
+	// 断られた登録はロールバックされ、招待クーポンは増えない
+	if n, err := h.store.Coupons.CountByCodeForUpdate(ctx, "INV_"+inviter.InvitationCode); err != nil || n != 3 {
+		t.Errorf("invitation coupons = %d, %v, want 3", n, err)
+	}
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	chair := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, chair)
+	if chair.OwnerID != owner.ID {
+		t.Errorf("chair owner = %s, want %s", chair.OwnerID, owner.ID)
+	}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: "invalid",
+	}, http.StatusUnauthorized, nil)
+}
+
+func TestHandlerRideLifecycle(t *testing.T) {
+	h, pg := newTestHandler(t)
+	ctx := context.Background()
+
+	user := signupTestUser(t, h, "rider", nil, http.StatusCreated)
+	asUser := withContextValue("user", user)
+	callHandler(t, h.appPostPaymentMethods, http.MethodPost, &appPostPaymentMethodsRequest{Token: "rider-token"}, http.StatusNoContent, nil, asUser)
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	registered := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, registered)
+	if err := h.store.Chairs.SetLocation(ctx, registered.ID, Coordinate{Latitude: 0, Longitude: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.Chairs.SetActive(ctx, registered.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	chair, err := h.store.Chairs.Get(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asChair := withContextValue("chair", chair)
+
+	notification := &appGetNotificationResponse{}
+	callHandler(t, h.appGetNotification, http.MethodGet, nil, http.StatusOK, notification, asUser)
+	if notification.Data != nil {
+		t.Fatalf("notification before any ride = %+v", notification.Data)
+	}
+
+	ride := &appPostRidesResponse{}
+	callHandler(t, h.appPostRides, http.MethodPost, &appPostRidesRequest{
+		PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+		DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 10},
+	}, http.StatusAccepted, ride, asUser)
+
+	// 通知した未送信のステータスは、次の通知では最新のステータスとして返る
+	for range 2 {
+		callHandler(t, h.appGetNotification, http.MethodGet, nil, http.StatusOK, notification, asUser)
+		if notification.Data == nil || notification.Data.RideID != ride.RideID || notification.Data.Status != "MATCHING" {
+			t.Fatalf("notification = %+v", notification.Data)
+		}
+		if notification.Data.Fare != ride.Fare {
+			t.Errorf("notification fare = %d, want %d", notification.Data.Fare, ride.Fare)
+		}
+	}
+
+	callHandler(t, h.internalGetMatching, http.MethodGet, nil, http.StatusNoContent, nil)
+	if matched, err := h.store.Rides.Get(ctx, ride.RideID); err != nil || matched.ChairID.String != chair.ID {
+		t.Fatalf("matched ride = %+v, %v", matched, err)
+	}
+	// 運び終えるまでは次のライドに割り当てない
+	if free, err := h.store.Rides.IsChairFree(ctx, chair.ID); err != nil || free {
+		t.Fatalf("chair free while carrying = %v, %v", free, err)
+	}
+
+	callHandler(t, h.appPostRideEvaluatation, http.MethodPost, &appPostRideEvaluationRequest{Evaluation: 5}, http.StatusBadRequest, nil, asUser, withPathValue("ride_id", ride.RideID))
+
+	// 椅子が迎えに行き、乗せて目的地まで運ぶ
+	asRide := withPathValue("ride_id", ride.RideID)
+	callHandler(t, h.chairPostRideStatus, http.MethodPost, &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil, asChair, asRide)
+	callHandler(t, h.chairPostCoordinate, http.MethodPost, &Coordinate{Latitude: 0, Longitude: 0}, http.StatusOK, nil, asChair)
+	callHandler(t, h.chairPostRideStatus, http.MethodPost, &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil, asChair, asRide)
+	callHandler(t, h.chairPostCoordinate, http.MethodPost, &Coordinate{Latitude: 10, Longitude: 10}, http.StatusOK, nil, asChair)
+	if status, err := h.store.Rides.LatestStatus(ctx, ride.RideID); err != nil || status != "ARRIVED" {
+		t.Fatalf("status after arriving = %q, %v", status, err)
+	}
+	callHandler(t, h.appGetNotification, http.MethodGet, nil, http.StatusOK, notification, asUser)
+	if notification.Data.Status != "ENROUTE" || notification.Data.Chair == nil || notification.Data.Chair.ID != chair.ID {
+		t.Fatalf("notification after matching = %+v", notification.Data)
+	}
+
+	comment := "thanks"
+	evaluation := &appPostRideEvaluationResponse{}
+	callHandler(t, h.appPostRideEvaluatation, http.MethodPost, &appPostRideEvaluationRequest{
+		Evaluation:  4,
+		reviewInput: reviewInput{Comment: &comment},
+	}, http.StatusOK, evaluation, asUser, withPathValue("ride_id", ride.RideID))
+	if evaluation.CompletedAt == 0 {
+		t.Error("completed_at is not set")
+	}
+	if payments := pg.Payments("rider-token"); len(payments) != 1 || payments[0] != ride.Fare {
+		t.Errorf("payments = %v, want [%d]", payments, ride.Fare)
+	}
+	// 完了した後に評価し直しても二重に決済しない
+	callHandler(t, h.appPostRideEvaluatation, http.MethodPost, &appPostRideEvaluationRequest{Evaluation: 1}, http.StatusBadRequest, nil, asUser, withPathValue("ride_id", ride.RideID))
+	if payments := pg.Payments("rider-token"); len(payments) != 1 {
+		t.Errorf("payments after second evaluation = %v", payments)
+	}
+	for subjectType, subjectID := range map[string]string{"chair": chair.ID, "owner": owner.ID} {
+		reputation, err := h.store.Reviews.GetReputation(ctx, subjectType, subjectID)
+		if err != nil || reputation.RatingCount != 1 || reputation.RatingSum != 4 {
+			t.Errorf("%s reputation = %+v, %v", subjectType, reputation, err)
+		}
+	}
+
+	callHandler(t, h.chairPostRideEvaluation, http.MethodPost, &chairPostRideEvaluationRequest{Evaluation: 2}, http.StatusNoContent, nil, asChair, withPathValue("ride_id", ride.RideID))
+	callHandler(t, h.chairPostRideEvaluation, http.MethodPost, &chairPostRideEvaluationRequest{Evaluation: 2}, http.StatusConflict, nil, asChair, withPathValue("ride_id", ride.RideID))
+	if reputation, err := h.store.Reviews.GetReputation(ctx, "user", user.ID); err != nil || reputation.RatingCount != 1 || reputation.RatingSum != 2 {
+		t.Errorf("user reputation = %+v, %v", reputation, err)
+	}
+
+	// すべてのステータスを椅子に通知し終えたら空きとして扱う
+	for {
+		status, err := h.store.Rides.NextUnsentChairStatus(ctx, []string{ride.RideID})
+		if err != nil {
+			break
+		}
+		if err := h.store.Rides.MarkChairSent(ctx, status.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if free, err := h.store.Rides.IsChairFree(ctx, chair.ID); err != nil || !free {
+		t.Errorf("chair free after completion = %v, %v", free, err)
+	}
+}
+
+// 決済している間はトランザクションを持たず、同時に評価されても一度しか決済しない
+func TestHandlerEvaluationPayment(t *testing.T) {
+	h, pg := newTestHandler(t)
+	ctx := context.Background()
+
+	user := signupTestUser(t, h, "rider", nil, http.StatusCreated)
+	asUser := withContextValue("user", user)
+	callHandler(t, h.appPostPaymentMethods, http.MethodPost, &appPostPaymentMethodsRequest{Token: "rider-token"}, http.StatusNoContent, nil, asUser)
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	registered := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, registered)
+	if err := h.store.Chairs.SetLocation(ctx, registered.ID, Coordinate{Latitude: 0, Longitude: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.Chairs.SetActive(ctx, registered.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	chair, err := h.store.Chairs.Get(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asChair := withContextValue("chair", chair)
+
+	ride := &appPostRidesResponse{}
+	callHandler(t, h.appPostRides, http.MethodPost, &appPostRidesRequest{
+		PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+		DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 10},
+	}, http.StatusAccepted, ride, asUser)
+	callHandler(t, h.internalGetMatching, http.MethodGet, nil, http.StatusNoContent, nil)
+	asRide := withPathValue("ride_id", ride.RideID)
+	callHandler(t, h.chairPostRideStatus, http.MethodPost, &postChairRidesRideIDStatusRequest{Status: "ENROUTE"}, http.StatusNoContent, nil, asChair, asRide)
+	callHandler(t, h.chairPostCoordinate, http.MethodPost, &Coordinate{Latitude: 0, Longitude: 0}, http.StatusOK, nil, asChair)
+	callHandler(t, h.chairPostRideStatus, http.MethodPost, &postChairRidesRideIDStatusRequest{Status: "CARRYING"}, http.StatusNoContent, nil, asChair, asRide)
+	callHandler(t, h.chairPostCoordinate, http.MethodPost, &Coordinate{Latitude: 10, Longitude: 10}, http.StatusOK, nil, asChair)
+
+	// 決済を受けている間に、同じライドの行をロックするトランザクションが待たされないか確かめる
+	var blocked bool
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			locked := make(chan error, 1)
+			go func() {
+				locked <- h.store.InTx(ctx, func(tx Repositories) error {
+					_, err := tx.Rides.GetForUpdate(ctx, ride.RideID)
+					return err
+				})
+			}()
+			select {
+			case err := <-locked:
+				if err != nil {
+					t.Error(err)
+				}
+			case <-time.After(time.Second):
+				blocked = true
+			}
+		}
+		pg.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(gateway.Close)
+	settings := *currentSettings()
+	settings.PaymentGatewayURL = gateway.URL
+	runtimeSettings.Store(&settings)
+
+	statuses := make(chan int, 3)
+	for range cap(statuses) {
+		go func() {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"evaluation":5}`)))
+			r = asRide(asUser(r))
+			w := httptest.NewRecorder()
+			h.appPostRideEvaluatation(w, r)
+			statuses <- w.Code
+		}()
+	}
+	completed := 0
+	for range cap(statuses) {
+		switch code := <-statuses; code {
+		case http.StatusOK:
+			completed++
+		case http.StatusBadRequest:
+		default:
+			t.Errorf("evaluation status = %d", code)
+		}
+	}
+	if completed != 1 {
+		t.Errorf("completed evaluations = %d, want 1", completed)
+	}
+	if blocked {
+		t.Error("ride stayed locked while waiting for the payment gateway")
+	}
+	if payments := pg.Payments("rider-token"); len(payments) != 1 {
+		t.Errorf("payments = %v, want exactly one", payments)
+	}
+}
+
+func TestHandlerScheduledRideDispatch(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	user := signupTestUser(t, h, "scheduler", nil, http.StatusCreated)
+	asUser := withContextValue("user", user)
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	registered := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, registered)
+	if err := h.store.Chairs.SetLocation(ctx, registered.ID, Coordinate{Latitude: 0, Longitude: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.Chairs.SetActive(ctx, registered.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// 到着見込みが分からなければ配車時刻の scheduledRideDefaultLead 前から配車する
+	scheduled := &appPostScheduledRidesResponse{}
+	callHandler(t, h.appPostScheduledRides, http.MethodPost, &appPostScheduledRidesRequest{
+		PickupCoordinate:      &Coordinate{Latitude: 0, Longitude: 0},
+		DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 10},
+		ScheduledAt:           time.Now().Add(scheduledRideMinLead + time.Minute).UnixMilli(),
+	}, http.StatusCreated, scheduled, asUser)
+
+	// マッチングの前に予約ライドを通常のライドにし、そのまま椅子を割り当てる
+	callHandler(t, h.internalGetMatching, http.MethodGet, nil, http.StatusNoContent, nil)
+	rides, err := h.store.Rides.ListByUser(ctx, user.ID)
+	if err != nil || len(rides) != 1 {
+		t.Fatalf("rides after dispatch = %+v, %v", rides, err)
+	}
+	if rides[0].ChairID.String != registered.ID {
+		t.Errorf("dispatched ride chair = %q, want %q", rides[0].ChairID.String, registered.ID)
+	}
+
+	list := &appGetScheduledRidesResponse{}
+	callHandler(t, h.appGetScheduledRides, http.MethodGet, nil, http.StatusOK, list, asUser)
+	if len(list.ScheduledRides) != 0 {
+		t.Errorf("pending scheduled rides after dispatch = %+v", list.ScheduledRides)
+	}
+	callHandler(t, h.appDeleteScheduledRide, http.MethodDelete, nil, http.StatusConflict, nil, asUser, withPathValue("scheduled_ride_id", scheduled.ScheduledRideID))
+}
+
+func TestHandlerPoolCapacity(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	registered := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, registered)
+	if err := h.store.Chairs.SetLocation(ctx, registered.ID, Coordinate{Latitude: 0, Longitude: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.Chairs.SetActive(ctx, registered.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rides := make([]Ride, 0, poolCapacity+1)
+	for i := range poolCapacity + 1 {
+		user := signupTestUser(t, h, fmt.Sprintf("rider%d", i), nil, http.StatusCreated)
+		res := &appPostRidesResponse{}
+		callHandler(t, h.appPostRides, http.MethodPost, &appPostRidesRequest{
+			PickupCoordinate:      &Coordinate{Latitude: i, Longitude: 0},
+			DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 0},
+			Pooled:                true,
+		}, http.StatusAccepted, res, withContextValue("user", user))
+		ride, err := h.store.Rides.Get(ctx, res.RideID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rides = append(rides, *ride)
+	}
+
+	// 1人目は空いている椅子に乗り、まだ誰とも相乗りしていないので割り引かない
+	chairs, err := h.store.Chairs.ListAvailable(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, matched := h.matchRide(ctx, rides[0], chairs); !matched {
+		t.Fatal("first pooled ride was not matched")
+	}
+	if first, _ := h.store.Rides.Get(ctx, rides[0].ID); first.PoolShared || calculateSale(*first, nil) != 500+100*10 {
+		t.Errorf("first ride before pooling = %+v, sale %d", first, calculateSale(*first, nil))
+	}
+
+	// 2人目は同じ椅子に相乗りし、2人とも割り引く
+	chair, err := findPoolableChair(ctx, h.store.Repositories, rides[1])
+	if err != nil || chair == nil || chair.ID != registered.ID {
+		t.Fatalf("poolable chair = %+v, %v", chair, err)
+	}
+	joinTestPool(t, h, rides[1], chair.ID, true)
+	for _, ride := range rides[:2] {
+		got, err := h.store.Rides.Get(ctx, ride.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 500 + 100*calculateDistance(ride.PickupLatitude, ride.PickupLongitude, 10, 0)*pooledFareRate/100
+		if !got.PoolShared || calculateSale(*got, nil) != want {
+			t.Errorf("pooled ride %s = shared %v, sale %d, want sale %d", ride.ID, got.PoolShared, calculateSale(*got, nil), want)
+		}
+	}
+
+	// 定員に達した椅子は候補にならず、古い候補で乗せようとしても確かめ直して断る
+	if chair, err := findPoolableChair(ctx, h.store.Repositories, rides[2]); err != nil || chair != nil {
+		t.Errorf("poolable chair over capacity = %+v, %v", chair, err)
+	}
+	joinTestPool(t, h, rides[2], registered.ID, false)
+	if third, _ := h.store.Rides.Get(ctx, rides[2].ID); third.ChairID.Valid || third.PoolShared {
+		t.Errorf("ride over capacity = %+v", third)
+	}
+}
+
+func TestHandlerPoolRecheck(t *testing.T) {
+	// 候補を探してから乗せるまでに状況が変わっても、joinPool で確かめ直して断る
+	tests := []struct {
+		name   string
+		change func(t *testing.T, h *Handler, chairID string, first Ride, second *Ride)
+	}{
+		{name: "first rider arrived", change: func(t *testing.T, h *Handler, chairID string, first Ride, second *Ride) {
+			if err := h.store.Rides.AddStatus(context.Background(), first.ID, "ARRIVED"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "detour over budget", change: func(t *testing.T, h *Handler, chairID string, first Ride, second *Ride) {
+			second.PickupLatitude, second.PickupLongitude = 0, 40
+			second.DestinationLatitude, second.DestinationLongitude = 0, 41
+		}},
+		{name: "chair deactivated", change: func(t *testing.T, h *Handler, chairID string, first Ride, second *Ride) {
+			if err := h.store.Chairs.SetActive(context.Background(), chairID, false); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "chair suspended", change: func(t *testing.T, h *Handler, chairID string, first Ride, second *Ride) {
+			now := time.Now()
+			if err := h.store.Chairs.SetSuspended(context.Background(), chairID, &now); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+			ctx := context.Background()
+
+			owner := &ownerPostOwnersResponse{}
+			callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+			registered := &chairPostChairsResponse{}
+			callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+				Name:               "chair",
+				Model:              "model",
+				ChairRegisterToken: owner.ChairRegisterToken,
+			}, http.StatusCreated, registered)
+			if err := h.store.Chairs.SetLocation(ctx, registered.ID, Coordinate{Latitude: 0, Longitude: 0}); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.store.Chairs.SetActive(ctx, registered.ID, true); err != nil {
+				t.Fatal(err)
+			}
+
+			rides := make([]Ride, 0, 2)
+			for i := range 2 {
+				user := signupTestUser(t, h, fmt.Sprintf("rider%d", i), nil, http.StatusCreated)
+				res := &appPostRidesResponse{}
+				callHandler(t, h.appPostRides, http.MethodPost, &appPostRidesRequest{
+					PickupCoordinate:      &Coordinate{Latitude: i, Longitude: 0},
+					DestinationCoordinate: &Coordinate{Latitude: 10, Longitude: 0},
+					Pooled:                true,
+				}, http.StatusAccepted, res, withContextValue("user", user))
+				ride, err := h.store.Rides.Get(ctx, res.RideID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rides = append(rides, *ride)
+			}
+			chairs, err := h.store.Chairs.ListAvailable(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, matched := h.matchRide(ctx, rides[0], chairs); !matched {
+				t.Fatal("first pooled ride was not matched")
+			}
+			if chair, err := findPoolableChair(ctx, h.store.Repositories, rides[1]); err != nil || chair == nil || chair.ID != registered.ID {
+				t.Fatalf("poolable chair = %+v, %v", chair, err)
+			}
+
+			tt.change(t, h, registered.ID, rides[0], &rides[1])
+			joinTestPool(t, h, rides[1], registered.ID, false)
+			if second, _ := h.store.Rides.Get(ctx, rides[1].ID); second.ChairID.Valid || second.PoolShared {
+				t.Errorf("ride after recheck = %+v", second)
+			}
+		})
+	}
+}
+
+func joinTestPool(t *testing.T, h *Handler, ride Ride, chairID string, want bool) {
+	t.Helper()
+	ctx := context.Background()
+	joined := false
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		var err error
+		joined, err = joinPool(ctx, tx, ride, chairID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if joined != want {
+		t.Fatalf("joined = %v, want %v", joined, want)
+	}
+}
+
+func TestHandlerAPIKeyUsage(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+	prevUsage := apiKeyUsage
+	t.Cleanup(func() { apiKeyUsage = prevUsage })
+	apiKeyUsage = newAPIKeyUsageRecorder(h.store)
+
+	registered := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, registered)
+	owner, err := h.store.Owners.Get(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey := &ownerPostAPIKeysResponse{}
+	callHandler(t, h.ownerPostAPIKeys, http.MethodPost, &ownerPostAPIKeysRequest{Name: "reader", Scopes: []string{"owner:read"}}, http.StatusCreated, apiKey,
+		withContextValue("owner", owner), withContextValue("session", &sessionContext{SubjectType: "owner", SubjectID: owner.ID}))
+
+	// 認証では書き込まず、まとめて書き込むときに記録する
+	if _, err := h.lookupAPIKey(ctx, "owner", apiKey.Key); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := h.store.APIKeys.Get(ctx, apiKey.ID); err != nil || stored.LastUsedAt != nil {
+		t.Fatalf("api key before flush = %+v, %v", stored, err)
+	}
+	if err := apiKeyUsage.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := h.store.APIKeys.Get(ctx, apiKey.ID); err != nil || stored.LastUsedAt == nil {
+		t.Errorf("api key after flush = %+v, %v", stored, err)
+	}
+}
+
+func TestCoordinateBufferBackpressure(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	chair := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, chair)
+
+	// 書き込みだけ失敗する Store
+	down := false
+	store := *h.store
+	store.inTx = func(ctx context.Context, fn func(tx Repositories) error) error {
+		if down {
+			return errors.New("database is down")
+		}
+		return h.store.InTx(ctx, fn)
+	}
+	b := newChairCoordinateBuffer(&store, 2)
+	add := func(to Coordinate) (*coordinateBatch, error) {
+		c, err := b.lockChair(ctx, chair.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.mu.Unlock()
+		return b.add(c, chair.ID, to, time.Now(), nil)
+	}
+
+	down = true
+	first, err := add(Coordinate{Latitude: 0, Longitude: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.flush(ctx); err == nil {
+		t.Fatal("flush succeeded while the database is down")
+	}
+	// 戻された位置を待っている側は、戻した先が書き込まれるまで待ち続ける
+	waited := make(chan error, 2)
+	go func() { waited <- b.wait(ctx, first) }()
+	second, err := add(Coordinate{Latitude: 1, Longitude: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { waited <- b.wait(ctx, second) }()
+	if err := b.flush(ctx); err == nil {
+		t.Fatal("flush succeeded while the database is down")
+	}
+	select {
+	case err := <-waited:
+		t.Fatalf("wait returned before the requeued batch was written: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// 上限まで溜まったら書き込めるまで断る
+	if _, err := add(Coordinate{Latitude: 2, Longitude: 0}); !errors.Is(err, errCoordinateBufferFull) {
+		t.Fatalf("add over max = %v, want %v", err, errCoordinateBufferFull)
+	}
+
+	down = false
+	if err := b.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := <-waited; err != nil {
+			t.Errorf("wait for requeued batch = %v, want nil", err)
+		}
+	}
+	if _, err := add(Coordinate{Latitude: 2, Longitude: 0}); err != nil {
+		t.Errorf("add after flush = %v", err)
+	}
+	got, err := h.store.Chairs.Get(ctx, chair.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 戻された位置も一度だけ書き込まれる
+	if got.TotalDistance != 1 || got.Latitude.Int64 != 1 {
+		t.Errorf("chair after flush = total_distance %d, latitude %d, want 1, 1", got.TotalDistance, got.Latitude.Int64)
+	}
+}
+
+func TestCoordinateBufferSyncWait(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	owner := &ownerPostOwnersResponse{}
+	callHandler(t, h.ownerPostOwners, http.MethodPost, &ownerPostOwnersRequest{Name: "owner"}, http.StatusCreated, owner)
+	registered := &chairPostChairsResponse{}
+	callHandler(t, h.chairPostChairs, http.MethodPost, &chairPostChairsRequest{
+		Name:               "chair",
+		Model:              "model",
+		ChairRegisterToken: owner.ChairRegisterToken,
+	}, http.StatusCreated, registered)
+	chair, err := h.store.Chairs.Get(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevConfig, prevBuffer := config, coordinateBuffer
+	t.Cleanup(func() { config, coordinateBuffer = prevConfig, prevBuffer })
+	config.Coordinate.Durability = "sync"
+	coordinateBuffer = newChairCoordinateBuffer(h.store, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		callHandler(t, h.chairPostCoordinate, http.MethodPost, &Coordinate{Latitude: 1, Longitude: 1}, http.StatusOK, nil, withContextValue("chair", chair))
+	}()
+
+	// 書き込みを待っている間も、同じ椅子の位置は受け付けられる
+	deadline := time.Now().Add(time.Second)
+	for {
+		coordinateBuffer.mu.Lock()
+		locations := coordinateBuffer.pending.locations
+		coordinateBuffer.mu.Unlock()
+		if locations == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("coordinate was not buffered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	locked := make(chan error, 1)
+	go func() {
+		c, err := coordinateBuffer.lockChair(ctx, chair.ID)
+		if err == nil {
+			c.mu.Unlock()
+		}
+		locked <- err
+	}()
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("chair stays locked while waiting for the batch to be written")
+	}
+	select {
+	case <-done:
+		t.Fatal("sync coordinate returned before the batch was written")
+	default:
+	}
+
+	if err := coordinateBuffer.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if got, err := h.store.Chairs.Get(ctx, chair.ID); err != nil || got.Latitude.Int64 != 1 {
+		t.Errorf("chair after sync coordinate = %+v, %v", got, err)
+	}
+}
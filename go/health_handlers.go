@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 停止処理が始まったらreadyzを落として、新しいリクエストが来ないようにする
+var shuttingDown atomic.Bool
+
+// 停止時に終わるのを待つバックグラウンド処理
+var backgroundWorkers sync.WaitGroup
+
+func startBackgroundWorker(fn func()) {
+	backgroundWorkers.Add(1)
+	go func() {
+		defer backgroundWorkers.Done()
+		fn()
+	}()
+}
+
+// バックグラウンド処理が止まるのを ctx が切れるまで待つ
+func waitBackgroundWorkers(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		backgroundWorkers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+const healthCheckTimeout = time.Second
+
+func checkDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+func writeHealth(w http.ResponseWriter, checks map[string]error) {
+	res := healthResponse{Status: "ok", Checks: map[string]string{}}
+	statusCode := http.StatusOK
+	for name, err := range checks {
+		if err != nil {
+			res.Checks[name] = err.Error()
+			res.Status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+		res.Checks[name] = "ok"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, statusCode, res)
+}
+
+// プロセスが動いていてDBにつながるか
+func getHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, map[string]error{
+		"db": checkDB(r.Context()),
+	})
+}
+
+// DBのスキーマがこのアプリの想定するバージョンまで上がっているか。
+// init.sh で作り直している間やマイグレーションを適用する前は、必要なテーブルや列がない
+func checkSchema(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	version, err := currentMigrationVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if want := latestMigrationVersion(); version < want {
+		return fmt.Errorf("schema is at migration %d, want %d", version, want)
+	}
+	return nil
+}
+
+// リクエストを受けられるか。スキーマが古いときと停止中は受けない
+func getReadyz(w http.ResponseWriter, r *http.Request) {
+	var draining error
+	if shuttingDown.Load() {
+		draining = errors.New("shutting down")
+	}
+	writeHealth(w, map[string]error{
+		"db":       checkDB(r.Context()),
+		"schema":   checkSchema(r.Context()),
+		"shutdown": draining,
+	})
+}
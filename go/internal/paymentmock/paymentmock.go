@@ -0,0 +1,89 @@
+// paymentmock は payment_mock と同じAPIを持つ決済サーバー。
+// 任意のトークンを受け付けて、決済額をトークンごとに記録する。
+// FailNext で、本番の決済サービスのようにエラーを返させることもできる
+package paymentmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type Gateway struct {
+	mu       sync.Mutex
+	payments map[string][]int
+	// これから失敗させる POST /payments。true なら決済を記録してから失敗を返す
+	failures []bool
+}
+
+func New() *Gateway {
+	return &Gateway{payments: map[string][]int{}}
+}
+
+type payment struct {
+	Amount int    `json:"amount"`
+	Status string `json:"status,omitempty"`
+}
+
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var req payment
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount < 0 || req.Amount > 1_000_000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		g.mu.Lock()
+		fail, recorded := false, true
+		if len(g.failures) > 0 {
+			fail, recorded = true, g.failures[0]
+			g.failures = g.failures[1:]
+		}
+		if recorded {
+			g.payments[token] = append(g.payments[token], req.Amount)
+		}
+		g.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /payments", func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res := []payment{}
+		for _, amount := range g.Payments(token) {
+			res = append(res, payment{Amount: amount, Status: "成功"})
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(res)
+	})
+	return mux
+}
+
+// token で受け付けた決済額を、受け付けた順に返す
+func (g *Gateway) Payments(token string) []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]int(nil), g.payments[token]...)
+}
+
+// 次の n 回の POST /payments を 500 で失敗させる。
+// recorded なら、エラーを返しても決済は済んでいる場合を再現する
+func (g *Gateway) FailNext(n int, recorded bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for range n {
+		g.failures = append(g.failures, recorded)
+	}
+}
@@ -0,0 +1,150 @@
+// redismock はキャッシュに使う分だけのコマンドを持つ、Redis の代役サーバー。
+// 値はメモリに持ち、有効期限は読むときに確かめる
+package redismock
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isucon/isucon14/webapp/go/internal/resp"
+)
+
+type entry struct {
+	value []byte
+	// ゼロ値なら期限なし
+	expiresAt time.Time
+}
+
+type Server struct {
+	mu   sync.Mutex
+	data map[string]entry
+}
+
+func New() *Server {
+	return &Server{data: map[string]entry{}}
+}
+
+// l を閉じるまで接続を受け付ける
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(c)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	for {
+		v, err := resp.ReadValue(r)
+		if err != nil {
+			return
+		}
+		if v.Kind != '*' || len(v.Array) == 0 {
+			resp.WriteError(w, "ERR expected a command array")
+		} else {
+			args := make([]string, len(v.Array))
+			for i, a := range v.Array {
+				args[i] = string(a.Str)
+			}
+			s.exec(w, args)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(w *bufio.Writer, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		resp.WriteSimple(w, "PONG")
+	case "AUTH", "SELECT":
+		resp.WriteSimple(w, "OK")
+	case "GET":
+		if len(args) != 2 {
+			resp.WriteError(w, "ERR wrong number of arguments for 'get' command")
+			return
+		}
+		e, ok := s.get(args[1], now)
+		if !ok {
+			resp.WriteNull(w)
+			return
+		}
+		resp.WriteBulk(w, e.value)
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			resp.WriteError(w, "ERR syntax error")
+			return
+		}
+		e := entry{value: []byte(args[2])}
+		if len(args) == 5 {
+			n, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || n <= 0 {
+				resp.WriteError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				e.expiresAt = now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				e.expiresAt = now.Add(time.Duration(n) * time.Millisecond)
+			default:
+				resp.WriteError(w, "ERR syntax error")
+				return
+			}
+		}
+		s.data[args[1]] = e
+		resp.WriteSimple(w, "OK")
+	case "DEL":
+		deleted := int64(0)
+		for _, key := range args[1:] {
+			if _, ok := s.get(key, now); ok {
+				deleted++
+			}
+			delete(s.data, key)
+		}
+		resp.WriteInt(w, deleted)
+	case "FLUSHDB":
+		s.data = map[string]entry{}
+		resp.WriteSimple(w, "OK")
+	case "DBSIZE":
+		n := int64(0)
+		for key := range s.data {
+			if _, ok := s.get(key, now); ok {
+				n++
+			}
+		}
+		resp.WriteInt(w, n)
+	default:
+		resp.WriteError(w, "ERR unknown command '"+args[0]+"'")
+	}
+}
+
+func (s *Server) get(key string, now time.Time) (entry, bool) {
+	e, ok := s.data[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+		delete(s.data, key)
+		return entry{}, false
+	}
+	return e, true
+}
@@ -0,0 +1,303 @@
+// resp は Redis のプロトコル(RESP2)で話す最小限のクライアントと、応答を書くための関数。
+// アプリのキャッシュと、テスト用の代役サーバー(internal/redismock)で使う
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// サーバーが返したエラー(-ERR ...)
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+var ErrClosed = errors.New("resp: client is closed")
+
+// 応答1つ。Kind は '+', '-', ':', '$', '*' のどれか
+type Value struct {
+	Kind  byte
+	Str   []byte
+	Int   int64
+	Array []Value
+	// $-1 と *-1
+	Null bool
+}
+
+func ReadValue(r *bufio.Reader) (Value, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, errors.New("resp: empty line")
+	}
+	v := Value{Kind: line[0]}
+	body := line[1:]
+	switch v.Kind {
+	case '+', '-':
+		v.Str = body
+	case ':':
+		v.Int, err = strconv.ParseInt(string(body), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(body))
+		if err != nil {
+			return v, err
+		}
+		if n < 0 {
+			v.Null = true
+			return v, nil
+		}
+		v.Str = make([]byte, n+2)
+		if _, err := io.ReadFull(r, v.Str); err != nil {
+			return v, err
+		}
+		v.Str = v.Str[:n]
+	case '*':
+		n, err := strconv.Atoi(string(body))
+		if err != nil {
+			return v, err
+		}
+		if n < 0 {
+			v.Null = true
+			return v, nil
+		}
+		v.Array = make([]Value, n)
+		for i := range v.Array {
+			if v.Array[i], err = ReadValue(r); err != nil {
+				return v, err
+			}
+		}
+	default:
+		return v, fmt.Errorf("resp: unknown type %q", v.Kind)
+	}
+	return v, err
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("resp: line does not end with CRLF")
+	}
+	return append([]byte(nil), line[:len(line)-2]...), nil
+}
+
+// コマンドはバルク文字列の配列として送る
+func WriteCommand(w *bufio.Writer, args ...[]byte) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		WriteBulk(w, arg)
+	}
+	return w.Flush()
+}
+
+func WriteSimple(w *bufio.Writer, s string) { fmt.Fprintf(w, "+%s\r\n", s) }
+
+func WriteError(w *bufio.Writer, s string) { fmt.Fprintf(w, "-%s\r\n", s) }
+
+func WriteInt(w *bufio.Writer, n int64) { fmt.Fprintf(w, ":%d\r\n", n) }
+
+func WriteNull(w *bufio.Writer) { w.WriteString("$-1\r\n") }
+
+func WriteBulk(w *bufio.Writer, b []byte) {
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	// 使い終わった接続を取っておく数
+	PoolSize int
+	// 同時に開いておく接続の上限。使い切っていると、接続が返ってくるまで待つ
+	MaxOpen int
+	// 接続と1コマンドの往復にかける時間の上限
+	Timeout time.Duration
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+var ErrPoolTimeout = errors.New("resp: timed out waiting for a connection")
+
+// 接続を使い回すクライアント。複数の goroutine から使える
+type Client struct {
+	opts Options
+
+	// 開いている接続1本につき1つ入れる
+	open chan struct{}
+	idle chan *conn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 1
+	}
+	if opts.MaxOpen < opts.PoolSize {
+		opts.MaxOpen = opts.PoolSize
+	}
+	return &Client{
+		opts: opts,
+		open: make(chan struct{}, opts.MaxOpen),
+		idle: make(chan *conn, opts.PoolSize),
+	}
+}
+
+// コマンドを送って応答を返す。応答がエラーなら Error を返す
+func (c *Client) Do(ctx context.Context, args ...string) (Value, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := cn.do(ctx, c.opts.Timeout, args...)
+	var respErr Error
+	if err != nil && !errors.As(err, &respErr) {
+		// 応答の途中で失敗した接続は使い回さない
+		c.discard(cn)
+		return v, err
+	}
+	c.put(cn)
+	return v, err
+}
+
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args ...string) (Value, error) {
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return Value{}, err
+	}
+	b := make([][]byte, len(args))
+	for i, arg := range args {
+		b[i] = []byte(arg)
+	}
+	if err := WriteCommand(cn.w, b...); err != nil {
+		return Value{}, err
+	}
+	v, err := ReadValue(cn.r)
+	if err != nil {
+		return v, err
+	}
+	if v.Kind == '-' {
+		return v, Error(v.Str)
+	}
+	return v, nil
+}
+
+// 空いている接続を使う。なければ上限まで新しく開き、上限に達していれば返ってくるのを Timeout まで待つ
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if c.opts.Timeout > 0 {
+		timer := time.NewTimer(c.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	case c.open <- struct{}{}:
+		cn, err := c.dial(ctx)
+		if err != nil {
+			<-c.open
+			return nil, err
+		}
+		return cn, nil
+	case <-timeout:
+		return nil, ErrPoolTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{Timeout: c.opts.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.opts.Password != "" {
+		if _, err := cn.do(ctx, c.opts.Timeout, "AUTH", c.opts.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.do(ctx, c.opts.Timeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.discard(cn)
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		c.discard(cn)
+	}
+}
+
+// 接続を閉じて、開いている数から外す
+func (c *Client) discard(cn *conn) {
+	cn.Close()
+	<-c.open
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for {
+		select {
+		case cn := <-c.idle:
+			c.discard(cn)
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientMaxOpen(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// 接続を受けるだけで何も返さない
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	c := NewClient(Options{Addr: l.Addr().String(), PoolSize: 1, MaxOpen: 2, Timeout: 50 * time.Millisecond})
+	defer c.Close()
+	ctx := context.Background()
+	a, err := c.get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上限まで開いているので、返ってくるまで待って諦める
+	if _, err := c.get(ctx); !errors.Is(err, ErrPoolTimeout) {
+		t.Fatalf("get over max open = %v, want %v", err, ErrPoolTimeout)
+	}
+
+	// 返した接続は待っている側が使う
+	done := make(chan *conn)
+	go func() {
+		cn, err := c.get(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- cn
+	}()
+	c.put(a)
+	if cn := <-done; cn != a {
+		t.Errorf("got a new connection, want the returned one")
+	}
+	// 取っておく数を超えて返した接続は閉じて、開いている数から外す
+	c.put(a)
+	c.put(b)
+	if n := len(c.open); n != 1 {
+		t.Errorf("open connections = %d, want 1", n)
+	}
+}
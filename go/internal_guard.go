@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+)
+
+const internalSecretHeader = "X-Isuride-Internal-Secret"
+
+// 接続元を表すヘッダ。nginx がクライアントの送ってきた値を $remote_addr で上書きする
+const realIPHeader = "X-Real-IP"
+
+// 内部向けAPIの保護方式(config.Internal.Guard)
+//   - auto:     config.Production なら loopback、そうでなければ none(デフォルト)
+//   - loopback: ループバックアドレスから、X-Real-IP もループバックアドレスのものだけ許可する
+//   - secret:   config.Internal.Secret と同じ値を X-Isuride-Internal-Secret ヘッダで送ってきたもののみ許可する
+//   - mtls:     config.TLS.Listen で受けた接続のうち、config.TLS.ClientCA で検証できるクライアント証明書を提示したもののみ許可する
+//   - none:     保護しない
+//
+// nginx は外部からのリクエストも 127.0.0.1 から送ってくるので、接続元だけでは外部かどうか分からない。
+// X-Real-IP はクライアントも送れるが、ループバックアドレスから来た場合(nginx か同じホストのプロセス)だけ信用する。
+// nginx を通さずに呼ぶ場合も X-Real-IP: 127.0.0.1 を付ける。付いていなければ弾く。
+// ベンチマーカーは別のホストから nginx 経由で POST /api/initialize を呼ぶので、競技中は loopback にできない
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return false
+	}
+	realIP := net.ParseIP(r.Header.Get(realIPHeader))
+	return realIP != nil && realIP.IsLoopback()
+}
+
+// auto を実際の保護方式に置き換える
+func (cfg InternalConfig) resolve(production bool) InternalConfig {
+	if cfg.Guard == "auto" {
+		cfg.Guard = "none"
+		if production {
+			cfg.Guard = "loopback"
+		}
+	}
+	return cfg
+}
+
+func (cfg InternalConfig) check(r *http.Request) error {
+	switch cfg.Guard {
+	case "none":
+		return nil
+	case "loopback":
+		if !isLoopbackRequest(r) {
+			return errors.New("request is not from loopback")
+		}
+	case "secret":
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(internalSecretHeader)), []byte(cfg.Secret)) != 1 {
+			return errors.New("internal secret mismatch")
+		}
+	case "mtls":
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return errors.New("verified client certificate is required")
+		}
+	}
+	return nil
+}
+
+func internalGuardMiddleware(cfg InternalConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := cfg.check(r); err != nil {
+				slog.Warn("rejected internal request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"remote_addr", r.RemoteAddr,
+					"guard", cfg.Guard,
+					"reason", err.Error(),
+				)
+				writeError(w, r, http.StatusForbidden, errors.New("forbidden"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 証明書と鍵が設定されていれば、config.Listen とは別に config.TLS.Listen でTLSで待ち受ける。
+// nginx からは平文の config.Listen に送る。ClientCA があればクライアント証明書を検証する(提示は任意)
+func loadTLSConfig(c TLSConfig) (*tls.Config, error) {
+	if c.Cert == "" || c.Key == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCA != "" {
+		pem, err := os.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
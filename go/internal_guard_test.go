@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInternalGuard(t *testing.T) {
+	var logs bytes.Buffer
+	prevLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prevLogger) })
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+	tests := []struct {
+		name       string
+		cfg        InternalConfig
+		remoteAddr string
+		header     map[string]string
+		tls        *tls.ConnectionState
+		wantReason string
+	}{
+		{name: "loopback via nginx", cfg: InternalConfig{Guard: "loopback"}, remoteAddr: "127.0.0.1:1234", header: map[string]string{realIPHeader: "127.0.0.1"}},
+		{name: "loopback from remote client via nginx", cfg: InternalConfig{Guard: "loopback"}, remoteAddr: "127.0.0.1:1234", header: map[string]string{realIPHeader: "192.0.2.1"}, wantReason: "request is not from loopback"},
+		{name: "loopback without X-Real-IP", cfg: InternalConfig{Guard: "loopback"}, remoteAddr: "127.0.0.1:1234", wantReason: "request is not from loopback"},
+		{name: "loopback from remote host", cfg: InternalConfig{Guard: "loopback"}, remoteAddr: "192.0.2.1:1234", header: map[string]string{realIPHeader: "127.0.0.1"}, wantReason: "request is not from loopback"},
+		{name: "secret", cfg: InternalConfig{Guard: "secret", Secret: "s3cret"}, remoteAddr: "192.0.2.1:1234", header: map[string]string{internalSecretHeader: "s3cret"}},
+		{name: "secret mismatch", cfg: InternalConfig{Guard: "secret", Secret: "s3cret"}, remoteAddr: "192.0.2.1:1234", header: map[string]string{internalSecretHeader: "wrong"}, wantReason: "internal secret mismatch"},
+		{name: "secret missing", cfg: InternalConfig{Guard: "secret", Secret: "s3cret"}, remoteAddr: "192.0.2.1:1234", wantReason: "internal secret mismatch"},
+		{name: "mtls", cfg: InternalConfig{Guard: "mtls"}, remoteAddr: "192.0.2.1:1234", tls: verified},
+		{name: "mtls without tls", cfg: InternalConfig{Guard: "mtls"}, remoteAddr: "192.0.2.1:1234", wantReason: "verified client certificate is required"},
+		{name: "mtls without client certificate", cfg: InternalConfig{Guard: "mtls"}, remoteAddr: "192.0.2.1:1234", tls: &tls.ConnectionState{}, wantReason: "verified client certificate is required"},
+		{name: "auto outside production", cfg: InternalConfig{Guard: "auto"}.resolve(false), remoteAddr: "192.0.2.1:1234"},
+		{name: "auto in production", cfg: InternalConfig{Guard: "auto"}.resolve(true), remoteAddr: "192.0.2.1:1234", wantReason: "request is not from loopback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			handler := internalGuardMiddleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/api/initialize", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.TLS = tt.tls
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.wantReason == "" {
+				if w.Code != http.StatusNoContent {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+				}
+				if logs.Len() != 0 {
+					t.Errorf("allowed request was logged: %s", logs.String())
+				}
+				return
+			}
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+			// 弾いた理由は応答には含めず、ログにだけ残す
+			if strings.Contains(w.Body.String(), tt.wantReason) {
+				t.Errorf("response leaks the reason: %s", w.Body.String())
+			}
+			var entry map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				e := map[string]any{}
+				if err := json.Unmarshal([]byte(line), &e); err == nil && e["msg"] == "rejected internal request" {
+					entry = e
+				}
+			}
+			if entry == nil {
+				t.Fatalf("rejection was not logged: %s", logs.String())
+			}
+			if entry["level"] != "WARN" || entry["guard"] != tt.cfg.Guard || entry["reason"] != tt.wantReason ||
+				entry["path"] != "/api/initialize" || entry["remote_addr"] != tt.remoteAddr {
+				t.Errorf("log entry = %v", entry)
+			}
+		})
+	}
+}
@@ -2,52 +2,129 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"errors"
+	"log/slog"
 	"net/http"
 	"sort"
+	"time"
 )
 
-func internalGetMatching(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+func (h *Handler) internalGetMatching(w http.ResponseWriter, r *http.Request) {
+	if err := h.runMatching(r.Context()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 内部のループと /api/internal/matching が、ほかのアプリケーションサーバーのものも含めて同時に走らないようにする
+const matchingLockName = "isuride_matching"
+
+func (h *Handler) runMatching(ctx context.Context) error {
+	return h.store.WithLock(ctx, matchingLockName, func() error {
+		return h.matchRides(ctx)
+	})
+}
+
+func (h *Handler) matchRides(ctx context.Context) error {
+	// 配車時刻が近づいた予約ライドを待ち行列に入れる
+	if err := h.dispatchScheduledRides(ctx); err != nil {
+		return err
+	}
+
 	// MEMO: 一旦最も待たせているリクエストに適当な空いている椅子マッチさせる実装とする。おそらくもっといい方法があるはず…
-	rides := []Ride{}
+	chairs, err := h.store.Chairs.ListAvailable(ctx)
+	if err != nil {
+		return nil
+	}
 
-	chairs := []Chair{}
-	if err := db.SelectContext(ctx, &chairs, "SELECT * FROM chairs c WHERE c.is_active = TRUE AND latitude IS NOT NULL"); err != nil {
-		w.WriteHeader(http.StatusNoContent)
-		return
+	rides, err := h.store.Rides.ListUnmatched(ctx)
+	if err != nil {
+		return err
 	}
 
-	if err := db.SelectContext(ctx, &rides, `SELECT * FROM rides WHERE chair_id IS NULL ORDER BY created_at`); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			w.WriteHeader(http.StatusNoContent)
-			return
+	// 低評価のユーザーは後回しにし、低評価の椅子は遠くにいるものとして扱う
+	lowRatedUsers, err := h.store.Reviews.ListLowRated(ctx, "user")
+	if err != nil {
+		return err
+	}
+	lowRatedChairs, err := h.store.Reviews.ListLowRated(ctx, "chair")
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(rides, func(i, j int) bool {
+		return !lowRatedUsers[rides[i].UserID] && lowRatedUsers[rides[j].UserID]
+	})
+	matchingDistance := func(ride Ride, chair Chair) int {
+		distance := calculateDistance(ride.PickupLatitude, ride.PickupLongitude, int(chair.Latitude.Int64), int(chair.Longitude.Int64))
+		if lowRatedChairs[chair.ID] {
+			distance += lowRatedChairPenalty
 		}
-		writeError(w, http.StatusInternalServerError, err)
-		return
+		return distance
 	}
 
 	for _, v := range rides {
+		// 相乗り希望であれば、まず走行中の相乗り椅子に乗せられないか探す
+		if v.Pooled {
+			chair, err := findPoolableChair(ctx, h.store.Repositories, v)
+			if err != nil {
+				return err
+			}
+			if chair != nil {
+				joined := false
+				if err := h.store.InTx(ctx, func(tx Repositories) error {
+					joined, err = joinPool(ctx, tx, v, chair.ID)
+					return err
+				}); err != nil {
+					return err
+				}
+				if joined {
+					continue
+				}
+			}
+		}
+
 		sort.Slice(chairs, func(i, j int) bool {
-			return calculateDistance(v.PickupLatitude, v.PickupLongitude, int(chairs[i].Latitude.Int64), int(chairs[i].Longitude.Int64)) < calculateDistance(v.PickupLatitude, v.PickupLongitude, int(chairs[j].Latitude.Int64), int(chairs[j].Longitude.Int64))
+			return matchingDistance(v, chairs[i]) < matchingDistance(v, chairs[j])
 		})
 		var i int
 		var matched bool
-		if i, matched = matchRide(ctx, v, chairs); !matched {
-			w.WriteHeader(http.StatusNoContent)
-			return
+		if i, matched = h.matchRide(ctx, v, chairs); !matched {
+			return nil
 		}
 		chairs = append(chairs[:i], chairs[i+1:]...)
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// matching_interval_ms が設定されていれば、外部から呼ばれなくても定期的にマッチングする
+func (h *Handler) startMatchingLoop(ctx context.Context) {
+	startBackgroundWorker(func() {
+		for {
+			interval := time.Duration(currentSettings().MatchingIntervalMs) * time.Millisecond
+			if interval <= 0 {
+				// 設定が変わるのを待つ
+				interval = time.Second
+			} else {
+				// 停止が始まっても、途中までのマッチングは最後までやり切る
+				if err := h.runMatching(context.WithoutCancel(ctx)); err != nil {
+					slog.Error("failed to run matching", "error", err)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	})
 }
 
 // rideを受け取って、マッチングさせる。マッチングできたらtrueを返す
-func matchRide(ctx context.Context, ride Ride, chairs []Chair) (int, bool) {
+func (h *Handler) matchRide(ctx context.Context, ride Ride, chairs []Chair) (int, bool) {
 	for i, chair := range chairs {
-		if isChairEmpty(ctx, chair) {
-			if err := saveMatchedRide(ctx, ride, chair); err != nil {
+		if free, err := h.store.Rides.IsChairFree(ctx, chair.ID); err == nil && free {
+			if err := saveMatchedRide(ctx, h.store.Rides, ride, chair); err != nil {
 				return i, false
 			}
 			return i, true
@@ -57,15 +134,10 @@ func matchRide(ctx context.Context, ride Ride, chairs []Chair) (int, bool) {
 	return len(chairs), false
 }
 
-func isChairEmpty(ctx context.Context, chair Chair) bool {
-	empty := false
-	if err := db.GetContext(ctx, &empty, "SELECT COUNT(*) = 0 FROM (SELECT COUNT(chair_sent_at) = 6 AS completed FROM ride_statuses WHERE ride_id IN (SELECT id FROM rides WHERE chair_id = ?) GROUP BY ride_id) is_completed WHERE completed = FALSE", chair.ID); err != nil {
-		return false
+func saveMatchedRide(ctx context.Context, rides RideRepository, ride Ride, chair Chair) error {
+	if err := rides.SetChair(ctx, ride.ID, chair.ID); err != nil {
+		return err
 	}
-	return empty
-}
-
-func saveMatchedRide(ctx context.Context, ride Ride, chair Chair) error {
-	_, err := db.ExecContext(ctx, "UPDATE rides SET chair_id = ? WHERE id = ?", chair.ID, ride.ID)
-	return err
+	matchLatency.Observe(time.Since(ride.CreatedAt).Seconds())
+	return nil
 }
@@ -4,119 +4,325 @@ import (
 	"context"
 	crand "crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"slices"
 	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/coocood/freecache"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-var cache *freecache.Cache
 var db *sqlx.DB
 var startedTime time.Time
 
 func main() {
-	cache = freecache.NewCache(100 * 1024 * 1024) // 100MiB
+	if len(os.Args) > 1 {
+		var command func(args []string, out io.Writer) error
+		switch os.Args[1] {
+		case "migrate":
+			command = runMigrateCommand
+		case "distances":
+			command = runDistancesCommand
+		}
+		if command != nil {
+			if err := command(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
-	mux := setup()
-	slog.Info("Listening on :8080")
-	http.ListenAndServe(":8080", mux)
-}
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		panic(err)
+	}
+	config = cfg
 
-func setup() http.Handler {
-	host := os.Getenv("ISUCON_DB_HOST")
-	if host == "" {
-		host = "127.0.0.1"
+	cache, err = newCache(config)
+	if err != nil {
+		panic(err)
 	}
-	port := os.Getenv("ISUCON_DB_PORT")
-	if port == "" {
-		port = "3306"
+
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		panic(err)
 	}
-	_, err := strconv.Atoi(port)
+
+	// SIGTERMを受けたら新しいリクエストの受付をやめ、処理中のものが終わるのを待ってから止まる
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var mux http.Handler = setup(ctx)
+	debugServer := startDebugServer(config.DebugAddr)
+	if tracingEnabled() {
+		mux = otelhttp.NewHandler(mux, "isuride")
+	}
+
+	tlsConfig, err := loadTLSConfig(config.TLS)
 	if err != nil {
-		panic(fmt.Sprintf("failed to convert DB port number from ISUCON_DB_PORT environment variable into int: %v", err))
+		panic(err)
 	}
-	user := os.Getenv("ISUCON_DB_USER")
-	if user == "" {
-		user = "isucon"
+	server := &http.Server{
+		Addr:              config.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-	password := os.Getenv("ISUCON_DB_PASSWORD")
-	if password == "" {
-		password = "isucon"
+	servers := []*http.Server{server}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("Listening", "addr", config.Listen)
+		serveErr <- server.ListenAndServe()
+	}()
+	// nginx からは平文で受けるので、TLSは別のアドレスで待ち受ける
+	if tlsConfig != nil {
+		tlsServer := &http.Server{
+			Addr:              config.TLS.Listen,
+			Handler:           mux,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, tlsServer)
+		go func() {
+			slog.Info("Listening (TLS)", "addr", config.TLS.Listen)
+			serveErr <- tlsServer.ListenAndServeTLS("", "")
+		}()
 	}
-	dbname := os.Getenv("ISUCON_DB_NAME")
-	if dbname == "" {
-		dbname = "isuride"
+
+	select {
+	case err := <-serveErr:
+		// 起動に失敗した
+		stop()
+		slog.Error("server stopped", "error", err)
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutting down")
 	}
 
+	shutdown(servers, debugServer, shutdownTracing)
+}
+
+func shutdown(servers []*http.Server, debugServer *http.Server, shutdownTracing func(context.Context) error) {
+	shuttingDown.Store(true)
+
+	// readyzが落ちたことにロードバランサーが気づくまで待つ
+	time.Sleep(time.Duration(config.ShutdownDelayMs) * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("failed to drain requests", "addr", server.Addr, "error", err)
+		}
+	}
+	if err := waitBackgroundWorkers(ctx); err != nil {
+		slog.Error("failed to stop background workers", "error", err)
+	}
+	// 受け付け終わったので、溜めている椅子の位置を書き込む
+	if err := coordinateBuffer.flush(ctx); err != nil {
+		slog.Error("failed to flush chair coordinates", "error", err)
+	}
+	if err := apiKeyUsage.flush(ctx); err != nil {
+		slog.Error("failed to flush api key usage", "error", err)
+	}
+	if debugServer != nil {
+		debugServer.Shutdown(ctx)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close db", "error", err)
+	}
+	if replicas != nil {
+		replicas.Close()
+	}
+	slog.Info("shutdown completed")
+}
+
+func newDBConfig(c DBConfig) *mysql.Config {
 	dbConfig := mysql.NewConfig()
-	dbConfig.User = user
-	dbConfig.Passwd = password
-	dbConfig.Addr = net.JoinHostPort(host, port)
+	dbConfig.User = c.User
+	dbConfig.Passwd = c.Password
+	dbConfig.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
 	dbConfig.Net = "tcp"
-	dbConfig.DBName = dbname
+	dbConfig.DBName = c.Name
 	dbConfig.ParseTime = true
 	dbConfig.InterpolateParams = true
+	return dbConfig
+}
 
-	_db, err := sqlx.Connect("mysql", dbConfig.FormatDSN())
+// ctx が切れるとバックグラウンド処理が止まる
+func setup(ctx context.Context) http.Handler {
+	_db, err := openDB(newDBConfig(config.DB).FormatDSN())
 	if err != nil {
 		panic(err)
 	}
 	db = _db
-	db.SetMaxIdleConns(8)
-	db.SetMaxOpenConns(16)
+	db.SetMaxIdleConns(config.DB.MaxIdleConns)
+	db.SetMaxOpenConns(config.DB.MaxOpenConns)
+
+	if config.AutoMigrate {
+		done, err := migrateUp(ctx, db, latestMigrationVersion())
+		if err != nil {
+			panic(err)
+		}
+		for _, m := range done {
+			slog.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+	if err := loadRuntimeSettings(ctx); err != nil {
+		panic(err)
+	}
+	startSettingsReloader(ctx, time.Duration(config.SettingsReloadIntervalMs)*time.Millisecond)
+
+	if config.DB.ReplicaDSN != "" {
+		replicas, err = newReplicaRouter(config.DB.ReplicaDSN, config.DB)
+		if err != nil {
+			panic(err)
+		}
+		startReplicaMonitor(ctx, replicas, time.Duration(config.DB.ReplicaCheckIntervalMs)*time.Millisecond)
+	}
+
+	h := newHandler(newMySQLStore(db, replicas))
+	h.startMatchingLoop(ctx)
+	if config.Coordinate.FlushIntervalMs > 0 {
+		coordinateBuffer = newChairCoordinateBuffer(h.store, config.Coordinate.MaxBufferedLocations)
+		coordinateBuffer.start(ctx, time.Duration(config.Coordinate.FlushIntervalMs)*time.Millisecond)
+	}
+	apiKeyUsage = newAPIKeyUsageRecorder(h.store)
+	apiKeyUsage.start(ctx, apiKeyUsageFlushInterval)
 
 	mux := chi.NewRouter()
 	// mux.Use(middleware.Logger)
+	mux.Use(middleware.RequestID)
 	mux.Use(middleware.Recoverer)
-	mux.HandleFunc("POST /api/initialize", postInitialize)
+	mux.Use(metricsMiddleware)
+	if config.RecordFile != "" {
+		recorder, err := newTrafficRecorder(config.RecordFile)
+		if err != nil {
+			panic(err)
+		}
+		mux.Use(recordMiddleware(recorder))
+	}
+	if tracingEnabled() {
+		mux.Use(traceRouteMiddleware)
+	}
+
+	internalMux := mux.With(internalGuardMiddleware(config.Internal.resolve(config.Production)))
+	rateLimits := loadRateLimits(config.RateLimits)
+
+	mux.HandleFunc("GET /healthz", getHealthz)
+	mux.HandleFunc("GET /readyz", getReadyz)
+
+	internalMux.HandleFunc("POST /api/initialize", postInitialize)
+	internalMux.Handle("GET /metrics", newMetricsHandler(ctx))
 
 	// app handlers
 	{
-		mux.HandleFunc("POST /api/app/users", appPostUsers)
-
-		authedMux := mux.With(appAuthMiddleware)
-		authedMux.HandleFunc("POST /api/app/payment-methods", appPostPaymentMethods)
-		authedMux.HandleFunc("GET /api/app/rides", appGetRides)
-		authedMux.HandleFunc("POST /api/app/rides", appPostRides)
-		authedMux.HandleFunc("POST /api/app/rides/estimated-fare", appPostRidesEstimatedFare)
-		authedMux.HandleFunc("POST /api/app/rides/{ride_id}/evaluation", appPostRideEvaluatation)
-		authedMux.HandleFunc("GET /api/app/notification", appGetNotification)
-		authedMux.HandleFunc("GET /api/app/nearby-chairs", appGetNearbyChairs)
+		mux.HandleFunc("POST /api/app/users", h.appPostUsers)
+		mux.HandleFunc("POST /api/app/session", h.postSessionLogin("user"))
+		mux.HandleFunc("POST /api/app/session/refresh", h.postSessionRefresh("user"))
+
+		authedMux := mux.With(h.appAuthMiddleware, pinWritesMiddleware)
+		authedMux.HandleFunc("POST /api/app/payment-methods", h.appPostPaymentMethods)
+		authedMux.HandleFunc("GET /api/app/rides", h.appGetRides)
+		authedMux.With(rateLimitMiddleware(rateLimits, "app_post_rides")).HandleFunc("POST /api/app/rides", h.appPostRides)
+		authedMux.With(rateLimitMiddleware(rateLimits, "app_estimated_fare")).HandleFunc("POST /api/app/rides/estimated-fare", h.appPostRidesEstimatedFare)
+		authedMux.With(rateLimitMiddleware(rateLimits, "app_ride_evaluation")).HandleFunc("POST /api/app/rides/{ride_id}/evaluation", h.appPostRideEvaluatation)
+		authedMux.With(rateLimitMiddleware(rateLimits, "app_notification")).HandleFunc("GET /api/app/notification", h.appGetNotification)
+		authedMux.With(rateLimitMiddleware(rateLimits, "app_nearby_chairs")).HandleFunc("GET /api/app/nearby-chairs", h.appGetNearbyChairs)
+		authedMux.HandleFunc("POST /api/app/scheduled-rides", h.appPostScheduledRides)
+		authedMux.HandleFunc("GET /api/app/scheduled-rides", h.appGetScheduledRides)
+		authedMux.HandleFunc("DELETE /api/app/scheduled-rides/{scheduled_ride_id}", h.appDeleteScheduledRide)
+		authedMux.HandleFunc("POST /api/app/rides/{ride_id}/share", h.appPostRideShare)
+		authedMux.HandleFunc("DELETE /api/app/rides/{ride_id}/share", h.appDeleteRideShare)
+		authedMux.HandleFunc("POST /api/app/rides/{ride_id}/sos", h.appPostRideSOS)
+		authedMux.HandleFunc("GET /api/app/reputations/{subject_type}/{subject_id}", h.getReputations)
+		authedMux.HandleFunc("POST /api/app/session/logout", h.postSessionLogout("user"))
+		authedMux.HandleFunc("GET /api/app/sessions", h.getSessions)
+		authedMux.HandleFunc("DELETE /api/app/sessions/{session_id}", h.deleteSession)
 	}
 
 	// owner handlers
 	{
-		mux.HandleFunc("POST /api/owner/owners", ownerPostOwners)
-
-		authedMux := mux.With(ownerAuthMiddleware)
-		authedMux.HandleFunc("GET /api/owner/sales", ownerGetSales)
-		authedMux.HandleFunc("GET /api/owner/chairs", ownerGetChairs)
+		mux.HandleFunc("POST /api/owner/owners", h.ownerPostOwners)
+		mux.HandleFunc("POST /api/owner/session", h.postSessionLogin("owner"))
+		mux.HandleFunc("POST /api/owner/session/refresh", h.postSessionRefresh("owner"))
+
+		authedMux := mux.With(h.ownerAuthMiddleware, pinWritesMiddleware)
+		authedMux.With(requireScope("owner:read"), rateLimitMiddleware(rateLimits, "owner_sales")).HandleFunc("GET /api/owner/sales", h.ownerGetSales)
+		authedMux.With(requireScope("owner:read")).HandleFunc("GET /api/owner/chairs", h.ownerGetChairs)
+		authedMux.With(requireScope("owner:read")).HandleFunc("GET /api/owner/reputations/{subject_type}/{subject_id}", h.getReputations)
+		authedMux.With(requireScope("owner:api_keys")).HandleFunc("POST /api/owner/api-keys", h.ownerPostAPIKeys)
+		authedMux.With(requireScope("owner:api_keys")).HandleFunc("GET /api/owner/api-keys", h.ownerGetAPIKeys)
+		authedMux.With(requireScope("owner:api_keys")).HandleFunc("DELETE /api/owner/api-keys/{api_key_id}", h.ownerDeleteAPIKey)
+		authedMux.With(requireCookieSession).HandleFunc("POST /api/owner/session/logout", h.postSessionLogout("owner"))
+		authedMux.With(requireCookieSession).HandleFunc("GET /api/owner/sessions", h.getSessions)
+		authedMux.With(requireCookieSession).HandleFunc("DELETE /api/owner/sessions/{session_id}", h.deleteSession)
 	}
 
 	// chair handlers
 	{
-		mux.HandleFunc("POST /api/chair/chairs", chairPostChairs)
+		mux.HandleFunc("POST /api/chair/chairs", h.chairPostChairs)
+		mux.HandleFunc("POST /api/chair/session", h.postSessionLogin("chair"))
+		mux.HandleFunc("POST /api/chair/session/refresh", h.postSessionRefresh("chair"))
+
+		authedMux := mux.With(h.chairAuthMiddleware, pinWritesMiddleware)
+		authedMux.With(requireScope("chair:activity")).HandleFunc("POST /api/chair/activity", h.chairPostActivity)
+		authedMux.With(requireScope("chair:coordinate"), rateLimitMiddleware(rateLimits, "chair_coordinate")).HandleFunc("POST /api/chair/coordinate", h.chairPostCoordinate)
+		authedMux.With(requireScope("chair:rides"), rateLimitMiddleware(rateLimits, "chair_notification")).HandleFunc("GET /api/chair/notification", h.chairGetNotification)
+		authedMux.With(requireScope("chair:rides"), rateLimitMiddleware(rateLimits, "chair_ride_status")).HandleFunc("POST /api/chair/rides/{ride_id}/status", h.chairPostRideStatus)
+		authedMux.With(requireScope("chair:rides")).HandleFunc("POST /api/chair/rides/{ride_id}/evaluation", h.chairPostRideEvaluation)
+		authedMux.With(requireCookieSession).HandleFunc("POST /api/chair/session/logout", h.postSessionLogout("chair"))
+		authedMux.With(requireCookieSession).HandleFunc("GET /api/chair/sessions", h.getSessions)
+		authedMux.With(requireCookieSession).HandleFunc("DELETE /api/chair/sessions/{session_id}", h.deleteSession)
+	}
+
+	// share handlers
+	{
+		mux.HandleFunc("GET /api/share/{token}", h.getSharedRide)
+	}
 
-		authedMux := mux.With(chairAuthMiddleware)
-		authedMux.HandleFunc("POST /api/chair/activity", chairPostActivity)
-		authedMux.HandleFunc("POST /api/chair/coordinate", chairPostCoordinate)
-		authedMux.HandleFunc("GET /api/chair/notification", chairGetNotification)
-		authedMux.HandleFunc("POST /api/chair/rides/{ride_id}/status", chairPostRideStatus)
+	// admin handlers
+	{
+		authedMux := mux.With(adminAuthMiddleware, pinWritesMiddleware)
+		authedMux.HandleFunc("GET /api/admin/users", h.adminGetUsers)
+		authedMux.HandleFunc("POST /api/admin/users/{id}/suspension", h.adminPostSuspension("user"))
+		authedMux.HandleFunc("GET /api/admin/owners", h.adminGetOwners)
+		authedMux.HandleFunc("POST /api/admin/owners/{id}/suspension", h.adminPostSuspension("owner"))
+		authedMux.HandleFunc("GET /api/admin/chairs", h.adminGetChairs)
+		authedMux.HandleFunc("POST /api/admin/chairs/{id}/suspension", h.adminPostSuspension("chair"))
+		authedMux.HandleFunc("GET /api/admin/rides", h.adminGetRides)
+		authedMux.HandleFunc("GET /api/admin/rides/{ride_id}", h.adminGetRide)
+		authedMux.HandleFunc("POST /api/admin/rides/{ride_id}/status", h.adminPostRideStatus)
+		authedMux.HandleFunc("POST /api/admin/rides/{ride_id}/chair", h.adminPostRideChair)
+		authedMux.HandleFunc("POST /api/admin/coupons", h.adminPostCoupons)
+		authedMux.HandleFunc("GET /api/admin/incidents", h.adminGetIncidents)
+		authedMux.HandleFunc("POST /api/admin/incidents/{incident_id}/resolve", h.adminPostIncidentResolve)
+		authedMux.HandleFunc("GET /api/admin/audit-logs", h.adminGetAuditLogs)
+		authedMux.HandleFunc("GET /api/admin/settings", adminGetSettings)
+		authedMux.HandleFunc("POST /api/admin/settings", adminPostSettings)
 	}
 
 	// internal handlers
 	{
-		mux.HandleFunc("GET /api/internal/matching", internalGetMatching)
+		internalMux.HandleFunc("GET /api/internal/matching", h.internalGetMatching)
 	}
 
 	return mux
@@ -130,61 +336,55 @@ type postInitializeResponse struct {
 	Language string `json:"language"`
 }
 
-func initChairDistances(ctx context.Context) {
-	chairs := []chairWithDetail{}
-	if err := db.SelectContext(ctx, &chairs, `SELECT id,
-		 owner_id,
-		 name,
-		 access_token,
-		 model,
-		 is_active,
-		 created_at,
-		 updated_at,
-		 IFNULL(total_distance2, 0) AS total_distance,
-		 total_distance_updated_at2 AS total_distance_updated_at
-FROM chairs
-		 LEFT JOIN (SELECT chair_id,
-												SUM(IFNULL(distance, 0)) AS total_distance2,
-												MAX(created_at)          AS total_distance_updated_at2
-								 FROM (SELECT chair_id,
-															created_at,
-															ABS(latitude - LAG(latitude) OVER (PARTITION BY chair_id ORDER BY created_at)) +
-															ABS(longitude - LAG(longitude) OVER (PARTITION BY chair_id ORDER BY created_at)) AS distance
-											 FROM chair_locations) tmp
-								 GROUP BY chair_id) distance_table ON distance_table.chair_id = chairs.id
-`); err != nil {
-		panic(err)
-	}
-
-	for _, chair := range chairs {
-		db.ExecContext(ctx, "UPDATE chairs SET total_distance = ?, total_distance_updated_at = ? WHERE id = ?", chair.TotalDistance, chair.TotalDistanceUpdatedAt, chair.ID)
-		currentChairLocation := &ChairLocation{}
-		db.GetContext(ctx, currentChairLocation, "SELECT * FROM chair_locations WHERE chair_id = ? ORDER BY created_at DESC LIMIT 1", chair.ID)
-		if currentChairLocation.ID != "" {
-			db.ExecContext(ctx, "UPDATE chairs SET latitude = ?, longitude = ? WHERE id = ?", currentChairLocation.Latitude, currentChairLocation.Longitude, chair.ID)
-		}
-	}
-}
-
 func postInitialize(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if config.Production {
+		slog.Warn("rejected initialize request in production mode", "remote_addr", r.RemoteAddr)
+		writeError(w, r, http.StatusForbidden, errors.New("initialize is disabled in production mode"))
+		return
+	}
+
 	req := &postInitializeRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
+	// 作り直す前のDBに書き込まれないよう、溜めている椅子の位置を捨てる
+	coordinateBuffer.reset()
 	if out, err := exec.Command("../sql/init.sh").CombinedOutput(); err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to initialize: %s: %w", string(out), err))
+		writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to initialize: %s: %w", string(out), err))
+		return
+	}
+	// init.sh が作るのは初期データを入れられる古いスキーマなので、残りのマイグレーションをここで適用する。
+	// 適用済みのバージョンは init.sh が記録するので、0014 のような初期データを書き換えるマイグレーションは流れない
+	done, err := migrateUp(ctx, db, latestMigrationVersion())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// 走行距離の列を作ったときだけ初期データの値を入れる。ENV=local-dev で作り直さなかったときは何もしない
+	if slices.ContainsFunc(done, func(m migration) bool { return m.Version == rideDistancesMigrationVersion }) {
+		if err := loadInitialDistances(ctx, db); err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	// DBを作り直したのでセッションなどのキャッシュも捨てる
+	if err := cache.Clear(ctx); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	if _, err := db.ExecContext(ctx, "UPDATE settings SET value = ? WHERE name = 'payment_gateway_url'", req.PaymentServer); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if err := loadRuntimeSettings(ctx); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	initChairDistances(ctx)
 	startedTime = time.Now()
 
 	writeJSON(w, http.StatusOK, postInitializeResponse{Language: "go"})
@@ -210,20 +410,6 @@ func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
 	w.Write(buf)
 }
 
-func writeError(w http.ResponseWriter, statusCode int, err error) {
-	// w.Header().Set("Content-Type", "application/json;charset=utf-8")
-	// w.WriteHeader(statusCode)
-	// buf, marshalError := json.Marshal(map[string]string{"message": err.Error()})
-	// if marshalError != nil {
-	// 	w.WriteHeader(http.StatusInternalServerError)
-	// 	w.Write([]byte(`{"error":"marshaling error failed"}`))
-	// 	return
-	// }
-	// w.Write(buf)
-
-	// slog.Error("error response wrote", err)
-}
-
 func secureRandomStr(b int) string {
 	k := make([]byte, b)
 	if _, err := crand.Read(k); err != nil {
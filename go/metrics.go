@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "isuride"
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method", "route", "status"})
+
+	matchLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "match_latency_seconds",
+		Help:      "Time from ride request to chair assignment.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	paymentRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "payment_retries_total",
+		Help:      "Number of retried payment gateway requests.",
+	})
+
+	paymentFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "payment_failures_total",
+		Help:      "Number of payments that failed after all retries.",
+	})
+
+	readRoutes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "read_only_transactions_total",
+		Help:      "Number of read-only transactions by the database they were routed to.",
+	}, []string{"target"})
+
+	cacheErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_errors_total",
+		Help:      "Number of cache operations that failed, treated as misses.",
+	}, []string{"op"})
+
+	replicaLagSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "replica_lag_seconds",
+		Help:      "Replication lag of each replica at the last check.",
+	}, []string{"replica"})
+)
+
+// ルートごとのレイテンシを記録する。ルーティング後にパターンが決まるので、後から取り出す
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// DBから集計するビジネス指標。集計は重いので、スクレイプのたびではなく interval ごとに行って結果を返す
+type businessCollector struct {
+	ridesByStatus *prometheus.Desc
+	waitingRides  *prometheus.Desc
+	idleChairs    *prometheus.Desc
+
+	mu sync.Mutex
+	// 一度も集計できていなければ nil
+	last *businessMetrics
+}
+
+type businessMetrics struct {
+	ridesByStatus map[string]int
+	waitingRides  int
+	idleChairs    int
+}
+
+var businessRideStatuses = []string{"MATCHING", "ENROUTE", "PICKUP", "CARRYING", "STOPOVER", "ARRIVED", "COMPLETED"}
+
+func newBusinessCollector() *businessCollector {
+	return &businessCollector{
+		ridesByStatus: prometheus.NewDesc(metricsNamespace+"_rides", "Number of rides by latest status.", []string{"status"}, nil),
+		waitingRides:  prometheus.NewDesc(metricsNamespace+"_waiting_rides", "Number of rides waiting for a chair.", nil, nil),
+		idleChairs:    prometheus.NewDesc(metricsNamespace+"_idle_chairs", "Number of active chairs without a ride in progress.", nil, nil),
+	}
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ridesByStatus
+	ch <- c.waitingRides
+	ch <- c.idleChairs
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	m := c.last
+	c.mu.Unlock()
+	if m == nil {
+		return
+	}
+	for _, status := range businessRideStatuses {
+		ch <- prometheus.MustNewConstMetric(c.ridesByStatus, prometheus.GaugeValue, float64(m.ridesByStatus[status]), status)
+	}
+	ch <- prometheus.MustNewConstMetric(c.waitingRides, prometheus.GaugeValue, float64(m.waitingRides))
+	ch <- prometheus.MustNewConstMetric(c.idleChairs, prometheus.GaugeValue, float64(m.idleChairs))
+}
+
+// ctx が切れるまで、interval ごとに集計し直す
+func (c *businessCollector) start(ctx context.Context, interval time.Duration) {
+	startBackgroundWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := c.refresh(ctx, interval); err != nil {
+				slog.Error("failed to collect business metrics", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (c *businessCollector) refresh(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// 件数をそろえるため、1つの読み取り専用のトランザクションで数える
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 評価済みのライドは完了しているので、進行中のものだけ最新の状態を調べる
+	counts := []struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
+	}{}
+	if err := tx.SelectContext(ctx, &counts, `
+SELECT status, COUNT(*) AS count
+FROM (SELECT ride_statuses.status, ROW_NUMBER() OVER (PARTITION BY ride_statuses.ride_id ORDER BY ride_statuses.created_at DESC) AS rn
+      FROM ride_statuses
+               JOIN rides ON rides.id = ride_statuses.ride_id
+      WHERE rides.evaluation IS NULL) latest
+WHERE rn = 1
+GROUP BY status`); err != nil {
+		return err
+	}
+	m := &businessMetrics{ridesByStatus: map[string]int{}}
+	for _, c := range counts {
+		m.ridesByStatus[c.Status] += c.Count
+	}
+	completed := 0
+	if err := tx.GetContext(ctx, &completed, `SELECT COUNT(*) FROM rides WHERE evaluation IS NOT NULL`); err != nil {
+		return err
+	}
+	m.ridesByStatus["COMPLETED"] += completed
+
+	if err := tx.GetContext(ctx, &m.waitingRides, `SELECT COUNT(*) FROM rides WHERE chair_id IS NULL`); err != nil {
+		return err
+	}
+	if err := tx.GetContext(ctx, &m.idleChairs, `
+SELECT COUNT(*)
+FROM chairs
+WHERE is_active = TRUE
+  AND suspended_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM rides WHERE rides.chair_id = chairs.id AND rides.evaluation IS NULL)`); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.last = m
+	c.mu.Unlock()
+	return nil
+}
+
+func newMetricsHandler(ctx context.Context) http.Handler {
+	business := newBusinessCollector()
+	business.start(ctx, time.Duration(config.BusinessMetricsIntervalMs)*time.Millisecond)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db.DB, "isuride"),
+		httpRequestDuration,
+		matchLatency,
+		paymentRetries,
+		paymentFailures,
+		readRoutes,
+		replicaLagSeconds,
+		cacheErrors,
+		business,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_hit_ratio",
+			Help:      "Cache hit ratio since start.",
+		}, func() float64 { return cache.Stats().HitRate() }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_hits_total",
+			Help:      "Cache hits.",
+		}, func() float64 { return float64(cache.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_misses_total",
+			Help:      "Cache misses.",
+		}, func() float64 { return float64(cache.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_entries",
+			Help:      "Cache entries.",
+		}, func() float64 { return float64(cache.Stats().Entries) }),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -2,81 +2,94 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"crypto/subtle"
 	"errors"
 	"net/http"
 )
 
-func appAuthMiddleware(next http.Handler) http.Handler {
+func (h *Handler) appAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		c, err := r.Cookie("app_session")
-		if errors.Is(err, http.ErrNoCookie) || c.Value == "" {
-			writeError(w, http.StatusUnauthorized, errors.New("app_session cookie is required"))
-			return
-		}
-		accessToken := c.Value
 		user := &User{}
-		err = db.GetContext(ctx, user, "SELECT * FROM users WHERE access_token = ?", accessToken)
+		session, status, err := h.authenticateSession(r, "user", user)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusUnauthorized, errors.New("invalid access token"))
-				return
-			}
-			writeError(w, http.StatusInternalServerError, err)
+			writeError(w, r, status, err)
+			return
+		}
+
+		if user.SuspendedAt != nil {
+			writeError(w, r, http.StatusForbidden, errors.New("account is suspended"))
 			return
 		}
 
 		ctx = context.WithValue(ctx, "user", user)
+		ctx = context.WithValue(ctx, "session", session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func ownerAuthMiddleware(next http.Handler) http.Handler {
+func (h *Handler) ownerAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		c, err := r.Cookie("owner_session")
-		if errors.Is(err, http.ErrNoCookie) || c.Value == "" {
-			writeError(w, http.StatusUnauthorized, errors.New("owner_session cookie is required"))
+		owner := &Owner{}
+		session, status, err := h.authenticateSession(r, "owner", owner)
+		if err != nil {
+			writeError(w, r, status, err)
 			return
 		}
-		accessToken := c.Value
-		owner := &Owner{}
-		if err := db.GetContext(ctx, owner, "SELECT * FROM owners WHERE access_token = ?", accessToken); err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusUnauthorized, errors.New("invalid access token"))
-				return
-			}
-			writeError(w, http.StatusInternalServerError, err)
+
+		if owner.SuspendedAt != nil {
+			writeError(w, r, http.StatusForbidden, errors.New("account is suspended"))
 			return
 		}
 
 		ctx = context.WithValue(ctx, "owner", owner)
+		ctx = context.WithValue(ctx, "session", session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func chairAuthMiddleware(next http.Handler) http.Handler {
+func (h *Handler) chairAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		c, err := r.Cookie("chair_session")
-		if errors.Is(err, http.ErrNoCookie) || c.Value == "" {
-			writeError(w, http.StatusUnauthorized, errors.New("chair_session cookie is required"))
-			return
-		}
-		accessToken := c.Value
 		chair := &Chair{}
-		err = db.GetContext(ctx, chair, "SELECT * FROM chairs WHERE access_token = ?", accessToken)
+		session, status, err := h.authenticateSession(r, "chair", chair)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusUnauthorized, errors.New("invalid access token"))
-				return
-			}
-			writeError(w, http.StatusInternalServerError, err)
+			writeError(w, r, status, err)
+			return
+		}
+
+		if chair.SuspendedAt != nil {
+			writeError(w, r, http.StatusForbidden, errors.New("account is suspended"))
 			return
 		}
 
 		ctx = context.WithValue(ctx, "chair", chair)
+		ctx = context.WithValue(ctx, "session", session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func adminAuthMiddleware(next http.Handler) http.Handler {
+	adminUser := config.Admin.User
+	adminPassword := config.Admin.Password
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		// 管理者の認証情報が設定されていなければ管理APIは使えない
+		if adminUser == "" || adminPassword == "" {
+			writeError(w, r, http.StatusNotFound, errors.New("admin api is disabled"))
+			return
+		}
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(adminUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(adminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="isuride-admin"`)
+			writeError(w, r, http.StatusUnauthorized, errors.New("invalid admin credentials"))
+			return
+		}
+
+		ctx = context.WithValue(ctx, "admin", username)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// マイグレーションは migrations/NNNN_名前.up.sql と .down.sql の組で、バージョンの小さい順に適用する。
+// 一度リリースしたファイルは書き換えず、変更は新しいバージョンとして足す
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// sql/1-schema.sql と 4-alter.sql で作られるスキーマのバージョン
+const baselineMigrationVersion = 1
+
+// 複数のアプリが同時に適用しないように取るロック
+const migrationLockName = "isuride_schema_migrations"
+
+const migrationLockTimeoutSeconds = 60
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var (
+	ErrUnknownMigrationVersion = errors.New("unknown migration version")
+	ErrMigrationLockTimeout    = errors.New("timed out waiting for the migration lock")
+	// マイグレーションを入れる前から使っているDBは、どこまで適用済みかを先に記録する必要がある
+	ErrMigrationBaselineRequired = errors.New("tables already exist but no migration is recorded; run `isuride migrate baseline <version>` first")
+)
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type migrationState struct {
+	migration
+	AppliedAt *time.Time
+}
+
+var migrations = mustLoadMigrations()
+
+func mustLoadMigrations() []migration {
+	ms, err := loadMigrations()
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, e := range entries {
+		m := migrationFileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		b, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	ms := make([]migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if strings.TrimSpace(mg.Up) == "" || strings.TrimSpace(mg.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down scripts", mg.Version, mg.Name)
+		}
+		ms = append(ms, *mg)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+func latestMigrationVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func hasMigrationVersion(version int) bool {
+	if version == 0 {
+		return true
+	}
+	for _, m := range migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+const createSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+  version    INTEGER      NOT NULL COMMENT 'バージョン',
+  name       VARCHAR(255) NOT NULL COMMENT 'マイグレーション名',
+  applied_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '適用日時',
+  PRIMARY KEY (version)
+)
+  COMMENT = '適用済みのマイグレーションテーブル'`
+
+// 適用するあいだ、1本の接続でロックを取っておく
+func withMigrationLock(ctx context.Context, db *sqlx.DB, f func(conn *sqlx.Conn) error) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.GetContext(ctx, &locked, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeoutSeconds); err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return ErrMigrationLockTimeout
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+func appliedMigrationVersions(ctx context.Context, conn *sqlx.Conn) (map[int]time.Time, error) {
+	rows := []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}{}
+	if err := conn.SelectContext(ctx, &rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// DDLはトランザクションで囲めないので1文ずつ流し、最後まで通ったら適用済みとして記録する。
+// 途中で失敗したときは記録されないので、直してからもう一度流す
+func execMigrationScript(ctx context.Context, conn *sqlx.Conn, script string) error {
+	for _, stmt := range splitSQLStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// target までのマイグレーションを適用して、適用したものを返す
+func migrateUp(ctx context.Context, db *sqlx.DB, target int) ([]migration, error) {
+	if !hasMigrationVersion(target) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigrationVersion, target)
+	}
+	var done []migration
+	err := withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrationVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			// 0001 を流すと既存のテーブルとぶつかるので、記録のないまま作られたスキーマには適用しない
+			exists, err := hasUnversionedSchema(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrMigrationBaselineRequired
+			}
+		}
+		for _, m := range migrations {
+			if m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := execMigrationScript(ctx, conn, m.Up); err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// target より新しいマイグレーションを新しい順に戻して、戻したものを返す
+func migrateDown(ctx context.Context, db *sqlx.DB, target int) ([]migration, error) {
+	if !hasMigrationVersion(target) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigrationVersion, target)
+	}
+	var done []migration
+	err := withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrationVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if m.Version <= target {
+				break
+			}
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := execMigrationScript(ctx, conn, m.Down); err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 0001 で作るテーブルがすでにあるか
+func hasUnversionedSchema(ctx context.Context, conn *sqlx.Conn) (bool, error) {
+	exists := false
+	if err := conn.GetContext(ctx, &exists, "SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'chairs'"); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// マイグレーションを流さずに作られたスキーマを、version まで適用済みとして記録する。
+// init.sh で作り直した直後や、マイグレーションを入れる前から使っているDBで使う
+func markMigrationBaseline(ctx context.Context, db *sqlx.DB, version int) ([]migration, error) {
+	if !hasMigrationVersion(version) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigrationVersion, version)
+	}
+	var done []migration
+	err := withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrationVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if m.Version > version {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 適用済みの最新のバージョン。ロックは取らないので、適用中なら途中のバージョンを返す
+func currentMigrationVersion(ctx context.Context, q sqlx.QueryerContext) (int, error) {
+	version := 0
+	if err := sqlx.GetContext(ctx, q, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func migrationStatus(ctx context.Context, db *sqlx.DB) ([]migrationState, error) {
+	var states []migrationState
+	err := withMigrationLock(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrationVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			s := migrationState{migration: m}
+			if at, ok := applied[m.Version]; ok {
+				s.AppliedAt = &at
+			}
+			states = append(states, s)
+		}
+		return nil
+	})
+	return states, err
+}
+
+// ";" で区切って1文ずつに分ける。文字列・識別子の引用符と、"--"、"#"、"/* */" のコメントの中の ";" は区切りとみなさない
+func splitSQLStatements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			b.WriteByte(c)
+			for i++; i < len(script); i++ {
+				b.WriteByte(script[i])
+				if script[i] == '\\' && c != '`' && i+1 < len(script) {
+					i++
+					b.WriteByte(script[i])
+					continue
+				}
+				if script[i] == c {
+					break
+				}
+			}
+		case c == '#' || (c == '-' && isLineComment(script[i:])):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += 2 + end + 1
+			}
+			b.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// isuride migrate <up|down|baseline|status> [version] [flags...]
+//
+// up は version まで(省略したら最新まで)適用し、down は version まで(省略したら1つ前まで)戻す。
+// baseline はマイグレーションを入れる前から使っているDBに、version までを流さずに適用済みとして記録する。
+// ベンチマーカー向けの初期データで作ったDBなら、sql/4-alter.sql まで流してあれば 1 を指定する。
+// DBの接続先はアプリと同じ設定ファイル・環境変数・フラグで指定する
+func runMigrateCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: isuride migrate <up|down|baseline|status> [version] [flags...]")
+	}
+	command, args := args[0], args[1:]
+	version := -1
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[0])
+		}
+		version, args = v, args[1:]
+	}
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return err
+	}
+	migrationDB, err := sqlx.Connect("mysql", newDBConfig(cfg.DB).FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer migrationDB.Close()
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		if version < 0 {
+			version = latestMigrationVersion()
+		}
+		done, err := migrateUp(ctx, migrationDB, version)
+		for _, m := range done {
+			fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		if version < 0 {
+			states, err := migrationStatus(ctx, migrationDB)
+			if err != nil {
+				return err
+			}
+			// 適用済みのうち最新の1つだけ戻す
+			version = 0
+			for i := len(states) - 1; i >= 0; i-- {
+				if states[i].AppliedAt != nil {
+					if i > 0 {
+						version = states[i-1].Version
+					}
+					break
+				}
+			}
+		}
+		done, err := migrateDown(ctx, migrationDB, version)
+		for _, m := range done {
+			fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "baseline":
+		if version < 0 {
+			return errors.New("usage: isuride migrate baseline <version> [flags...]")
+		}
+		done, err := markMigrationBaseline(ctx, migrationDB, version)
+		for _, m := range done {
+			fmt.Fprintf(out, "marked %04d_%s as applied\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := migrationStatus(ctx, migrationDB)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied at " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command: %s", command)
+	}
+}
+
+// MySQLの "--" コメントは後ろに空白が要る
+func isLineComment(s string) bool {
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] == ' ' || s[2] == '\t' || s[2] == '\n' || s[2] == '\r')
+}
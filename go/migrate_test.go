@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// init.sh と同じ手順で作った、マイグレーションの記録がないDBに適用する
+func TestMigrateUnversionedDB(t *testing.T) {
+	addr := startEmptyTestDB(t)
+	conn, err := sqlx.Open("mysql", fmt.Sprintf("root@tcp(%s)/isuride?parseTime=true", addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	execSQLFile(t, conn, "../sql/1-schema.sql")
+	execSQLFile(t, conn, "../sql/2-master-data.sql")
+	execSQLFile(t, conn, "../sql/4-alter.sql")
+
+	ctx := context.Background()
+	if _, err := migrateUp(ctx, conn, latestMigrationVersion()); !errors.Is(err, ErrMigrationBaselineRequired) {
+		t.Fatalf("migrate up without baseline: err = %v, want %v", err, ErrMigrationBaselineRequired)
+	}
+
+	done, err := markMigrationBaseline(ctx, conn, baselineMigrationVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != 1 {
+		t.Fatalf("baseline marked %+v, want only 0001", done)
+	}
+	done, err = migrateUp(ctx, conn, latestMigrationVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != latestMigrationVersion()-baselineMigrationVersion {
+		t.Errorf("applied %d migrations, want %d", len(done), latestMigrationVersion()-baselineMigrationVersion)
+	}
+
+	// 0001 のあとに足したテーブルと列がそろっている
+	for _, q := range []string{
+		"SELECT COUNT(*) FROM scheduled_rides",
+		"SELECT COUNT(*) FROM sessions",
+		"SELECT COUNT(*) FROM api_keys",
+		"SELECT COUNT(*) FROM chairs WHERE suspended_at IS NULL AND total_distance = 0",
+	} {
+		var n int
+		if err := conn.Get(&n, q); err != nil {
+			t.Errorf("%s: %v", q, err)
+		}
+	}
+
+	// 0001 まで戻すと、ベースラインのスキーマに戻る
+	if _, err := migrateDown(ctx, conn, baselineMigrationVersion); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := conn.Get(&n, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'sessions'"); err != nil || n != 0 {
+		t.Errorf("sessions table after migrate down = %d, %v", n, err)
+	}
+}
+
+// init.sh が記録したバージョンは流さず、走行距離は初期データの値を入れる
+func TestMigrateInitialData(t *testing.T) {
+	addr := startEmptyTestDB(t)
+	conn, err := sqlx.Open("mysql", fmt.Sprintf("root@tcp(%s)/isuride?parseTime=true", addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	execSQLFile(t, conn, "../sql/1-schema.sql")
+	execSQLFile(t, conn, "../sql/2-master-data.sql")
+	// 3-initial-data.sql.gz は大きいので、初期データの椅子を1台だけ入れる
+	if _, err := conn.Exec("INSERT INTO chairs (id, owner_id, name, model, is_active, access_token) VALUES ('01JDFEF7MGXXCJKW1MNJXPA77A', 'owner', 'chair', 'AeroSeat', 1, 'token')"); err != nil {
+		t.Fatal(err)
+	}
+	execSQLFile(t, conn, "../sql/4-alter.sql")
+	execSQLFile(t, conn, "../sql/5-schema-migrations.sql")
+
+	ctx := context.Background()
+	done, err := migrateUp(ctx, conn, latestMigrationVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != latestMigrationVersion()-2 {
+		t.Errorf("applied %d migrations, want %d", len(done), latestMigrationVersion()-2)
+	}
+	for _, m := range done {
+		if m.Name == "backfill_chair_distances" {
+			t.Errorf("applied %04d_%s, which the initial data already has", m.Version, m.Name)
+		}
+	}
+
+	if err := loadInitialDistances(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	chair := Chair{}
+	if err := conn.Get(&chair, "SELECT * FROM chairs WHERE id = '01JDFEF7MGXXCJKW1MNJXPA77A'"); err != nil {
+		t.Fatal(err)
+	}
+	if chair.TotalDistance != 128 || chair.LoadedDistance != 19 || chair.Latitude.Int64 != -30 || chair.Longitude.Int64 != 33 {
+		t.Errorf("chair = %+v, want total 128, loaded 19 at (-30, 33)", chair)
+	}
+}
+
+// 走行距離の埋め戻しは、位置が記録されている椅子だけを数え直す
+func TestMigrateBackfillChairDistances(t *testing.T) {
+	addr := startEmptyTestDB(t)
+	conn, err := sqlx.Open("mysql", fmt.Sprintf("root@tcp(%s)/isuride?parseTime=true", addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	backfill := 0
+	for _, m := range migrations {
+		if m.Name == "backfill_chair_distances" {
+			backfill = m.Version
+		}
+	}
+	if _, err := migrateUp(ctx, conn, backfill-1); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		"INSERT INTO chairs (id, owner_id, name, model, is_active, access_token) VALUES ('moved', 'owner', 'moved', 'AeroSeat', 1, 'moved')",
+		"INSERT INTO chairs (id, owner_id, name, model, is_active, access_token, total_distance, loaded_distance, latitude, longitude) VALUES ('unmoved', 'owner', 'unmoved', 'AeroSeat', 1, 'unmoved', 42, 10, 5, 6)",
+		"INSERT INTO chair_locations (id, chair_id, latitude, longitude, created_at) VALUES ('loc1', 'moved', 0, 0, '2024-11-01 00:00:00'), ('loc2', 'moved', 3, 4, '2024-11-01 00:00:01')",
+	} {
+		if _, err := conn.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrateUp(ctx, conn, backfill); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		id                  string
+		total, loaded       int
+		latitude, longitude int64
+	}{
+		{id: "moved", total: 3 + 4, loaded: 0, latitude: 3, longitude: 4},
+		// 履歴がないので、それまでの値のまま
+		{id: "unmoved", total: 42, loaded: 10, latitude: 5, longitude: 6},
+	} {
+		chair := Chair{}
+		if err := conn.Get(&chair, "SELECT * FROM chairs WHERE id = ?", tt.id); err != nil {
+			t.Fatal(err)
+		}
+		if chair.TotalDistance != tt.total || chair.LoadedDistance != tt.loaded || chair.Latitude.Int64 != tt.latitude || chair.Longitude.Int64 != tt.longitude {
+			t.Errorf("chair %s = %+v, want total %d, loaded %d at (%d, %d)", tt.id, chair, tt.total, tt.loaded, tt.latitude, tt.longitude)
+		}
+	}
+}
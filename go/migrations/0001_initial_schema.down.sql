@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS coupons;
+DROP TABLE IF EXISTS owners;
+DROP TABLE IF EXISTS ride_statuses;
+DROP TABLE IF EXISTS rides;
+DROP TABLE IF EXISTS payment_tokens;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS chair_locations;
+DROP TABLE IF EXISTS chairs;
+DROP TABLE IF EXISTS chair_models;
+DROP TABLE IF EXISTS settings;
@@ -0,0 +1,139 @@
+CREATE TABLE settings
+(
+  name  VARCHAR(30) NOT NULL COMMENT '設定名',
+  value TEXT        NOT NULL COMMENT '設定値',
+  PRIMARY KEY (name)
+)
+  COMMENT = 'システム設定テーブル';
+
+CREATE TABLE chair_models
+(
+  name  VARCHAR(50) NOT NULL COMMENT '椅子モデル名',
+  speed INTEGER     NOT NULL COMMENT '移動速度',
+  PRIMARY KEY (name)
+)
+  COMMENT = '椅子モデルテーブル';
+
+CREATE TABLE chairs
+(
+  id           VARCHAR(26)  NOT NULL COMMENT '椅子ID',
+  owner_id     VARCHAR(26)  NOT NULL COMMENT 'オーナーID',
+  name         VARCHAR(30)  NOT NULL COMMENT '椅子の名前',
+  model        TEXT         NOT NULL COMMENT '椅子のモデル',
+  is_active    TINYINT(1)   NOT NULL COMMENT '配椅子受付中かどうか',
+  access_token VARCHAR(255) NOT NULL COMMENT 'アクセストークン',
+  created_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '登録日時',
+  updated_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '更新日時',
+  PRIMARY KEY (id)
+)
+  COMMENT = '椅子情報テーブル';
+
+CREATE INDEX owner_id_idx ON chairs (owner_id);
+CREATE INDEX access_token_idx ON chairs (access_token);
+
+CREATE TABLE chair_locations
+(
+  id         VARCHAR(26) NOT NULL,
+  chair_id   VARCHAR(26) NOT NULL COMMENT '椅子ID',
+  latitude   INTEGER     NOT NULL COMMENT '経度',
+  longitude  INTEGER     NOT NULL COMMENT '緯度',
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '登録日時',
+  PRIMARY KEY (id),
+  INDEX chair_id_catd (chair_id, created_at DESC)
+)
+  COMMENT = '椅子の現在位置情報テーブル';
+
+CREATE TABLE users
+(
+  id              VARCHAR(26)  NOT NULL COMMENT 'ユーザーID',
+  username        VARCHAR(30)  NOT NULL COMMENT 'ユーザー名',
+  firstname       VARCHAR(30)  NOT NULL COMMENT '本名(名前)',
+  lastname        VARCHAR(30)  NOT NULL COMMENT '本名(名字)',
+  date_of_birth   VARCHAR(30)  NOT NULL COMMENT '生年月日',
+  access_token    VARCHAR(255) NOT NULL COMMENT 'アクセストークン',
+  invitation_code VARCHAR(30)  NOT NULL COMMENT '招待トークン',
+  created_at      DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '登録日時',
+  updated_at      DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '更新日時',
+  PRIMARY KEY (id),
+  UNIQUE (username),
+  UNIQUE (access_token),
+  UNIQUE (invitation_code)
+)
+  COMMENT = '利用者情報テーブル';
+
+CREATE TABLE payment_tokens
+(
+  user_id    VARCHAR(26)  NOT NULL COMMENT 'ユーザーID',
+  token      VARCHAR(255) NOT NULL COMMENT '決済トークン',
+  created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '登録日時',
+  PRIMARY KEY (user_id)
+)
+  COMMENT = '決済トークンテーブル';
+
+CREATE TABLE rides
+(
+  id                    VARCHAR(26) NOT NULL COMMENT 'ライドID',
+  user_id               VARCHAR(26) NOT NULL COMMENT 'ユーザーID',
+  chair_id              VARCHAR(26) NULL     COMMENT '割り当てられた椅子ID',
+  pickup_latitude       INTEGER     NOT NULL COMMENT '配車位置(経度)',
+  pickup_longitude      INTEGER     NOT NULL COMMENT '配車位置(緯度)',
+  destination_latitude  INTEGER     NOT NULL COMMENT '目的地(経度)',
+  destination_longitude INTEGER     NOT NULL COMMENT '目的地(緯度)',
+  evaluation            INTEGER     NULL     COMMENT '評価',
+  created_at            DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '要求日時',
+  updated_at            DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '状態更新日時',
+  PRIMARY KEY (id)
+)
+  COMMENT = 'ライド情報テーブル';
+
+CREATE INDEX idx_chair_updated ON rides (chair_id, updated_at DESC);
+CREATE INDEX idx_user_id_created_at ON rides (user_id, created_at DESC);
+CREATE INDEX idx_chair_id_created_at ON rides (chair_id, created_at DESC);
+
+CREATE TABLE ride_statuses
+(
+  id              VARCHAR(26)                                                                NOT NULL,
+  ride_id VARCHAR(26)                                                                        NOT NULL COMMENT 'ライドID',
+  status          ENUM ('MATCHING', 'ENROUTE', 'PICKUP', 'CARRYING', 'ARRIVED', 'COMPLETED') NOT NULL COMMENT '状態',
+  created_at      DATETIME(6)                                                                NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '状態変更日時',
+  app_sent_at     DATETIME(6)                                                                NULL COMMENT 'ユーザーへの状態通知日時',
+  chair_sent_at   DATETIME(6)                                                                NULL COMMENT '椅子への状態通知日時',
+  PRIMARY KEY (id),
+  INDEX  ride_id_catd (ride_id, created_at DESC),
+  INDEX  ride_id_cata (ride_id, created_at ASC)
+)
+  COMMENT = 'ライドステータスの変更履歴テーブル';
+
+CREATE TABLE owners
+(
+  id                   VARCHAR(26)  NOT NULL COMMENT 'オーナーID',
+  name                 VARCHAR(30)  NOT NULL COMMENT 'オーナー名',
+  access_token         VARCHAR(255) NOT NULL COMMENT 'アクセストークン',
+  chair_register_token VARCHAR(255) NOT NULL COMMENT '椅子登録トークン',
+  created_at           DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '登録日時',
+  updated_at           DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '更新日時',
+  PRIMARY KEY (id),
+  UNIQUE (name),
+  UNIQUE (access_token),
+  UNIQUE (chair_register_token)
+)
+  COMMENT = '椅子のオーナー情報テーブル';
+
+CREATE TABLE coupons
+(
+  user_id    VARCHAR(26)  NOT NULL COMMENT '所有しているユーザーのID',
+  code       VARCHAR(255) NOT NULL COMMENT 'クーポンコード',
+  discount   INTEGER      NOT NULL COMMENT '割引額',
+  created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '付与日時',
+  used_by    VARCHAR(26)  NULL COMMENT 'クーポンが適用されたライドのID',
+  PRIMARY KEY (user_id, code)
+)
+  COMMENT 'クーポンテーブル';
+
+CREATE INDEX used_by_idx ON coupons (used_by);
+
+ALTER TABLE chairs ADD COLUMN total_distance INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chairs ADD COLUMN total_distance_updated_at DATETIME(6);
+
+ALTER TABLE chairs ADD COLUMN latitude INTEGER;
+ALTER TABLE chairs ADD COLUMN longitude INTEGER;
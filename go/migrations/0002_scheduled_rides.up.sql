@@ -0,0 +1,17 @@
+CREATE TABLE scheduled_rides
+(
+  id                    VARCHAR(26) NOT NULL COMMENT '予約ID',
+  user_id               VARCHAR(26) NOT NULL COMMENT 'ユーザーID',
+  pickup_latitude       INTEGER     NOT NULL COMMENT '配車位置(経度)',
+  pickup_longitude      INTEGER     NOT NULL COMMENT '配車位置(緯度)',
+  destination_latitude  INTEGER     NOT NULL COMMENT '目的地(経度)',
+  destination_longitude INTEGER     NOT NULL COMMENT '目的地(緯度)',
+  scheduled_at          DATETIME(6) NOT NULL COMMENT '希望配車日時',
+  ride_id               VARCHAR(26) NULL     COMMENT '配車済みの場合のライドID',
+  canceled_at           DATETIME(6) NULL     COMMENT 'キャンセル日時',
+  created_at            DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '予約日時',
+  PRIMARY KEY (id),
+  INDEX user_id_scheduled_at (user_id, scheduled_at),
+  INDEX pending_scheduled_at (ride_id, canceled_at, scheduled_at)
+)
+  COMMENT = '予約ライドテーブル';
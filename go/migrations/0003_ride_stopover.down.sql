@@ -0,0 +1,5 @@
+-- STOPOVER の履歴が残っていると戻せないので、先に消しておく
+DELETE FROM ride_statuses WHERE status = 'STOPOVER';
+ALTER TABLE ride_statuses MODIFY COLUMN status ENUM ('MATCHING', 'ENROUTE', 'PICKUP', 'CARRYING', 'ARRIVED', 'COMPLETED') NOT NULL COMMENT '状態';
+ALTER TABLE rides DROP COLUMN waypoint_count;
+DROP TABLE IF EXISTS ride_waypoints;
@@ -0,0 +1,13 @@
+CREATE TABLE ride_waypoints
+(
+  ride_id    VARCHAR(26) NOT NULL COMMENT 'ライドID',
+  seq        INTEGER     NOT NULL COMMENT '経由順',
+  latitude   INTEGER     NOT NULL COMMENT '経由地(経度)',
+  longitude  INTEGER     NOT NULL COMMENT '経由地(緯度)',
+  arrived_at DATETIME(6) NULL     COMMENT '経由地への到着日時',
+  PRIMARY KEY (ride_id, seq)
+)
+  COMMENT = 'ライドの経由地テーブル';
+
+ALTER TABLE rides ADD COLUMN waypoint_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE ride_statuses MODIFY COLUMN status ENUM ('MATCHING', 'ENROUTE', 'PICKUP', 'CARRYING', 'STOPOVER', 'ARRIVED', 'COMPLETED') NOT NULL COMMENT '状態';
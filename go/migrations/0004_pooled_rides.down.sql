@@ -0,0 +1,2 @@
+DROP INDEX idx_pooled_chair ON rides;
+ALTER TABLE rides DROP COLUMN pooled;
@@ -0,0 +1,2 @@
+ALTER TABLE rides ADD COLUMN pooled TINYINT(1) NOT NULL DEFAULT 0;
+CREATE INDEX idx_pooled_chair ON rides (pooled, chair_id, evaluation);
@@ -0,0 +1,38 @@
+CREATE TABLE ride_reviews
+(
+  ride_id       VARCHAR(26)            NOT NULL COMMENT 'ライドID',
+  reviewer_type ENUM ('user', 'chair') NOT NULL COMMENT '評価した側',
+  rating        INTEGER                NOT NULL COMMENT '評価',
+  comment       TEXT                   NULL     COMMENT 'コメント',
+  tags          TEXT                   NULL     COMMENT 'タグ(JSON配列)',
+  created_at    DATETIME(6)            NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '評価日時',
+  PRIMARY KEY (ride_id, reviewer_type)
+)
+  COMMENT = 'ライドの相互評価テーブル';
+
+CREATE TABLE reputations
+(
+  subject_type ENUM ('user', 'chair', 'owner') NOT NULL COMMENT '評価対象の種類',
+  subject_id   VARCHAR(26)                     NOT NULL COMMENT '評価対象のID',
+  rating_count INTEGER                         NOT NULL DEFAULT 0 COMMENT '評価数',
+  rating_sum   INTEGER                         NOT NULL DEFAULT 0 COMMENT '評価の合計',
+  updated_at   DATETIME(6)                     NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '更新日時',
+  PRIMARY KEY (subject_type, subject_id)
+)
+  COMMENT = '評価の集計テーブル';
+
+-- 初期データの評価を集計テーブルに反映
+INSERT INTO reputations (subject_type, subject_id, rating_count, rating_sum)
+SELECT 'chair', r.chair_id, COUNT(*), SUM(r.evaluation)
+FROM rides r
+WHERE r.evaluation IS NOT NULL
+  AND EXISTS (SELECT 1 FROM ride_statuses rs WHERE rs.ride_id = r.id AND rs.status = 'COMPLETED')
+GROUP BY r.chair_id;
+
+INSERT INTO reputations (subject_type, subject_id, rating_count, rating_sum)
+SELECT 'owner', c.owner_id, COUNT(*), SUM(r.evaluation)
+FROM rides r
+       JOIN chairs c ON c.id = r.chair_id
+WHERE r.evaluation IS NOT NULL
+  AND EXISTS (SELECT 1 FROM ride_statuses rs WHERE rs.ride_id = r.id AND rs.status = 'COMPLETED')
+GROUP BY c.owner_id;
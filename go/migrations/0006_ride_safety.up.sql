@@ -0,0 +1,28 @@
+CREATE TABLE ride_shares
+(
+  token      VARCHAR(64) NOT NULL COMMENT '共有トークン',
+  ride_id    VARCHAR(26) NOT NULL COMMENT 'ライドID',
+  user_id    VARCHAR(26) NOT NULL COMMENT '共有したユーザーID',
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '共有日時',
+  PRIMARY KEY (token),
+  INDEX ride_id_idx (ride_id)
+)
+  COMMENT = 'ライドの共有リンクテーブル';
+
+CREATE TABLE incidents
+(
+  id          VARCHAR(26)                  NOT NULL COMMENT 'インシデントID',
+  ride_id     VARCHAR(26)                  NOT NULL COMMENT 'ライドID',
+  user_id     VARCHAR(26)                  NOT NULL COMMENT '通報したユーザーID',
+  chair_id    VARCHAR(26)                  NULL     COMMENT '通報時に割り当てられていた椅子ID',
+  kind        ENUM ('SOS')                 NOT NULL COMMENT '種類',
+  message     TEXT                         NULL     COMMENT 'メッセージ',
+  latitude    INTEGER                      NULL     COMMENT '通報位置(経度)',
+  longitude   INTEGER                      NULL     COMMENT '通報位置(緯度)',
+  status      ENUM ('OPEN', 'RESOLVED')    NOT NULL DEFAULT 'OPEN' COMMENT '対応状況',
+  created_at  DATETIME(6)                  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '通報日時',
+  resolved_at DATETIME(6)                  NULL     COMMENT '対応完了日時',
+  PRIMARY KEY (id),
+  INDEX status_created_at (status, created_at)
+)
+  COMMENT = 'オペレーター確認用のインシデントテーブル';
@@ -0,0 +1,13 @@
+CREATE TABLE admin_audit_logs
+(
+  id          VARCHAR(26)  NOT NULL COMMENT 'ログID',
+  actor       VARCHAR(255) NOT NULL COMMENT '操作した管理者',
+  action      VARCHAR(64)  NOT NULL COMMENT '操作',
+  target_type VARCHAR(32)  NOT NULL COMMENT '操作対象の種類',
+  target_id   VARCHAR(255) NOT NULL COMMENT '操作対象のID',
+  detail      TEXT         NULL     COMMENT '詳細(JSON)',
+  created_at  DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '操作日時',
+  PRIMARY KEY (id),
+  INDEX target (target_type, target_id, created_at)
+)
+  COMMENT = '管理操作の監査ログテーブル';
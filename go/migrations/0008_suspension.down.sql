@@ -0,0 +1,3 @@
+ALTER TABLE chairs DROP COLUMN suspended_at;
+ALTER TABLE owners DROP COLUMN suspended_at;
+ALTER TABLE users DROP COLUMN suspended_at;
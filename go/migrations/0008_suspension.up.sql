@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN suspended_at DATETIME(6) NULL;
+ALTER TABLE owners ADD COLUMN suspended_at DATETIME(6) NULL;
+ALTER TABLE chairs ADD COLUMN suspended_at DATETIME(6) NULL;
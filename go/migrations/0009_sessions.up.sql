@@ -0,0 +1,19 @@
+CREATE TABLE sessions
+(
+  id                 VARCHAR(26)                     NOT NULL COMMENT 'セッションID',
+  subject_type       ENUM ('user', 'owner', 'chair') NOT NULL COMMENT 'ログインしている主体の種類',
+  subject_id         VARCHAR(26)                     NOT NULL COMMENT 'ログインしている主体のID',
+  token_hash         CHAR(64)                        NOT NULL COMMENT 'セッショントークンのSHA-256',
+  refresh_token_hash CHAR(64)                        NOT NULL COMMENT 'リフレッシュトークンのSHA-256',
+  device             VARCHAR(255)                    NOT NULL DEFAULT '' COMMENT '端末名',
+  expires_at         DATETIME(6)                     NOT NULL COMMENT 'セッショントークンの有効期限',
+  refresh_expires_at DATETIME(6)                     NOT NULL COMMENT 'リフレッシュトークンの有効期限',
+  created_at         DATETIME(6)                     NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '作成日時',
+  updated_at         DATETIME(6)                     NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6) COMMENT '更新日時',
+  revoked_at         DATETIME(6)                     NULL     COMMENT '失効日時',
+  PRIMARY KEY (id),
+  UNIQUE token_hash (token_hash),
+  UNIQUE refresh_token_hash (refresh_token_hash),
+  INDEX subject (subject_type, subject_id)
+)
+  COMMENT = 'ログインセッションテーブル';
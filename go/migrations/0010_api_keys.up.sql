@@ -0,0 +1,17 @@
+CREATE TABLE api_keys
+(
+  id           VARCHAR(26)             NOT NULL COMMENT 'APIキーID',
+  owner_id     VARCHAR(26)             NOT NULL COMMENT '発行したオーナーID',
+  subject_type ENUM ('owner', 'chair') NOT NULL COMMENT '認証される主体の種類',
+  subject_id   VARCHAR(26)             NOT NULL COMMENT '認証される主体のID',
+  name         VARCHAR(255)            NOT NULL COMMENT 'キーの名前',
+  key_hash     CHAR(64)                NOT NULL COMMENT 'キーのSHA-256',
+  scopes       VARCHAR(255)            NOT NULL COMMENT '許可するスコープ(カンマ区切り)',
+  expires_at   DATETIME(6)             NULL     COMMENT '有効期限',
+  last_used_at DATETIME(6)             NULL     COMMENT '最終利用日時',
+  created_at   DATETIME(6)             NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '発行日時',
+  revoked_at   DATETIME(6)             NULL     COMMENT '失効日時',
+  PRIMARY KEY (id),
+  INDEX owner_id (owner_id, created_at)
+)
+  COMMENT = 'APIキーテーブル';
@@ -0,0 +1,3 @@
+ALTER TABLE rides DROP COLUMN loaded_distance;
+ALTER TABLE rides DROP COLUMN pickup_distance;
+ALTER TABLE chairs DROP COLUMN loaded_distance;
@@ -0,0 +1,5 @@
+-- 乗客を乗せて走った距離。total_distance との差が空車で走った距離になる
+ALTER TABLE chairs ADD COLUMN loaded_distance INTEGER NOT NULL DEFAULT 0;
+-- ライドごとの、迎車で走った距離と乗客を乗せて走った距離
+ALTER TABLE rides ADD COLUMN pickup_distance INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rides ADD COLUMN loaded_distance INTEGER NOT NULL DEFAULT 0;
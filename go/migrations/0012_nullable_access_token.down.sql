@@ -0,0 +1,7 @@
+-- NOT NULL に戻せるよう、access_token のないものには推測できない値を入れる
+UPDATE users SET access_token = SHA2(UUID(), 256) WHERE access_token IS NULL;
+UPDATE owners SET access_token = SHA2(UUID(), 256) WHERE access_token IS NULL;
+UPDATE chairs SET access_token = SHA2(UUID(), 256) WHERE access_token IS NULL;
+ALTER TABLE chairs MODIFY COLUMN access_token VARCHAR(255) NOT NULL COMMENT 'アクセストークン';
+ALTER TABLE owners MODIFY COLUMN access_token VARCHAR(255) NOT NULL COMMENT 'アクセストークン';
+ALTER TABLE users MODIFY COLUMN access_token VARCHAR(255) NOT NULL COMMENT 'アクセストークン';
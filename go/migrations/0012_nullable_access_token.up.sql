@@ -0,0 +1,4 @@
+-- 新しく登録するユーザー・オーナー・椅子には access_token を発行しない。初期データのものだけが残る
+ALTER TABLE users MODIFY COLUMN access_token VARCHAR(255) NULL COMMENT 'アクセストークン';
+ALTER TABLE owners MODIFY COLUMN access_token VARCHAR(255) NULL COMMENT 'アクセストークン';
+ALTER TABLE chairs MODIFY COLUMN access_token VARCHAR(255) NULL COMMENT 'アクセストークン';
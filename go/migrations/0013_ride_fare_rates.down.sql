@@ -0,0 +1,2 @@
+ALTER TABLE rides DROP COLUMN fare_per_distance;
+ALTER TABLE rides DROP COLUMN initial_fare;
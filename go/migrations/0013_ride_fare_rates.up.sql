@@ -0,0 +1,4 @@
+-- ライドを作った時点の運賃の設定。あとで設定を変えても、そのライドの請求額と売上は変わらない。
+-- 既存のライドは設定を変えられるようになる前の運賃で作られている
+ALTER TABLE rides ADD COLUMN initial_fare INTEGER NOT NULL DEFAULT 500;
+ALTER TABLE rides ADD COLUMN fare_per_distance INTEGER NOT NULL DEFAULT 100;
@@ -0,0 +1 @@
+-- 埋めた走行距離はそのまま残す。数え直すときは isuride distances rebuild を使う
@@ -0,0 +1,111 @@
+-- 走行距離を chair_locations と ride_statuses から集合演算で埋める。規則は computeChairDistances と同じで、
+-- 位置が記録された時点で最新だったライドのステータスで、迎車中か乗車中かを決める。
+-- 位置が記録されていない椅子とそのライドは数え直せないので、いまの値のままにする
+DROP TABLE IF EXISTS distance_backfill_moves;
+DROP TABLE IF EXISTS distance_backfill_statuses;
+
+CREATE TABLE distance_backfill_moves
+(
+  id         VARCHAR(26) NOT NULL,
+  chair_id   VARCHAR(26) NOT NULL,
+  latitude   INTEGER     NOT NULL,
+  longitude  INTEGER     NOT NULL,
+  created_at DATETIME(6) NOT NULL,
+  -- 直前の位置からの距離。椅子の最初の位置では NULL
+  distance   INTEGER     NULL,
+  -- 椅子の最後の位置なら 1
+  is_last    INTEGER     NOT NULL,
+  PRIMARY KEY (id),
+  INDEX chair_id_created_at_idx (chair_id, created_at)
+);
+
+INSERT INTO distance_backfill_moves (id, chair_id, latitude, longitude, created_at, distance, is_last)
+SELECT id,
+       chair_id,
+       latitude,
+       longitude,
+       created_at,
+       ABS(latitude - LAG(latitude) OVER (PARTITION BY chair_id ORDER BY created_at, id)) +
+       ABS(longitude - LAG(longitude) OVER (PARTITION BY chair_id ORDER BY created_at, id)),
+       ROW_NUMBER() OVER (PARTITION BY chair_id ORDER BY created_at DESC, id DESC) = 1
+FROM chair_locations;
+
+-- 迎車中・乗車中のステータスが続いた期間。next_created_at は次のステータスの日時で、最新のステータスなら NULL
+CREATE TABLE distance_backfill_statuses
+(
+  ride_id         VARCHAR(26) NOT NULL,
+  chair_id        VARCHAR(26) NOT NULL,
+  carrying        INTEGER     NOT NULL,
+  created_at      DATETIME(6) NOT NULL,
+  next_created_at DATETIME(6) NULL,
+  INDEX chair_id_created_at_idx (chair_id, created_at)
+);
+
+INSERT INTO distance_backfill_statuses (ride_id, chair_id, carrying, created_at, next_created_at)
+SELECT s.ride_id, s.chair_id, s.status <> 'ENROUTE', s.created_at, s.next_created_at
+FROM (SELECT rs.ride_id,
+             r.chair_id,
+             rs.status,
+             rs.created_at,
+             LEAD(rs.created_at) OVER (PARTITION BY rs.ride_id ORDER BY rs.created_at, rs.id) AS next_created_at
+      FROM ride_statuses rs
+             JOIN rides r ON r.id = rs.ride_id
+      WHERE r.chair_id IS NOT NULL) s
+WHERE s.status IN ('ENROUTE', 'CARRYING', 'STOPOVER');
+
+UPDATE rides
+SET pickup_distance = 0,
+    loaded_distance = 0,
+    updated_at      = updated_at
+WHERE chair_id IN (SELECT chair_id FROM distance_backfill_moves);
+
+UPDATE rides r
+  JOIN (SELECT s.ride_id,
+               SUM(CASE WHEN s.carrying = 0 THEN m.distance ELSE 0 END) AS pickup_distance,
+               SUM(CASE WHEN s.carrying = 1 THEN m.distance ELSE 0 END) AS loaded_distance
+        FROM distance_backfill_moves m
+               JOIN distance_backfill_statuses s
+                    ON s.chair_id = m.chair_id
+                      AND s.created_at < m.created_at
+                      AND (s.next_created_at IS NULL OR s.next_created_at >= m.created_at)
+        WHERE m.distance IS NOT NULL
+        GROUP BY s.ride_id) d ON d.ride_id = r.id
+SET r.pickup_distance = d.pickup_distance,
+    r.loaded_distance = d.loaded_distance,
+    r.updated_at      = r.updated_at;
+
+UPDATE chairs
+SET total_distance            = 0,
+    loaded_distance           = 0,
+    total_distance_updated_at = NULL
+WHERE id IN (SELECT chair_id FROM distance_backfill_moves);
+
+UPDATE chairs c
+  JOIN (SELECT m.chair_id,
+               COALESCE(SUM(m.distance), 0)                                  AS total_distance,
+               MAX(CASE WHEN m.distance IS NOT NULL THEN m.created_at END)   AS total_distance_updated_at,
+               MAX(CASE WHEN m.is_last = 1 THEN m.latitude END)              AS latitude,
+               MAX(CASE WHEN m.is_last = 1 THEN m.longitude END)             AS longitude
+        FROM distance_backfill_moves m
+        GROUP BY m.chair_id) d ON d.chair_id = c.id
+SET c.total_distance            = d.total_distance,
+    c.total_distance_updated_at = d.total_distance_updated_at,
+    c.latitude                  = d.latitude,
+    c.longitude                 = d.longitude;
+
+-- 乗車中のライドが1つでもあった区間を椅子の loaded_distance に足す
+UPDATE chairs c
+  JOIN (SELECT m.chair_id, SUM(m.distance) AS loaded_distance
+        FROM distance_backfill_moves m
+        WHERE m.distance IS NOT NULL
+          AND EXISTS (SELECT 1
+                      FROM distance_backfill_statuses s
+                      WHERE s.chair_id = m.chair_id
+                        AND s.carrying = 1
+                        AND s.created_at < m.created_at
+                        AND (s.next_created_at IS NULL OR s.next_created_at >= m.created_at))
+        GROUP BY m.chair_id) d ON d.chair_id = c.id
+SET c.loaded_distance = d.loaded_distance;
+
+DROP TABLE distance_backfill_moves;
+DROP TABLE distance_backfill_statuses;
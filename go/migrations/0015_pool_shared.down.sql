@@ -0,0 +1 @@
+ALTER TABLE rides DROP COLUMN pool_shared;
@@ -0,0 +1,2 @@
+-- 相乗りを希望したかではなく、実際にほかの乗客と同じ椅子に乗ったかで相乗り運賃にする
+ALTER TABLE rides ADD COLUMN pool_shared TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,23 @@
+-- ハッシュから元のトークンには戻せないので、発行済みの共有リンクは使えなくなる
+CREATE TABLE ride_shares_backup AS
+SELECT token_hash, ride_id, user_id, created_at
+FROM ride_shares
+WHERE revoked_at IS NULL;
+
+DROP TABLE ride_shares;
+CREATE TABLE ride_shares
+(
+  token      VARCHAR(64) NOT NULL COMMENT '共有トークン',
+  ride_id    VARCHAR(26) NOT NULL COMMENT 'ライドID',
+  user_id    VARCHAR(26) NOT NULL COMMENT '共有したユーザーID',
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '共有日時',
+  PRIMARY KEY (token),
+  INDEX ride_id_idx (ride_id)
+)
+  COMMENT = 'ライドの共有リンクテーブル';
+
+INSERT INTO ride_shares (token, ride_id, user_id, created_at)
+SELECT token_hash, ride_id, user_id, created_at
+FROM ride_shares_backup;
+
+DROP TABLE ride_shares_backup;
@@ -0,0 +1,23 @@
+-- 共有トークンは平文で持たず、ハッシュにして保存する。発行済みのものもハッシュにして移す
+CREATE TABLE ride_shares_backup AS
+SELECT SHA2(token, 256) AS token_hash, ride_id, user_id, created_at
+FROM ride_shares;
+
+DROP TABLE ride_shares;
+CREATE TABLE ride_shares
+(
+  token_hash VARCHAR(64) NOT NULL COMMENT '共有トークンのハッシュ',
+  ride_id    VARCHAR(26) NOT NULL COMMENT 'ライドID',
+  user_id    VARCHAR(26) NOT NULL COMMENT '共有したユーザーID',
+  created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '共有日時',
+  revoked_at DATETIME(6) NULL COMMENT '共有停止日時',
+  PRIMARY KEY (token_hash),
+  INDEX ride_id_idx (ride_id)
+)
+  COMMENT = 'ライドの共有リンクテーブル';
+
+INSERT INTO ride_shares (token_hash, ride_id, user_id, created_at)
+SELECT token_hash, ride_id, user_id, created_at
+FROM ride_shares_backup;
+
+DROP TABLE ride_shares_backup;
@@ -6,18 +6,20 @@ import (
 )
 
 type Chair struct {
-	ID                     string        `db:"id"`
-	OwnerID                string        `db:"owner_id"`
-	Name                   string        `db:"name"`
-	Model                  string        `db:"model"`
-	IsActive               bool          `db:"is_active"`
-	AccessToken            string        `db:"access_token"`
-	CreatedAt              time.Time     `db:"created_at"`
-	UpdatedAt              time.Time     `db:"updated_at"`
-	TotalDistance          int           `db:"total_distance"`
-	TotalDistanceUpdatedAt sql.NullTime  `db:"total_distance_updated_at"`
-	Latitude               sql.NullInt64 `db:"latitude"`
-	Longitude              sql.NullInt64 `db:"longitude"`
+	ID                     string         `db:"id"`
+	OwnerID                string         `db:"owner_id"`
+	Name                   string         `db:"name"`
+	Model                  string         `db:"model"`
+	IsActive               bool           `db:"is_active"`
+	AccessToken            sql.NullString `db:"access_token"`
+	CreatedAt              time.Time      `db:"created_at"`
+	UpdatedAt              time.Time      `db:"updated_at"`
+	TotalDistance          int            `db:"total_distance"`
+	TotalDistanceUpdatedAt sql.NullTime   `db:"total_distance_updated_at"`
+	LoadedDistance         int            `db:"loaded_distance"`
+	Latitude               sql.NullInt64  `db:"latitude"`
+	Longitude              sql.NullInt64  `db:"longitude"`
+	SuspendedAt            *time.Time     `db:"suspended_at"`
 }
 
 type ChairModel struct {
@@ -34,15 +36,16 @@ type ChairLocation struct {
 }
 
 type User struct {
-	ID             string    `db:"id"`
-	Username       string    `db:"username"`
-	Firstname      string    `db:"firstname"`
-	Lastname       string    `db:"lastname"`
-	DateOfBirth    string    `db:"date_of_birth"`
-	AccessToken    string    `db:"access_token"`
-	InvitationCode string    `db:"invitation_code"`
-	CreatedAt      time.Time `db:"created_at"`
-	UpdatedAt      time.Time `db:"updated_at"`
+	ID             string         `db:"id"`
+	Username       string         `db:"username"`
+	Firstname      string         `db:"firstname"`
+	Lastname       string         `db:"lastname"`
+	DateOfBirth    string         `db:"date_of_birth"`
+	AccessToken    sql.NullString `db:"access_token"`
+	InvitationCode string         `db:"invitation_code"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
+	SuspendedAt    *time.Time     `db:"suspended_at"`
 }
 
 type PaymentToken struct {
@@ -62,6 +65,22 @@ type Ride struct {
 	Evaluation           *int           `db:"evaluation"`
 	CreatedAt            time.Time      `db:"created_at"`
 	UpdatedAt            time.Time      `db:"updated_at"`
+	WaypointCount        int            `db:"waypoint_count"`
+	Pooled               bool           `db:"pooled"`
+	// ほかの乗客と実際に相乗りした
+	PoolShared      bool `db:"pool_shared"`
+	PickupDistance  int  `db:"pickup_distance"`
+	LoadedDistance  int  `db:"loaded_distance"`
+	InitialFare     int  `db:"initial_fare"`
+	FarePerDistance int  `db:"fare_per_distance"`
+}
+
+type RideWaypoint struct {
+	RideID    string     `db:"ride_id"`
+	Seq       int        `db:"seq"`
+	Latitude  int        `db:"latitude"`
+	Longitude int        `db:"longitude"`
+	ArrivedAt *time.Time `db:"arrived_at"`
 }
 
 type RideStatus struct {
@@ -74,12 +93,13 @@ type RideStatus struct {
 }
 
 type Owner struct {
-	ID                 string    `db:"id"`
-	Name               string    `db:"name"`
-	AccessToken        string    `db:"access_token"`
-	ChairRegisterToken string    `db:"chair_register_token"`
-	CreatedAt          time.Time `db:"created_at"`
-	UpdatedAt          time.Time `db:"updated_at"`
+	ID                 string         `db:"id"`
+	Name               string         `db:"name"`
+	AccessToken        sql.NullString `db:"access_token"`
+	ChairRegisterToken string         `db:"chair_register_token"`
+	CreatedAt          time.Time      `db:"created_at"`
+	UpdatedAt          time.Time      `db:"updated_at"`
+	SuspendedAt        *time.Time     `db:"suspended_at"`
 }
 
 type Coupon struct {
@@ -89,3 +109,93 @@ type Coupon struct {
 	CreatedAt time.Time `db:"created_at"`
 	UsedBy    *string   `db:"used_by"`
 }
+
+type ScheduledRide struct {
+	ID                   string         `db:"id"`
+	UserID               string         `db:"user_id"`
+	PickupLatitude       int            `db:"pickup_latitude"`
+	PickupLongitude      int            `db:"pickup_longitude"`
+	DestinationLatitude  int            `db:"destination_latitude"`
+	DestinationLongitude int            `db:"destination_longitude"`
+	ScheduledAt          time.Time      `db:"scheduled_at"`
+	RideID               sql.NullString `db:"ride_id"`
+	CanceledAt           *time.Time     `db:"canceled_at"`
+	CreatedAt            time.Time      `db:"created_at"`
+}
+
+type RideReview struct {
+	RideID       string    `db:"ride_id"`
+	ReviewerType string    `db:"reviewer_type"`
+	Rating       int       `db:"rating"`
+	Comment      *string   `db:"comment"`
+	Tags         *string   `db:"tags"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type Reputation struct {
+	SubjectType string    `db:"subject_type"`
+	SubjectID   string    `db:"subject_id"`
+	RatingCount int       `db:"rating_count"`
+	RatingSum   int       `db:"rating_sum"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+type RideShare struct {
+	TokenHash string     `db:"token_hash"`
+	RideID    string     `db:"ride_id"`
+	UserID    string     `db:"user_id"`
+	CreatedAt time.Time  `db:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+type Incident struct {
+	ID         string         `db:"id"`
+	RideID     string         `db:"ride_id"`
+	UserID     string         `db:"user_id"`
+	ChairID    sql.NullString `db:"chair_id"`
+	Kind       string         `db:"kind"`
+	Message    *string        `db:"message"`
+	Latitude   sql.NullInt64  `db:"latitude"`
+	Longitude  sql.NullInt64  `db:"longitude"`
+	Status     string         `db:"status"`
+	CreatedAt  time.Time      `db:"created_at"`
+	ResolvedAt *time.Time     `db:"resolved_at"`
+}
+
+type AdminAuditLog struct {
+	ID         string    `db:"id"`
+	Actor      string    `db:"actor"`
+	Action     string    `db:"action"`
+	TargetType string    `db:"target_type"`
+	TargetID   string    `db:"target_id"`
+	Detail     *string   `db:"detail"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type Session struct {
+	ID               string     `db:"id"`
+	SubjectType      string     `db:"subject_type"`
+	SubjectID        string     `db:"subject_id"`
+	TokenHash        string     `db:"token_hash"`
+	RefreshTokenHash string     `db:"refresh_token_hash"`
+	Device           string     `db:"device"`
+	ExpiresAt        time.Time  `db:"expires_at"`
+	RefreshExpiresAt time.Time  `db:"refresh_expires_at"`
+	CreatedAt        time.Time  `db:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at"`
+	RevokedAt        *time.Time `db:"revoked_at"`
+}
+
+type APIKey struct {
+	ID          string     `db:"id"`
+	OwnerID     string     `db:"owner_id"`
+	SubjectType string     `db:"subject_type"`
+	SubjectID   string     `db:"subject_id"`
+	Name        string     `db:"name"`
+	KeyHash     string     `db:"key_hash"`
+	Scopes      string     `db:"scopes"`
+	ExpiresAt   *time.Time `db:"expires_at"`
+	LastUsedAt  *time.Time `db:"last_used_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	RevokedAt   *time.Time `db:"revoked_at"`
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
 	"strconv"
@@ -10,11 +9,6 @@ import (
 	"github.com/oklog/ulid/v2"
 )
 
-const (
-	initialFare     = 500
-	farePerDistance = 100
-)
-
 type ownerPostOwnersRequest struct {
 	Name string `json:"name"`
 }
@@ -22,43 +16,52 @@ type ownerPostOwnersRequest struct {
 type ownerPostOwnersResponse struct {
 	ID                 string `json:"id"`
 	ChairRegisterToken string `json:"chair_register_token"`
+	// POST /api/owner/session でセッションを発行し直すためのトークン。このレスポンスでしか返さない
+	AccessToken string `json:"access_token"`
 }
 
-func ownerPostOwners(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ownerPostOwners(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	req := &ownerPostOwnersRequest{}
 	if err := bindJSON(r, req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, http.StatusBadRequest, err)
 		return
 	}
 	if req.Name == "" {
-		writeError(w, http.StatusBadRequest, errors.New("some of required fields(name) are empty"))
+		writeError(w, r, http.StatusBadRequest, errors.New("some of required fields(name) are empty"))
 		return
 	}
 
 	ownerID := ulid.Make().String()
-	accessToken := secureRandomStr(32)
 	chairRegisterToken := secureRandomStr(32)
+	accessToken := secureRandomStr(32)
 
-	_, err := db.ExecContext(
-		ctx,
-		"INSERT INTO owners (id, name, access_token, chair_register_token) VALUES (?, ?, ?, ?)",
-		ownerID, req.Name, accessToken, chairRegisterToken,
-	)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	var session issuedSession
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		if err := tx.Owners.Create(ctx, &Owner{
+			ID:                 ownerID,
+			Name:               req.Name,
+			AccessToken:        hashedAccessToken(accessToken),
+			ChairRegisterToken: chairRegisterToken,
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		session, err = createSession(ctx, tx.Sessions, "owner", ownerID, deviceName(r))
+		return err
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Path:  "/",
-		Name:  "owner_session",
-		Value: accessToken,
-	})
+	replicas.pinPrincipal(ctx, "owner."+ownerID)
+	setSessionCookies(w, "owner", session)
 
 	writeJSON(w, http.StatusCreated, &ownerPostOwnersResponse{
 		ID:                 ownerID,
 		ChairRegisterToken: chairRegisterToken,
+		AccessToken:        accessToken,
 	})
 }
 
@@ -79,14 +82,14 @@ type ownerGetSalesResponse struct {
 	Models     []modelSales `json:"models"`
 }
 
-func ownerGetSales(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ownerGetSales(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	since := time.Unix(0, 0)
 	until := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
 	if r.URL.Query().Get("since") != "" {
 		parsed, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
+			writeError(w, r, http.StatusBadRequest, err)
 			return
 		}
 		since = time.UnixMilli(parsed)
@@ -94,7 +97,7 @@ func ownerGetSales(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Query().Get("until") != "" {
 		parsed, err := strconv.ParseInt(r.URL.Query().Get("until"), 10, 64)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
+			writeError(w, r, http.StatusBadRequest, err)
 			return
 		}
 		until = time.UnixMilli(parsed)
@@ -102,42 +105,49 @@ func ownerGetSales(w http.ResponseWriter, r *http.Request) {
 
 	owner := r.Context().Value("owner").(*Owner)
 
-	tx, err := db.Beginx()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer tx.Rollback()
-
-	chairs := []Chair{}
-	if err := tx.SelectContext(ctx, &chairs, "SELECT * FROM chairs WHERE owner_id = ?", owner.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
-		return
-	}
-
 	res := ownerGetSalesResponse{
 		TotalSales: 0,
 	}
-
-	// N+1の匂い
 	modelSalesByModel := map[string]int{}
-	for _, chair := range chairs {
-		rides := []Ride{}
-		if err := tx.SelectContext(ctx, &rides, "SELECT rides.* FROM rides JOIN ride_statuses ON rides.id = ride_statuses.ride_id WHERE chair_id = ? AND status = 'COMPLETED' AND updated_at BETWEEN ? AND ? + INTERVAL 999 MICROSECOND", chair.ID, since, until); err != nil {
-			writeError(w, http.StatusInternalServerError, err)
-			return
+	if err := h.store.InReadOnlyTx(ctx, func(tx Repositories) error {
+		chairs, err := tx.Chairs.ListByOwner(ctx, owner.ID)
+		if err != nil {
+			return err
 		}
 
-		sales := sumSales(rides)
-		res.TotalSales += sales
-
-		res.Chairs = append(res.Chairs, chairSales{
-			ID:    chair.ID,
-			Name:  chair.Name,
-			Sales: sales,
-		})
-
-		modelSalesByModel[chair.Model] += sales
+		// N+1の匂い
+		for _, chair := range chairs {
+			rides, err := tx.Rides.ListCompletedByChair(ctx, chair.ID, since, until)
+			if err != nil {
+				return err
+			}
+
+			rideIDs := make([]string, 0, len(rides))
+			for _, ride := range rides {
+				if ride.WaypointCount > 0 {
+					rideIDs = append(rideIDs, ride.ID)
+				}
+			}
+			waypointsByRideID, err := tx.Rides.WaypointsByRideIDs(ctx, rideIDs)
+			if err != nil {
+				return err
+			}
+
+			sales := sumSales(rides, waypointsByRideID)
+			res.TotalSales += sales
+
+			res.Chairs = append(res.Chairs, chairSales{
+				ID:    chair.ID,
+				Name:  chair.Name,
+				Sales: sales,
+			})
+
+			modelSalesByModel[chair.Model] += sales
+		}
+		return nil
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
 	}
 
 	models := []modelSales{}
@@ -152,29 +162,23 @@ func ownerGetSales(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, res)
 }
 
-func sumSales(rides []Ride) int {
+func sumSales(rides []Ride, waypointsByRideID map[string][]Coordinate) int {
 	sale := 0
 	for _, ride := range rides {
-		sale += calculateSale(ride)
+		sale += calculateSale(ride, waypointsByRideID[ride.ID])
 	}
 	return sale
 }
 
-func calculateSale(ride Ride) int {
-	return calculateFare(ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude)
-}
-
-type chairWithDetail struct {
-	ID                     string       `db:"id"`
-	OwnerID                string       `db:"owner_id"`
-	Name                   string       `db:"name"`
-	AccessToken            string       `db:"access_token"`
-	Model                  string       `db:"model"`
-	IsActive               bool         `db:"is_active"`
-	CreatedAt              time.Time    `db:"created_at"`
-	UpdatedAt              time.Time    `db:"updated_at"`
-	TotalDistance          int          `db:"total_distance"`
-	TotalDistanceUpdatedAt sql.NullTime `db:"total_distance_updated_at"`
+// 設定が変わっても、ライドを作った時点の運賃で計上する
+func calculateSale(ride Ride, waypoints []Coordinate) int {
+	rates := ride.fareRates()
+	meteredFare := rates.FarePerDistance * calculateRouteDistance(
+		Coordinate{Latitude: ride.PickupLatitude, Longitude: ride.PickupLongitude},
+		waypoints,
+		Coordinate{Latitude: ride.DestinationLatitude, Longitude: ride.DestinationLongitude},
+	)
+	return rates.InitialFare + applyPooledRate(meteredFare, ride.PoolShared)
 }
 
 type ownerGetChairResponse struct {
@@ -189,39 +193,32 @@ type ownerGetChairResponseChair struct {
 	RegisteredAt           int64  `json:"registered_at"`
 	TotalDistance          int    `json:"total_distance"`
 	TotalDistanceUpdatedAt *int64 `json:"total_distance_updated_at,omitempty"`
+	// 乗客を乗せて走った距離と、迎車などで空車のまま走った距離
+	LoadedDistance int `json:"loaded_distance"`
+	EmptyDistance  int `json:"empty_distance"`
 }
 
-func ownerGetChairs(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ownerGetChairs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	owner := ctx.Value("owner").(*Owner)
 
-	chairs := []chairWithDetail{}
-	if err := db.SelectContext(ctx, &chairs, `SELECT id,
-       owner_id,
-       name,
-       access_token,
-       model,
-       is_active,
-       created_at,
-       updated_at,
-       total_distance,
-       total_distance_updated_at
-FROM chairs
-WHERE owner_id = ?
-`, owner.ID); err != nil {
-		writeError(w, http.StatusInternalServerError, err)
+	chairs, err := h.store.Chairs.ListByOwner(ctx, owner.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	res := ownerGetChairResponse{}
 	for _, chair := range chairs {
 		c := ownerGetChairResponseChair{
-			ID:            chair.ID,
-			Name:          chair.Name,
-			Model:         chair.Model,
-			Active:        chair.IsActive,
-			RegisteredAt:  chair.CreatedAt.UnixMilli(),
-			TotalDistance: chair.TotalDistance,
+			ID:             chair.ID,
+			Name:           chair.Name,
+			Model:          chair.Model,
+			Active:         chair.IsActive,
+			RegisteredAt:   chair.CreatedAt.UnixMilli(),
+			TotalDistance:  chair.TotalDistance,
+			LoadedDistance: chair.LoadedDistance,
+			EmptyDistance:  chair.TotalDistance - chair.LoadedDistance,
 		}
 		if chair.TotalDistanceUpdatedAt.Valid {
 			t := chair.TotalDistanceUpdatedAt.Time.UnixMilli()
@@ -29,6 +29,7 @@ func requestPaymentGatewayPostPayment(ctx context.Context, paymentGatewayURL str
 
 	// 失敗したらとりあえずリトライ
 	// FIXME: 社内決済マイクロサービスのインフラに異常が発生していて、同時にたくさんリクエストすると変なことになる可能性あり
+	settings := currentSettings()
 	retry := 0
 	for {
 		err := func() error {
@@ -39,7 +40,7 @@ func requestPaymentGatewayPostPayment(ctx context.Context, paymentGatewayURL str
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("Authorization", "Bearer "+token)
 
-			res, err := http.DefaultClient.Do(req)
+			res, err := httpClient().Do(req)
 			if err != nil {
 				return err
 			}
@@ -53,7 +54,7 @@ func requestPaymentGatewayPostPayment(ctx context.Context, paymentGatewayURL str
 				}
 				getReq.Header.Set("Authorization", "Bearer "+token)
 
-				getRes, err := http.DefaultClient.Do(getReq)
+				getRes, err := httpClient().Do(getReq)
 				if err != nil {
 					return err
 				}
@@ -82,11 +83,13 @@ func requestPaymentGatewayPostPayment(ctx context.Context, paymentGatewayURL str
 			return nil
 		}()
 		if err != nil {
-			if retry < 5 {
+			if retry < settings.PaymentRetryCount {
 				retry++
-				time.Sleep(100 * time.Millisecond)
+				paymentRetries.Inc()
+				time.Sleep(time.Duration(settings.PaymentRetryIntervalMs) * time.Millisecond)
 				continue
 			} else {
+				paymentFailures.Inc()
 				return err
 			}
 		}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+)
+
+const (
+	// 1台の椅子に相乗りできる乗客数
+	poolCapacity = 2
+	// 相乗りによって各乗客が遠回りしてよい距離
+	poolDetourBudget = 30
+	// 実際に相乗りしたときの距離運賃の割合(%)
+	pooledFareRate = 80
+)
+
+func applyPooledRate(meteredFare int, shared bool) int {
+	if !shared {
+		return meteredFare
+	}
+	return meteredFare * pooledFareRate / 100
+}
+
+type chairStop struct {
+	RideID     string     `json:"ride_id"`
+	Type       string     `json:"type"`
+	Coordinate Coordinate `json:"coordinate"`
+}
+
+type rideWithStatus struct {
+	Ride   Ride
+	Status string
+}
+
+// まだ乗せていない乗客を先に全員迎えに行き、その後依頼順に降ろす
+func planChairStops(rides []rideWithStatus) []chairStop {
+	rides = append([]rideWithStatus{}, rides...)
+	sort.SliceStable(rides, func(i, j int) bool {
+		return rides[i].Ride.CreatedAt.Before(rides[j].Ride.CreatedAt)
+	})
+
+	stops := []chairStop{}
+	for _, r := range rides {
+		if r.Status == "MATCHING" || r.Status == "ENROUTE" {
+			stops = append(stops, chairStop{
+				RideID:     r.Ride.ID,
+				Type:       "pickup",
+				Coordinate: Coordinate{Latitude: r.Ride.PickupLatitude, Longitude: r.Ride.PickupLongitude},
+			})
+		}
+	}
+	for _, r := range rides {
+		if r.Status == "ARRIVED" || r.Status == "COMPLETED" {
+			continue
+		}
+		stops = append(stops, chairStop{
+			RideID:     r.Ride.ID,
+			Type:       "dropoff",
+			Coordinate: Coordinate{Latitude: r.Ride.DestinationLatitude, Longitude: r.Ride.DestinationLongitude},
+		})
+	}
+	return stops
+}
+
+// 各乗客について、直行した場合と比べてどれだけ遠回りになるか
+func calculateDetours(current Coordinate, stops []chairStop) map[string]int {
+	detours := map[string]int{}
+	boardedAt := map[string]Coordinate{}
+	boardedDistance := map[string]int{}
+
+	traveled := 0
+	position := current
+	for _, stop := range stops {
+		traveled += calculateDistance(position.Latitude, position.Longitude, stop.Coordinate.Latitude, stop.Coordinate.Longitude)
+		position = stop.Coordinate
+		switch stop.Type {
+		case "pickup":
+			boardedAt[stop.RideID] = stop.Coordinate
+			boardedDistance[stop.RideID] = traveled
+		case "dropoff":
+			from, ok := boardedAt[stop.RideID]
+			if !ok {
+				// すでに乗っている乗客は現在地から
+				from = current
+			}
+			direct := calculateDistance(from.Latitude, from.Longitude, stop.Coordinate.Latitude, stop.Coordinate.Longitude)
+			detours[stop.RideID] = traveled - boardedDistance[stop.RideID] - direct
+		}
+	}
+	return detours
+}
+
+func routeLength(current Coordinate, stops []chairStop) int {
+	length := 0
+	position := current
+	for _, stop := range stops {
+		length += calculateDistance(position.Latitude, position.Longitude, stop.Coordinate.Latitude, stop.Coordinate.Longitude)
+		position = stop.Coordinate
+	}
+	return length
+}
+
+// 椅子が現在担当しているライド。相乗り中であれば未完了の相乗りライドも全て返す
+func getChairCurrentRides(ctx context.Context, rides RideRepository, chairID string) ([]Ride, error) {
+	latest, err := rides.LatestByChair(ctx, chairID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	current := []Ride{*latest}
+	if !latest.Pooled {
+		return current, nil
+	}
+
+	pooled, err := rides.ListActivePooledByChair(ctx, chairID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ride := range pooled {
+		if ride.ID != latest.ID {
+			current = append(current, ride)
+		}
+	}
+	return current, nil
+}
+
+func getRidesWithStatus(ctx context.Context, repo RideRepository, rides []Ride) ([]rideWithStatus, error) {
+	res := make([]rideWithStatus, 0, len(rides))
+	for _, ride := range rides {
+		status, err := getLatestRideStatus(ctx, repo, ride.ID)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rideWithStatus{Ride: ride, Status: status})
+	}
+	return res, nil
+}
+
+// 椅子に相乗りさせたときに増える走行距離。相乗りさせられない場合は false
+func poolJoinCost(chair Chair, current []rideWithStatus, ride Ride) (int, bool) {
+	if !chair.IsActive || chair.SuspendedAt != nil || !chair.Latitude.Valid || !chair.Longitude.Valid {
+		return 0, false
+	}
+	// 降車済みの乗客がいる椅子は空くのを待つ
+	for _, r := range current {
+		if r.Status == "ARRIVED" || r.Status == "COMPLETED" {
+			return 0, false
+		}
+	}
+
+	position := Coordinate{Latitude: int(chair.Latitude.Int64), Longitude: int(chair.Longitude.Int64)}
+	before := planChairStops(current)
+	after := planChairStops(append(current, rideWithStatus{Ride: ride, Status: "MATCHING"}))
+	for _, detour := range calculateDetours(position, after) {
+		if detour > poolDetourBudget {
+			return 0, false
+		}
+	}
+	return routeLength(position, after) - routeLength(position, before), true
+}
+
+// 相乗りの乗客を乗せられる椅子を探す。遠回りが予算内に収まる中で一番追加距離が短いものを選ぶ。
+// トランザクションの外で探すので、乗せるときは joinPool で定員を確かめ直す
+func findPoolableChair(ctx context.Context, repo Repositories, ride Ride) (*Chair, error) {
+	pooledRides, err := repo.Rides.ListActivePooled(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ridesByChairID := map[string][]Ride{}
+	for _, r := range pooledRides {
+		ridesByChairID[r.ChairID.String] = append(ridesByChairID[r.ChairID.String], r)
+	}
+
+	var best *Chair
+	bestCost := 0
+	for _, chairID := range sortedKeys(ridesByChairID) {
+		rides := ridesByChairID[chairID]
+		if len(rides) >= poolCapacity {
+			continue
+		}
+
+		chair, err := repo.Chairs.Get(ctx, chairID)
+		if err != nil {
+			return nil, err
+		}
+		current, err := getRidesWithStatus(ctx, repo.Rides, rides)
+		if err != nil {
+			return nil, err
+		}
+		cost, ok := poolJoinCost(*chair, current, ride)
+		if !ok {
+			continue
+		}
+
+		if best == nil || cost < bestCost {
+			best = chair
+			bestCost = cost
+		}
+	}
+	return best, nil
+}
+
+// 椅子をロックしてから定員や遠回り、椅子の状態を確かめ直し、乗せられるなら相乗りさせる。乗せられたら true
+func joinPool(ctx context.Context, tx Repositories, ride Ride, chairID string) (bool, error) {
+	chair, err := tx.Chairs.GetForUpdate(ctx, chairID)
+	if err != nil {
+		return false, err
+	}
+	current, err := tx.Rides.ListActivePooledByChair(ctx, chairID)
+	if err != nil {
+		return false, err
+	}
+	if len(current) == 0 || len(current) >= poolCapacity {
+		return false, nil
+	}
+	withStatus, err := getRidesWithStatus(ctx, tx.Rides, current)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := poolJoinCost(*chair, withStatus, ride); !ok {
+		return false, nil
+	}
+	// ほかのマッチングで先に割り当てられていないか
+	latest, err := tx.Rides.GetForUpdate(ctx, ride.ID)
+	if err != nil {
+		return false, err
+	}
+	if latest.ChairID.Valid {
+		return false, nil
+	}
+
+	shared := []string{ride.ID}
+	for _, r := range current {
+		shared = append(shared, r.ID)
+	}
+	if err := tx.Rides.MarkPoolShared(ctx, shared); err != nil {
+		return false, err
+	}
+	if err := saveMatchedRide(ctx, tx.Rides, ride, *chair); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 1秒あたりに補充されるトークン数と、貯められるトークンの上限
+type rateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ルートごとのデフォルトの制限。通知のポーリングは retry_after_ms の最短間隔(60ms)でも制限にかからないようにしている
+var defaultRateLimits = map[string]rateLimit{
+	"app_post_rides":      {Rate: 2, Burst: 5},
+	"app_estimated_fare":  {Rate: 5, Burst: 10},
+	"app_notification":    {Rate: 20, Burst: 40},
+	"chair_coordinate":    {Rate: 20, Burst: 40},
+	"chair_notification":  {Rate: 20, Burst: 40},
+	"owner_sales":         {Rate: 5, Burst: 10},
+	"app_nearby_chairs":   {Rate: 10, Burst: 20},
+	"chair_ride_status":   {Rate: 10, Burst: 20},
+	"app_ride_evaluation": {Rate: 5, Burst: 10},
+}
+
+// config.RateLimits に "chair_coordinate=10:20,app_notification=0" のように書いて上書きする。Rateが0なら制限しない
+func loadRateLimits(spec string) map[string]rateLimit {
+	limits := make(map[string]rateLimit, len(defaultRateLimits))
+	for name, limit := range defaultRateLimits {
+		limits[name] = limit
+	}
+
+	for _, entry := range splitRateLimits(spec) {
+		limit, err := parseRateLimit(entry)
+		if err != nil {
+			slog.Warn("ignored invalid rate limit", "entry", entry, "error", err)
+			continue
+		}
+		name, _, _ := strings.Cut(entry, "=")
+		limits[name] = limit
+	}
+	return limits
+}
+
+func splitRateLimits(spec string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func parseRateLimit(entry string) (rateLimit, error) {
+	_, value, ok := strings.Cut(entry, "=")
+	if !ok {
+		return rateLimit{}, fmt.Errorf("missing '='")
+	}
+	rateStr, burstStr, _ := strings.Cut(value, ":")
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate < 0 {
+		return rateLimit{}, fmt.Errorf("invalid rate: %s", rateStr)
+	}
+	burst := int(math.Ceil(rate))
+	if burstStr != "" {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return rateLimit{}, fmt.Errorf("invalid burst: %s", burstStr)
+		}
+	}
+	return rateLimit{Rate: rate, Burst: max(burst, 1)}, nil
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// バケツはプロセスごとに持つ。アプリケーションサーバーを並べると、台数倍までリクエストが通る
+type rateLimiter struct {
+	limit rateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(limit rateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:     limit,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// トークンを1つ消費する。足りなければ次のトークンが貯まるまでの時間を返す
+func (l *rateLimiter) take(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.limit.Burst), updatedAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(l.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*l.limit.Rate)
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// 満タンに戻ったバケットは持っていても意味がないので定期的に捨てる
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updatedAt) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// 認証ミドルウェアが積んだ利用者・オーナー・椅子をキーにする。未認証ならIPアドレス
+func rateLimitKey(r *http.Request) string {
+	ctx := r.Context()
+	if user, ok := ctx.Value("user").(*User); ok {
+		return "user:" + user.ID
+	}
+	if chair, ok := ctx.Value("chair").(*Chair); ok {
+		return "chair:" + chair.ID
+	}
+	if owner, ok := ctx.Value("owner").(*Owner); ok {
+		return "owner:" + owner.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+type rateLimitedResponse struct {
+	errorResponse
+	RetryAfterMs int `json:"retry_after_ms"`
+}
+
+// 認証ミドルウェアの後ろに置く
+func rateLimitMiddleware(limits map[string]rateLimit, name string) func(http.Handler) http.Handler {
+	limit, ok := limits[name]
+	if !ok || limit.Rate <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiter := newRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, wait := limiter.take(rateLimitKey(r), time.Now())
+			if !allowed {
+				retryAfterMs := int(math.Ceil(float64(wait) / float64(time.Millisecond)))
+				// Retry-Afterは秒単位なので切り上げる
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeJSON(w, http.StatusTooManyRequests, &rateLimitedResponse{
+					errorResponse: newErrorResponse(r, http.StatusTooManyRequests, "rate limit exceeded"),
+					RetryAfterMs:  retryAfterMs,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// これより大きいボディは記録しない
+const maxRecordedBodyBytes = 1 << 20
+
+// 記録するときに値を伏せるJSONのキー。再生時はレスポンスの値で対応が取れる
+var recordSecretKeys = map[string]bool{
+	"token":                true,
+	"key":                  true,
+	"access_token":         true,
+	"chair_register_token": true,
+	"invitation_code":      true,
+}
+
+// 記録しない個人情報のキー。名前や生年月日は候補が少なくハッシュから戻せるので、固定の値にする
+var recordPersonalKeys = map[string]bool{
+	"firstname":     true,
+	"lastname":      true,
+	"date_of_birth": true,
+}
+
+// 椅子への通知の user.name のように、親のキーと組み合わせて個人情報になるもの
+var recordPersonalNestedKeys = map[string]map[string]bool{
+	"user": {"name": true},
+}
+
+// 個人情報を伏せた値。再生時はどんな値とも一致するものとして比べる
+const redactedValue = "[redacted]"
+
+// この後ろのパスの要素は秘密の値なので伏せる
+var recordSecretPathPrefixes = []string{
+	"/api/share/",
+}
+
+// 記録する1往復分。秘密の値は secretPlaceholder に置き換えてある
+type trafficRecord struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// クエリ文字列を含む
+	Path string `json:"path"`
+	// リクエストで送られてきたセッションCookie(名前 → プレースホルダ)
+	Sessions map[string]string `json:"sessions,omitempty"`
+	// Authorization: Bearer で送られてきたAPIキーのプレースホルダ
+	Bearer      string          `json:"bearer,omitempty"`
+	RequestBody json.RawMessage `json:"request_body,omitempty"`
+
+	Status int `json:"status"`
+	// レスポンスで発行されたセッションCookie(名前 → プレースホルダ)
+	SetSessions  map[string]string `json:"set_sessions,omitempty"`
+	ResponseBody json.RawMessage   `json:"response_body,omitempty"`
+	DurationMs   float64           `json:"duration_ms"`
+}
+
+// 同じ値は同じプレースホルダになるので、再生時に誰のリクエストかを区別できる
+func secretPlaceholder(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "secret:" + hex.EncodeToString(sum[:8])
+}
+
+type trafficRecorder struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newTrafficRecorder(path string) (*trafficRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &trafficRecorder{out: f}, nil
+}
+
+func (tr *trafficRecorder) write(rec *trafficRecord) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("failed to encode traffic record", "error", err)
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if _, err := tr.out.Write(append(b, '\n')); err != nil {
+		slog.Error("failed to write traffic record", "error", err)
+	}
+}
+
+// /api/ へのリクエストとレスポンスを、Cookieやトークンを伏せて記録する
+func recordMiddleware(tr *trafficRecorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &trafficRecord{
+				Time:   time.Now(),
+				Method: r.Method,
+				Path:   redactRecordedPath(r.URL),
+			}
+			for _, c := range r.Cookies() {
+				if strings.HasSuffix(c.Name, "_session") && c.Value != "" {
+					if rec.Sessions == nil {
+						rec.Sessions = map[string]string{}
+					}
+					rec.Sessions[c.Name] = secretPlaceholder(c.Value)
+				}
+			}
+			if key, ok := bearerToken(r); ok {
+				rec.Bearer = secretPlaceholder(key)
+			}
+			if r.Body != nil {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					writeError(w, r, http.StatusBadRequest, err)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				rec.RequestBody = sanitizeRecordedBody(body)
+			}
+
+			var resBody bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&limitedWriter{w: &resBody, n: maxRecordedBodyBytes + 1})
+			next.ServeHTTP(ww, r)
+
+			rec.Status = ww.Status()
+			if rec.Status == 0 {
+				rec.Status = http.StatusOK
+			}
+			rec.DurationMs = float64(time.Since(rec.Time).Microseconds()) / 1000
+			for _, line := range ww.Header().Values("Set-Cookie") {
+				c, err := http.ParseSetCookie(line)
+				if err != nil || !strings.HasSuffix(c.Name, "_session") || c.Value == "" {
+					continue
+				}
+				if rec.SetSessions == nil {
+					rec.SetSessions = map[string]string{}
+				}
+				rec.SetSessions[c.Name] = secretPlaceholder(c.Value)
+			}
+			rec.ResponseBody = sanitizeRecordedBody(resBody.Bytes())
+
+			tr.write(rec)
+		})
+	}
+}
+
+// JSONでなかったり大きすぎたりするボディは記録しない
+func sanitizeRecordedBody(body []byte) json.RawMessage {
+	if len(body) == 0 || len(body) > maxRecordedBodyBytes {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	b, err := json.Marshal(redactSecrets("", v))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func redactSecrets(parentKey string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if s, ok := child.(string); ok && s != "" {
+				if recordSecretKeys[k] {
+					v[k] = secretPlaceholder(s)
+					continue
+				}
+				if recordPersonalKeys[k] || recordPersonalNestedKeys[parentKey][k] {
+					v[k] = redactedValue
+					continue
+				}
+			}
+			v[k] = redactSecrets(k, child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactSecrets(parentKey, child)
+		}
+	}
+	return v
+}
+
+// 共有リンクのトークンのようにパスに入っている秘密の値を、ボディと同じプレースホルダにする
+func redactRecordedPath(u *url.URL) string {
+	for _, prefix := range recordSecretPathPrefixes {
+		rest, ok := strings.CutPrefix(u.Path, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		secret, tail, _ := strings.Cut(rest, "/")
+		redacted := *u
+		redacted.Path = prefix + secretPlaceholder(secret)
+		if tail != "" {
+			redacted.Path += "/" + tail
+		}
+		redacted.RawPath = ""
+		return redacted.RequestURI()
+	}
+	return u.RequestURI()
+}
+
+// n バイトを超えた分は捨てる
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n <= 0 {
+		return len(p), nil
+	}
+	if len(p) > l.n {
+		l.w.Write(p[:l.n])
+		l.n = 0
+		return len(p), nil
+	}
+	l.n -= len(p)
+	return l.w.Write(p)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordMiddlewareRedaction(t *testing.T) {
+	var out bytes.Buffer
+	handler := recordMiddleware(&trafficRecorder{out: &out})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"user":  map[string]any{"id": "U1", "name": "Taro Isu"},
+			"chair": map[string]any{"name": "isu-1"},
+		})
+	}))
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest("POST", "/api/app/users", strings.NewReader(`{"username":"taro","firstname":"Taro","lastname":"Isu","date_of_birth":"2000-01-01","invitation_code":"abc"}`)),
+		httptest.NewRequest("GET", "/api/share/sharetoken123?x=1", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("recorded %d lines, want 2", len(lines))
+	}
+	for _, leaked := range []string{"Taro", "2000-01-01", "sharetoken123", `"abc"`} {
+		if strings.Contains(out.String(), leaked) {
+			t.Errorf("record contains %s:\n%s", leaked, out.String())
+		}
+	}
+
+	var signup, share trafficRecord
+	if err := json.Unmarshal([]byte(lines[0]), &signup); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &share); err != nil {
+		t.Fatal(err)
+	}
+	req := map[string]string{}
+	if err := json.Unmarshal(signup.RequestBody, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req["username"] != "taro" || req["firstname"] != redactedValue || req["date_of_birth"] != redactedValue {
+		t.Errorf("request body = %v", req)
+	}
+	if !strings.Contains(string(signup.ResponseBody), `"isu-1"`) {
+		t.Errorf("chair name was redacted: %s", signup.ResponseBody)
+	}
+	// トークンはボディと同じプレースホルダにして、再生時に発行された値へ置き換えられるようにする
+	if want := "/api/share/" + secretPlaceholder("sharetoken123") + "?x=1"; share.Path != want {
+		t.Errorf("path = %s, want %s", share.Path, want)
+	}
+}
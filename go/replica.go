@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// 読み取り専用のトランザクションを振り分けるレプリカ。setup で config.DB.ReplicaDSN があれば作る
+var replicas *replicaRouter
+
+var errNoReplicaStatus = errors.New("replica status has no lag column")
+
+type replica struct {
+	name string
+	db   *sqlx.DB
+	// 最後に確認した遅延(秒)。確認できていなければ -1
+	lagSeconds atomic.Int64
+}
+
+// 遅延が許容範囲のレプリカに順番に振り分ける。使えるレプリカがなければプライマリを使う
+type replicaRouter struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+
+	// 書き込んだ主体の読み取りを、レプリカに反映されるまでプライマリに向ける期間
+	pinDuration time.Duration
+	pinned      sync.Map // 主体 → time.Time
+	// 全員の読み取りをプライマリに向ける期限(UnixNano)
+	pinnedAllUntil atomic.Int64
+}
+
+// dsns はカンマ区切りの go-sql-driver 形式のDSN
+func newReplicaRouter(dsns string, c DBConfig) (*replicaRouter, error) {
+	router := &replicaRouter{
+		maxLag:      time.Duration(c.ReplicaMaxLagSeconds) * time.Second,
+		pinDuration: time.Duration(c.ReplicaMaxLagSeconds)*time.Second + time.Duration(c.ReplicaCheckIntervalMs)*time.Millisecond,
+	}
+	for _, dsn := range strings.Split(dsns, ",") {
+		dsn = strings.TrimSpace(dsn)
+		if dsn == "" {
+			continue
+		}
+		replicaConfig, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		replicaConfig.ParseTime = true
+		replicaConfig.InterpolateParams = true
+		replicaDB, err := openDB(replicaConfig.FormatDSN())
+		if err != nil {
+			router.Close()
+			return nil, err
+		}
+		replicaDB.SetMaxIdleConns(c.MaxIdleConns)
+		replicaDB.SetMaxOpenConns(c.MaxOpenConns)
+		r := &replica{name: replicaConfig.Addr, db: replicaDB}
+		r.lagSeconds.Store(-1)
+		router.replicas = append(router.replicas, r)
+	}
+	return router, nil
+}
+
+func (rr *replicaRouter) Close() {
+	for _, r := range rr.replicas {
+		if err := r.db.Close(); err != nil {
+			slog.Error("failed to close replica", "replica", r.name, "error", err)
+		}
+	}
+}
+
+// 遅延が許容範囲のレプリカを1つ選ぶ。なければ nil
+func (rr *replicaRouter) pick(ctx context.Context) *replica {
+	if rr == nil || len(rr.replicas) == 0 || rr.isPinned(ctx) {
+		return nil
+	}
+	start := rr.next.Add(1)
+	for i := range rr.replicas {
+		r := rr.replicas[(start+uint64(i))%uint64(len(rr.replicas))]
+		lag := r.lagSeconds.Load()
+		if lag >= 0 && time.Duration(lag)*time.Second <= rr.maxLag {
+			return r
+		}
+	}
+	return nil
+}
+
+// 書き込みのあとは、自分の書いた内容が読めるようにしばらくプライマリから読む
+func (rr *replicaRouter) pin(ctx context.Context) {
+	if _, ok := ctx.Value("admin").(string); ok {
+		rr.pinAll(ctx)
+		return
+	}
+	rr.pinPrincipal(ctx, principalKey(ctx))
+}
+
+// 固定はキャッシュにも書き、次のリクエストがほかのアプリケーションサーバーに届いても効くようにする
+var replicaPinCache = newTypedCache[bool]("replica.pin.")
+
+// 管理者の書き込みは誰のデータを変えたか分からないので、全員の読み取りをプライマリに向ける
+const replicaPinAllKey = "*"
+
+// 登録のように主体がまだ ctx にない書き込みでは、作った主体を直接指定する
+func (rr *replicaRouter) pinPrincipal(ctx context.Context, key string) {
+	if rr == nil || len(rr.replicas) == 0 || key == "" {
+		return
+	}
+	rr.pinned.Store(key, time.Now().Add(rr.pinDuration))
+	replicaPinCache.Set(ctx, key, true, rr.pinDuration)
+}
+
+func (rr *replicaRouter) pinAll(ctx context.Context) {
+	if rr == nil || len(rr.replicas) == 0 {
+		return
+	}
+	rr.pinnedAllUntil.Store(time.Now().Add(rr.pinDuration).UnixNano())
+	replicaPinCache.Set(ctx, replicaPinAllKey, true, rr.pinDuration)
+}
+
+func (rr *replicaRouter) isPinned(ctx context.Context) bool {
+	if time.Now().UnixNano() < rr.pinnedAllUntil.Load() {
+		return true
+	}
+	key := principalKey(ctx)
+	if key != "" {
+		if v, ok := rr.pinned.Load(key); ok {
+			if time.Now().Before(v.(time.Time)) {
+				return true
+			}
+			rr.pinned.CompareAndDelete(key, v)
+		}
+	}
+	// ほかのアプリケーションサーバーで書き込んだ固定
+	if _, ok := replicaPinCache.Get(ctx, replicaPinAllKey); ok {
+		return true
+	}
+	if key == "" {
+		return false
+	}
+	_, ok := replicaPinCache.Get(ctx, key)
+	return ok
+}
+
+// 書き込みのリクエストのあとは、Store を通らない書き込みも含めて主体の読み取りをプライマリに向ける。
+// 認証ミドルウェアのあとに積む
+func pinWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			replicas.pin(r.Context())
+		}
+	})
+}
+
+// 認証ミドルウェアが積んだ主体。主体がなければ空文字列
+func principalKey(ctx context.Context) string {
+	if user, ok := ctx.Value("user").(*User); ok {
+		return "user." + user.ID
+	}
+	if chair, ok := ctx.Value("chair").(*Chair); ok {
+		return "chair." + chair.ID
+	}
+	if owner, ok := ctx.Value("owner").(*Owner); ok {
+		return "owner." + owner.ID
+	}
+	return ""
+}
+
+// ctx が切れるまで、interval ごとにレプリカの遅延を確認する
+func startReplicaMonitor(ctx context.Context, rr *replicaRouter, interval time.Duration) {
+	check := func() {
+		for _, r := range rr.replicas {
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			lag, err := replicaLag(checkCtx, r.db)
+			cancel()
+			if err != nil {
+				if r.lagSeconds.Swap(-1) >= 0 {
+					slog.Warn("replica is unavailable", "replica", r.name, "error", err)
+				}
+				continue
+			}
+			if r.lagSeconds.Swap(int64(lag/time.Second)) < 0 {
+				slog.Info("replica is available", "replica", r.name, "lag", lag)
+			}
+			replicaLagSeconds.WithLabelValues(r.name).Set(lag.Seconds())
+		}
+	}
+	check()
+
+	startBackgroundWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	})
+}
+
+// SHOW REPLICA STATUS の Seconds_Behind_Source。レプリケーションしていない(行がない)ときは 0 とみなす
+func replicaLag(ctx context.Context, q *sqlx.DB) (time.Duration, error) {
+	rows, err := q.QueryxContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL 8.0.22 より前
+		rows, err = q.QueryxContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	status := map[string]any{}
+	if err := rows.MapScan(status); err != nil {
+		return 0, err
+	}
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		v, ok := status[column]
+		if !ok {
+			continue
+		}
+		// レプリケーションが止まっていると NULL になる
+		if v == nil {
+			return 0, errors.New("replication is not running")
+		}
+		s, ok := v.([]byte)
+		if !ok {
+			s = []byte(fmt.Sprint(v))
+		}
+		seconds, err := strconv.ParseInt(string(s), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errNoReplicaStatus
+}
+
+// 読み取り専用のトランザクションを始める。レプリカが使えなければプライマリで始める
+func (rr *replicaRouter) beginReadOnly(ctx context.Context, primary *sqlx.DB) (*sqlx.Tx, error) {
+	opts := &sql.TxOptions{ReadOnly: true}
+	if r := rr.pick(ctx); r != nil {
+		tx, err := r.db.BeginTxx(ctx, opts)
+		if err == nil {
+			readRoutes.WithLabelValues("replica").Inc()
+			return tx, nil
+		}
+		slog.Warn("failed to begin transaction on replica", "replica", r.name, "error", err)
+	}
+	readRoutes.WithLabelValues("primary").Inc()
+	return primary.BeginTxx(ctx, opts)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 見つからない場合は、どの実装でも sql.ErrNoRows を返す
+
+// 一意なキーが重複したときにメモリ上の実装が返す。MySQL ではエラー番号 1062 になる
+var errDuplicateEntry = errors.New("duplicate entry")
+
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.Is(err, errDuplicateEntry) || (errors.As(err, &mysqlErr) && mysqlErr.Number == 1062)
+}
+
+type RideRepository interface {
+	Get(ctx context.Context, id string) (*Ride, error)
+	GetForUpdate(ctx context.Context, id string) (*Ride, error)
+	// created_at の昇順
+	ListByUser(ctx context.Context, userID string) ([]Ride, error)
+	LatestByUser(ctx context.Context, userID string) (*Ride, error)
+	CountByUser(ctx context.Context, userID string) (int, error)
+	// created_at の降順
+	ListByChair(ctx context.Context, chairID string) ([]Ride, error)
+	// 椅子に最後に割り当てられた(updated_at が最新の)ライド
+	LatestByChair(ctx context.Context, chairID string) (*Ride, error)
+	// 椅子が割り当てられていないライド。created_at の昇順
+	ListUnmatched(ctx context.Context) ([]Ride, error)
+	// 椅子が割り当てられていて、評価されていない相乗りライド。created_at の昇順
+	ListActivePooled(ctx context.Context) ([]Ride, error)
+	// 評価されていない相乗りライド。created_at の昇順
+	ListActivePooledByChair(ctx context.Context, chairID string) ([]Ride, error)
+	// since から until までに完了したライド
+	ListCompletedByChair(ctx context.Context, chairID string, since time.Time, until time.Time) ([]Ride, error)
+	Create(ctx context.Context, ride *Ride) error
+	SetChair(ctx context.Context, rideID string, chairID string) error
+	// 実際に相乗りしたことを記録する。updated_at は変えない
+	MarkPoolShared(ctx context.Context, rideIDs []string) error
+	SetEvaluation(ctx context.Context, rideID string, evaluation int) error
+
+	AddStatus(ctx context.Context, rideID string, status string) error
+	LatestStatus(ctx context.Context, rideID string) (string, error)
+	// 椅子に割り当てられたライドがすべてCOMPLETEDになり、そこまでのステータスを椅子に通知し終えているか。
+	// 経由地があるとステータスの数が変わるので、数ではなく通知済みかどうかで判定する
+	IsChairFree(ctx context.Context, chairID string) (bool, error)
+	// ユーザーにまだ通知していない一番古いステータス
+	NextUnsentAppStatus(ctx context.Context, rideID string) (*RideStatus, error)
+	MarkAppSent(ctx context.Context, statusID string) error
+	// 椅子にまだ通知していない一番古いステータス
+	NextUnsentChairStatus(ctx context.Context, rideIDs []string) (*RideStatus, error)
+	MarkChairSent(ctx context.Context, statusID string) error
+
+	AddWaypoints(ctx context.Context, rideID string, waypoints []Coordinate) error
+	// seq の昇順
+	Waypoints(ctx context.Context, rideID string) ([]RideWaypoint, error)
+	WaypointsByRideIDs(ctx context.Context, rideIDs []string) (map[string][]Coordinate, error)
+	MarkWaypointArrived(ctx context.Context, rideID string, seq int) error
+
+	// 迎車中と乗車中に走った距離を足す
+	AddDistance(ctx context.Context, rideID string, pickup int, loaded int) error
+
+	// created_at の昇順
+	Statuses(ctx context.Context, rideID string) ([]RideStatus, error)
+	// 管理APIの検索。空の条件では絞り込まない。created_at の降順
+	Search(ctx context.Context, userID string, chairID string, limit int) ([]Ride, error)
+}
+
+// まとめて書き込む椅子の移動。位置は Locations の最後になる
+type ChairMove struct {
+	ChairID string
+	// 受け付けた順。chair_locations には受け付けた日時で記録する
+	Locations      []ChairLocation
+	Distance       int
+	LoadedDistance int
+	// 最後に距離を数えた日時。初めて位置を送ってきただけならゼロ値
+	MovedAt time.Time
+}
+
+type ChairRepository interface {
+	Get(ctx context.Context, id string) (*Chair, error)
+	GetForUpdate(ctx context.Context, id string) (*Chair, error)
+	ListByOwner(ctx context.Context, ownerID string) ([]Chair, error)
+	// 配車を受け付けていて、位置が分かっている椅子
+	ListAvailable(ctx context.Context) ([]Chair, error)
+	// 配車を受け付けていて、範囲内にいる椅子
+	ListAvailableInArea(ctx context.Context, min Coordinate, max Coordinate) ([]Chair, error)
+	// 配車を受け付けていて位置が分かっている椅子と、そのモデルの速度
+	ListAvailableWithSpeed(ctx context.Context) ([]chairWithSpeed, error)
+	// access_token カラムの値で引く。登録時に発行したものはハッシュにして保存してある
+	GetByAccessToken(ctx context.Context, token string) (*Chair, error)
+	// 管理APIの検索。IDが q と一致するか名前に q を含む椅子。created_at の降順
+	Search(ctx context.Context, q string, ownerID string, limit int) ([]Chair, error)
+	SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error
+	Create(ctx context.Context, chair *Chair) error
+	SetActive(ctx context.Context, id string, active bool) error
+	// 位置は chair_locations にも記録し、走行距離を作り直すときに使う。
+	// 初めて位置を送ってきたときは走行距離を数えない
+	SetLocation(ctx context.Context, id string, to Coordinate) error
+	// loaded なら乗客を乗せて走った距離にも足す
+	Move(ctx context.Context, id string, to Coordinate, distance int, loaded bool) error
+	// SetLocation と Move をまとめて行う
+	ApplyMoves(ctx context.Context, moves []ChairMove) error
+}
+
+type UserRepository interface {
+	Get(ctx context.Context, id string) (*User, error)
+	GetByInvitationCode(ctx context.Context, code string) (*User, error)
+	// access_token カラムの値で引く。登録時に発行したものはハッシュにして保存してある
+	GetByAccessToken(ctx context.Context, token string) (*User, error)
+	Create(ctx context.Context, user *User) error
+	// 管理APIの検索。IDが q と一致するか、ユーザー名が q で始まるか、氏名に q を含むユーザー。created_at の降順
+	Search(ctx context.Context, q string, limit int) ([]User, error)
+	SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error
+}
+
+type OwnerRepository interface {
+	Get(ctx context.Context, id string) (*Owner, error)
+	GetByChairRegisterToken(ctx context.Context, token string) (*Owner, error)
+	// access_token カラムの値で引く。登録時に発行したものはハッシュにして保存してある
+	GetByAccessToken(ctx context.Context, token string) (*Owner, error)
+	Create(ctx context.Context, owner *Owner) error
+	// 管理APIの検索。IDが q と一致するか名前に q を含むオーナー。created_at の降順
+	Search(ctx context.Context, q string, limit int) ([]Owner, error)
+	SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error
+}
+
+type CouponRepository interface {
+	Create(ctx context.Context, coupon *Coupon) error
+	// 招待コードで付与したクーポンの数。招待する側の上限を数えるのでロックする
+	CountByCodeForUpdate(ctx context.Context, code string) (int, error)
+	// ライドに適用済みのクーポン
+	GetByRide(ctx context.Context, rideID string) (*Coupon, error)
+	GetUnused(ctx context.Context, userID string, code string) (*Coupon, error)
+	GetUnusedForUpdate(ctx context.Context, userID string, code string) (*Coupon, error)
+	// 付与された順で最初の未使用クーポン
+	OldestUnused(ctx context.Context, userID string) (*Coupon, error)
+	OldestUnusedForUpdate(ctx context.Context, userID string) (*Coupon, error)
+	Use(ctx context.Context, userID string, code string, rideID string) error
+}
+
+type PaymentRepository interface {
+	SaveToken(ctx context.Context, userID string, token string) error
+	GetToken(ctx context.Context, userID string) (*PaymentToken, error)
+}
+
+type ReviewRepository interface {
+	Exists(ctx context.Context, rideID string, reviewerType string) (bool, error)
+	Create(ctx context.Context, review *RideReview) error
+	// 評価対象ごとの集計に加える
+	AddRating(ctx context.Context, subjectType string, subjectID string, rating int) error
+	// まだ評価されていなければ件数0の集計を返す
+	GetReputation(ctx context.Context, subjectType string, subjectID string) (Reputation, error)
+	// 十分な件数の評価があり、平均が低い評価対象のID
+	ListLowRated(ctx context.Context, subjectType string) (map[string]bool, error)
+	// 椅子、またはオーナーの椅子が利用者から受けた評価。created_at の降順
+	ListRecentBySubject(ctx context.Context, subjectType string, subjectID string, limit int) ([]RideReview, error)
+}
+
+type ScheduledRideRepository interface {
+	Create(ctx context.Context, scheduledRide *ScheduledRide) error
+	GetForUpdate(ctx context.Context, id string) (*ScheduledRide, error)
+	// 配車もキャンセルもされていない予約。scheduled_at の昇順
+	ListPendingByUser(ctx context.Context, userID string) ([]ScheduledRide, error)
+	// until までに配車時刻が来る、配車もキャンセルもされていない予約。scheduled_at の昇順
+	ListPendingUntil(ctx context.Context, until time.Time) ([]ScheduledRide, error)
+	Cancel(ctx context.Context, id string) error
+	SetRide(ctx context.Context, id string, rideID string) error
+}
+
+type ShareRepository interface {
+	Create(ctx context.Context, share *RideShare) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*RideShare, error)
+	// ライドの共有リンクを全て止める
+	RevokeByRide(ctx context.Context, rideID string) error
+}
+
+type IncidentRepository interface {
+	Create(ctx context.Context, incident *Incident) error
+	// created_at の昇順
+	ListByStatus(ctx context.Context, status string, limit int) ([]Incident, error)
+	// OPEN のインシデントを RESOLVED にする。OPEN のものがなければ false
+	Resolve(ctx context.Context, id string) (bool, error)
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	Get(ctx context.Context, id string) (*Session, error)
+	GetByTokenHash(ctx context.Context, subjectType string, tokenHash string) (*Session, error)
+	GetByRefreshTokenHashForUpdate(ctx context.Context, subjectType string, refreshTokenHash string) (*Session, error)
+	// 失効しておらず、リフレッシュできるセッション。created_at の降順
+	ListActive(ctx context.Context, subjectType string, subjectID string, now time.Time) ([]Session, error)
+	// トークンを両方とも差し替えて期限を延ばす
+	Rotate(ctx context.Context, id string, tokenHash string, refreshTokenHash string, expiresAt time.Time, refreshExpiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, apiKey *APIKey) error
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// created_at の降順
+	ListByOwner(ctx context.Context, ownerID string) ([]APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	SetLastUsed(ctx context.Context, id string, at time.Time) error
+}
+
+type AuditLogRepository interface {
+	Create(ctx context.Context, log *AdminAuditLog) error
+	// 空の条件では絞り込まない。created_at の降順
+	Search(ctx context.Context, targetType string, targetID string, limit int) ([]AdminAuditLog, error)
+}
+
+type Repositories struct {
+	Rides          RideRepository
+	Chairs         ChairRepository
+	Users          UserRepository
+	Owners         OwnerRepository
+	Coupons        CouponRepository
+	Payments       PaymentRepository
+	Reviews        ReviewRepository
+	ScheduledRides ScheduledRideRepository
+	Shares         ShareRepository
+	Incidents      IncidentRepository
+	Sessions       SessionRepository
+	APIKeys        APIKeyRepository
+	AuditLogs      AuditLogRepository
+}
+
+// ハンドラーから使う永続化層。トランザクションの外ではそのまま Repositories を使う
+type Store struct {
+	Repositories
+	inTx         func(ctx context.Context, fn func(tx Repositories) error) error
+	inReadOnlyTx func(ctx context.Context, fn func(tx Repositories) error) error
+	withLock     func(ctx context.Context, name string, fn func() error) error
+}
+
+// fn の中の操作を1つのトランザクションで行う。fn がエラーを返したらロールバックする
+func (s *Store) InTx(ctx context.Context, fn func(tx Repositories) error) error {
+	return s.inTx(ctx, fn)
+}
+
+// 読み取りだけの fn を1つのトランザクションで行う。少し古いデータを読んでもよい処理に使い、
+// レプリカがあればレプリカで実行する
+func (s *Store) InReadOnlyTx(ctx context.Context, fn func(tx Repositories) error) error {
+	return s.inReadOnlyTx(ctx, fn)
+}
+
+// name のロックを取って fn を行う。MySQL ではDBのロックなので、複数のアプリケーションサーバーのあいだでも排他になる
+func (s *Store) WithLock(ctx context.Context, name string, fn func() error) error {
+	return s.withLock(ctx, name, fn)
+}
@@ -0,0 +1,1402 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// テストやローカルでの確認用に、DBなしで動かすための実装。
+// トランザクションは直列に実行し、失敗したら開始時点の状態に戻す。
+// トランザクションの外から並行して書き込んだ内容はロールバックで消えることがある
+func newMemoryStore() *Store {
+	s := &memoryStore{data: newMemoryData()}
+	inTx := func(ctx context.Context, fn func(tx Repositories) error) error {
+		s.txMu.Lock()
+		defer s.txMu.Unlock()
+
+		s.mu.Lock()
+		snapshot := s.data.clone()
+		s.mu.Unlock()
+
+		if err := fn(s.repositories()); err != nil {
+			s.mu.Lock()
+			s.data = snapshot
+			s.mu.Unlock()
+			return err
+		}
+		return nil
+	}
+	return &Store{
+		Repositories: s.repositories(),
+		inTx:         inTx,
+		inReadOnlyTx: inTx,
+		withLock: func(ctx context.Context, name string, fn func() error) error {
+			mu, _ := s.locks.LoadOrStore(name, &sync.Mutex{})
+			mu.(*sync.Mutex).Lock()
+			defer mu.(*sync.Mutex).Unlock()
+			return fn()
+		},
+	}
+}
+
+type memoryStore struct {
+	txMu  sync.Mutex
+	mu    sync.Mutex
+	data  *memoryData
+	locks sync.Map // 名前 → *sync.Mutex
+}
+
+func (s *memoryStore) repositories() Repositories {
+	return Repositories{
+		Rides:          memoryRideRepository{s},
+		Chairs:         memoryChairRepository{s},
+		Users:          memoryUserRepository{s},
+		Owners:         memoryOwnerRepository{s},
+		Coupons:        memoryCouponRepository{s},
+		Payments:       memoryPaymentRepository{s},
+		Reviews:        memoryReviewRepository{s},
+		ScheduledRides: memoryScheduledRideRepository{s},
+		Shares:         memoryShareRepository{s},
+		Incidents:      memoryIncidentRepository{s},
+		Sessions:       memorySessionRepository{s},
+		APIKeys:        memoryAPIKeyRepository{s},
+		AuditLogs:      memoryAuditLogRepository{s},
+	}
+}
+
+type memoryData struct {
+	rides          map[string]Ride
+	rideStatuses   []RideStatus
+	rideWaypoints  map[string][]RideWaypoint
+	chairs         map[string]Chair
+	chairLocations []ChairLocation
+	users          map[string]User
+	owners         map[string]Owner
+	coupons        []Coupon
+	paymentTokens  map[string]PaymentToken
+	reviews        []RideReview
+	reputations    map[string]Reputation
+	scheduledRides map[string]ScheduledRide
+	shares         map[string]RideShare
+	incidents      []Incident
+	sessions       map[string]Session
+	apiKeys        map[string]APIKey
+	auditLogs      []AdminAuditLog
+	lastNow        time.Time
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		rides:          map[string]Ride{},
+		rideWaypoints:  map[string][]RideWaypoint{},
+		chairs:         map[string]Chair{},
+		users:          map[string]User{},
+		owners:         map[string]Owner{},
+		paymentTokens:  map[string]PaymentToken{},
+		reputations:    map[string]Reputation{},
+		scheduledRides: map[string]ScheduledRide{},
+		shares:         map[string]RideShare{},
+		sessions:       map[string]Session{},
+		apiKeys:        map[string]APIKey{},
+	}
+}
+
+// 要素は値で持ち、更新時は差し替えるので浅いコピーでよい
+func (d *memoryData) clone() *memoryData {
+	waypoints := make(map[string][]RideWaypoint, len(d.rideWaypoints))
+	for rideID, w := range d.rideWaypoints {
+		waypoints[rideID] = slices.Clone(w)
+	}
+	return &memoryData{
+		rides:          maps.Clone(d.rides),
+		rideStatuses:   slices.Clone(d.rideStatuses),
+		rideWaypoints:  waypoints,
+		chairs:         maps.Clone(d.chairs),
+		chairLocations: slices.Clone(d.chairLocations),
+		users:          maps.Clone(d.users),
+		owners:         maps.Clone(d.owners),
+		coupons:        slices.Clone(d.coupons),
+		paymentTokens:  maps.Clone(d.paymentTokens),
+		reviews:        slices.Clone(d.reviews),
+		reputations:    maps.Clone(d.reputations),
+		scheduledRides: maps.Clone(d.scheduledRides),
+		shares:         maps.Clone(d.shares),
+		incidents:      slices.Clone(d.incidents),
+		sessions:       maps.Clone(d.sessions),
+		apiKeys:        maps.Clone(d.apiKeys),
+		auditLogs:      slices.Clone(d.auditLogs),
+		lastNow:        d.lastNow,
+	}
+}
+
+// DATETIME(6) と同じ精度で、created_at の順序が入れた順になるよう単調に増やす
+func (d *memoryData) now() time.Time {
+	t := time.Now().Truncate(time.Microsecond)
+	if !t.After(d.lastNow) {
+		t = d.lastNow.Add(time.Microsecond)
+	}
+	d.lastNow = t
+	return t
+}
+
+func (d *memoryData) addChairLocation(chairID string, to Coordinate, now time.Time) {
+	d.chairLocations = append(d.chairLocations, ChairLocation{
+		ID:        ulid.Make().String(),
+		ChairID:   chairID,
+		Latitude:  to.Latitude,
+		Longitude: to.Longitude,
+		CreatedAt: now,
+	})
+}
+
+func (d *memoryData) latestStatus(rideID string) (RideStatus, bool) {
+	for i := len(d.rideStatuses) - 1; i >= 0; i-- {
+		if d.rideStatuses[i].RideID == rideID {
+			return d.rideStatuses[i], true
+		}
+	}
+	return RideStatus{}, false
+}
+
+func copyOf[T any](v T) *T {
+	return &v
+}
+
+// created_at の降順に並べて limit 件まで返す
+func latestFirst[T any](items []T, createdAt func(v T) time.Time, limit int) []T {
+	slices.SortStableFunc(items, func(a, b T) int { return createdAt(b).Compare(createdAt(a)) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func byCreatedAt(a, b Ride) int {
+	return a.CreatedAt.Compare(b.CreatedAt)
+}
+
+type memoryRideRepository struct {
+	s *memoryStore
+}
+
+func (r memoryRideRepository) Get(ctx context.Context, id string) (*Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	ride, ok := r.s.data.rides[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &ride, nil
+}
+
+func (r memoryRideRepository) GetForUpdate(ctx context.Context, id string) (*Ride, error) {
+	return r.Get(ctx, id)
+}
+
+func (r memoryRideRepository) filter(pred func(ride Ride) bool, cmp func(a, b Ride) int) []Ride {
+	rides := []Ride{}
+	for _, ride := range r.s.data.rides {
+		if pred(ride) {
+			rides = append(rides, ride)
+		}
+	}
+	slices.SortFunc(rides, cmp)
+	return rides
+}
+
+func (r memoryRideRepository) ListByUser(ctx context.Context, userID string) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(ride Ride) bool { return ride.UserID == userID }, byCreatedAt), nil
+}
+
+func (r memoryRideRepository) LatestByUser(ctx context.Context, userID string) (*Ride, error) {
+	rides, _ := r.ListByUser(ctx, userID)
+	if len(rides) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &rides[len(rides)-1], nil
+}
+
+func (r memoryRideRepository) CountByUser(ctx context.Context, userID string) (int, error) {
+	rides, _ := r.ListByUser(ctx, userID)
+	return len(rides), nil
+}
+
+func (r memoryRideRepository) ListByChair(ctx context.Context, chairID string) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(
+		func(ride Ride) bool { return ride.ChairID.String == chairID },
+		func(a, b Ride) int { return byCreatedAt(b, a) },
+	), nil
+}
+
+func (r memoryRideRepository) LatestByChair(ctx context.Context, chairID string) (*Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	rides := r.filter(
+		func(ride Ride) bool { return ride.ChairID.String == chairID },
+		func(a, b Ride) int { return b.UpdatedAt.Compare(a.UpdatedAt) },
+	)
+	if len(rides) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &rides[0], nil
+}
+
+func (r memoryRideRepository) ListUnmatched(ctx context.Context) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(ride Ride) bool { return !ride.ChairID.Valid }, byCreatedAt), nil
+}
+
+func (r memoryRideRepository) ListActivePooled(ctx context.Context) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(ride Ride) bool {
+		return ride.ChairID.Valid && ride.Pooled && ride.Evaluation == nil
+	}, byCreatedAt), nil
+}
+
+func (r memoryRideRepository) ListActivePooledByChair(ctx context.Context, chairID string) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(ride Ride) bool {
+		return ride.ChairID.String == chairID && ride.Pooled && ride.Evaluation == nil
+	}, byCreatedAt), nil
+}
+
+func (r memoryRideRepository) ListCompletedByChair(ctx context.Context, chairID string, since time.Time, until time.Time) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	until = until.Add(999 * time.Microsecond)
+	completed := map[string]bool{}
+	for _, status := range r.s.data.rideStatuses {
+		if status.Status == "COMPLETED" {
+			completed[status.RideID] = true
+		}
+	}
+	return r.filter(func(ride Ride) bool {
+		return ride.ChairID.String == chairID && completed[ride.ID] && !ride.UpdatedAt.Before(since) && !ride.UpdatedAt.After(until)
+	}, byCreatedAt), nil
+}
+
+func (r memoryRideRepository) Create(ctx context.Context, ride *Ride) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.rides[ride.ID]; ok {
+		return fmt.Errorf("%w: ride %s", errDuplicateEntry, ride.ID)
+	}
+	now := r.s.data.now()
+	r.s.data.rides[ride.ID] = Ride{
+		ID:                   ride.ID,
+		UserID:               ride.UserID,
+		PickupLatitude:       ride.PickupLatitude,
+		PickupLongitude:      ride.PickupLongitude,
+		DestinationLatitude:  ride.DestinationLatitude,
+		DestinationLongitude: ride.DestinationLongitude,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+		WaypointCount:        ride.WaypointCount,
+		Pooled:               ride.Pooled,
+		InitialFare:          ride.InitialFare,
+		FarePerDistance:      ride.FarePerDistance,
+	}
+	return nil
+}
+
+func (r memoryRideRepository) update(rideID string, fn func(ride *Ride)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	ride, ok := r.s.data.rides[rideID]
+	if !ok {
+		return nil
+	}
+	fn(&ride)
+	ride.UpdatedAt = r.s.data.now()
+	r.s.data.rides[rideID] = ride
+	return nil
+}
+
+func (r memoryRideRepository) MarkPoolShared(ctx context.Context, rideIDs []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, id := range rideIDs {
+		if ride, ok := r.s.data.rides[id]; ok {
+			ride.PoolShared = true
+			r.s.data.rides[id] = ride
+		}
+	}
+	return nil
+}
+
+func (r memoryRideRepository) SetChair(ctx context.Context, rideID string, chairID string) error {
+	return r.update(rideID, func(ride *Ride) {
+		ride.ChairID = sql.NullString{String: chairID, Valid: true}
+	})
+}
+
+func (r memoryRideRepository) SetEvaluation(ctx context.Context, rideID string, evaluation int) error {
+	return r.update(rideID, func(ride *Ride) {
+		ride.Evaluation = &evaluation
+	})
+}
+
+func (r memoryRideRepository) AddStatus(ctx context.Context, rideID string, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.data.rideStatuses = append(r.s.data.rideStatuses, RideStatus{
+		ID:        ulid.Make().String(),
+		RideID:    rideID,
+		Status:    status,
+		CreatedAt: r.s.data.now(),
+	})
+	return nil
+}
+
+func (r memoryRideRepository) LatestStatus(ctx context.Context, rideID string) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	status, ok := r.s.data.latestStatus(rideID)
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return status.Status, nil
+}
+
+func (r memoryRideRepository) IsChairFree(ctx context.Context, chairID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	type progress struct{ sent, completed bool }
+	rides := map[string]*progress{}
+	for _, status := range r.s.data.rideStatuses {
+		ride := r.s.data.rides[status.RideID]
+		if ride.ChairID.String != chairID || !ride.ChairID.Valid {
+			continue
+		}
+		p, ok := rides[status.RideID]
+		if !ok {
+			p = &progress{sent: true}
+			rides[status.RideID] = p
+		}
+		p.sent = p.sent && status.ChairSentAt != nil
+		p.completed = p.completed || status.Status == "COMPLETED"
+	}
+	for _, p := range rides {
+		if !p.sent || !p.completed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r memoryRideRepository) nextUnsent(pred func(status RideStatus) bool) (*RideStatus, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, status := range r.s.data.rideStatuses {
+		if pred(status) {
+			return &status, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryRideRepository) markSent(statusID string, fn func(status *RideStatus, now time.Time)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i := range r.s.data.rideStatuses {
+		if r.s.data.rideStatuses[i].ID == statusID {
+			status := r.s.data.rideStatuses[i]
+			fn(&status, r.s.data.now())
+			r.s.data.rideStatuses[i] = status
+		}
+	}
+	return nil
+}
+
+func (r memoryRideRepository) NextUnsentAppStatus(ctx context.Context, rideID string) (*RideStatus, error) {
+	return r.nextUnsent(func(status RideStatus) bool {
+		return status.RideID == rideID && status.AppSentAt == nil
+	})
+}
+
+func (r memoryRideRepository) MarkAppSent(ctx context.Context, statusID string) error {
+	return r.markSent(statusID, func(status *RideStatus, now time.Time) {
+		status.AppSentAt = &now
+	})
+}
+
+func (r memoryRideRepository) NextUnsentChairStatus(ctx context.Context, rideIDs []string) (*RideStatus, error) {
+	return r.nextUnsent(func(status RideStatus) bool {
+		return slices.Contains(rideIDs, status.RideID) && status.ChairSentAt == nil
+	})
+}
+
+func (r memoryRideRepository) MarkChairSent(ctx context.Context, statusID string) error {
+	return r.markSent(statusID, func(status *RideStatus, now time.Time) {
+		status.ChairSentAt = &now
+	})
+}
+
+func (r memoryRideRepository) AddWaypoints(ctx context.Context, rideID string, waypoints []Coordinate) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, waypoint := range waypoints {
+		r.s.data.rideWaypoints[rideID] = append(r.s.data.rideWaypoints[rideID], RideWaypoint{
+			RideID:    rideID,
+			Seq:       i + 1,
+			Latitude:  waypoint.Latitude,
+			Longitude: waypoint.Longitude,
+		})
+	}
+	return nil
+}
+
+func (r memoryRideRepository) Waypoints(ctx context.Context, rideID string) ([]RideWaypoint, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	waypoints := slices.Clone(r.s.data.rideWaypoints[rideID])
+	if waypoints == nil {
+		waypoints = []RideWaypoint{}
+	}
+	return waypoints, nil
+}
+
+func (r memoryRideRepository) WaypointsByRideIDs(ctx context.Context, rideIDs []string) (map[string][]Coordinate, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	res := map[string][]Coordinate{}
+	for _, rideID := range rideIDs {
+		if waypoints := r.s.data.rideWaypoints[rideID]; len(waypoints) > 0 {
+			res[rideID] = waypointCoordinates(waypoints)
+		}
+	}
+	return res, nil
+}
+
+func (r memoryRideRepository) MarkWaypointArrived(ctx context.Context, rideID string, seq int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	waypoints := r.s.data.rideWaypoints[rideID]
+	for i := range waypoints {
+		if waypoints[i].Seq == seq {
+			waypoints[i].ArrivedAt = copyOf(r.s.data.now())
+		}
+	}
+	return nil
+}
+
+func (r memoryRideRepository) AddDistance(ctx context.Context, rideID string, pickup int, loaded int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	ride, ok := r.s.data.rides[rideID]
+	if !ok {
+		return nil
+	}
+	ride.PickupDistance += pickup
+	ride.LoadedDistance += loaded
+	r.s.data.rides[rideID] = ride
+	return nil
+}
+
+func (r memoryRideRepository) Statuses(ctx context.Context, rideID string) ([]RideStatus, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	statuses := []RideStatus{}
+	for _, status := range r.s.data.rideStatuses {
+		if status.RideID == rideID {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+func (r memoryRideRepository) Search(ctx context.Context, userID string, chairID string, limit int) ([]Ride, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	rides := r.filter(func(ride Ride) bool {
+		return (userID == "" || ride.UserID == userID) && (chairID == "" || ride.ChairID.String == chairID)
+	}, byCreatedAt)
+	return latestFirst(rides, func(ride Ride) time.Time { return ride.CreatedAt }, limit), nil
+}
+
+type memoryChairRepository struct {
+	s *memoryStore
+}
+
+func (r memoryChairRepository) Get(ctx context.Context, id string) (*Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	chair, ok := r.s.data.chairs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &chair, nil
+}
+
+func (r memoryChairRepository) GetForUpdate(ctx context.Context, id string) (*Chair, error) {
+	return r.Get(ctx, id)
+}
+
+func (r memoryChairRepository) filter(pred func(chair Chair) bool) []Chair {
+	chairs := []Chair{}
+	for _, chair := range r.s.data.chairs {
+		if pred(chair) {
+			chairs = append(chairs, chair)
+		}
+	}
+	slices.SortFunc(chairs, func(a, b Chair) int { return cmp.Compare(a.ID, b.ID) })
+	return chairs
+}
+
+func (r memoryChairRepository) ListByOwner(ctx context.Context, ownerID string) ([]Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(chair Chair) bool { return chair.OwnerID == ownerID }), nil
+}
+
+func (r memoryChairRepository) ListAvailable(ctx context.Context) ([]Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(chair Chair) bool {
+		return chair.IsActive && chair.SuspendedAt == nil && chair.Latitude.Valid
+	}), nil
+}
+
+func (r memoryChairRepository) ListAvailableInArea(ctx context.Context, min Coordinate, max Coordinate) ([]Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.filter(func(chair Chair) bool {
+		if !chair.IsActive || chair.SuspendedAt != nil || !chair.Latitude.Valid || !chair.Longitude.Valid {
+			return false
+		}
+		lat, lon := int(chair.Latitude.Int64), int(chair.Longitude.Int64)
+		return min.Latitude <= lat && lat <= max.Latitude && min.Longitude <= lon && lon <= max.Longitude
+	}), nil
+}
+
+// メモリ上の実装には chair_models がないので、速度の分からない椅子として返す
+func (r memoryChairRepository) ListAvailableWithSpeed(ctx context.Context) ([]chairWithSpeed, error) {
+	chairs, _ := r.ListAvailable(ctx)
+	res := make([]chairWithSpeed, 0, len(chairs))
+	for _, chair := range chairs {
+		res = append(res, chairWithSpeed{ID: chair.ID, Latitude: chair.Latitude, Longitude: chair.Longitude})
+	}
+	return res, nil
+}
+
+func (r memoryChairRepository) GetByAccessToken(ctx context.Context, token string) (*Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	chairs := r.filter(func(chair Chair) bool { return chair.AccessToken.Valid && chair.AccessToken.String == token })
+	if len(chairs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &chairs[0], nil
+}
+
+func (r memoryChairRepository) Search(ctx context.Context, q string, ownerID string, limit int) ([]Chair, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	chairs := r.filter(func(chair Chair) bool {
+		return (chair.ID == q || strings.Contains(chair.Name, q)) && (ownerID == "" || chair.OwnerID == ownerID)
+	})
+	return latestFirst(chairs, func(chair Chair) time.Time { return chair.CreatedAt }, limit), nil
+}
+
+func (r memoryChairRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	return r.update(id, func(chair *Chair, now time.Time) {
+		chair.SuspendedAt = suspendedAt
+	})
+}
+
+func (r memoryChairRepository) Create(ctx context.Context, chair *Chair) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.chairs[chair.ID]; ok {
+		return fmt.Errorf("%w: chair %s", errDuplicateEntry, chair.ID)
+	}
+	now := r.s.data.now()
+	r.s.data.chairs[chair.ID] = Chair{
+		ID:          chair.ID,
+		OwnerID:     chair.OwnerID,
+		Name:        chair.Name,
+		Model:       chair.Model,
+		IsActive:    chair.IsActive,
+		AccessToken: chair.AccessToken,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return nil
+}
+
+func (r memoryChairRepository) update(id string, fn func(chair *Chair, now time.Time)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	chair, ok := r.s.data.chairs[id]
+	if !ok {
+		return nil
+	}
+	now := r.s.data.now()
+	fn(&chair, now)
+	chair.UpdatedAt = now
+	r.s.data.chairs[id] = chair
+	return nil
+}
+
+func (r memoryChairRepository) SetActive(ctx context.Context, id string, active bool) error {
+	return r.update(id, func(chair *Chair, now time.Time) {
+		chair.IsActive = active
+	})
+}
+
+func (r memoryChairRepository) SetLocation(ctx context.Context, id string, to Coordinate) error {
+	return r.update(id, func(chair *Chair, now time.Time) {
+		r.s.data.addChairLocation(id, to, now)
+		chair.Latitude = sql.NullInt64{Int64: int64(to.Latitude), Valid: true}
+		chair.Longitude = sql.NullInt64{Int64: int64(to.Longitude), Valid: true}
+	})
+}
+
+func (r memoryChairRepository) Move(ctx context.Context, id string, to Coordinate, distance int, loaded bool) error {
+	return r.update(id, func(chair *Chair, now time.Time) {
+		r.s.data.addChairLocation(id, to, now)
+		chair.TotalDistance += distance
+		if loaded {
+			chair.LoadedDistance += distance
+		}
+		chair.TotalDistanceUpdatedAt = sql.NullTime{Time: now, Valid: true}
+		chair.Latitude = sql.NullInt64{Int64: int64(to.Latitude), Valid: true}
+		chair.Longitude = sql.NullInt64{Int64: int64(to.Longitude), Valid: true}
+	})
+}
+
+func (r memoryChairRepository) ApplyMoves(ctx context.Context, moves []ChairMove) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, move := range moves {
+		if len(move.Locations) == 0 {
+			continue
+		}
+		r.s.data.chairLocations = append(r.s.data.chairLocations, move.Locations...)
+		chair, ok := r.s.data.chairs[move.ChairID]
+		if !ok {
+			continue
+		}
+		to := move.Locations[len(move.Locations)-1]
+		chair.TotalDistance += move.Distance
+		chair.LoadedDistance += move.LoadedDistance
+		if !move.MovedAt.IsZero() {
+			chair.TotalDistanceUpdatedAt = sql.NullTime{Time: move.MovedAt, Valid: true}
+		}
+		chair.Latitude = sql.NullInt64{Int64: int64(to.Latitude), Valid: true}
+		chair.Longitude = sql.NullInt64{Int64: int64(to.Longitude), Valid: true}
+		chair.UpdatedAt = r.s.data.now()
+		r.s.data.chairs[move.ChairID] = chair
+	}
+	return nil
+}
+
+type memoryUserRepository struct {
+	s *memoryStore
+}
+
+func (r memoryUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+func (r memoryUserRepository) GetByInvitationCode(ctx context.Context, code string) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if user.InvitationCode == code {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryUserRepository) GetByAccessToken(ctx context.Context, token string) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, user := range r.s.data.users {
+		if user.AccessToken.Valid && user.AccessToken.String == token {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryUserRepository) Search(ctx context.Context, q string, limit int) ([]User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	users := []User{}
+	for _, user := range r.s.data.users {
+		if user.ID == q || strings.HasPrefix(user.Username, q) || strings.Contains(user.Firstname+" "+user.Lastname, q) {
+			users = append(users, user)
+		}
+	}
+	return latestFirst(users, func(user User) time.Time { return user.CreatedAt }, limit), nil
+}
+
+func (r memoryUserRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if user, ok := r.s.data.users[id]; ok {
+		user.SuspendedAt = suspendedAt
+		user.UpdatedAt = r.s.data.now()
+		r.s.data.users[id] = user
+	}
+	return nil
+}
+
+func (r memoryUserRepository) Create(ctx context.Context, user *User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.data.users {
+		if u.ID == user.ID || u.Username == user.Username || (u.AccessToken.Valid && u.AccessToken == user.AccessToken) || u.InvitationCode == user.InvitationCode {
+			return fmt.Errorf("%w: user %s", errDuplicateEntry, user.Username)
+		}
+	}
+	now := r.s.data.now()
+	u := *user
+	u.CreatedAt = now
+	u.UpdatedAt = now
+	u.SuspendedAt = nil
+	r.s.data.users[user.ID] = u
+	return nil
+}
+
+type memoryOwnerRepository struct {
+	s *memoryStore
+}
+
+func (r memoryOwnerRepository) Get(ctx context.Context, id string) (*Owner, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	owner, ok := r.s.data.owners[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &owner, nil
+}
+
+func (r memoryOwnerRepository) GetByChairRegisterToken(ctx context.Context, token string) (*Owner, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, owner := range r.s.data.owners {
+		if owner.ChairRegisterToken == token {
+			return &owner, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryOwnerRepository) GetByAccessToken(ctx context.Context, token string) (*Owner, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, owner := range r.s.data.owners {
+		if owner.AccessToken.Valid && owner.AccessToken.String == token {
+			return &owner, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryOwnerRepository) Search(ctx context.Context, q string, limit int) ([]Owner, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	owners := []Owner{}
+	for _, owner := range r.s.data.owners {
+		if owner.ID == q || strings.Contains(owner.Name, q) {
+			owners = append(owners, owner)
+		}
+	}
+	return latestFirst(owners, func(owner Owner) time.Time { return owner.CreatedAt }, limit), nil
+}
+
+func (r memoryOwnerRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if owner, ok := r.s.data.owners[id]; ok {
+		owner.SuspendedAt = suspendedAt
+		owner.UpdatedAt = r.s.data.now()
+		r.s.data.owners[id] = owner
+	}
+	return nil
+}
+
+func (r memoryOwnerRepository) Create(ctx context.Context, owner *Owner) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, o := range r.s.data.owners {
+		if o.ID == owner.ID || o.Name == owner.Name || (o.AccessToken.Valid && o.AccessToken == owner.AccessToken) || o.ChairRegisterToken == owner.ChairRegisterToken {
+			return fmt.Errorf("%w: owner %s", errDuplicateEntry, owner.Name)
+		}
+	}
+	now := r.s.data.now()
+	o := *owner
+	o.CreatedAt = now
+	o.UpdatedAt = now
+	o.SuspendedAt = nil
+	r.s.data.owners[owner.ID] = o
+	return nil
+}
+
+type memoryCouponRepository struct {
+	s *memoryStore
+}
+
+func (r memoryCouponRepository) Create(ctx context.Context, coupon *Coupon) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, c := range r.s.data.coupons {
+		if c.UserID == coupon.UserID && c.Code == coupon.Code {
+			return fmt.Errorf("%w: coupon %s", errDuplicateEntry, coupon.Code)
+		}
+	}
+	r.s.data.coupons = append(r.s.data.coupons, Coupon{
+		UserID:    coupon.UserID,
+		Code:      coupon.Code,
+		Discount:  coupon.Discount,
+		CreatedAt: r.s.data.now(),
+	})
+	return nil
+}
+
+func (r memoryCouponRepository) CountByCodeForUpdate(ctx context.Context, code string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for _, coupon := range r.s.data.coupons {
+		if coupon.Code == code {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryCouponRepository) find(pred func(coupon Coupon) bool) (*Coupon, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	// 付与された順に並んでいる
+	for _, coupon := range r.s.data.coupons {
+		if pred(coupon) {
+			return &coupon, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memoryCouponRepository) GetByRide(ctx context.Context, rideID string) (*Coupon, error) {
+	return r.find(func(coupon Coupon) bool {
+		return coupon.UsedBy != nil && *coupon.UsedBy == rideID
+	})
+}
+
+func (r memoryCouponRepository) GetUnused(ctx context.Context, userID string, code string) (*Coupon, error) {
+	return r.find(func(coupon Coupon) bool {
+		return coupon.UserID == userID && coupon.Code == code && coupon.UsedBy == nil
+	})
+}
+
+func (r memoryCouponRepository) GetUnusedForUpdate(ctx context.Context, userID string, code string) (*Coupon, error) {
+	return r.GetUnused(ctx, userID, code)
+}
+
+func (r memoryCouponRepository) OldestUnused(ctx context.Context, userID string) (*Coupon, error) {
+	return r.find(func(coupon Coupon) bool {
+		return coupon.UserID == userID && coupon.UsedBy == nil
+	})
+}
+
+func (r memoryCouponRepository) OldestUnusedForUpdate(ctx context.Context, userID string) (*Coupon, error) {
+	return r.OldestUnused(ctx, userID)
+}
+
+func (r memoryCouponRepository) Use(ctx context.Context, userID string, code string, rideID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, coupon := range r.s.data.coupons {
+		if coupon.UserID == userID && coupon.Code == code {
+			r.s.data.coupons[i].UsedBy = &rideID
+		}
+	}
+	return nil
+}
+
+type memoryPaymentRepository struct {
+	s *memoryStore
+}
+
+func (r memoryPaymentRepository) SaveToken(ctx context.Context, userID string, token string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.paymentTokens[userID]; ok {
+		return fmt.Errorf("%w: payment token %s", errDuplicateEntry, userID)
+	}
+	r.s.data.paymentTokens[userID] = PaymentToken{
+		UserID:    userID,
+		Token:     token,
+		CreatedAt: r.s.data.now(),
+	}
+	return nil
+}
+
+func (r memoryPaymentRepository) GetToken(ctx context.Context, userID string) (*PaymentToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	token, ok := r.s.data.paymentTokens[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &token, nil
+}
+
+type memoryReviewRepository struct {
+	s *memoryStore
+}
+
+func (r memoryReviewRepository) Exists(ctx context.Context, rideID string, reviewerType string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return slices.ContainsFunc(r.s.data.reviews, func(review RideReview) bool {
+		return review.RideID == rideID && review.ReviewerType == reviewerType
+	}), nil
+}
+
+func (r memoryReviewRepository) Create(ctx context.Context, review *RideReview) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, rv := range r.s.data.reviews {
+		if rv.RideID == review.RideID && rv.ReviewerType == review.ReviewerType {
+			return fmt.Errorf("%w: review %s", errDuplicateEntry, review.RideID)
+		}
+	}
+	r.s.data.reviews = append(r.s.data.reviews, RideReview{
+		RideID:       review.RideID,
+		ReviewerType: review.ReviewerType,
+		Rating:       review.Rating,
+		Comment:      review.Comment,
+		Tags:         review.Tags,
+		CreatedAt:    r.s.data.now(),
+	})
+	return nil
+}
+
+func (r memoryReviewRepository) AddRating(ctx context.Context, subjectType string, subjectID string, rating int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	key := subjectType + "/" + subjectID
+	reputation := r.s.data.reputations[key]
+	reputation.SubjectType = subjectType
+	reputation.SubjectID = subjectID
+	reputation.RatingCount++
+	reputation.RatingSum += rating
+	reputation.UpdatedAt = r.s.data.now()
+	r.s.data.reputations[key] = reputation
+	return nil
+}
+
+func (r memoryReviewRepository) GetReputation(ctx context.Context, subjectType string, subjectID string) (Reputation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if reputation, ok := r.s.data.reputations[subjectType+"/"+subjectID]; ok {
+		return reputation, nil
+	}
+	return Reputation{SubjectType: subjectType, SubjectID: subjectID}, nil
+}
+
+func (r memoryReviewRepository) ListLowRated(ctx context.Context, subjectType string) (map[string]bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	res := map[string]bool{}
+	for _, reputation := range r.s.data.reputations {
+		if reputation.SubjectType == subjectType && reputation.RatingCount >= minRatingsForReputation && float64(reputation.RatingSum) < float64(reputation.RatingCount)*lowRatingThreshold {
+			res[reputation.SubjectID] = true
+		}
+	}
+	return res, nil
+}
+
+func (r memoryReviewRepository) ListRecentBySubject(ctx context.Context, subjectType string, subjectID string, limit int) ([]RideReview, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	reviews := []RideReview{}
+	for _, review := range r.s.data.reviews {
+		if review.ReviewerType != "user" {
+			continue
+		}
+		ride, ok := r.s.data.rides[review.RideID]
+		if !ok || !ride.ChairID.Valid {
+			continue
+		}
+		switch subjectType {
+		case "chair":
+			ok = ride.ChairID.String == subjectID
+		case "owner":
+			ok = r.s.data.chairs[ride.ChairID.String].OwnerID == subjectID
+		default:
+			ok = false
+		}
+		if ok {
+			reviews = append(reviews, review)
+		}
+	}
+	return latestFirst(reviews, func(review RideReview) time.Time { return review.CreatedAt }, limit), nil
+}
+
+type memoryScheduledRideRepository struct {
+	s *memoryStore
+}
+
+func (r memoryScheduledRideRepository) Create(ctx context.Context, scheduledRide *ScheduledRide) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.scheduledRides[scheduledRide.ID]; ok {
+		return fmt.Errorf("%w: scheduled ride %s", errDuplicateEntry, scheduledRide.ID)
+	}
+	s := *scheduledRide
+	s.RideID = sql.NullString{}
+	s.CanceledAt = nil
+	s.CreatedAt = r.s.data.now()
+	r.s.data.scheduledRides[s.ID] = s
+	return nil
+}
+
+func (r memoryScheduledRideRepository) GetForUpdate(ctx context.Context, id string) (*ScheduledRide, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	s, ok := r.s.data.scheduledRides[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &s, nil
+}
+
+func (r memoryScheduledRideRepository) listPending(pred func(s ScheduledRide) bool) []ScheduledRide {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	res := []ScheduledRide{}
+	for _, s := range r.s.data.scheduledRides {
+		if !s.RideID.Valid && s.CanceledAt == nil && pred(s) {
+			res = append(res, s)
+		}
+	}
+	slices.SortFunc(res, func(a, b ScheduledRide) int {
+		return cmp.Or(a.ScheduledAt.Compare(b.ScheduledAt), cmp.Compare(a.ID, b.ID))
+	})
+	return res
+}
+
+func (r memoryScheduledRideRepository) ListPendingByUser(ctx context.Context, userID string) ([]ScheduledRide, error) {
+	return r.listPending(func(s ScheduledRide) bool { return s.UserID == userID }), nil
+}
+
+func (r memoryScheduledRideRepository) ListPendingUntil(ctx context.Context, until time.Time) ([]ScheduledRide, error) {
+	return r.listPending(func(s ScheduledRide) bool { return !s.ScheduledAt.After(until) }), nil
+}
+
+func (r memoryScheduledRideRepository) update(id string, fn func(s *ScheduledRide, now time.Time)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if s, ok := r.s.data.scheduledRides[id]; ok {
+		fn(&s, r.s.data.now())
+		r.s.data.scheduledRides[id] = s
+	}
+	return nil
+}
+
+func (r memoryScheduledRideRepository) Cancel(ctx context.Context, id string) error {
+	return r.update(id, func(s *ScheduledRide, now time.Time) {
+		s.CanceledAt = &now
+	})
+}
+
+func (r memoryScheduledRideRepository) SetRide(ctx context.Context, id string, rideID string) error {
+	return r.update(id, func(s *ScheduledRide, now time.Time) {
+		s.RideID = sql.NullString{String: rideID, Valid: true}
+	})
+}
+
+type memoryShareRepository struct {
+	s *memoryStore
+}
+
+func (r memoryShareRepository) Create(ctx context.Context, share *RideShare) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.shares[share.TokenHash]; ok {
+		return fmt.Errorf("%w: share %s", errDuplicateEntry, share.RideID)
+	}
+	r.s.data.shares[share.TokenHash] = RideShare{
+		TokenHash: share.TokenHash,
+		RideID:    share.RideID,
+		UserID:    share.UserID,
+		CreatedAt: r.s.data.now(),
+	}
+	return nil
+}
+
+func (r memoryShareRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*RideShare, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	share, ok := r.s.data.shares[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &share, nil
+}
+
+func (r memoryShareRepository) RevokeByRide(ctx context.Context, rideID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := r.s.data.now()
+	for tokenHash, share := range r.s.data.shares {
+		if share.RideID == rideID && share.RevokedAt == nil {
+			share.RevokedAt = &now
+			r.s.data.shares[tokenHash] = share
+		}
+	}
+	return nil
+}
+
+type memoryIncidentRepository struct {
+	s *memoryStore
+}
+
+func (r memoryIncidentRepository) Create(ctx context.Context, incident *Incident) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	i := *incident
+	i.Status = "OPEN"
+	i.CreatedAt = r.s.data.now()
+	i.ResolvedAt = nil
+	r.s.data.incidents = append(r.s.data.incidents, i)
+	return nil
+}
+
+func (r memoryIncidentRepository) ListByStatus(ctx context.Context, status string, limit int) ([]Incident, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	res := []Incident{}
+	for _, incident := range r.s.data.incidents {
+		if incident.Status == status && len(res) < limit {
+			res = append(res, incident)
+		}
+	}
+	return res, nil
+}
+
+func (r memoryIncidentRepository) Resolve(ctx context.Context, id string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, incident := range r.s.data.incidents {
+		if incident.ID == id && incident.Status == "OPEN" {
+			now := r.s.data.now()
+			r.s.data.incidents[i].Status = "RESOLVED"
+			r.s.data.incidents[i].ResolvedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memorySessionRepository struct {
+	s *memoryStore
+}
+
+func (r memorySessionRepository) Create(ctx context.Context, session *Session) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.sessions[session.ID]; ok {
+		return fmt.Errorf("%w: session %s", errDuplicateEntry, session.ID)
+	}
+	now := r.s.data.now()
+	r.s.data.sessions[session.ID] = Session{
+		ID:               session.ID,
+		SubjectType:      session.SubjectType,
+		SubjectID:        session.SubjectID,
+		TokenHash:        session.TokenHash,
+		RefreshTokenHash: session.RefreshTokenHash,
+		Device:           session.Device,
+		ExpiresAt:        session.ExpiresAt,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	return nil
+}
+
+func (r memorySessionRepository) find(pred func(session Session) bool) (*Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, session := range r.s.data.sessions {
+		if pred(session) {
+			return &session, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r memorySessionRepository) Get(ctx context.Context, id string) (*Session, error) {
+	return r.find(func(session Session) bool { return session.ID == id })
+}
+
+func (r memorySessionRepository) GetByTokenHash(ctx context.Context, subjectType string, tokenHash string) (*Session, error) {
+	return r.find(func(session Session) bool {
+		return session.SubjectType == subjectType && session.TokenHash == tokenHash
+	})
+}
+
+func (r memorySessionRepository) GetByRefreshTokenHashForUpdate(ctx context.Context, subjectType string, refreshTokenHash string) (*Session, error) {
+	return r.find(func(session Session) bool {
+		return session.SubjectType == subjectType && session.RefreshTokenHash == refreshTokenHash
+	})
+}
+
+func (r memorySessionRepository) ListActive(ctx context.Context, subjectType string, subjectID string, now time.Time) ([]Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	sessions := []Session{}
+	for _, session := range r.s.data.sessions {
+		if session.SubjectType == subjectType && session.SubjectID == subjectID && session.RevokedAt == nil && session.RefreshExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	return latestFirst(sessions, func(session Session) time.Time { return session.CreatedAt }, len(sessions)), nil
+}
+
+func (r memorySessionRepository) update(id string, fn func(session *Session, now time.Time)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if session, ok := r.s.data.sessions[id]; ok {
+		now := r.s.data.now()
+		fn(&session, now)
+		session.UpdatedAt = now
+		r.s.data.sessions[id] = session
+	}
+	return nil
+}
+
+func (r memorySessionRepository) Rotate(ctx context.Context, id string, tokenHash string, refreshTokenHash string, expiresAt time.Time, refreshExpiresAt time.Time) error {
+	return r.update(id, func(session *Session, now time.Time) {
+		session.TokenHash = tokenHash
+		session.RefreshTokenHash = refreshTokenHash
+		session.ExpiresAt = expiresAt
+		session.RefreshExpiresAt = refreshExpiresAt
+	})
+}
+
+func (r memorySessionRepository) Revoke(ctx context.Context, id string) error {
+	return r.update(id, func(session *Session, now time.Time) {
+		if session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	})
+}
+
+type memoryAPIKeyRepository struct {
+	s *memoryStore
+}
+
+func (r memoryAPIKeyRepository) Create(ctx context.Context, apiKey *APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.apiKeys[apiKey.ID]; ok {
+		return fmt.Errorf("%w: api key %s", errDuplicateEntry, apiKey.ID)
+	}
+	k := *apiKey
+	k.CreatedAt = r.s.data.now()
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+	r.s.data.apiKeys[k.ID] = k
+	return nil
+}
+
+func (r memoryAPIKeyRepository) Get(ctx context.Context, id string) (*APIKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	apiKey, ok := r.s.data.apiKeys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &apiKey, nil
+}
+
+func (r memoryAPIKeyRepository) ListByOwner(ctx context.Context, ownerID string) ([]APIKey, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	apiKeys := []APIKey{}
+	for _, apiKey := range r.s.data.apiKeys {
+		if apiKey.OwnerID == ownerID {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return latestFirst(apiKeys, func(apiKey APIKey) time.Time { return apiKey.CreatedAt }, len(apiKeys)), nil
+}
+
+func (r memoryAPIKeyRepository) update(id string, fn func(apiKey *APIKey, now time.Time)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if apiKey, ok := r.s.data.apiKeys[id]; ok {
+		fn(&apiKey, r.s.data.now())
+		r.s.data.apiKeys[id] = apiKey
+	}
+	return nil
+}
+
+func (r memoryAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	return r.update(id, func(apiKey *APIKey, now time.Time) {
+		if apiKey.RevokedAt == nil {
+			apiKey.RevokedAt = &now
+		}
+	})
+}
+
+func (r memoryAPIKeyRepository) SetLastUsed(ctx context.Context, id string, at time.Time) error {
+	return r.update(id, func(apiKey *APIKey, now time.Time) {
+		apiKey.LastUsedAt = &at
+	})
+}
+
+type memoryAuditLogRepository struct {
+	s *memoryStore
+}
+
+func (r memoryAuditLogRepository) Create(ctx context.Context, log *AdminAuditLog) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	l := *log
+	l.CreatedAt = r.s.data.now()
+	r.s.data.auditLogs = append(r.s.data.auditLogs, l)
+	return nil
+}
+
+func (r memoryAuditLogRepository) Search(ctx context.Context, targetType string, targetID string, limit int) ([]AdminAuditLog, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	logs := []AdminAuditLog{}
+	for _, l := range r.s.data.auditLogs {
+		if (targetType == "" || l.TargetType == targetType) && (targetID == "" || l.TargetID == targetID) {
+			logs = append(logs, l)
+		}
+	}
+	return latestFirst(logs, func(l AdminAuditLog) time.Time { return l.CreatedAt }, limit), nil
+}
@@ -0,0 +1,824 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/oklog/ulid/v2"
+)
+
+// replicas が nil なら読み取り専用のトランザクションもプライマリで行う
+func newMySQLStore(db *sqlx.DB, replicas *replicaRouter) *Store {
+	return &Store{
+		Repositories: newMySQLRepositories(db),
+		inTx: func(ctx context.Context, fn func(tx Repositories) error) error {
+			tx, err := db.Beginx()
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if err := fn(newMySQLRepositories(tx)); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			// 書き込んだ主体の次の読み取りがレプリカの遅延で古くならないようにする
+			replicas.pin(ctx)
+			return nil
+		},
+		inReadOnlyTx: func(ctx context.Context, fn func(tx Repositories) error) error {
+			tx, err := replicas.beginReadOnly(ctx, db)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if err := fn(newMySQLRepositories(tx)); err != nil {
+				return err
+			}
+			return tx.Commit()
+		},
+		withLock: func(ctx context.Context, name string, fn func() error) error {
+			// GET_LOCK は接続ごとなので、解放するまで同じ接続を持っておく
+			conn, err := db.Connx(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			var locked sql.NullInt64
+			if err := conn.GetContext(ctx, &locked, "SELECT GET_LOCK(?, ?)", name, storeLockTimeoutSeconds); err != nil {
+				return err
+			}
+			if !locked.Valid || locked.Int64 != 1 {
+				return fmt.Errorf("%w: %s", errStoreLockTimeout, name)
+			}
+			defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", name)
+			return fn()
+		},
+	}
+}
+
+const storeLockTimeoutSeconds = 10
+
+var errStoreLockTimeout = errors.New("timed out waiting for lock")
+
+// q には *sqlx.DB も *sqlx.Tx も渡せる。まだリポジトリを通していない処理が自分のトランザクションで使う
+func newMySQLRepositories(q sqlx.ExtContext) Repositories {
+	return Repositories{
+		Rides:          mysqlRideRepository{q},
+		Chairs:         mysqlChairRepository{q},
+		Users:          mysqlUserRepository{q},
+		Owners:         mysqlOwnerRepository{q},
+		Coupons:        mysqlCouponRepository{q},
+		Payments:       mysqlPaymentRepository{q},
+		Reviews:        mysqlReviewRepository{q},
+		ScheduledRides: mysqlScheduledRideRepository{q},
+		Shares:         mysqlShareRepository{q},
+		Incidents:      mysqlIncidentRepository{q},
+		Sessions:       mysqlSessionRepository{q},
+		APIKeys:        mysqlAPIKeyRepository{q},
+		AuditLogs:      mysqlAuditLogRepository{q},
+	}
+}
+
+func getOne[T any](ctx context.Context, q sqlx.QueryerContext, query string, args ...any) (*T, error) {
+	v := new(T)
+	if err := sqlx.GetContext(ctx, q, v, query, args...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func selectAll[T any](ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]T, error) {
+	v := []T{}
+	if err := sqlx.SelectContext(ctx, q, &v, query, args...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type mysqlRideRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlRideRepository) Get(ctx context.Context, id string) (*Ride, error) {
+	return getOne[Ride](ctx, r.q, `SELECT * FROM rides WHERE id = ?`, id)
+}
+
+func (r mysqlRideRepository) GetForUpdate(ctx context.Context, id string) (*Ride, error) {
+	return getOne[Ride](ctx, r.q, `SELECT * FROM rides WHERE id = ? FOR UPDATE`, id)
+}
+
+func (r mysqlRideRepository) ListByUser(ctx context.Context, userID string) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT * FROM rides WHERE user_id = ? ORDER BY created_at ASC`, userID)
+}
+
+func (r mysqlRideRepository) LatestByUser(ctx context.Context, userID string) (*Ride, error) {
+	return getOne[Ride](ctx, r.q, `SELECT * FROM rides WHERE user_id = ? ORDER BY created_at DESC LIMIT 1`, userID)
+}
+
+func (r mysqlRideRepository) CountByUser(ctx context.Context, userID string) (int, error) {
+	count := 0
+	if err := sqlx.GetContext(ctx, r.q, &count, `SELECT COUNT(*) FROM rides WHERE user_id = ?`, userID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r mysqlRideRepository) ListByChair(ctx context.Context, chairID string) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT * FROM rides WHERE chair_id = ? ORDER BY created_at DESC`, chairID)
+}
+
+func (r mysqlRideRepository) LatestByChair(ctx context.Context, chairID string) (*Ride, error) {
+	return getOne[Ride](ctx, r.q, `SELECT * FROM rides WHERE chair_id = ? ORDER BY updated_at DESC LIMIT 1`, chairID)
+}
+
+func (r mysqlRideRepository) ListUnmatched(ctx context.Context) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT * FROM rides WHERE chair_id IS NULL ORDER BY created_at`)
+}
+
+func (r mysqlRideRepository) ListActivePooled(ctx context.Context) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT * FROM rides WHERE pooled = TRUE AND chair_id IS NOT NULL AND evaluation IS NULL ORDER BY created_at`)
+}
+
+func (r mysqlRideRepository) ListActivePooledByChair(ctx context.Context, chairID string) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT * FROM rides WHERE chair_id = ? AND pooled = TRUE AND evaluation IS NULL ORDER BY created_at`, chairID)
+}
+
+func (r mysqlRideRepository) ListCompletedByChair(ctx context.Context, chairID string, since time.Time, until time.Time) ([]Ride, error) {
+	return selectAll[Ride](ctx, r.q, `SELECT rides.* FROM rides JOIN ride_statuses ON rides.id = ride_statuses.ride_id WHERE chair_id = ? AND status = 'COMPLETED' AND updated_at BETWEEN ? AND ? + INTERVAL 999 MICROSECOND`, chairID, since, until)
+}
+
+func (r mysqlRideRepository) Create(ctx context.Context, ride *Ride) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO rides (id, user_id, pickup_latitude, pickup_longitude, destination_latitude, destination_longitude, waypoint_count, pooled, initial_fare, fare_per_distance)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ride.ID, ride.UserID, ride.PickupLatitude, ride.PickupLongitude, ride.DestinationLatitude, ride.DestinationLongitude, ride.WaypointCount, ride.Pooled, ride.InitialFare, ride.FarePerDistance,
+	)
+	return err
+}
+
+func (r mysqlRideRepository) SetChair(ctx context.Context, rideID string, chairID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE rides SET chair_id = ? WHERE id = ?`, chairID, rideID)
+	return err
+}
+
+func (r mysqlRideRepository) MarkPoolShared(ctx context.Context, rideIDs []string) error {
+	if len(rideIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`UPDATE rides SET pool_shared = TRUE, updated_at = updated_at WHERE id IN (?)`, rideIDs)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r mysqlRideRepository) SetEvaluation(ctx context.Context, rideID string, evaluation int) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE rides SET evaluation = ? WHERE id = ?`, evaluation, rideID)
+	return err
+}
+
+func (r mysqlRideRepository) AddStatus(ctx context.Context, rideID string, status string) error {
+	_, err := r.q.ExecContext(ctx, `INSERT INTO ride_statuses (id, ride_id, status) VALUES (?, ?, ?)`, ulid.Make().String(), rideID, status)
+	return err
+}
+
+func (r mysqlRideRepository) LatestStatus(ctx context.Context, rideID string) (string, error) {
+	status := ""
+	if err := sqlx.GetContext(ctx, r.q, &status, `SELECT status FROM ride_statuses WHERE ride_id = ? ORDER BY created_at DESC LIMIT 1`, rideID); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+func (r mysqlRideRepository) IsChairFree(ctx context.Context, chairID string) (bool, error) {
+	free := false
+	if err := sqlx.GetContext(ctx, r.q, &free, "SELECT COUNT(*) = 0 FROM (SELECT COUNT(chair_sent_at) = COUNT(*) AND SUM(status = 'COMPLETED') > 0 AS completed FROM ride_statuses WHERE ride_id IN (SELECT id FROM rides WHERE chair_id = ?) GROUP BY ride_id) is_completed WHERE completed = FALSE", chairID); err != nil {
+		return false, err
+	}
+	return free, nil
+}
+
+func (r mysqlRideRepository) NextUnsentAppStatus(ctx context.Context, rideID string) (*RideStatus, error) {
+	return getOne[RideStatus](ctx, r.q, `SELECT * FROM ride_statuses WHERE ride_id = ? AND app_sent_at IS NULL ORDER BY created_at ASC LIMIT 1`, rideID)
+}
+
+func (r mysqlRideRepository) MarkAppSent(ctx context.Context, statusID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE ride_statuses SET app_sent_at = CURRENT_TIMESTAMP(6) WHERE id = ?`, statusID)
+	return err
+}
+
+func (r mysqlRideRepository) NextUnsentChairStatus(ctx context.Context, rideIDs []string) (*RideStatus, error) {
+	query, args, err := sqlx.In(`SELECT * FROM ride_statuses WHERE ride_id IN (?) AND chair_sent_at IS NULL ORDER BY created_at ASC LIMIT 1`, rideIDs)
+	if err != nil {
+		return nil, err
+	}
+	return getOne[RideStatus](ctx, r.q, query, args...)
+}
+
+func (r mysqlRideRepository) MarkChairSent(ctx context.Context, statusID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE ride_statuses SET chair_sent_at = CURRENT_TIMESTAMP(6) WHERE id = ?`, statusID)
+	return err
+}
+
+func (r mysqlRideRepository) AddWaypoints(ctx context.Context, rideID string, waypoints []Coordinate) error {
+	for i, waypoint := range waypoints {
+		if _, err := r.q.ExecContext(
+			ctx,
+			`INSERT INTO ride_waypoints (ride_id, seq, latitude, longitude) VALUES (?, ?, ?, ?)`,
+			rideID, i+1, waypoint.Latitude, waypoint.Longitude,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r mysqlRideRepository) Waypoints(ctx context.Context, rideID string) ([]RideWaypoint, error) {
+	return selectAll[RideWaypoint](ctx, r.q, `SELECT * FROM ride_waypoints WHERE ride_id = ? ORDER BY seq`, rideID)
+}
+
+func (r mysqlRideRepository) WaypointsByRideIDs(ctx context.Context, rideIDs []string) (map[string][]Coordinate, error) {
+	res := map[string][]Coordinate{}
+	if len(rideIDs) == 0 {
+		return res, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT * FROM ride_waypoints WHERE ride_id IN (?) ORDER BY ride_id, seq`, rideIDs)
+	if err != nil {
+		return nil, err
+	}
+	waypoints, err := selectAll[RideWaypoint](ctx, r.q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, waypoint := range waypoints {
+		res[waypoint.RideID] = append(res[waypoint.RideID], Coordinate{Latitude: waypoint.Latitude, Longitude: waypoint.Longitude})
+	}
+	return res, nil
+}
+
+func (r mysqlRideRepository) MarkWaypointArrived(ctx context.Context, rideID string, seq int) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE ride_waypoints SET arrived_at = CURRENT_TIMESTAMP(6) WHERE ride_id = ? AND seq = ?`, rideID, seq)
+	return err
+}
+
+func (r mysqlRideRepository) AddDistance(ctx context.Context, rideID string, pickup int, loaded int) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		// 距離を足しただけでは updated_at を進めない(LatestByChair の順序が変わるため)
+		`UPDATE rides SET pickup_distance = pickup_distance + ?, loaded_distance = loaded_distance + ?, updated_at = updated_at WHERE id = ?`,
+		pickup, loaded, rideID,
+	)
+	return err
+}
+
+func (r mysqlRideRepository) Statuses(ctx context.Context, rideID string) ([]RideStatus, error) {
+	return selectAll[RideStatus](ctx, r.q, `SELECT * FROM ride_statuses WHERE ride_id = ? ORDER BY created_at ASC`, rideID)
+}
+
+func (r mysqlRideRepository) Search(ctx context.Context, userID string, chairID string, limit int) ([]Ride, error) {
+	return selectAll[Ride](
+		ctx, r.q,
+		`SELECT * FROM rides WHERE (? = '' OR user_id = ?) AND (? = '' OR chair_id = ?) ORDER BY created_at DESC LIMIT ?`,
+		userID, userID, chairID, chairID, limit,
+	)
+}
+
+type mysqlChairRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlChairRepository) Get(ctx context.Context, id string) (*Chair, error) {
+	return getOne[Chair](ctx, r.q, `SELECT * FROM chairs WHERE id = ?`, id)
+}
+
+func (r mysqlChairRepository) GetForUpdate(ctx context.Context, id string) (*Chair, error) {
+	return getOne[Chair](ctx, r.q, `SELECT * FROM chairs WHERE id = ? FOR UPDATE`, id)
+}
+
+func (r mysqlChairRepository) ListByOwner(ctx context.Context, ownerID string) ([]Chair, error) {
+	return selectAll[Chair](ctx, r.q, `SELECT * FROM chairs WHERE owner_id = ?`, ownerID)
+}
+
+func (r mysqlChairRepository) ListAvailable(ctx context.Context) ([]Chair, error) {
+	return selectAll[Chair](ctx, r.q, `SELECT * FROM chairs WHERE is_active = TRUE AND latitude IS NOT NULL AND suspended_at IS NULL`)
+}
+
+func (r mysqlChairRepository) ListAvailableInArea(ctx context.Context, min Coordinate, max Coordinate) ([]Chair, error) {
+	return selectAll[Chair](
+		ctx, r.q,
+		`SELECT * FROM chairs WHERE is_active = 1 AND suspended_at IS NULL AND latitude <= ? AND latitude >= ? AND longitude <= ? AND longitude >= ?`,
+		max.Latitude, min.Latitude, max.Longitude, min.Longitude,
+	)
+}
+
+func (r mysqlChairRepository) ListAvailableWithSpeed(ctx context.Context) ([]chairWithSpeed, error) {
+	return selectAll[chairWithSpeed](
+		ctx, r.q,
+		`SELECT chairs.id, chairs.latitude, chairs.longitude, chair_models.speed FROM chairs JOIN chair_models ON chairs.model = chair_models.name WHERE chairs.is_active = TRUE AND chairs.suspended_at IS NULL AND chairs.latitude IS NOT NULL`,
+	)
+}
+
+func (r mysqlChairRepository) GetByAccessToken(ctx context.Context, token string) (*Chair, error) {
+	return getOne[Chair](ctx, r.q, `SELECT * FROM chairs WHERE access_token = ?`, token)
+}
+
+func (r mysqlChairRepository) Search(ctx context.Context, q string, ownerID string, limit int) ([]Chair, error) {
+	return selectAll[Chair](
+		ctx, r.q,
+		`SELECT * FROM chairs WHERE (id = ? OR name LIKE CONCAT('%', ?, '%')) AND (? = '' OR owner_id = ?) ORDER BY created_at DESC LIMIT ?`,
+		q, q, ownerID, ownerID, limit,
+	)
+}
+
+func (r mysqlChairRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE chairs SET suspended_at = ? WHERE id = ?`, suspendedAt, id)
+	return err
+}
+
+func (r mysqlChairRepository) Create(ctx context.Context, chair *Chair) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO chairs (id, owner_id, name, model, is_active, access_token) VALUES (?, ?, ?, ?, ?, ?)`,
+		chair.ID, chair.OwnerID, chair.Name, chair.Model, chair.IsActive, chair.AccessToken,
+	)
+	return err
+}
+
+func (r mysqlChairRepository) SetActive(ctx context.Context, id string, active bool) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE chairs SET is_active = ? WHERE id = ?`, active, id)
+	return err
+}
+
+func (r mysqlChairRepository) addLocation(ctx context.Context, id string, to Coordinate) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO chair_locations (id, chair_id, latitude, longitude) VALUES (?, ?, ?, ?)`,
+		ulid.Make().String(), id, to.Latitude, to.Longitude,
+	)
+	return err
+}
+
+func (r mysqlChairRepository) SetLocation(ctx context.Context, id string, to Coordinate) error {
+	if err := r.addLocation(ctx, id, to); err != nil {
+		return err
+	}
+	_, err := r.q.ExecContext(ctx, `UPDATE chairs SET latitude = ?, longitude = ? WHERE id = ?`, to.Latitude, to.Longitude, id)
+	return err
+}
+
+func (r mysqlChairRepository) Move(ctx context.Context, id string, to Coordinate, distance int, loaded bool) error {
+	if err := r.addLocation(ctx, id, to); err != nil {
+		return err
+	}
+	loadedDistance := 0
+	if loaded {
+		loadedDistance = distance
+	}
+	_, err := r.q.ExecContext(
+		ctx,
+		`UPDATE chairs SET total_distance = total_distance + ?, loaded_distance = loaded_distance + ?, total_distance_updated_at = CURRENT_TIMESTAMP(6), latitude = ?, longitude = ? WHERE id = ?`,
+		distance, loadedDistance, to.Latitude, to.Longitude, id,
+	)
+	return err
+}
+
+// 1つの INSERT で書き込む chair_locations の行数
+const chairLocationInsertBatchSize = 1000
+
+func (r mysqlChairRepository) ApplyMoves(ctx context.Context, moves []ChairMove) error {
+	locations := []ChairLocation{}
+	for _, move := range moves {
+		locations = append(locations, move.Locations...)
+	}
+	for start := 0; start < len(locations); start += chairLocationInsertBatchSize {
+		batch := locations[start:min(start+chairLocationInsertBatchSize, len(locations))]
+		if _, err := sqlx.NamedExecContext(
+			ctx, r.q,
+			`INSERT INTO chair_locations (id, chair_id, latitude, longitude, created_at) VALUES (:id, :chair_id, :latitude, :longitude, :created_at)`,
+			batch,
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, move := range moves {
+		if len(move.Locations) == 0 {
+			continue
+		}
+		to := move.Locations[len(move.Locations)-1]
+		var movedAt *time.Time
+		if !move.MovedAt.IsZero() {
+			movedAt = &move.MovedAt
+		}
+		if _, err := r.q.ExecContext(
+			ctx,
+			`UPDATE chairs SET total_distance = total_distance + ?, loaded_distance = loaded_distance + ?, total_distance_updated_at = COALESCE(?, total_distance_updated_at), latitude = ?, longitude = ? WHERE id = ?`,
+			move.Distance, move.LoadedDistance, movedAt, to.Latitude, to.Longitude, move.ChairID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mysqlUserRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	return getOne[User](ctx, r.q, `SELECT * FROM users WHERE id = ?`, id)
+}
+
+func (r mysqlUserRepository) GetByInvitationCode(ctx context.Context, code string) (*User, error) {
+	return getOne[User](ctx, r.q, `SELECT * FROM users WHERE invitation_code = ?`, code)
+}
+
+func (r mysqlUserRepository) GetByAccessToken(ctx context.Context, token string) (*User, error) {
+	return getOne[User](ctx, r.q, `SELECT * FROM users WHERE access_token = ?`, token)
+}
+
+func (r mysqlUserRepository) Search(ctx context.Context, q string, limit int) ([]User, error) {
+	return selectAll[User](
+		ctx, r.q,
+		`SELECT * FROM users WHERE id = ? OR username LIKE CONCAT(?, '%') OR CONCAT(firstname, ' ', lastname) LIKE CONCAT('%', ?, '%') ORDER BY created_at DESC LIMIT ?`,
+		q, q, q, limit,
+	)
+}
+
+func (r mysqlUserRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE users SET suspended_at = ? WHERE id = ?`, suspendedAt, id)
+	return err
+}
+
+func (r mysqlUserRepository) Create(ctx context.Context, user *User) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO users (id, username, firstname, lastname, date_of_birth, access_token, invitation_code) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.Firstname, user.Lastname, user.DateOfBirth, user.AccessToken, user.InvitationCode,
+	)
+	return err
+}
+
+type mysqlOwnerRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlOwnerRepository) Get(ctx context.Context, id string) (*Owner, error) {
+	return getOne[Owner](ctx, r.q, `SELECT * FROM owners WHERE id = ?`, id)
+}
+
+func (r mysqlOwnerRepository) GetByChairRegisterToken(ctx context.Context, token string) (*Owner, error) {
+	return getOne[Owner](ctx, r.q, `SELECT * FROM owners WHERE chair_register_token = ?`, token)
+}
+
+func (r mysqlOwnerRepository) GetByAccessToken(ctx context.Context, token string) (*Owner, error) {
+	return getOne[Owner](ctx, r.q, `SELECT * FROM owners WHERE access_token = ?`, token)
+}
+
+func (r mysqlOwnerRepository) Search(ctx context.Context, q string, limit int) ([]Owner, error) {
+	return selectAll[Owner](
+		ctx, r.q,
+		`SELECT * FROM owners WHERE id = ? OR name LIKE CONCAT('%', ?, '%') ORDER BY created_at DESC LIMIT ?`,
+		q, q, limit,
+	)
+}
+
+func (r mysqlOwnerRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE owners SET suspended_at = ? WHERE id = ?`, suspendedAt, id)
+	return err
+}
+
+func (r mysqlOwnerRepository) Create(ctx context.Context, owner *Owner) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO owners (id, name, access_token, chair_register_token) VALUES (?, ?, ?, ?)`,
+		owner.ID, owner.Name, owner.AccessToken, owner.ChairRegisterToken,
+	)
+	return err
+}
+
+type mysqlCouponRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlCouponRepository) Create(ctx context.Context, coupon *Coupon) error {
+	_, err := r.q.ExecContext(ctx, `INSERT INTO coupons (user_id, code, discount) VALUES (?, ?, ?)`, coupon.UserID, coupon.Code, coupon.Discount)
+	return err
+}
+
+func (r mysqlCouponRepository) CountByCodeForUpdate(ctx context.Context, code string) (int, error) {
+	coupons, err := selectAll[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE code = ? FOR UPDATE`, code)
+	if err != nil {
+		return 0, err
+	}
+	return len(coupons), nil
+}
+
+func (r mysqlCouponRepository) GetByRide(ctx context.Context, rideID string) (*Coupon, error) {
+	return getOne[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE used_by = ?`, rideID)
+}
+
+func (r mysqlCouponRepository) GetUnused(ctx context.Context, userID string, code string) (*Coupon, error) {
+	return getOne[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE user_id = ? AND code = ? AND used_by IS NULL`, userID, code)
+}
+
+func (r mysqlCouponRepository) GetUnusedForUpdate(ctx context.Context, userID string, code string) (*Coupon, error) {
+	return getOne[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE user_id = ? AND code = ? AND used_by IS NULL FOR UPDATE`, userID, code)
+}
+
+func (r mysqlCouponRepository) OldestUnused(ctx context.Context, userID string) (*Coupon, error) {
+	return getOne[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1`, userID)
+}
+
+func (r mysqlCouponRepository) OldestUnusedForUpdate(ctx context.Context, userID string) (*Coupon, error) {
+	return getOne[Coupon](ctx, r.q, `SELECT * FROM coupons WHERE user_id = ? AND used_by IS NULL ORDER BY created_at LIMIT 1 FOR UPDATE`, userID)
+}
+
+func (r mysqlCouponRepository) Use(ctx context.Context, userID string, code string, rideID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE coupons SET used_by = ? WHERE user_id = ? AND code = ?`, rideID, userID, code)
+	return err
+}
+
+type mysqlPaymentRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlPaymentRepository) SaveToken(ctx context.Context, userID string, token string) error {
+	_, err := r.q.ExecContext(ctx, `INSERT INTO payment_tokens (user_id, token) VALUES (?, ?)`, userID, token)
+	return err
+}
+
+func (r mysqlPaymentRepository) GetToken(ctx context.Context, userID string) (*PaymentToken, error) {
+	return getOne[PaymentToken](ctx, r.q, `SELECT * FROM payment_tokens WHERE user_id = ?`, userID)
+}
+
+type mysqlReviewRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlReviewRepository) Exists(ctx context.Context, rideID string, reviewerType string) (bool, error) {
+	exists := false
+	if err := sqlx.GetContext(ctx, r.q, &exists, `SELECT COUNT(*) > 0 FROM ride_reviews WHERE ride_id = ? AND reviewer_type = ?`, rideID, reviewerType); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r mysqlReviewRepository) Create(ctx context.Context, review *RideReview) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO ride_reviews (ride_id, reviewer_type, rating, comment, tags) VALUES (?, ?, ?, ?, ?)`,
+		review.RideID, review.ReviewerType, review.Rating, review.Comment, review.Tags,
+	)
+	return err
+}
+
+func (r mysqlReviewRepository) AddRating(ctx context.Context, subjectType string, subjectID string, rating int) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO reputations (subject_type, subject_id, rating_count, rating_sum) VALUES (?, ?, 1, ?)
+		 ON DUPLICATE KEY UPDATE rating_count = rating_count + 1, rating_sum = rating_sum + VALUES(rating_sum)`,
+		subjectType, subjectID, rating,
+	)
+	return err
+}
+
+func (r mysqlReviewRepository) GetReputation(ctx context.Context, subjectType string, subjectID string) (Reputation, error) {
+	reputation, err := getOne[Reputation](ctx, r.q, `SELECT * FROM reputations WHERE subject_type = ? AND subject_id = ?`, subjectType, subjectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reputation{SubjectType: subjectType, SubjectID: subjectID}, nil
+	}
+	if err != nil {
+		return Reputation{}, err
+	}
+	return *reputation, nil
+}
+
+func (r mysqlReviewRepository) ListLowRated(ctx context.Context, subjectType string) (map[string]bool, error) {
+	ids := []string{}
+	if err := sqlx.SelectContext(
+		ctx,
+		r.q,
+		&ids,
+		`SELECT subject_id FROM reputations WHERE subject_type = ? AND rating_count >= ? AND rating_sum < rating_count * ?`,
+		subjectType, minRatingsForReputation, lowRatingThreshold,
+	); err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		res[id] = true
+	}
+	return res, nil
+}
+
+func (r mysqlReviewRepository) ListRecentBySubject(ctx context.Context, subjectType string, subjectID string, limit int) ([]RideReview, error) {
+	switch subjectType {
+	case "chair":
+		return selectAll[RideReview](
+			ctx, r.q,
+			`SELECT ride_reviews.* FROM ride_reviews JOIN rides ON rides.id = ride_reviews.ride_id
+			WHERE ride_reviews.reviewer_type = 'user' AND rides.chair_id = ? ORDER BY ride_reviews.created_at DESC LIMIT ?`,
+			subjectID, limit,
+		)
+	case "owner":
+		return selectAll[RideReview](
+			ctx, r.q,
+			`SELECT ride_reviews.* FROM ride_reviews JOIN rides ON rides.id = ride_reviews.ride_id JOIN chairs ON chairs.id = rides.chair_id
+			WHERE ride_reviews.reviewer_type = 'user' AND chairs.owner_id = ? ORDER BY ride_reviews.created_at DESC LIMIT ?`,
+			subjectID, limit,
+		)
+	}
+	return []RideReview{}, nil
+}
+
+type mysqlScheduledRideRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlScheduledRideRepository) Create(ctx context.Context, scheduledRide *ScheduledRide) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO scheduled_rides (id, user_id, pickup_latitude, pickup_longitude, destination_latitude, destination_longitude, scheduled_at)
+				  VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		scheduledRide.ID, scheduledRide.UserID, scheduledRide.PickupLatitude, scheduledRide.PickupLongitude, scheduledRide.DestinationLatitude, scheduledRide.DestinationLongitude, scheduledRide.ScheduledAt,
+	)
+	return err
+}
+
+func (r mysqlScheduledRideRepository) GetForUpdate(ctx context.Context, id string) (*ScheduledRide, error) {
+	return getOne[ScheduledRide](ctx, r.q, `SELECT * FROM scheduled_rides WHERE id = ? FOR UPDATE`, id)
+}
+
+func (r mysqlScheduledRideRepository) ListPendingByUser(ctx context.Context, userID string) ([]ScheduledRide, error) {
+	return selectAll[ScheduledRide](ctx, r.q, `SELECT * FROM scheduled_rides WHERE user_id = ? AND ride_id IS NULL AND canceled_at IS NULL ORDER BY scheduled_at`, userID)
+}
+
+func (r mysqlScheduledRideRepository) ListPendingUntil(ctx context.Context, until time.Time) ([]ScheduledRide, error) {
+	return selectAll[ScheduledRide](ctx, r.q, `SELECT * FROM scheduled_rides WHERE ride_id IS NULL AND canceled_at IS NULL AND scheduled_at <= ? ORDER BY scheduled_at`, until)
+}
+
+func (r mysqlScheduledRideRepository) Cancel(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE scheduled_rides SET canceled_at = CURRENT_TIMESTAMP(6) WHERE id = ?`, id)
+	return err
+}
+
+func (r mysqlScheduledRideRepository) SetRide(ctx context.Context, id string, rideID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE scheduled_rides SET ride_id = ? WHERE id = ?`, rideID, id)
+	return err
+}
+
+type mysqlShareRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlShareRepository) Create(ctx context.Context, share *RideShare) error {
+	_, err := r.q.ExecContext(ctx, `INSERT INTO ride_shares (token_hash, ride_id, user_id) VALUES (?, ?, ?)`, share.TokenHash, share.RideID, share.UserID)
+	return err
+}
+
+func (r mysqlShareRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*RideShare, error) {
+	return getOne[RideShare](ctx, r.q, `SELECT * FROM ride_shares WHERE token_hash = ?`, tokenHash)
+}
+
+func (r mysqlShareRepository) RevokeByRide(ctx context.Context, rideID string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE ride_shares SET revoked_at = CURRENT_TIMESTAMP(6) WHERE ride_id = ? AND revoked_at IS NULL`, rideID)
+	return err
+}
+
+type mysqlIncidentRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlIncidentRepository) Create(ctx context.Context, incident *Incident) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO incidents (id, ride_id, user_id, chair_id, kind, message, latitude, longitude) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		incident.ID, incident.RideID, incident.UserID, incident.ChairID, incident.Kind, incident.Message, incident.Latitude, incident.Longitude,
+	)
+	return err
+}
+
+func (r mysqlIncidentRepository) ListByStatus(ctx context.Context, status string, limit int) ([]Incident, error) {
+	return selectAll[Incident](ctx, r.q, `SELECT * FROM incidents WHERE status = ? ORDER BY created_at ASC LIMIT ?`, status, limit)
+}
+
+func (r mysqlIncidentRepository) Resolve(ctx context.Context, id string) (bool, error) {
+	result, err := r.q.ExecContext(ctx, `UPDATE incidents SET status = 'RESOLVED', resolved_at = CURRENT_TIMESTAMP(6) WHERE id = ? AND status = 'OPEN'`, id)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+type mysqlSessionRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlSessionRepository) Create(ctx context.Context, session *Session) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO sessions (id, subject_type, subject_id, token_hash, refresh_token_hash, device, expires_at, refresh_expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.SubjectType, session.SubjectID, session.TokenHash, session.RefreshTokenHash, session.Device, session.ExpiresAt, session.RefreshExpiresAt,
+	)
+	return err
+}
+
+func (r mysqlSessionRepository) Get(ctx context.Context, id string) (*Session, error) {
+	return getOne[Session](ctx, r.q, `SELECT * FROM sessions WHERE id = ?`, id)
+}
+
+func (r mysqlSessionRepository) GetByTokenHash(ctx context.Context, subjectType string, tokenHash string) (*Session, error) {
+	return getOne[Session](ctx, r.q, `SELECT * FROM sessions WHERE token_hash = ? AND subject_type = ?`, tokenHash, subjectType)
+}
+
+func (r mysqlSessionRepository) GetByRefreshTokenHashForUpdate(ctx context.Context, subjectType string, refreshTokenHash string) (*Session, error) {
+	return getOne[Session](ctx, r.q, `SELECT * FROM sessions WHERE refresh_token_hash = ? AND subject_type = ? FOR UPDATE`, refreshTokenHash, subjectType)
+}
+
+func (r mysqlSessionRepository) ListActive(ctx context.Context, subjectType string, subjectID string, now time.Time) ([]Session, error) {
+	return selectAll[Session](
+		ctx, r.q,
+		`SELECT * FROM sessions WHERE subject_type = ? AND subject_id = ? AND revoked_at IS NULL AND refresh_expires_at > ? ORDER BY created_at DESC`,
+		subjectType, subjectID, now,
+	)
+}
+
+func (r mysqlSessionRepository) Rotate(ctx context.Context, id string, tokenHash string, refreshTokenHash string, expiresAt time.Time, refreshExpiresAt time.Time) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`UPDATE sessions SET token_hash = ?, refresh_token_hash = ?, expires_at = ?, refresh_expires_at = ? WHERE id = ?`,
+		tokenHash, refreshTokenHash, expiresAt, refreshExpiresAt, id,
+	)
+	return err
+}
+
+func (r mysqlSessionRepository) Revoke(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP(6) WHERE id = ? AND revoked_at IS NULL`, id)
+	return err
+}
+
+type mysqlAPIKeyRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlAPIKeyRepository) Create(ctx context.Context, apiKey *APIKey) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO api_keys (id, owner_id, subject_type, subject_id, name, key_hash, scopes, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		apiKey.ID, apiKey.OwnerID, apiKey.SubjectType, apiKey.SubjectID, apiKey.Name, apiKey.KeyHash, apiKey.Scopes, apiKey.ExpiresAt,
+	)
+	return err
+}
+
+func (r mysqlAPIKeyRepository) Get(ctx context.Context, id string) (*APIKey, error) {
+	return getOne[APIKey](ctx, r.q, `SELECT * FROM api_keys WHERE id = ?`, id)
+}
+
+func (r mysqlAPIKeyRepository) ListByOwner(ctx context.Context, ownerID string) ([]APIKey, error) {
+	return selectAll[APIKey](ctx, r.q, `SELECT * FROM api_keys WHERE owner_id = ? ORDER BY created_at DESC`, ownerID)
+}
+
+func (r mysqlAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP(6) WHERE id = ? AND revoked_at IS NULL`, id)
+	return err
+}
+
+func (r mysqlAPIKeyRepository) SetLastUsed(ctx context.Context, id string, at time.Time) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+type mysqlAuditLogRepository struct {
+	q sqlx.ExtContext
+}
+
+func (r mysqlAuditLogRepository) Create(ctx context.Context, log *AdminAuditLog) error {
+	_, err := r.q.ExecContext(
+		ctx,
+		`INSERT INTO admin_audit_logs (id, actor, action, target_type, target_id, detail) VALUES (?, ?, ?, ?, ?, ?)`,
+		log.ID, log.Actor, log.Action, log.TargetType, log.TargetID, log.Detail,
+	)
+	return err
+}
+
+func (r mysqlAuditLogRepository) Search(ctx context.Context, targetType string, targetID string, limit int) ([]AdminAuditLog, error) {
+	return selectAll[AdminAuditLog](
+		ctx, r.q,
+		`SELECT * FROM admin_audit_logs WHERE (? = '' OR target_type = ?) AND (? = '' OR target_id = ?) ORDER BY created_at DESC LIMIT ?`,
+		targetType, targetType, targetID, targetID, limit,
+	)
+}
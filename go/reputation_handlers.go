@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	maxReviewCommentLength = 500
+	maxReviewTags          = 5
+	maxReviewTagLength     = 30
+
+	// この件数以上評価されていて平均がしきい値未満なら低評価として扱う
+	minRatingsForReputation = 3
+	lowRatingThreshold      = 2.5
+	// 低評価の椅子はこの距離だけ遠くにいるものとしてマッチングする
+	lowRatedChairPenalty = 50
+	// 評判と一緒に返す最近のレビューの件数
+	recentReviewsLimit = 10
+)
+
+type reviewInput struct {
+	Comment *string  `json:"comment"`
+	Tags    []string `json:"tags"`
+}
+
+func (in reviewInput) validate() error {
+	if in.Comment != nil && len([]rune(*in.Comment)) > maxReviewCommentLength {
+		return fmt.Errorf("comment must be at most %d characters", maxReviewCommentLength)
+	}
+	if len(in.Tags) > maxReviewTags {
+		return fmt.Errorf("too many tags (max %d)", maxReviewTags)
+	}
+	for _, tag := range in.Tags {
+		if tag == "" || len([]rune(tag)) > maxReviewTagLength {
+			return fmt.Errorf("each tag must be 1 to %d characters", maxReviewTagLength)
+		}
+	}
+	return nil
+}
+
+// 評価を記録し、評価対象の集計を加算する
+func recordReview(ctx context.Context, reviews ReviewRepository, rideID string, reviewerType string, rating int, in reviewInput, subjects map[string]string) error {
+	var tags *string
+	if len(in.Tags) > 0 {
+		b, err := json.Marshal(in.Tags)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		tags = &s
+	}
+
+	if err := reviews.Create(ctx, &RideReview{
+		RideID:       rideID,
+		ReviewerType: reviewerType,
+		Rating:       rating,
+		Comment:      in.Comment,
+		Tags:         tags,
+	}); err != nil {
+		return err
+	}
+
+	for subjectType, subjectID := range subjects {
+		if err := reviews.AddRating(ctx, subjectType, subjectID, rating); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Reputation) average() float64 {
+	if r.RatingCount == 0 {
+		return 0
+	}
+	return float64(r.RatingSum) / float64(r.RatingCount)
+}
+
+type getReputationResponse struct {
+	SubjectType   string                        `json:"subject_type"`
+	SubjectID     string                        `json:"subject_id"`
+	RatingCount   int                           `json:"rating_count"`
+	AverageRating float64                       `json:"average_rating"`
+	RecentReviews []getReputationResponseReview `json:"recent_reviews"`
+}
+
+type getReputationResponseReview struct {
+	Rating    int      `json:"rating"`
+	Comment   string   `json:"comment,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// ユーザーは椅子・オーナーと自分の評判を、オーナーは自分と自分の椅子の評判を見られる
+func canViewReputation(ctx context.Context, chairs ChairRepository, subjectType string, subjectID string) (bool, error) {
+	if user, ok := ctx.Value("user").(*User); ok {
+		return subjectType != "user" || subjectID == user.ID, nil
+	}
+	if owner, ok := ctx.Value("owner").(*Owner); ok {
+		switch subjectType {
+		case "owner":
+			return subjectID == owner.ID, nil
+		case "chair":
+			chair, err := chairs.Get(ctx, subjectID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return false, nil
+				}
+				return false, err
+			}
+			return chair.OwnerID == owner.ID, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *Handler) getReputations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subjectType := r.PathValue("subject_type")
+	subjectID := r.PathValue("subject_id")
+
+	switch subjectType {
+	case "chair", "owner", "user":
+	default:
+		writeError(w, r, http.StatusBadRequest, errors.New("subject_type must be one of chair, owner, user"))
+		return
+	}
+	if ok, err := canViewReputation(ctx, h.store.Chairs, subjectType, subjectID); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	} else if !ok {
+		writeError(w, r, http.StatusForbidden, errors.New("not allowed to view this reputation"))
+		return
+	}
+
+	reputation, err := h.store.Reviews.GetReputation(ctx, subjectType, subjectID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := getReputationResponse{
+		SubjectType:   subjectType,
+		SubjectID:     subjectID,
+		RatingCount:   reputation.RatingCount,
+		AverageRating: reputation.average(),
+		RecentReviews: []getReputationResponseReview{},
+	}
+
+	// ユーザー向けのコメントは本人以外に見せない
+	if subjectType != "user" {
+		reviews, err := h.store.Reviews.ListRecentBySubject(ctx, subjectType, subjectID, recentReviewsLimit)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		for _, review := range reviews {
+			item := getReputationResponseReview{
+				Rating:    review.Rating,
+				CreatedAt: review.CreatedAt.UnixMilli(),
+			}
+			if review.Comment != nil {
+				item.Comment = *review.Comment
+			}
+			if review.Tags != nil {
+				if err := json.Unmarshal([]byte(*review.Tags), &item.Tags); err != nil {
+					writeError(w, r, http.StatusInternalServerError, err)
+					return
+				}
+			}
+			res.RecentReviews = append(res.RecentReviews, item)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const maxIncidentMessageLength = 1000
+
+type appPostRideShareResponse struct {
+	Token string `json:"token"`
+}
+
+// 利用者本人のライドを取得する
+func getUserRide(ctx context.Context, rides RideRepository, rideID string, userID string) (*Ride, error) {
+	ride, err := rides.Get(ctx, rideID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	return ride, nil
+}
+
+func (h *Handler) appPostRideShare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+	user := ctx.Value("user").(*User)
+
+	ride, err := getUserRide(ctx, h.store.Rides, rideID, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("ride not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	status, err := getLatestRideStatus(ctx, h.store.Rides, ride.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if status == "COMPLETED" {
+		writeError(w, r, http.StatusBadRequest, errors.New("ride has already been completed"))
+		return
+	}
+
+	// トークンはハッシュにして保存し、平文はこの応答でだけ返す
+	token := secureRandomStr(32)
+	if err := h.store.Shares.Create(ctx, &RideShare{TokenHash: hashToken(token), RideID: ride.ID, UserID: user.ID}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, &appPostRideShareResponse{
+		Token: token,
+	})
+}
+
+// ライドの共有リンクを全て止める。止めたリンクは完了したときと同じく 410 を返す
+func (h *Handler) appDeleteRideShare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+	user := ctx.Value("user").(*User)
+
+	ride, err := getUserRide(ctx, h.store.Rides, rideID, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("ride not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.store.Shares.RevokeByRide(ctx, ride.ID); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type getSharedRideResponse struct {
+	RideID                string                      `json:"ride_id"`
+	Status                string                      `json:"status"`
+	PickupCoordinate      Coordinate                  `json:"pickup_coordinate"`
+	DestinationCoordinate Coordinate                  `json:"destination_coordinate"`
+	Chair                 *getSharedRideResponseChair `json:"chair,omitempty"`
+	RetrievedAt           int64                       `json:"retrieved_at"`
+}
+
+type getSharedRideResponseChair struct {
+	Name              string      `json:"name"`
+	Model             string      `json:"model"`
+	CurrentCoordinate *Coordinate `json:"current_coordinate,omitempty"`
+}
+
+// 共有リンクは認証なしで見られるので、利用者が特定できる情報は返さない
+func (h *Handler) getSharedRide(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := r.PathValue("token")
+
+	share, err := h.store.Shares.GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("shared ride not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if share.RevokedAt != nil {
+		writeError(w, r, http.StatusGone, errors.New("shared ride has been revoked"))
+		return
+	}
+
+	ride, err := h.store.Rides.Get(ctx, share.RideID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	status, err := getLatestRideStatus(ctx, h.store.Rides, ride.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// 完了したライドの共有は終了
+	if status == "COMPLETED" {
+		writeError(w, r, http.StatusGone, errors.New("shared ride has been completed"))
+		return
+	}
+
+	res := &getSharedRideResponse{
+		RideID:                ride.ID,
+		Status:                status,
+		PickupCoordinate:      Coordinate{Latitude: ride.PickupLatitude, Longitude: ride.PickupLongitude},
+		DestinationCoordinate: Coordinate{Latitude: ride.DestinationLatitude, Longitude: ride.DestinationLongitude},
+		RetrievedAt:           time.Now().UnixMilli(),
+	}
+
+	if ride.ChairID.Valid {
+		chair, err := h.store.Chairs.Get(ctx, ride.ChairID.String)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		res.Chair = &getSharedRideResponseChair{
+			Name:  chair.Name,
+			Model: chair.Model,
+		}
+		// 位置を溜めているときは、まだ書き込んでいないメモリ上の位置のほうが新しい
+		if position, ok := coordinateBuffer.position(chair.ID); ok {
+			res.Chair.CurrentCoordinate = &position
+		} else if chair.Latitude.Valid && chair.Longitude.Valid {
+			res.Chair.CurrentCoordinate = &Coordinate{
+				Latitude:  int(chair.Latitude.Int64),
+				Longitude: int(chair.Longitude.Int64),
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+type appPostRideSOSRequest struct {
+	Message    *string     `json:"message"`
+	Coordinate *Coordinate `json:"coordinate"`
+}
+
+type appPostRideSOSResponse struct {
+	IncidentID string `json:"incident_id"`
+}
+
+func (h *Handler) appPostRideSOS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rideID := r.PathValue("ride_id")
+	user := ctx.Value("user").(*User)
+
+	req := &appPostRideSOSRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Message != nil && len([]rune(*req.Message)) > maxIncidentMessageLength {
+		writeError(w, r, http.StatusBadRequest, errors.New("message is too long"))
+		return
+	}
+
+	ride, err := getUserRide(ctx, h.store.Rides, rideID, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("ride not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	var latitude, longitude sql.NullInt64
+	if req.Coordinate != nil {
+		latitude = sql.NullInt64{Int64: int64(req.Coordinate.Latitude), Valid: true}
+		longitude = sql.NullInt64{Int64: int64(req.Coordinate.Longitude), Valid: true}
+	}
+
+	incidentID := ulid.Make().String()
+	if err := h.store.Incidents.Create(ctx, &Incident{
+		ID:        incidentID,
+		RideID:    ride.ID,
+		UserID:    user.ID,
+		ChairID:   ride.ChairID,
+		Kind:      "SOS",
+		Message:   req.Message,
+		Latitude:  latitude,
+		Longitude: longitude,
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	slog.Warn("SOS raised", "incident_id", incidentID, "ride_id", ride.ID, "user_id", user.ID, "chair_id", ride.ChairID.String)
+
+	writeJSON(w, http.StatusCreated, &appPostRideSOSResponse{
+		IncidentID: incidentID,
+	})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// 予約できるのは何日先までか
+	scheduledRideMaxDays = 7
+	// 予約時刻はこれより先でなければならない
+	scheduledRideMinLead = 10 * time.Minute
+	// 椅子が見つからないときに何分前に配車を始めるか
+	scheduledRideDefaultLead = 15 * time.Minute
+	// 到着見込みに上乗せする余裕
+	scheduledRideDispatchBuffer = 3 * time.Minute
+	// 椅子は概ねこの間隔で座標を送ってきて、1回にspeed分だけ移動する
+	chairMoveInterval = time.Second
+	// 同じユーザーの予約や走行中のライドとこれより近い時刻には予約できない
+	scheduledRideMinInterval = 30 * time.Minute
+)
+
+var errScheduledRideOverlaps = errors.New("scheduled ride overlaps another ride")
+
+type appPostScheduledRidesRequest struct {
+	PickupCoordinate      *Coordinate `json:"pickup_coordinate"`
+	DestinationCoordinate *Coordinate `json:"destination_coordinate"`
+	ScheduledAt           int64       `json:"scheduled_at"`
+}
+
+type appPostScheduledRidesResponse struct {
+	ScheduledRideID string `json:"scheduled_ride_id"`
+	Fare            int    `json:"fare"`
+}
+
+func (h *Handler) appPostScheduledRides(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := &appPostScheduledRidesRequest{}
+	if err := bindJSON(r, req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.PickupCoordinate == nil || req.DestinationCoordinate == nil || req.ScheduledAt == 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("required fields(pickup_coordinate, destination_coordinate, scheduled_at) are empty"))
+		return
+	}
+
+	scheduledAt := time.UnixMilli(req.ScheduledAt)
+	now := time.Now()
+	if scheduledAt.Before(now.Add(scheduledRideMinLead)) {
+		writeError(w, r, http.StatusBadRequest, errors.New("scheduled_at is too early"))
+		return
+	}
+	if scheduledAt.After(now.AddDate(0, 0, scheduledRideMaxDays)) {
+		writeError(w, r, http.StatusBadRequest, errors.New("scheduled_at is too far in the future"))
+		return
+	}
+
+	user := ctx.Value("user").(*User)
+	scheduledRideID := ulid.Make().String()
+
+	// 同じユーザーの予約が同時に来ても重複を確かめてから作るよう、ユーザーごとのロックを持つ
+	fare := 0
+	err := h.store.WithLock(ctx, scheduledRideLockName(user.ID), func() error {
+		return h.store.InTx(ctx, func(tx Repositories) error {
+			if err := checkScheduledRideOverlap(ctx, tx, user.ID, scheduledAt, now); err != nil {
+				return err
+			}
+
+			if err := tx.ScheduledRides.Create(ctx, &ScheduledRide{
+				ID:                   scheduledRideID,
+				UserID:               user.ID,
+				PickupLatitude:       req.PickupCoordinate.Latitude,
+				PickupLongitude:      req.PickupCoordinate.Longitude,
+				DestinationLatitude:  req.DestinationCoordinate.Latitude,
+				DestinationLongitude: req.DestinationCoordinate.Longitude,
+				ScheduledAt:          scheduledAt,
+			}); err != nil {
+				return err
+			}
+
+			// クーポンは配車時に確定するので、ここでは見積もりを返す
+			var err error
+			fare, err = calculateDiscountedFare(ctx, tx, user.ID, nil, nil, false, req.PickupCoordinate.Latitude, req.PickupCoordinate.Longitude, req.DestinationCoordinate.Latitude, req.DestinationCoordinate.Longitude)
+			return err
+		})
+	})
+	if err != nil {
+		if errors.Is(err, errStoreLockTimeout) {
+			err = withStatus(http.StatusConflict, errors.New("another scheduled ride is being created"))
+		}
+		writeStatusError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, &appPostScheduledRidesResponse{
+		ScheduledRideID: scheduledRideID,
+		Fare:            fare,
+	})
+}
+
+func scheduledRideLockName(userID string) string {
+	return "isuride_scheduled_ride_" + userID
+}
+
+// 同じユーザーの未配車の予約や走行中のライドと時刻が近すぎないか
+func checkScheduledRideOverlap(ctx context.Context, tx Repositories, userID string, scheduledAt time.Time, now time.Time) error {
+	pending, err := tx.ScheduledRides.ListPendingByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, s := range pending {
+		if s.ScheduledAt.Sub(scheduledAt).Abs() < scheduledRideMinInterval {
+			return withStatus(http.StatusConflict, errScheduledRideOverlaps)
+		}
+	}
+
+	// 走行中のライドは今から予約時刻までに終わる見込みがなければ重なるとみなす
+	if scheduledAt.Sub(now) < scheduledRideMinInterval {
+		continuing, err := hasContinuingRide(ctx, tx.Rides, userID)
+		if err != nil {
+			return err
+		}
+		if continuing {
+			return withStatus(http.StatusConflict, errScheduledRideOverlaps)
+		}
+	}
+	return nil
+}
+
+type appGetScheduledRidesResponse struct {
+	ScheduledRides []appGetScheduledRidesResponseItem `json:"scheduled_rides"`
+}
+
+type appGetScheduledRidesResponseItem struct {
+	ID                    string     `json:"id"`
+	PickupCoordinate      Coordinate `json:"pickup_coordinate"`
+	DestinationCoordinate Coordinate `json:"destination_coordinate"`
+	ScheduledAt           int64      `json:"scheduled_at"`
+	CreatedAt             int64      `json:"created_at"`
+}
+
+func (h *Handler) appGetScheduledRides(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := ctx.Value("user").(*User)
+
+	scheduledRides, err := h.store.ScheduledRides.ListPendingByUser(ctx, user.ID)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	items := []appGetScheduledRidesResponseItem{}
+	for _, s := range scheduledRides {
+		items = append(items, appGetScheduledRidesResponseItem{
+			ID:                    s.ID,
+			PickupCoordinate:      Coordinate{Latitude: s.PickupLatitude, Longitude: s.PickupLongitude},
+			DestinationCoordinate: Coordinate{Latitude: s.DestinationLatitude, Longitude: s.DestinationLongitude},
+			ScheduledAt:           s.ScheduledAt.UnixMilli(),
+			CreatedAt:             s.CreatedAt.UnixMilli(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, &appGetScheduledRidesResponse{
+		ScheduledRides: items,
+	})
+}
+
+func (h *Handler) appDeleteScheduledRide(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scheduledRideID := r.PathValue("scheduled_ride_id")
+	user := ctx.Value("user").(*User)
+
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		scheduledRide, err := tx.ScheduledRides.GetForUpdate(ctx, scheduledRideID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return withStatus(http.StatusNotFound, errors.New("scheduled ride not found"))
+			}
+			return err
+		}
+		if scheduledRide.UserID != user.ID || scheduledRide.CanceledAt != nil {
+			return withStatus(http.StatusNotFound, errors.New("scheduled ride not found"))
+		}
+		if scheduledRide.RideID.Valid {
+			return withStatus(http.StatusConflict, errors.New("scheduled ride has already been dispatched"))
+		}
+		return tx.ScheduledRides.Cancel(ctx, scheduledRide.ID)
+	}); err != nil {
+		writeStatusError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type chairWithSpeed struct {
+	ID        string        `db:"id"`
+	Latitude  sql.NullInt64 `db:"latitude"`
+	Longitude sql.NullInt64 `db:"longitude"`
+	Speed     int           `db:"speed"`
+}
+
+// 空いている椅子のうち、一番早く迎えに行けそうな椅子の到着見込み時間を返す
+func estimatePickupETA(chairs []chairWithSpeed, pickup Coordinate) time.Duration {
+	eta := time.Duration(-1)
+	for _, chair := range chairs {
+		if !chair.Latitude.Valid || !chair.Longitude.Valid || chair.Speed <= 0 {
+			continue
+		}
+		distance := calculateDistance(pickup.Latitude, pickup.Longitude, int(chair.Latitude.Int64), int(chair.Longitude.Int64))
+		moves := (distance + chair.Speed - 1) / chair.Speed
+		if d := time.Duration(moves) * chairMoveInterval; eta < 0 || d < eta {
+			eta = d
+		}
+	}
+	if eta < 0 {
+		return scheduledRideDefaultLead
+	}
+	return eta
+}
+
+// 配車時刻になった予約ライドを通常のライドとして作成する
+func (h *Handler) dispatchScheduledRides(ctx context.Context) error {
+	now := time.Now()
+
+	scheduledRides, err := h.store.ScheduledRides.ListPendingUntil(ctx, now.Add(scheduledRideDefaultLead+scheduledRideDispatchBuffer))
+	if err != nil {
+		return err
+	}
+	if len(scheduledRides) == 0 {
+		return nil
+	}
+
+	available, err := h.store.Chairs.ListAvailableWithSpeed(ctx)
+	if err != nil {
+		return err
+	}
+	// 他のライドを担当している椅子はすぐには迎えに行けないので、到着見込みには使わない
+	chairs := make([]chairWithSpeed, 0, len(available))
+	for _, chair := range available {
+		free, err := h.store.Rides.IsChairFree(ctx, chair.ID)
+		if err != nil {
+			return err
+		}
+		if free {
+			chairs = append(chairs, chair)
+		}
+	}
+
+	for _, s := range scheduledRides {
+		pickup := Coordinate{Latitude: s.PickupLatitude, Longitude: s.PickupLongitude}
+		eta := min(estimatePickupETA(chairs, pickup), scheduledRideDefaultLead)
+		if now.Before(s.ScheduledAt.Add(-eta - scheduledRideDispatchBuffer)) {
+			continue
+		}
+		if err := h.dispatchScheduledRide(ctx, s); err != nil {
+			slog.Error("failed to dispatch scheduled ride", "scheduled_ride_id", s.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (h *Handler) dispatchScheduledRide(ctx context.Context, s ScheduledRide) error {
+	rideID := ulid.Make().String()
+	dispatched := false
+	if err := h.store.InTx(ctx, func(tx Repositories) error {
+		locked, err := tx.ScheduledRides.GetForUpdate(ctx, s.ID)
+		if err != nil {
+			return err
+		}
+		if locked.RideID.Valid || locked.CanceledAt != nil {
+			return nil
+		}
+
+		// 前のライドが終わっていなければ次回に回す
+		continuing, err := hasContinuingRide(ctx, tx.Rides, s.UserID)
+		if err != nil {
+			return err
+		}
+		if continuing {
+			return nil
+		}
+
+		if err := insertRide(
+			ctx, tx, rideID, s.UserID,
+			Coordinate{Latitude: s.PickupLatitude, Longitude: s.PickupLongitude},
+			nil,
+			Coordinate{Latitude: s.DestinationLatitude, Longitude: s.DestinationLongitude},
+			false,
+		); err != nil {
+			return err
+		}
+		dispatched = true
+		return tx.ScheduledRides.SetRide(ctx, s.ID, rideID)
+	}); err != nil {
+		return err
+	}
+
+	if dispatched {
+		rideStatusCache.Set(ctx, rideID, "MATCHING", rideStatusCacheTTL)
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// セッショントークンの有効期間
+	sessionTTL = 24 * time.Hour
+	// リフレッシュトークンの有効期間
+	sessionRefreshTTL = 30 * 24 * time.Hour
+	// 検証済みのセッションをキャッシュしておく秒数
+	sessionCacheSeconds = 60
+	// 認証済みの利用者・オーナー・椅子をキャッシュしておく秒数
+	principalCacheSeconds = 10
+
+	maxDeviceNameLength = 255
+)
+
+var errSessionNotFound = errors.New("session not found")
+
+// サブジェクト種別ごとのCookie名
+var sessionSubjects = map[string]struct {
+	CookiePrefix string
+}{
+	"user":  {CookiePrefix: "app"},
+	"owner": {CookiePrefix: "owner"},
+	"chair": {CookiePrefix: "chair"},
+}
+
+// 認証済みリクエストのセッション情報。access_tokenやAPIキーで認証した場合はIDが空になる
+type sessionContext struct {
+	ID          string   `json:"id"`
+	SubjectType string   `json:"subject_type"`
+	SubjectID   string   `json:"subject_id"`
+	ExpiresAt   int64    `json:"expires_at"`
+	APIKeyID    string   `json:"api_key_id,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+}
+
+// Cookieのセッションは全ての操作ができ、APIキーは付与されたスコープのみ
+func (s *sessionContext) allows(scope string) bool {
+	if s.APIKeyID == "" {
+		return true
+	}
+	return slices.Contains(s.Scopes, scope)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 初期データのaccess_tokenをそのままCookieにしての認証を許可するか。
+// 新しく登録したものの access_token はハッシュにして保存しているので、この方法では使えない
+func legacyAccessTokenEnabled() bool {
+	return config.Session.LegacyAccessToken
+}
+
+// 初期データの平文の access_token と区別するため、ハッシュにした値に付ける
+const accessTokenHashPrefix = "sha256:"
+
+// 登録時に発行した access_token はハッシュにして保存する。初期データのものは平文のまま
+func hashedAccessToken(token string) sql.NullString {
+	return sql.NullString{String: accessTokenHashPrefix + hashToken(token), Valid: true}
+}
+
+func secureCookieEnabled() bool {
+	return !config.Session.InsecureCookie
+}
+
+func deviceName(r *http.Request) string {
+	device := r.Header.Get("X-Device-Name")
+	if device == "" {
+		device = r.UserAgent()
+	}
+	if runes := []rune(device); len(runes) > maxDeviceNameLength {
+		device = string(runes[:maxDeviceNameLength])
+	}
+	return device
+}
+
+type issuedSession struct {
+	ID               string
+	Token            string
+	RefreshToken     string
+	ExpiresAt        time.Time
+	RefreshExpiresAt time.Time
+}
+
+func newIssuedSession(id string) issuedSession {
+	now := time.Now()
+	return issuedSession{
+		ID:               id,
+		Token:            secureRandomStr(32),
+		RefreshToken:     secureRandomStr(32),
+		ExpiresAt:        now.Add(sessionTTL),
+		RefreshExpiresAt: now.Add(sessionRefreshTTL),
+	}
+}
+
+// 端末ごとに新しいセッションを発行する
+func createSession(ctx context.Context, sessions SessionRepository, subjectType string, subjectID string, device string) (issuedSession, error) {
+	s := newIssuedSession(ulid.Make().String())
+	err := sessions.Create(ctx, &Session{
+		ID:               s.ID,
+		SubjectType:      subjectType,
+		SubjectID:        subjectID,
+		TokenHash:        hashToken(s.Token),
+		RefreshTokenHash: hashToken(s.RefreshToken),
+		Device:           device,
+		ExpiresAt:        s.ExpiresAt,
+		RefreshExpiresAt: s.RefreshExpiresAt,
+	})
+	return s, err
+}
+
+func setSessionCookies(w http.ResponseWriter, subjectType string, s issuedSession) {
+	prefix := sessionSubjects[subjectType].CookiePrefix
+	http.SetCookie(w, &http.Cookie{
+		Path:     "/",
+		Name:     prefix + "_session",
+		Value:    s.Token,
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secureCookieEnabled(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	// リフレッシュトークンはリフレッシュ時にしか送らせない
+	http.SetCookie(w, &http.Cookie{
+		Path:     "/api/" + prefix + "/session/refresh",
+		Name:     prefix + "_refresh",
+		Value:    s.RefreshToken,
+		Expires:  s.RefreshExpiresAt,
+		HttpOnly: true,
+		Secure:   secureCookieEnabled(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter, subjectType string) {
+	prefix := sessionSubjects[subjectType].CookiePrefix
+	for _, c := range []*http.Cookie{
+		{Path: "/", Name: prefix + "_session"},
+		{Path: "/api/" + prefix + "/session/refresh", Name: prefix + "_refresh"},
+	} {
+		c.MaxAge = -1
+		c.HttpOnly = true
+		c.Secure = secureCookieEnabled()
+		http.SetCookie(w, c)
+	}
+}
+
+// トークンのハッシュ → 検証したセッション情報
+var sessionCache = newTypedCache[sessionContext]("session.")
+
+// "<subjectType>.<subjectID>" → 利用者・オーナー・椅子の行。型は subjectType で決まるのでそのままの JSON で持つ
+var principalCache = newTypedCache[json.RawMessage]("principal.")
+
+// 利用停止などで認証済みの利用者・オーナー・椅子の情報が変わったときに呼ぶ
+func invalidatePrincipalCache(ctx context.Context, subjectType string, subjectID string) {
+	principalCache.Del(ctx, subjectType+"."+subjectID)
+}
+
+// トークンを検証してセッション情報を返す。検証結果はしばらくキャッシュする
+func (h *Handler) lookupSession(ctx context.Context, subjectType string, token string) (*sessionContext, error) {
+	tokenHash := hashToken(token)
+	now := time.Now()
+
+	if s, ok := sessionCache.Get(ctx, tokenHash); ok && s.SubjectType == subjectType && (s.ExpiresAt == 0 || now.UnixMilli() < s.ExpiresAt) {
+		return &s, nil
+	}
+
+	var res *sessionContext
+	session, err := h.store.Sessions.GetByTokenHash(ctx, subjectType, tokenHash)
+	switch {
+	case err == nil:
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			return nil, errSessionNotFound
+		}
+		res = &sessionContext{
+			ID:          session.ID,
+			SubjectType: subjectType,
+			SubjectID:   session.SubjectID,
+			ExpiresAt:   session.ExpiresAt.UnixMilli(),
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		if !legacyAccessTokenEnabled() {
+			return nil, errSessionNotFound
+		}
+		subjectID, err := h.lookupLegacyAccessToken(ctx, subjectType, token)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, errSessionNotFound
+			}
+			return nil, err
+		}
+		res = &sessionContext{SubjectType: subjectType, SubjectID: subjectID}
+	default:
+		return nil, err
+	}
+
+	ttl := sessionCacheSeconds
+	if res.ExpiresAt != 0 {
+		ttl = min(ttl, int(time.Until(time.UnixMilli(res.ExpiresAt)).Seconds()))
+	}
+	if ttl > 0 {
+		sessionCache.Set(ctx, tokenHash, *res, time.Duration(ttl)*time.Second)
+	}
+	return res, nil
+}
+
+// 初期データの平文の access_token の持ち主のIDを返す。保存してあるハッシュそのものは受け付けない
+func (h *Handler) lookupLegacyAccessToken(ctx context.Context, subjectType string, token string) (string, error) {
+	if strings.HasPrefix(token, accessTokenHashPrefix) {
+		return "", sql.ErrNoRows
+	}
+	return h.lookupAccessToken(ctx, subjectType, token)
+}
+
+// access_token カラムの値が stored である主体のIDを返す
+func (h *Handler) lookupAccessToken(ctx context.Context, subjectType string, stored string) (string, error) {
+	switch subjectType {
+	case "user":
+		user, err := h.store.Users.GetByAccessToken(ctx, stored)
+		if err != nil {
+			return "", err
+		}
+		return user.ID, nil
+	case "owner":
+		owner, err := h.store.Owners.GetByAccessToken(ctx, stored)
+		if err != nil {
+			return "", err
+		}
+		return owner.ID, nil
+	case "chair":
+		chair, err := h.store.Chairs.GetByAccessToken(ctx, stored)
+		if err != nil {
+			return "", err
+		}
+		return chair.ID, nil
+	}
+	return "", fmt.Errorf("unknown subject type: %s", subjectType)
+}
+
+// セッションの主体を取得する。短時間キャッシュする
+func (h *Handler) loadPrincipal(ctx context.Context, subjectType string, subjectID string, dest any) error {
+	key := subjectType + "." + subjectID
+	if item, ok := principalCache.Get(ctx, key); ok {
+		if err := json.Unmarshal(item, dest); err == nil {
+			return nil
+		}
+	}
+
+	switch dest := dest.(type) {
+	case *User:
+		user, err := h.store.Users.Get(ctx, subjectID)
+		if err != nil {
+			return err
+		}
+		*dest = *user
+	case *Owner:
+		owner, err := h.store.Owners.Get(ctx, subjectID)
+		if err != nil {
+			return err
+		}
+		*dest = *owner
+	case *Chair:
+		chair, err := h.store.Chairs.Get(ctx, subjectID)
+		if err != nil {
+			return err
+		}
+		*dest = *chair
+	default:
+		return fmt.Errorf("unsupported principal type: %T", dest)
+	}
+	if b, err := json.Marshal(dest); err == nil {
+		principalCache.Set(ctx, key, b, principalCacheSeconds*time.Second)
+	}
+	return nil
+}
+
+// Cookieのセッション、またはAuthorizationヘッダのAPIキーを検証し、主体をdestに読み込む
+func (h *Handler) authenticateSession(r *http.Request, subjectType string, dest any) (*sessionContext, int, error) {
+	ctx := r.Context()
+
+	var session *sessionContext
+	var err error
+	if key, ok := bearerToken(r); ok && apiKeyScopes[subjectType] != nil {
+		session, err = h.lookupAPIKey(ctx, subjectType, key)
+	} else {
+		cookieName := sessionSubjects[subjectType].CookiePrefix + "_session"
+		c, cookieErr := r.Cookie(cookieName)
+		if errors.Is(cookieErr, http.ErrNoCookie) || c.Value == "" {
+			return nil, http.StatusUnauthorized, errors.New(cookieName + " cookie is required")
+		}
+		session, err = h.lookupSession(ctx, subjectType, c.Value)
+	}
+	if err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			return nil, http.StatusUnauthorized, errors.New("invalid access token")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err := h.loadPrincipal(ctx, subjectType, session.SubjectID, dest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.StatusUnauthorized, errors.New("invalid access token")
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	return session, 0, nil
+}
+
+func revokeSession(ctx context.Context, sessions SessionRepository, session *Session) error {
+	if err := sessions.Revoke(ctx, session.ID); err != nil {
+		return err
+	}
+	sessionCache.Del(ctx, session.TokenHash)
+	return nil
+}
+
+type postSessionLoginRequest struct {
+	AccessToken string `json:"access_token"`
+}
+
+type postSessionLoginResponse struct {
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// 登録時に発行した access_token、または初期データの access_token で新しいセッションを発行する。
+// ログアウトした端末や、リフレッシュトークンの期限が切れた端末はここからやり直す
+func (h *Handler) postSessionLogin(subjectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		req := &postSessionLoginRequest{}
+		if err := bindJSON(r, req); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if req.AccessToken == "" {
+			writeError(w, r, http.StatusBadRequest, errors.New("access_token is required"))
+			return
+		}
+
+		subjectID, err := h.lookupAccessToken(ctx, subjectType, hashedAccessToken(req.AccessToken).String)
+		if errors.Is(err, sql.ErrNoRows) {
+			subjectID, err = h.lookupLegacyAccessToken(ctx, subjectType, req.AccessToken)
+		}
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, r, http.StatusUnauthorized, errors.New("invalid access token"))
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		session, err := createSession(ctx, h.store.Sessions, subjectType, subjectID, deviceName(r))
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		replicas.pinPrincipal(ctx, subjectType+"."+subjectID)
+		setSessionCookies(w, subjectType, session)
+		writeJSON(w, http.StatusOK, &postSessionLoginResponse{
+			ExpiresAt: session.ExpiresAt.UnixMilli(),
+		})
+	}
+}
+
+type postSessionRefreshResponse struct {
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// リフレッシュトークンを使ってセッションを延長する。トークンは両方とも再発行する
+func (h *Handler) postSessionRefresh(subjectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		cookieName := sessionSubjects[subjectType].CookiePrefix + "_refresh"
+		c, err := r.Cookie(cookieName)
+		if errors.Is(err, http.ErrNoCookie) || c.Value == "" {
+			writeError(w, r, http.StatusUnauthorized, errors.New(cookieName+" cookie is required"))
+			return
+		}
+
+		var session *Session
+		var issued issuedSession
+		err = h.store.InTx(ctx, func(tx Repositories) error {
+			var err error
+			session, err = tx.Sessions.GetByRefreshTokenHashForUpdate(ctx, subjectType, hashToken(c.Value))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return withStatus(http.StatusUnauthorized, errors.New("invalid refresh token"))
+				}
+				return err
+			}
+			if session.RevokedAt != nil || !time.Now().Before(session.RefreshExpiresAt) {
+				return withStatus(http.StatusUnauthorized, errors.New("invalid refresh token"))
+			}
+
+			issued = newIssuedSession(session.ID)
+			return tx.Sessions.Rotate(ctx, session.ID, hashToken(issued.Token), hashToken(issued.RefreshToken), issued.ExpiresAt, issued.RefreshExpiresAt)
+		})
+		if err != nil {
+			writeStatusError(w, r, err)
+			return
+		}
+
+		sessionCache.Del(ctx, session.TokenHash)
+		setSessionCookies(w, subjectType, issued)
+		writeJSON(w, http.StatusOK, &postSessionRefreshResponse{
+			ExpiresAt: issued.ExpiresAt.UnixMilli(),
+		})
+	}
+}
+
+// 現在のセッションを失効させる
+func (h *Handler) postSessionLogout(subjectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		current := ctx.Value("session").(*sessionContext)
+
+		// access_tokenでの認証はCookieを消すだけ
+		if current.ID != "" {
+			session, err := h.store.Sessions.Get(ctx, current.ID)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+			if err := revokeSession(ctx, h.store.Sessions, session); err != nil {
+				writeError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		}
+
+		clearSessionCookies(w, subjectType)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type getSessionsResponse struct {
+	Sessions []getSessionsResponseSession `json:"sessions"`
+}
+
+type getSessionsResponseSession struct {
+	ID        string `json:"id"`
+	Device    string `json:"device"`
+	Current   bool   `json:"current"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// ログイン中の端末の一覧
+func (h *Handler) getSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current := ctx.Value("session").(*sessionContext)
+
+	sessions, err := h.store.Sessions.ListActive(ctx, current.SubjectType, current.SubjectID, time.Now())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := getSessionsResponse{Sessions: []getSessionsResponseSession{}}
+	for _, s := range sessions {
+		res.Sessions = append(res.Sessions, getSessionsResponseSession{
+			ID:        s.ID,
+			Device:    s.Device,
+			Current:   s.ID == current.ID,
+			CreatedAt: s.CreatedAt.UnixMilli(),
+			ExpiresAt: s.ExpiresAt.UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// 他の端末のセッションを失効させる
+func (h *Handler) deleteSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	current := ctx.Value("session").(*sessionContext)
+	sessionID := r.PathValue("session_id")
+
+	session, err := h.store.Sessions.Get(ctx, sessionID)
+	if err == nil && (session.SubjectType != current.SubjectType || session.SubjectID != current.SubjectID) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, errors.New("session not found"))
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := revokeSession(ctx, h.store.Sessions, session); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
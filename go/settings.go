@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// settingsテーブルに保存され、稼働中に変更できる設定
+type RuntimeSettings struct {
+	PaymentGatewayURL      string `json:"payment_gateway_url"`
+	InitialFare            int    `json:"initial_fare"`
+	FarePerDistance        int    `json:"fare_per_distance"`
+	PaymentRetryCount      int    `json:"payment_retry_count"`
+	PaymentRetryIntervalMs int    `json:"payment_retry_interval_ms"`
+	// 0なら内部でマッチングを回さず、/api/internal/matching の呼び出しを待つ
+	MatchingIntervalMs int `json:"matching_interval_ms"`
+}
+
+func defaultRuntimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		InitialFare:            500,
+		FarePerDistance:        100,
+		PaymentRetryCount:      5,
+		PaymentRetryIntervalMs: 100,
+		MatchingIntervalMs:     0,
+	}
+}
+
+type settingDefinition struct {
+	Name  string
+	Apply func(s *RuntimeSettings, v string) error
+}
+
+func intSetting(min int, field func(s *RuntimeSettings) *int) func(s *RuntimeSettings, v string) error {
+	return func(s *RuntimeSettings, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if n < min {
+			return fmt.Errorf("must be at least %d", min)
+		}
+		*field(s) = n
+		return nil
+	}
+}
+
+var settingDefinitions = []settingDefinition{
+	{"payment_gateway_url", func(s *RuntimeSettings, v string) error {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("must be an http(s) URL")
+		}
+		s.PaymentGatewayURL = v
+		return nil
+	}},
+	{"initial_fare", intSetting(0, func(s *RuntimeSettings) *int { return &s.InitialFare })},
+	{"fare_per_distance", intSetting(0, func(s *RuntimeSettings) *int { return &s.FarePerDistance })},
+	{"payment_retry_count", intSetting(0, func(s *RuntimeSettings) *int { return &s.PaymentRetryCount })},
+	{"payment_retry_interval_ms", intSetting(0, func(s *RuntimeSettings) *int { return &s.PaymentRetryIntervalMs })},
+	{"matching_interval_ms", intSetting(0, func(s *RuntimeSettings) *int { return &s.MatchingIntervalMs })},
+}
+
+func findSettingDefinition(name string) (settingDefinition, bool) {
+	for _, def := range settingDefinitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return settingDefinition{}, false
+}
+
+// 運賃の計算に使う設定。ライドを作るときに rides に保存する
+type fareRates struct {
+	InitialFare     int
+	FarePerDistance int
+}
+
+func currentFareRates() fareRates {
+	s := currentSettings()
+	return fareRates{InitialFare: s.InitialFare, FarePerDistance: s.FarePerDistance}
+}
+
+// ライドを作った時点の運賃の設定
+func (r *Ride) fareRates() fareRates {
+	return fareRates{InitialFare: r.InitialFare, FarePerDistance: r.FarePerDistance}
+}
+
+var runtimeSettings atomic.Pointer[RuntimeSettings]
+
+// 毎回DBを読まないよう、キャッシュしている設定を返す
+func currentSettings() *RuntimeSettings {
+	if s := runtimeSettings.Load(); s != nil {
+		return s
+	}
+	s := defaultRuntimeSettings()
+	return &s
+}
+
+func loadRuntimeSettings(ctx context.Context) error {
+	rows := []struct {
+		Name  string `db:"name"`
+		Value string `db:"value"`
+	}{}
+	if err := db.SelectContext(ctx, &rows, `SELECT name, value FROM settings`); err != nil {
+		return err
+	}
+
+	s := defaultRuntimeSettings()
+	for _, row := range rows {
+		def, ok := findSettingDefinition(row.Name)
+		if !ok {
+			continue
+		}
+		// 壊れた値が入っていてもデフォルトで動き続ける
+		if err := def.Apply(&s, row.Value); err != nil {
+			slog.Warn("ignored invalid setting", "name", row.Name, "value", row.Value, "error", err)
+		}
+	}
+	runtimeSettings.Store(&s)
+	return nil
+}
+
+// settingsテーブルを定期的に読み直す。別のプロセスから書き換えても反映される
+func startSettingsReloader(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	startBackgroundWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := loadRuntimeSettings(ctx); err != nil {
+					slog.Error("failed to reload settings", "error", err)
+				}
+			}
+		}
+	})
+}
+
+// 現在の設定に変更を当てて検証する
+func validateSettingValues(values map[string]string) error {
+	s := *currentSettings()
+	for name, v := range values {
+		def, ok := findSettingDefinition(name)
+		if !ok {
+			return fmt.Errorf("unknown setting: %s", name)
+		}
+		if err := def.Apply(&s, v); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// 検証済みの値を保存し、すぐに反映する。変更は同じトランザクションで監査ログに残す
+func saveRuntimeSettings(ctx context.Context, values map[string]string) error {
+	before := *currentSettings()
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for name, v := range values {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO settings (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value)`, name, v); err != nil {
+			return err
+		}
+	}
+	if err := recordAdminAudit(ctx, newMySQLRepositories(tx).AuditLogs, "update_settings", "settings", "", map[string]any{
+		"before":  before,
+		"changes": values,
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return loadRuntimeSettings(ctx)
+}
+
+func adminGetSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentSettings())
+}
+
+// {"initial_fare": 600} のように変更したい設定だけを送る
+func adminPostSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req := map[string]json.RawMessage{}
+	if err := bindJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if len(req) == 0 {
+		writeError(w, r, http.StatusBadRequest, errors.New("no settings to update"))
+		return
+	}
+
+	values := make(map[string]string, len(req))
+	for name, raw := range req {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			// 数値などはそのままの表記で保存する
+			s = string(raw)
+		}
+		values[name] = s
+	}
+
+	if err := validateSettingValues(values); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := saveRuntimeSettings(ctx, values); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, currentSettings())
+}
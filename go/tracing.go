@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/XSAM/otelsql"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/isucon/isucon14/webapp/go"
+
+var tracer = otel.Tracer(tracerName)
+
+// config.Trace.Exporter でトレースの出力先を選ぶ
+//   - (空):    トレースしない
+//   - otlp:   OTEL_EXPORTER_OTLP_ENDPOINT などの標準の環境変数に従ってOTLP/HTTPで送る
+//   - stdout: 標準出力に書く
+//   - file:   config.Trace.File に追記する
+func tracingEnabled() bool {
+	return config.Trace.Exporter != ""
+}
+
+func newTraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch config.Trace.Exporter {
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	case "stdout":
+		return stdouttrace.New()
+	case "file":
+		f, err := os.OpenFile(config.Trace.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", config.Trace.Exporter)
+	}
+}
+
+// トレースを設定し、終了時に呼ぶ関数を返す
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if !tracingEnabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newTraceExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("isuride")))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.Trace.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// トレースが有効ならクエリごとにスパンを張るドライバで接続する
+func openDB(dsn string) (*sqlx.DB, error) {
+	if !tracingEnabled() {
+		return sqlx.Connect("mysql", dsn)
+	}
+
+	sqlDB, err := otelsql.Open("mysql", dsn,
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	sqlxDB := sqlx.NewDb(sqlDB, "mysql")
+	if err := sqlxDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return sqlxDB, nil
+}
+
+// ルーティング後にスパン名をルートのパターンにする
+func traceRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if pattern := chi.RouteContext(r.Context()).RoutePattern(); pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + pattern)
+			span.SetAttributes(semconv.HTTPRoute(pattern))
+		}
+	})
+}
+
+// 外部APIを呼ぶクライアント。トレースが有効ならスパンを張ってtraceparentを伝搬する
+var tracedHTTPClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+func httpClient() *http.Client {
+	if !tracingEnabled() {
+		return http.DefaultClient
+	}
+	return tracedHTTPClient
+}
+
+// キャッシュの参照にスパンを張る
+func cacheGet(ctx context.Context, key string) ([]byte, error) {
+	if !tracingEnabled() {
+		return cache.Get(ctx, key)
+	}
+	ctx, span := tracer.Start(ctx, "cache.Get", trace.WithAttributes(
+		attribute.String("cache.backend", config.Cache.Backend),
+		attribute.String("cache.key", key),
+	))
+	defer span.End()
+
+	value, err := cache.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	return value, err
+}
@@ -0,0 +1,53 @@
+package main
+
+import "context"
+
+// 1ライドあたりの経由地の上限
+const maxWaypoints = 5
+
+// 乗車地点から経由地を順に回って目的地に着くまでのマンハッタン距離の合計
+func calculateRouteDistance(pickup Coordinate, waypoints []Coordinate, destination Coordinate) int {
+	distance := 0
+	current := pickup
+	for _, waypoint := range waypoints {
+		distance += calculateDistance(current.Latitude, current.Longitude, waypoint.Latitude, waypoint.Longitude)
+		current = waypoint
+	}
+	return distance + calculateDistance(current.Latitude, current.Longitude, destination.Latitude, destination.Longitude)
+}
+
+// これから作るライドの、割引前の運賃
+func calculateRouteFare(pickup Coordinate, waypoints []Coordinate, destination Coordinate) int {
+	rates := currentFareRates()
+	return rates.InitialFare + rates.FarePerDistance*calculateRouteDistance(pickup, waypoints, destination)
+}
+
+func waypointCoordinates(waypoints []RideWaypoint) []Coordinate {
+	coordinates := make([]Coordinate, 0, len(waypoints))
+	for _, waypoint := range waypoints {
+		coordinates = append(coordinates, Coordinate{Latitude: waypoint.Latitude, Longitude: waypoint.Longitude})
+	}
+	return coordinates
+}
+
+// まだ到着していない最初の経由地
+func nextWaypoint(waypoints []RideWaypoint) *RideWaypoint {
+	for i := range waypoints {
+		if waypoints[i].ArrivedAt == nil {
+			return &waypoints[i]
+		}
+	}
+	return nil
+}
+
+// ride.WaypointCountが0なら問い合わせずに済ませる
+func loadWaypointCoordinates(ctx context.Context, rides RideRepository, ride *Ride) ([]Coordinate, error) {
+	if ride.WaypointCount == 0 {
+		return nil, nil
+	}
+	waypoints, err := rides.Waypoints(ctx, ride.ID)
+	if err != nil {
+		return nil, err
+	}
+	return waypointCoordinates(waypoints), nil
+}
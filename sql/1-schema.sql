@@ -3,6 +3,21 @@ SET CHARACTER_SET_CONNECTION = utf8mb4;
 
 USE isuride;
 
+-- go/migrations/0001_initial_schema.up.sql は、このファイルと 4-alter.sql を合わせたものと同じ。
+-- スキーマの変更はここではなく go/migrations に足し、初期データを入れたあとにアプリが残りを適用する。
+-- どこまで適用済みかは 5-schema-migrations.sql が記録する。
+-- 作り直すときは、マイグレーションで足したテーブルも消しておく
+DROP TABLE IF EXISTS schema_migrations;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS admin_audit_logs;
+DROP TABLE IF EXISTS incidents;
+DROP TABLE IF EXISTS ride_shares;
+DROP TABLE IF EXISTS reputations;
+DROP TABLE IF EXISTS ride_reviews;
+DROP TABLE IF EXISTS ride_waypoints;
+DROP TABLE IF EXISTS scheduled_rides;
+
 DROP TABLE IF EXISTS settings;
 CREATE TABLE settings
 (
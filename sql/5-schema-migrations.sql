@@ -0,0 +1,15 @@
+-- 初期データを入れたDBで適用済みのマイグレーション。go/migrate.go の createSchemaMigrationsTable と同じ定義にする。
+-- 0001 はこのディレクトリのスキーマそのもので、0014 の走行距離の埋め戻しは initial-distances.sql で済ませるので流さない。
+-- 残りは postInitialize が適用する
+CREATE TABLE schema_migrations
+(
+  version    INTEGER      NOT NULL COMMENT 'バージョン',
+  name       VARCHAR(255) NOT NULL COMMENT 'マイグレーション名',
+  applied_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) COMMENT '適用日時',
+  PRIMARY KEY (version)
+)
+  COMMENT = '適用済みのマイグレーションテーブル';
+
+INSERT INTO schema_migrations (version, name)
+VALUES (1, 'initial_schema'),
+       (14, 'backfill_chair_distances');
@@ -0,0 +1,1183 @@
+-- 3-initial-data.sql.gz の走行距離。マイグレーション 0011 で足した列は init.sh の時点ではまだないので、
+-- postInitialize がマイグレーションを適用したあとに流す。
+-- 値は 0014 の埋め戻しや `isuride distances rebuild` と同じで、初期データを入れたDBで数え直したもの。
+-- 初期データを変えたら作り直す
+DROP TABLE IF EXISTS initial_chair_distances;
+DROP TABLE IF EXISTS initial_ride_distances;
+
+CREATE TABLE initial_chair_distances
+(
+  chair_id                  VARCHAR(26) NOT NULL,
+  total_distance            INTEGER     NOT NULL,
+  loaded_distance           INTEGER     NOT NULL,
+  total_distance_updated_at DATETIME(6) NOT NULL,
+  latitude                  INTEGER     NOT NULL,
+  longitude                 INTEGER     NOT NULL,
+  PRIMARY KEY (chair_id)
+);
+
+CREATE TABLE initial_ride_distances
+(
+  ride_id         VARCHAR(26) NOT NULL,
+  pickup_distance INTEGER     NOT NULL,
+  loaded_distance INTEGER     NOT NULL,
+  PRIMARY KEY (ride_id)
+);
+
+INSERT INTO initial_chair_distances (chair_id, total_distance, loaded_distance, total_distance_updated_at, latitude, longitude)
+VALUES ('01JDFEF7MGXXCJKW1MNJXPA77A', 128, 19, '2024-11-26 04:51:42.000000', -30, 33),
+       ('01JDFEXJM006PH4C1EVHJ31QMV', 107, 20, '2024-11-26 08:03:35.000000', 66, 10),
+       ('01JDFF0T4G7TXSR2S5PKRAC3HK', 132, 45, '2024-11-26 07:46:02.000000', 37, 5),
+       ('01JDFFCJ3R35BM39QZ5SAMFXJD', 234, 56, '2024-11-26 03:33:31.000000', 38, 7),
+       ('01JDFFNACGHDSW5JB8S4GF8423', 105, 33, '2024-11-26 11:41:37.000000', -40, -19),
+       ('01JDFFT9J8JVCFVJAG4WN2B666', 94, 37, '2024-11-25 22:23:43.000000', 6, -9),
+       ('01JDFFVTCRFCWZS7C3CFS069ZH', 247, 44, '2024-11-26 06:41:44.000000', -5, -22),
+       ('01JDFFXC6GDVFK382S3RBEG5Q1', 147, 42, '2024-11-26 14:16:24.000000', 49, 25),
+       ('01JDFG801GD59PJFF2M8D3E4D5', 158, 37, '2024-11-26 10:13:31.000000', -45, 52),
+       ('01JDFGF7FR2ABDR0TQQ68DZBZV', 154, 57, '2024-11-26 10:15:07.000000', 57, 7),
+       ('01JDFGKYVG9FZW1R30YMSDSRYY', 75, 5, '2024-11-25 23:01:46.000000', -8, 5),
+       ('01JDFGQRXR0C4T3DFMS37GG7YG', 188, 40, '2024-11-26 12:23:57.000000', -21, -15),
+       ('01JDFGSWA07DEJYG8FMKDAJ2CY', 119, 25, '2024-11-26 00:36:10.000000', 19, -23),
+       ('01JDFGYJPGT3NQGFHHFWZXNGR5', 167, 37, '2024-11-26 11:21:24.000000', -40, -41),
+       ('01JDFH6WAGA8EEG0N6VPDRPCP7', 428, 77, '2024-11-26 02:11:37.000000', 50, -50),
+       ('01JDFHHWVRJV9P68AEWAB1QEW6', 342, 66, '2024-11-26 09:28:11.000000', 51, 39),
+       ('01JDFHNTV0KGFZ2N2B4CX0RBC5', 153, 43, '2024-11-26 09:42:02.000000', 26, -19),
+       ('01JDFHQ8QRXRJBV1XBFXV0RDY5', 72, 15, '2024-11-25 23:04:15.000000', 44, -4),
+       ('01JDFHZR78S51SNZ2RXQ1MTWCM', 338, 71, '2024-11-26 14:00:39.000000', 16, 16),
+       ('01JDFJ30Q0037J361FC7X4V0TA', 178, 58, '2024-11-25 20:03:45.000000', 14, -36),
+       ('01JDFJ6WQR5QMT57X4905AQY7B', 157, 67, '2024-11-25 20:27:10.000000', -9, 8),
+       ('01JDFJAC2866H315R7HTHZHABR', 218, 37, '2024-11-26 08:53:40.000000', -46, 52),
+       ('01JDFK1BDGV57XYXDMZVW1DWJK', 275, 84, '2024-11-25 20:07:14.000000', -27, 20),
+       ('01JDFK55FRKFD8F0MGBEYNHXYJ', 482, 72, '2024-11-26 03:31:15.000000', 25, -30),
+       ('01JDFK6JD8QRVJB6BAA658XZZ3', 91, 44, '2024-11-26 11:15:28.000000', -13, -10),
+       ('01JDFKF1WR5S48N2V9GN1466TD', 57, 6, '2024-11-26 13:00:23.000000', 1, -49),
+       ('01JDFKQDF8J79C07FTFK4W3F3B', 84, 24, '2024-11-26 11:52:52.000000', -29, 31),
+       ('01JDFM1TFG01NRWT038PTCEBN7', 165, 61, '2024-11-25 19:47:50.000000', 3, -21),
+       ('01JDFM2B2R13V0ZRE4P7YNVVRT', 244, 83, '2024-11-25 21:07:24.000000', 21, 27),
+       ('01JDFMB5A0YEPP983PQ2KV50BC', 88, 28, '2024-11-26 08:47:50.000000', -35, -21),
+       ('01JDFMFSR049R5F8CWC2AGRJND', 221, 45, '2024-11-26 01:51:34.000000', -14, 42),
+       ('01JDFMKWKGZ0AHDHVS375ESFA2', 160, 30, '2024-11-26 17:23:24.000000', 44, -7),
+       ('01JDFMZPH8R1DSVRN38KCHF1GC', 31, 27, '2024-11-25 19:10:47.000000', 34, -45),
+       ('01JDFNZ2E8ST9R7G997ZKC3D4A', 133, 48, '2024-11-26 06:12:54.000000', 16, 43),
+       ('01JDFNZA88YFYGS6063R5CARR3', 110, 26, '2024-11-26 06:57:29.000000', -48, -53),
+       ('01JDFP59N8EQY5SPWJ9DFR0NQ3', 78, 30, '2024-11-26 10:39:32.000000', 14, 16),
+       ('01JDFQ20KGASPZYFBH1KHA63ZB', 174, 9, '2024-11-26 10:59:58.000000', -45, -36),
+       ('01JDFQCSARJSAVAZRPRBCN02DQ', 247, 68, '2024-11-25 21:19:04.000000', -57, -24),
+       ('01JDFQDHR0ER76KTDBYKCM19BT', 94, 33, '2024-11-26 03:47:21.000000', 37, -64),
+       ('01JDFQGPAR50QP3FK0NRCGBE14', 275, 94, '2024-11-26 12:29:15.000000', -46, 47),
+       ('01JDFQXQAGGET383KRVXGKXQR9', 75, 33, '2024-11-25 23:31:24.000000', 49, -4),
+       ('01JDFR7DW0WFKN0T3CK2EKRADD', 154, 19, '2024-11-26 16:43:24.000000', 11, -6),
+       ('01JDFR8ST8WGK88TK7EZQ9SHNK', 228, 83, '2024-11-26 15:59:29.000000', -5, -10),
+       ('01JDFRA0W8QESHHBCHEBWD4MJA', 67, 30, '2024-11-25 19:12:56.000000', -7, 44),
+       ('01JDFRGTN0BY5PRY5JKCBHNAH3', 58, 15, '2024-11-26 08:50:55.000000', -34, -7),
+       ('01JDFRJ0QRSM1GK8JBRAQD3HX1', 144, 31, '2024-11-26 00:43:43.000000', 52, 2),
+       ('01JDFRRTGGCCKGFA5JNMN8THJ8', 154, 31, '2024-11-26 08:52:25.000000', -49, -51),
+       ('01JDFSPWDR7YXMN4WV01ZWXCB5', 157, 47, '2024-11-25 18:44:29.000000', -28, -30),
+       ('01JDFSPXD0JED9H63KKZN5YKTG', 207, 25, '2024-11-26 07:50:57.000000', -28, -41),
+       ('01JDFST9SRZJCBMBVP1PSXQFYK', 187, 34, '2024-11-25 22:07:16.000000', 43, -4),
+       ('01JDFTB9R0PCKWAJAZBSY6148B', 384, 100, '2024-11-26 02:58:37.000000', -6, -16),
+       ('01JDFTCEVG6KJGK253PSTPEN0Z', 151, 45, '2024-11-26 11:10:52.000000', 43, 57),
+       ('01JDFTPSX8KFHT1YC1TZR2D0HJ', 113, 31, '2024-11-26 10:47:13.000000', -62, 28),
+       ('01JDFTY58GMZGA4SB3HPJMJY8A', 331, 92, '2024-11-25 23:25:04.000000', -35, -14),
+       ('01JDFV0HE05B8T7CHWH9K7F52M', 179, 53, '2024-11-26 05:23:13.000000', 44, 51),
+       ('01JDFV2HWGCZ3GPKFVAZA1S7DF', 86, 60, '2024-11-25 18:52:45.000000', 3, -20),
+       ('01JDFVE038ZHJF1H3KD4CV4HBF', 292, 87, '2024-11-26 16:03:42.000000', -25, 11),
+       ('01JDFVGD80DYSRK1R4ZKHD4N6M', 112, 49, '2024-11-25 20:51:30.000000', 31, -26),
+       ('01JDFVKA0RHR0WEASQ0RX2QRNQ', 27, 22, '2024-11-25 23:26:53.000000', -12, 45),
+       ('01JDFW0BZRXHYR375VMF60NENY', 515, 129, '2024-11-25 21:48:41.000000', 25, -22),
+       ('01JDFW3KG85MD0ZDH9Y3XP1HQP', 55, 21, '2024-11-26 09:55:32.000000', -6, -7),
+       ('01JDFWJ2CR3DZP9NC78T16E4W2', 82, 14, '2024-11-25 23:44:31.000000', -32, 35),
+       ('01JDFWJ888V4DGSDYFBF8RQ402', 299, 54, '2024-11-26 07:24:11.000000', -28, 53),
+       ('01JDFWJG28EKE4RA775Z48F8AG', 136, 25, '2024-11-26 09:22:59.000000', 36, 0),
+       ('01JDFWMEJ828Q9JBCW7PKWR5R6', 332, 97, '2024-11-26 03:00:40.000000', -52, 30),
+       ('01JDFWX0ZG4JRGT50VP2JEYTZ5', 376, 63, '2024-11-26 12:52:30.000000', -3, 44),
+       ('01JDFX3G0GFR14N1AJF7KR9Y90', 289, 71, '2024-11-26 03:22:07.000000', -10, -2),
+       ('01JDFXANG8TB1XVEY557EET53Q', 64, 36, '2024-11-25 22:33:30.000000', -2, 4),
+       ('01JDFXG1C86S2D4BF49RR0PKYG', 114, 37, '2024-11-26 06:12:38.000000', 15, 73),
+       ('01JDFXJHERK5P0FCGFKQRZZ1RK', 111, 21, '2024-11-25 20:06:50.000000', -10, -5),
+       ('01JDFXJW6GR04B28RAY0QVXH9P', 152, 36, '2024-11-25 17:56:41.000000', -6, 5),
+       ('01JDFXWQM8FJSPHTAHAWCPJQGW', 75, 25, '2024-11-25 21:00:49.000000', 29, 2),
+       ('01JDFY2E80H35AENATB46XSCB2', 121, 31, '2024-11-26 02:45:15.000000', 23, 43),
+       ('01JDFYDNM0HWKK140PQDHA89B7', 191, 50, '2024-11-26 06:05:16.000000', -56, 35),
+       ('01JDFYK7BG0W46KY333WWRSS7W', 163, 63, '2024-11-25 20:31:50.000000', 8, 48),
+       ('01JDFYVNVRG13A044YFVMSRZCD', 239, 60, '2024-11-26 13:05:08.000000', -6, 1),
+       ('01JDFYW4GGZ2TXC99SRE1WME5K', 61, 23, '2024-11-26 01:56:54.000000', 3, -35),
+       ('01JDFYXV6G3S3KKQ8M43Y2F5XY', 102, 23, '2024-11-26 05:21:11.000000', 29, -23),
+       ('01JDFZDSY8VJBY7PATGWDYD190', 230, 99, '2024-11-26 13:27:29.000000', -43, -16),
+       ('01JDFZZY0R4QT04MQNNGD46X2T', 58, 15, '2024-11-26 12:11:59.000000', -24, 17),
+       ('01JDG049NGXDTM2XQGJ89EG16N', 122, 34, '2024-11-25 22:02:01.000000', 30, -24),
+       ('01JDG08BHRN4ABRDAM8N438G69', 200, 58, '2024-11-26 06:13:11.000000', -58, -32),
+       ('01JDG0J8Y0GZ9MJD83KEF9EWXJ', 57, 7, '2024-11-26 14:50:18.000000', 38, 8),
+       ('01JDG0TAR0V14GGFRQPF1QME5P', 212, 65, '2024-11-26 02:14:09.000000', -6, 22),
+       ('01JDG19H2G5D72AFQ00EJ9K81Q', 205, 48, '2024-11-25 19:49:34.000000', -47, -68),
+       ('01JDG1NYH82KFBCABGE0G3CC65', 127, 27, '2024-11-26 02:53:48.000000', -39, 34),
+       ('01JDG20W4RY3J34HSJYFN1R55T', 209, 62, '2024-11-26 13:57:24.000000', -7, 11),
+       ('01JDG24W2G3J82DV8TH84R3YQA', 112, 36, '2024-11-25 19:54:10.000000', 17, 45),
+       ('01JDG2B2A84G75QMF0S2QVS0DT', 123, 26, '2024-11-25 19:34:47.000000', 9, 29),
+       ('01JDG2C3GR60VCRN3F87HK0KRM', 50, 20, '2024-11-26 03:46:51.000000', 7, -28),
+       ('01JDG2E9TRPKS461EYXRJCCS0C', 332, 133, '2024-11-26 05:16:54.000000', -7, -30),
+       ('01JDG2N9F0ES4AP1W6FK2J36BK', 84, 21, '2024-11-26 01:13:07.000000', 33, 28),
+       ('01JDG2QQK0FGQV89NZ2GD4PW7G', 51, 11, '2024-11-25 21:24:09.000000', 37, -10),
+       ('01JDG31TTRS2P7CX55GRERVN7W', 224, 77, '2024-11-26 01:46:38.000000', -6, 1),
+       ('01JDG37JDRJWN4YS5K5GSYXP69', 501, 115, '2024-11-26 13:18:36.000000', -22, 40),
+       ('01JDG3C3Y00CP2TR5SVKYHB3FV', 141, 61, '2024-11-26 09:42:48.000000', -22, -26),
+       ('01JDG3T648JRY7X3FECWKEP5ZR', 139, 29, '2024-11-25 20:41:51.000000', 36, 42),
+       ('01JDG44WX0VRJGK7H6VWB1D38B', 39, 18, '2024-11-26 06:53:18.000000', 34, 7),
+       ('01JDG4DQ48GECTFQSTF590ANM6', 197, 50, '2024-11-25 21:16:56.000000', 80, -2),
+       ('01JDG4F238M68126NRX6MH1Y96', 315, 80, '2024-11-25 19:11:57.000000', 20, 27),
+       ('01JDG55MR8XV5W5SGTYXDDGQ67', 134, 39, '2024-11-26 01:34:31.000000', -22, -49),
+       ('01JDG5FVX0N8SNZCFMV5YB5HJZ', 195, 46, '2024-11-26 07:47:57.000000', -17, -14),
+       ('01JDG5S1V874Q0X8291AP91VP6', 217, 71, '2024-11-25 21:07:59.000000', 15, -7),
+       ('01JDG5TGQ8DPY3CRAPZWMQC5TR', 127, 32, '2024-11-26 13:54:42.000000', 21, -12),
+       ('01JDG656GRDAEHMEXYA0AZA1WZ', 78, 38, '2024-11-26 15:27:04.000000', -18, -22),
+       ('01JDG6G62RGB2VJQR1EEP9H216', 46, 19, '2024-11-26 05:03:17.000000', 7, -24),
+       ('01JDG6JK7GNXR9W9HHVPWQYM9E', 57, 20, '2024-11-26 08:29:55.000000', 35, 25),
+       ('01JDG6WPF81EGY9RMHTXAQQVGG', 124, 34, '2024-11-26 05:17:21.000000', 67, -13),
+       ('01JDG72JYGGAGF3HP5RVRA80DY', 151, 36, '2024-11-25 20:50:49.000000', -5, -51),
+       ('01JDG737ERT0807TESZFTGPA91', 96, 30, '2024-11-26 08:19:22.000000', -49, -16),
+       ('01JDG7P6W8HZQAVF9ZVES6EMMW', 209, 72, '2024-11-25 20:26:34.000000', 14, 15),
+       ('01JDG7Y6QRS9TKVTAVVC62VFFZ', 166, 60, '2024-11-26 01:33:19.000000', -1, -38),
+       ('01JDG82GE0QR7MQR61SEXA8W7J', 400, 77, '2024-11-26 08:51:23.000000', 2, -9),
+       ('01JDG85TW8NG9K7CC6RRNRA8Y3', 382, 140, '2024-11-25 20:10:05.000000', -26, 15),
+       ('01JDG8DMW8HJGN83TA415V94Y7', 100, 50, '2024-11-26 15:48:58.000000', -60, -42),
+       ('01JDG93C6813KM04RCVXVY4GBW', 211, 20, '2024-11-26 01:42:48.000000', -24, -35),
+       ('01JDG9F270C8DAYC5FV4Q06M8F', 111, 5, '2024-11-26 00:26:43.000000', -47, 14),
+       ('01JDG9GRX0PETMTF8BWR6JB80J', 267, 65, '2024-11-26 11:25:16.000000', 9, 62),
+       ('01JDG9H9G8FS6TY2Y188BT3ACG', 166, 48, '2024-11-26 06:29:42.000000', 3, -49),
+       ('01JDG9Q508ZM5HBBYH81JDCN7A', 77, 8, '2024-11-26 09:02:01.000000', -21, 11),
+       ('01JDG9WKT03V6050ABXPANF8HQ', 33, 7, '2024-11-26 02:27:48.000000', -13, -38),
+       ('01JDGA1A6GVF5VGGRJ2PS471W5', 280, 51, '2024-11-25 20:14:19.000000', -24, 18),
+       ('01JDGA8FP8FAGPKV01EE2WY58P', 80, 9, '2024-11-26 05:55:08.000000', 31, 20),
+       ('01JDGABHB8HYACJ9ZM66Q97BYP', 118, 16, '2024-11-26 10:44:31.000000', 36, 1),
+       ('01JDGAEB68HV6N0F5VNQXDGT4X', 61, 20, '2024-11-25 18:45:31.000000', -44, -57),
+       ('01JDGAMZ3GPZCY3628P7WJBEJZ', 342, 123, '2024-11-26 08:27:03.000000', -34, 64),
+       ('01JDGANPHGJ59H6FSW2E9A17BP', 475, 63, '2024-11-26 11:53:24.000000', -25, -23),
+       ('01JDGB69SGGMFR9GQQEEN4RXKS', 67, 30, '2024-11-25 20:04:07.000000', -11, -6),
+       ('01JDGB8NZ01B8NFXEH17H1F5AG', 142, 31, '2024-11-26 02:32:34.000000', 36, -16),
+       ('01JDGB99G0SB4C8FB8BSCTN1WN', 137, 31, '2024-11-25 22:13:23.000000', 28, -45),
+       ('01JDGBCZN814W0NXW13CYR18XZ', 296, 63, '2024-11-26 08:13:54.000000', -16, 19),
+       ('01JDGBT0N0GMM9PPWCSY4QDB64', 439, 90, '2024-11-26 00:44:54.000000', -5, -39),
+       ('01JDGBXQSGCKDDRRE184N7K7G5', 168, 46, '2024-11-26 13:09:35.000000', -7, -55),
+       ('01JDGBZR80MM7254R0NT7R1QGC', 32, 31, '2024-11-26 08:50:47.000000', 57, -5),
+       ('01JDGC72M02NS31M9HXYTN3AN6', 158, 69, '2024-11-26 10:19:16.000000', 23, 21),
+       ('01JDGCKNY8QT86YQ0H2YN8FZBQ', 62, 41, '2024-11-25 17:47:51.000000', -67, 56),
+       ('01JDGCVEZ09V23F2ZH49PSM2VV', 193, 70, '2024-11-26 06:55:50.000000', 2, 33),
+       ('01JDGD2MER79H8770CP38D1D2J', 206, 32, '2024-11-26 03:32:53.000000', -33, 11),
+       ('01JDGDAS6GM14P09XWYWAF7SWV', 182, 61, '2024-11-26 06:41:11.000000', 17, -35),
+       ('01JDGDRQFRGX8GM3CR68ZZM0A8', 74, 8, '2024-11-26 08:50:59.000000', 39, 1),
+       ('01JDGDT4D8YYDPX14E79Q0EPNE', 12, 5, '2024-11-26 01:00:07.000000', 31, 10),
+       ('01JDGDVK98D5TSF9NTC9WJ8CHF', 73, 24, '2024-11-26 06:33:14.000000', -5, 34),
+       ('01JDGDZ8F8PZJX922SS5QNVD7M', 114, 17, '2024-11-26 03:52:08.000000', 50, 39),
+       ('01JDGEKKV0J0S5KS9C852QYCK3', 486, 110, '2024-11-25 18:37:46.000000', -37, 26),
+       ('01JDGEM5DGEMP499GQB9K31NZV', 87, 5, '2024-11-25 23:20:33.000000', -14, -32),
+       ('01JDGEZ7X8P1PZ8JYG3ZKKJ0C6', 107, 30, '2024-11-26 03:44:26.000000', -29, 51),
+       ('01JDGF0660JWM5CDD9CTZKKN86', 340, 116, '2024-11-26 05:56:26.000000', -4, 25),
+       ('01JDGF2V4R6DNCPQJH4262QK4M', 143, 53, '2024-11-26 10:33:25.000000', 25, 16),
+       ('01JDGF8PMRW0WBDYQFFD87ESNJ', 104, 20, '2024-11-25 21:33:45.000000', -45, 20),
+       ('01JDGF968RCVRCSSXE7ZMA9MJK', 257, 49, '2024-11-26 11:50:42.000000', 54, 51),
+       ('01JDGFHGW0Z32WC9Z8ZFZ43SV4', 200, 26, '2024-11-25 23:10:02.000000', -41, -44),
+       ('01JDGFHHV8QQ9RBTV5Q16CWXTA', 236, 42, '2024-11-25 19:07:21.000000', -12, 55),
+       ('01JDGFKZ009K2JHAN9JFMV5VQZ', 122, 34, '2024-11-25 22:11:02.000000', -25, -21),
+       ('01JDGFN52RS8FZZ0RG9G4FNGG0', 187, 58, '2024-11-25 22:26:31.000000', 19, 14),
+       ('01JDGFSZC8JGWPCR48CSKCQFBY', 64, 25, '2024-11-26 12:12:59.000000', -54, -34),
+       ('01JDGFTB38REM072NY9Y3VN169', 170, 40, '2024-11-26 10:50:45.000000', 26, 30),
+       ('01JDGFWW5059K285X303GX1F4S', 77, 15, '2024-11-26 14:24:21.000000', 25, -7),
+       ('01JDGG1Y8GEX90HRBG65QCY58B', 174, 72, '2024-11-26 04:10:01.000000', 58, 2),
+       ('01JDGG7860A965P9Y06WE9X4CG', 213, 85, '2024-11-25 21:46:26.000000', 19, -15),
+       ('01JDGGCB8R3YD65WB8QEMXG8HS', 414, 110, '2024-11-26 09:26:14.000000', 15, -21),
+       ('01JDGGVKHR20EV6S19FPQJ5NGE', 248, 69, '2024-11-26 07:49:12.000000', 61, 16),
+       ('01JDGH6YTR08Z35XSHFEXB34YV', 122, 73, '2024-11-26 13:06:02.000000', 73, -28),
+       ('01JDGHCBP02TWWRE35F4FGPMKR', 62, 27, '2024-11-26 02:52:14.000000', 32, 6),
+       ('01JDGHF1M06NP00CNW23E212QE', 68, 46, '2024-11-26 09:59:18.000000', 20, -4),
+       ('01JDGHH5ZG1JQAPEY6EK9K57YM', 242, 44, '2024-11-26 03:46:17.000000', 42, -34),
+       ('01JDGHSY883D8ZFH44D52ZFCW1', 47, 20, '2024-11-26 04:51:05.000000', -29, -29),
+       ('01JDGJ2GNG0BHVN515PK30QKJP', 56, 13, '2024-11-26 07:56:35.000000', -19, -15),
+       ('01JDGJ392RQEVY1FPTYHZK3CCZ', 165, 49, '2024-11-26 01:43:19.000000', -30, -30),
+       ('01JDGJ4J38Y3WPDDP6MRYXFT83', 293, 40, '2024-11-26 17:27:19.000000', -28, 62),
+       ('01JDGJ9X00642A55SMY0Q7V7RR', 92, 20, '2024-11-26 06:14:08.000000', 11, 27),
+       ('01JDGJBGR869BEHSKVV3XWGD1Y', 232, 93, '2024-11-26 04:42:23.000000', -9, -24),
+       ('01JDGJJ4NGM0RZVQAQ62M35XN8', 208, 35, '2024-11-25 22:39:48.000000', 43, -15),
+       ('01JDGJJCFGYZFXE4XG8M7WJW77', 340, 56, '2024-11-26 09:04:23.000000', -10, -26),
+       ('01JDGJSC3RQJ0XAWGWEYRSGV9H', 103, 23, '2024-11-26 12:46:32.000000', -17, -21),
+       ('01JDGJVMC86FW9DBNSWAF2N09Q', 21, 11, '2024-11-26 03:42:48.000000', 37, 9),
+       ('01JDGKAE0GFR1NHYJARFMWP4G0', 97, 30, '2024-11-26 08:04:45.000000', -11, -43),
+       ('01JDGKQ94RKXC82YFV7RJYFK19', 45, 16, '2024-11-26 15:01:18.000000', -2, -9),
+       ('01JDGKWXT04PGTVCS5NTJ4SWB1', 141, 41, '2024-11-26 08:23:25.000000', -41, -61),
+       ('01JDGM21W0Z2VN9008K3R0FNA1', 55, 34, '2024-11-26 08:57:20.000000', 38, -1),
+       ('01JDGM711R0XYM5S8PG9MT64R7', 176, 25, '2024-11-26 01:40:38.000000', -44, -7),
+       ('01JDGMDK0G06P7GMPE3ED26YEQ', 213, 29, '2024-11-26 02:46:15.000000', -25, 60),
+       ('01JDGMGRJGXXW6B4V63H5ZR35R', 251, 83, '2024-11-26 02:47:37.000000', 54, 30),
+       ('01JDGMMRG89WYRMRXTX1RSYY2N', 66, 15, '2024-11-26 02:17:07.000000', 11, 19),
+       ('01JDGMW6S8T5BVJYDPBTC3TMFQ', 84, 31, '2024-11-26 05:18:08.000000', -37, -40),
+       ('01JDGMWRBR8WE4CNK2C6KA47N1', 270, 81, '2024-11-26 03:24:00.000000', 52, -3),
+       ('01JDGN4EERB70JSR5ADHSP5HBS', 466, 103, '2024-11-26 12:49:30.000000', 21, 20),
+       ('01JDGN7NZ8FKXZENWH908MPKQR', 304, 92, '2024-11-25 21:57:59.000000', -46, 62),
+       ('01JDGNEDSG7DPCY7AE2A38ZB13', 77, 12, '2024-11-26 10:51:24.000000', 20, -2),
+       ('01JDGNKDYG3MNPMTNYZPA8VWFX', 140, 59, '2024-11-26 13:27:36.000000', -35, -50),
+       ('01JDGNMXSRE394RPZDYGNK8EE0', 105, 54, '2024-11-25 18:54:52.000000', -13, -47),
+       ('01JDGNPBPGZ93J26PTMDWDNR6G', 302, 72, '2024-11-26 11:50:58.000000', -1, -40),
+       ('01JDGNYS7GV7R7KY9EQ82ZZ5YA', 123, 25, '2024-11-26 15:03:21.000000', -66, 24),
+       ('01JDGNZPH0NEMXQ92XQ6GX88K5', 77, 25, '2024-11-25 19:05:07.000000', 38, 7),
+       ('01JDGP1E68X1036QJ01AA0DED5', 253, 63, '2024-11-25 19:12:54.000000', -54, 56),
+       ('01JDGP425REBSQG9N10JZ4Q4F1', 163, 69, '2024-11-25 20:20:15.000000', 40, 14),
+       ('01JDGPT19REQAFNAHNENJ9WEEJ', 99, 32, '2024-11-26 10:36:45.000000', 1, -11),
+       ('01JDGPY17G22PX199Y6Q0CK1YF', 119, 24, '2024-11-26 06:51:18.000000', -57, 16),
+       ('01JDGQ1KFR24VKSBWA3YFKTPKR', 206, 73, '2024-11-25 20:23:21.000000', 84, -33),
+       ('01JDGQ21581T2B4FA4MRNHS3Y2', 291, 91, '2024-11-26 17:07:27.000000', 68, -70),
+       ('01JDGQ9N9R7EQFNJWDNF4J1PYX', 127, 49, '2024-11-26 09:58:04.000000', -25, 3),
+       ('01JDGQA01GH6YY2Y306JTG3W4A', 108, 18, '2024-11-25 21:12:20.000000', 9, -17),
+       ('01JDGQECNGQEKE4H9AMDTE3VQ4', 141, 21, '2024-11-25 20:13:39.000000', -57, 39),
+       ('01JDGQN9C00BRS9YBGMY4BVXB6', 264, 68, '2024-11-26 13:03:27.000000', 12, 38),
+       ('01JDGQYH8R5GR92NGK5AGBRHEQ', 190, 53, '2024-11-25 20:09:51.000000', 1, 41),
+       ('01JDGR1D28D52CRD8T7VE14T6C', 103, 22, '2024-11-25 20:09:07.000000', -12, -36),
+       ('01JDGR69A8FSRTJXH63NYKT4HW', 49, 21, '2024-11-25 22:19:28.000000', -15, 10),
+       ('01JDGR8HJRD2ZKX8S95HZD6MJW', 384, 87, '2024-11-26 08:49:28.000000', -36, -15),
+       ('01JDGRNBQRPDTF93DC5AWMK9M4', 71, 43, '2024-11-26 05:25:11.000000', 4, 54),
+       ('01JDGRPZG0PB0CXXH4Y96QY26S', 139, 40, '2024-11-26 14:51:01.000000', 59, 63),
+       ('01JDGRTTHGKE364NNTFSA76SJR', 135, 40, '2024-11-25 23:34:54.000000', 69, 2),
+       ('01JDGRTZDRQD3G2HHS7ZE96JVK', 71, 8, '2024-11-25 19:26:27.000000', 7, -13),
+       ('01JDGSKEM8QE2QDNP0MNZZP8CQ', 310, 85, '2024-11-26 13:13:39.000000', 53, 69),
+       ('01JDGSMCX0T1PQ6SN4PV7BZPG3', 109, 20, '2024-11-25 19:26:51.000000', -62, -5),
+       ('01JDGSQDJRJ7JFM3Y31VBA95Y1', 88, 45, '2024-11-26 00:15:43.000000', -37, 34),
+       ('01JDGST0K0CA9STW3TDMX0XYF4', 350, 72, '2024-11-26 18:01:16.000000', 38, 5),
+       ('01JDGSWRFGS5TKN25GZ5MNN4MW', 309, 70, '2024-11-25 22:31:35.000000', -3, 60),
+       ('01JDGSZQ6RE17SZH25336PH47X', 221, 86, '2024-11-26 01:14:32.000000', 6, -40),
+       ('01JDGT98W001467TT084B2HEWC', 107, 39, '2024-11-25 22:43:49.000000', 66, 35),
+       ('01JDGTAD08SMWVBEY72CTZN5ZF', 283, 80, '2024-11-26 11:41:55.000000', -31, -12),
+       ('01JDGTQQRG1EE3DYKC1ZB5BQNW', 285, 80, '2024-11-26 13:10:58.000000', -49, -25),
+       ('01JDGTSFDRS4ZPB0T1PYXQ38T5', 294, 94, '2024-11-26 12:39:31.000000', 24, 43),
+       ('01JDGTWD5R0A85VK3Q8M5VAR1G', 308, 92, '2024-11-26 07:41:47.000000', 41, -60),
+       ('01JDGV2DJ0RK6ET6SBBHZ8SWS5', 57, 24, '2024-11-26 09:36:32.000000', 19, -11),
+       ('01JDGV8SN80ETCMTB78C3W0Y8A', 91, 14, '2024-11-26 04:08:38.000000', -18, -24),
+       ('01JDGV8SN8E9MEVKMJSM47FSRX', 67, 21, '2024-11-26 07:02:41.000000', -23, 45),
+       ('01JDGV9D68CBADARMD6H9BQ8KJ', 163, 60, '2024-11-25 17:31:42.000000', -27, 37),
+       ('01JDGVNB10C3F1XDRDCEQVWN3A', 251, 70, '2024-11-26 00:29:31.000000', -21, -6),
+       ('01JDGW09KRKJN3TH6D8JFGW1R7', 90, 33, '2024-11-25 23:31:51.000000', 20, 6),
+       ('01JDGW0CHG4KP6RDB8GG8YYXVQ', 263, 108, '2024-11-26 05:45:26.000000', 27, 55),
+       ('01JDGW5KH8QDDC5M04JRSYZDP1', 55, 22, '2024-11-25 22:15:17.000000', 22, -16),
+       ('01JDGX34V822E1CW1FENGDRCBV', 253, 105, '2024-11-26 10:20:23.000000', -34, 46),
+       ('01JDGX9SQRJG3Y8KCBRGB9VKX3', 199, 70, '2024-11-26 10:39:35.000000', 35, 33),
+       ('01JDGXA7D8XNK7G3QH97SSCMWW', 252, 80, '2024-11-26 00:09:49.000000', -46, -5),
+       ('01JDGXCMJ0XT63R13M61ZVHC46', 119, 25, '2024-11-25 17:44:37.000000', -21, 19),
+       ('01JDGXEYS0S4ATK7V189P9SCMK', 121, 12, '2024-11-26 02:32:06.000000', 39, -32),
+       ('01JDGY3B40ZQCKDJA11378RR1H', 346, 63, '2024-11-26 12:45:22.000000', -41, -53),
+       ('01JDGYC3CRYEMBXBFRGXGFR181', 89, 13, '2024-11-26 07:49:05.000000', 0, -15),
+       ('01JDGYH1K8SSD5N6BZ0VVFDP3D', 83, 10, '2024-11-25 19:41:18.000000', 27, -38),
+       ('01JDGYP3PRK65020KN1NCEC2A7', 199, 42, '2024-11-26 13:04:35.000000', -53, -44),
+       ('01JDGYS5BRD28RVBHA8P5MB7GN', 281, 80, '2024-11-26 13:09:58.000000', -52, -17),
+       ('01JDGYZW6R57APE4Z00CP9TRRF', 56, 23, '2024-11-25 18:47:18.000000', 41, -35),
+       ('01JDGZ77J0AH6C3728YD9T65VA', 179, 80, '2024-11-26 07:41:05.000000', -60, -7),
+       ('01JDGZ7V30WXBKQACDB7XR1K87', 103, 13, '2024-11-26 11:00:52.000000', -36, -35),
+       ('01JDGZNZ7R6JWKE7V3VBA83NHE', 165, 46, '2024-11-26 12:09:11.000000', 64, -7),
+       ('01JDGZSW7RFWT9A80DZ3GF6G5Y', 165, 27, '2024-11-25 20:55:21.000000', 30, 9),
+       ('01JDGZYFPGVW8CHPABYKVGS0B9', 71, 12, '2024-11-26 13:30:31.000000', 41, -22),
+       ('01JDH09BBGTS6NJ58BB7C50Q7V', 308, 64, '2024-11-26 03:06:15.000000', 43, 25),
+       ('01JDH0B030JFW4HP7P257WEKNT', 417, 124, '2024-11-26 11:59:54.000000', 1, 38),
+       ('01JDH0RZBGZFRNFM79N1SSM99A', 166, 28, '2024-11-26 08:42:29.000000', -40, -33),
+       ('01JDH0SD108S2R1M39JC627QG0', 146, 50, '2024-11-26 14:52:24.000000', 26, -1),
+       ('01JDH11XFRJNEYRPJVWQKEN0DY', 202, 52, '2024-11-26 10:52:07.000000', -30, -8),
+       ('01JDH1J600S5RE3WC9RR7NRAMC', 121, 33, '2024-11-25 18:29:18.000000', -23, -31),
+       ('01JDH2BX7R2DFXCM3Z2QYPYMMM', 112, 44, '2024-11-26 01:24:10.000000', 40, 27),
+       ('01JDH2W5R0REEGXNVWXWC3YW8H', 51, 30, '2024-11-25 20:50:29.000000', 40, 13),
+       ('01JDH31AS8WV49A023FB4P8FBX', 58, 20, '2024-11-26 05:48:10.000000', -10, 65),
+       ('01JDH393T09RCSDQXN9C192SMG', 58, 21, '2024-11-26 12:08:59.000000', -25, -6),
+       ('01JDH3EXBG24A365WVF5N5PD6X', 362, 84, '2024-11-26 11:23:05.000000', 67, -14),
+       ('01JDH3RG00JRM4T0BCE3XJKNWX', 200, 56, '2024-11-25 21:29:51.000000', -57, -16),
+       ('01JDH3RXNGRDMJD097QYVJ1GWE', 237, 30, '2024-11-26 10:07:02.000000', -7, 20),
+       ('01JDH3V11R3JCA36MTYEGTS05G', 310, 65, '2024-11-26 02:27:17.000000', 51, 60),
+       ('01JDH3Y4N8SYPQFNEMN2SFBTAB', 214, 37, '2024-11-26 03:12:10.000000', -48, 18),
+       ('01JDH424K0W033MVGQN6RBZT73', 73, 21, '2024-11-26 06:35:36.000000', 56, -41),
+       ('01JDH4GCMR2GMBX7CJZ84DXNCK', 176, 62, '2024-11-25 21:04:01.000000', -57, -16),
+       ('01JDH4KR28FBNHZY6YSQZ5B5TT', 104, 11, '2024-11-26 08:13:47.000000', 21, 0),
+       ('01JDH4S2Z0ZJ4Z0967Y7DY5TVR', 410, 102, '2024-11-25 19:19:38.000000', -28, 6),
+       ('01JDH4S5WRMG7QBQHWB6TNSJFF', 111, 24, '2024-11-26 11:25:18.000000', 35, -39),
+       ('01JDH4TFWG7DGM6K56726PT54N', 289, 59, '2024-11-26 11:24:07.000000', 45, -33),
+       ('01JDH4XGJ8VDER59WZJ7ERPQ2Z', 407, 115, '2024-11-25 18:48:46.000000', 22, 46),
+       ('01JDH4YQM8P9DN1N5Z9CJ3F231', 98, 17, '2024-11-25 23:51:51.000000', 48, -55),
+       ('01JDH4ZF289XQHMHHHMNDCQ7SP', 308, 78, '2024-11-26 00:02:39.000000', -37, 61),
+       ('01JDH59BF887HY5P3W3RCN1857', 334, 60, '2024-11-25 20:11:41.000000', -45, -19),
+       ('01JDH5GQSRV5B9S9ECQ1F2C7R2', 181, 30, '2024-11-26 09:12:20.000000', 30, 6),
+       ('01JDH5K20RZAJBHGSPD9JFVN6M', 77, 33, '2024-11-26 03:54:26.000000', -44, -41),
+       ('01JDH5WDTGV0YVE8WPPQHPVSJQ', 125, 24, '2024-11-26 12:11:16.000000', -33, -37),
+       ('01JDH6951RR467D8V8ACW8XR3H', 362, 105, '2024-11-26 01:32:13.000000', 0, 50),
+       ('01JDH6951RZ34E2ND71HA607K1', 373, 90, '2024-11-25 21:55:25.000000', 10, 2),
+       ('01JDH6C1TGNKMWCFMF31DBFMAC', 239, 55, '2024-11-25 23:17:23.000000', 26, 6),
+       ('01JDH6CDHGJAJ2GWESA94B4Q9Z', 87, 22, '2024-11-25 23:25:33.000000', 6, 28),
+       ('01JDH6JDXRNRKF2KCM8TJ6PN17', 171, 31, '2024-11-26 06:42:32.000000', 11, -43),
+       ('01JDH6Q1CGKEJX69033CF5P8MB', 200, 44, '2024-11-26 02:55:51.000000', 20, -43),
+       ('01JDH6SV7GTGSYYXV294PYDS7Z', 284, 126, '2024-11-26 00:51:39.000000', 13, 61),
+       ('01JDH6Z73GQ5V3QGDY8X9HG488', 222, 48, '2024-11-25 20:03:29.000000', 2, -7),
+       ('01JDH6ZRP0Y32HFFPMAZDXJGJX', 128, 18, '2024-11-26 03:14:48.000000', -3, -36),
+       ('01JDH71HAGM03ZCG9AYQNJTMT9', 92, 26, '2024-11-25 21:31:38.000000', 32, 62),
+       ('01JDH7ABHRJVN26B2JMB647AD8', 222, 53, '2024-11-26 10:23:28.000000', 4, -42),
+       ('01JDH7GNPGWHN8KCW3NYZNJ9C9', 115, 71, '2024-11-26 14:38:50.000000', 83, 31),
+       ('01JDH7NVQ0QP1M0WYSYGT11521', 368, 107, '2024-11-26 01:50:20.000000', -2, -5),
+       ('01JDH7ZCD0YTK764S97JCB36A6', 70, 33, '2024-11-26 09:41:28.000000', -44, -20),
+       ('01JDH808Q8853YH7G79E2PWJ47', 96, 30, '2024-11-25 20:50:30.000000', 14, 26),
+       ('01JDH83XX8GRMNZJ34S9RVBC0E', 158, 46, '2024-11-26 00:01:34.000000', 28, 2),
+       ('01JDH85WD8BVBS76F62CDHXDR5', 63, 24, '2024-11-26 04:45:08.000000', -17, -1),
+       ('01JDH8ECW01ZG29SYNR2SHDJXB', 187, 67, '2024-11-26 13:33:45.000000', 28, -62),
+       ('01JDH8K0ARG2Z5AB94DMBAK2YH', 134, 57, '2024-11-26 01:46:52.000000', 45, -8),
+       ('01JDH8TNEG4BM5HNA1DXTXP02X', 66, 13, '2024-11-25 23:34:53.000000', -3, -26),
+       ('01JDH939T8DH20B6CFNEW1575T', 224, 49, '2024-11-25 21:10:48.000000', -23, -57),
+       ('01JDH94KT0Y3B8HET6TBHP683Q', 85, 19, '2024-11-25 20:42:58.000000', 61, 18),
+       ('01JDH9C6Z8ZZKQRME06R8GCSAF', 266, 83, '2024-11-26 07:52:16.000000', -30, -2),
+       ('01JDH9P3C81GEZG5Q376602JS7', 65, 30, '2024-11-26 09:59:48.000000', -5, 12),
+       ('01JDHB13BG62ZW5ZFCJ2YC9YH0', 163, 56, '2024-11-26 15:53:58.000000', 16, -37),
+       ('01JDHB1C4R9HQ7Y4YBE0E4VKRK', 50, 37, '2024-11-26 07:14:54.000000', -4, 51),
+       ('01JDHB5B38VW1XKEJHXQEWEJ4A', 212, 54, '2024-11-25 19:03:54.000000', 24, 0),
+       ('01JDHB8ZA0JCX4N6HPTARFGAX6', 68, 12, '2024-11-26 05:02:10.000000', -5, 27),
+       ('01JDHBC3WR8WY7YR990CPZ2N16', 147, 26, '2024-11-25 18:15:07.000000', 47, -10),
+       ('01JDHBD538T6DHPXJ3NRYPCTKD', 127, 17, '2024-11-26 13:40:21.000000', -38, -42),
+       ('01JDHBH9X8ZJQ6E68JRK4AKX05', 140, 57, '2024-11-26 15:54:24.000000', 61, -28),
+       ('01JDHCFHP0VREMF3D5EZ8HXWR8', 600, 140, '2024-11-26 00:25:06.000000', 27, 15),
+       ('01JDHCQNEGGCJGNCRQ6C8JBMXY', 79, 21, '2024-11-25 19:07:21.000000', -14, -31),
+       ('01JDHD164GPKBEX1DEGX6S6EPR', 646, 120, '2024-11-26 14:49:14.000000', -39, 23),
+       ('01JDHD6WR81BZ7XZMAMK1KP992', 87, 18, '2024-11-26 12:12:14.000000', -2, 32),
+       ('01JDHD88PGZ50M7CWF2TTD1N27', 129, 38, '2024-11-25 18:21:35.000000', 57, -11),
+       ('01JDHDBQ1RVBHJJJCAC6QAJ82W', 99, 24, '2024-11-26 13:40:42.000000', 14, -13),
+       ('01JDHDCCH8J2WRJ75WNB38X8NV', 69, 12, '2024-11-25 18:01:35.000000', 41, 0),
+       ('01JDHDJFV8H77NW06SQZSP9B41', 409, 93, '2024-11-26 13:49:48.000000', 13, 50),
+       ('01JDHDRQ28D2C7NNNSX47N4G4Z', 131, 54, '2024-11-26 15:20:19.000000', -9, 14),
+       ('01JDHDSS80G3A469PKA8HXA9M3', 98, 52, '2024-11-26 03:03:20.000000', -21, -23),
+       ('01JDHDV74R59WM9K3YE4M0GFNJ', 197, 46, '2024-11-26 12:51:42.000000', 10, 3),
+       ('01JDHDZSM8TF6THD8MWRQTR8P3', 63, 43, '2024-11-26 01:35:53.000000', -14, 16),
+       ('01JDHE4D30APFK1ZSVMNT9FCZE', 138, 27, '2024-11-26 10:07:47.000000', 21, 5),
+       ('01JDHF1MMGPZZ983R6Z21V35AJ', 66, 30, '2024-11-26 10:35:58.000000', 1, 27),
+       ('01JDHF6YJ0ABRD2XJBGVB95296', 78, 36, '2024-11-26 09:16:01.000000', -40, 26),
+       ('01JDHFJEQ8GQ2ZYYR8VSM1T3ST', 42, 18, '2024-11-26 12:08:59.000000', 14, -37),
+       ('01JDHFJYB81623BJ40DPY9G6B3', 114, 25, '2024-11-26 11:57:22.000000', 36, -21),
+       ('01JDHFNKA0NT0XKP5Z77VZ9B4S', 240, 51, '2024-11-26 04:17:06.000000', 1, 32),
+       ('01JDHFXVYRM5S46A9QC2YRNYFB', 74, 22, '2024-11-25 19:16:44.000000', 5, 3),
+       ('01JDHFYY4G5MS7T17MPECC53J4', 381, 57, '2024-11-26 02:53:31.000000', -54, -42),
+       ('01JDHG0QR8TWY1YEGQ1MY4F0ZZ', 159, 31, '2024-11-26 14:24:32.000000', 54, -17),
+       ('01JDHGCPJ8SG27A2V9T98HEKA2', 290, 59, '2024-11-26 12:33:53.000000', 38, 39),
+       ('01JDHGHDY0V1MFDJQJG11ES4W9', 241, 48, '2024-11-25 22:47:23.000000', -45, 11),
+       ('01JDHGNDVRQDTASEKT4K7SPG3S', 108, 25, '2024-11-26 02:06:17.000000', 4, 37),
+       ('01JDHGPXQ0KY3F6YPJF62HYQ3X', 170, 54, '2024-11-25 21:03:19.000000', 54, -54),
+       ('01JDHGTYM0YQMZ043DFBNR7VVV', 369, 94, '2024-11-26 01:17:37.000000', 58, 10),
+       ('01JDHGZ4D8SX8B2CXHJWGTECDQ', 197, 44, '2024-11-25 19:41:45.000000', 11, 13),
+       ('01JDHH3KZ0MG3PGY12JCWQAGHH', 152, 43, '2024-11-25 17:10:22.000000', -32, -13),
+       ('01JDHH9Y3RT9NX3Q2JBFRCX8NN', 82, 41, '2024-11-26 05:56:39.000000', -12, -9),
+       ('01JDHHFHSRJ2MDPKW8T0WBNPDD', 126, 16, '2024-11-26 06:00:57.000000', -53, 38),
+       ('01JDHHHDC0P5DXBSR2TY7ATMPF', 381, 133, '2024-11-26 07:47:17.000000', 13, 15),
+       ('01JDHJ0MNRHBW8SX79CH0C9DPJ', 25, 24, '2024-11-25 23:36:43.000000', 25, 26),
+       ('01JDHJ2P3GP18KD0981ZJQMZZR', 339, 75, '2024-11-26 00:34:37.000000', 25, -30),
+       ('01JDHJ35QGPJB57B1ZM8X9D0JF', 486, 123, '2024-11-26 00:07:29.000000', -81, 23),
+       ('01JDHJ3HEGTZTH8W595ZDN65YM', 202, 67, '2024-11-25 19:53:29.000000', 22, -14),
+       ('01JDHJ82YRJWXT1M6S5ZJBSG8Y', 42, 9, '2024-11-26 02:00:06.000000', 14, 30),
+       ('01JDHJANZ095C7KB99P023TSZA', 98, 49, '2024-11-26 08:32:50.000000', 8, 33),
+       ('01JDHJCV9RCNHTN98YVWR7T9Z1', 68, 23, '2024-11-25 19:21:26.000000', -19, -19),
+       ('01JDHJNBRGSE02H0KK5VM9667Y', 135, 67, '2024-11-26 12:52:55.000000', 13, -3),
+       ('01JDHK2YARQZ9DQWEC05K8Z0FW', 132, 40, '2024-11-26 11:33:24.000000', 10, -61),
+       ('01JDHK3370KZFCNEH5JNWQ3RF5', 164, 44, '2024-11-26 02:30:26.000000', 39, 22),
+       ('01JDHKES7RVEJM2FM14E0N61M4', 195, 34, '2024-11-26 01:51:37.000000', 20, 21),
+       ('01JDHKHG50W9V45FQFM94EW1F9', 632, 115, '2024-11-26 12:02:38.000000', -67, 25),
+       ('01JDHKVNB8S58TP1MB1PRVFWV3', 157, 29, '2024-11-26 05:41:57.000000', 55, -25),
+       ('01JDHM4TA81FSN7HB9XVMQSMX0', 407, 121, '2024-11-26 01:14:51.000000', 34, 34),
+       ('01JDHMWWTG90NYQWJXY3DTKVXA', 213, 62, '2024-11-26 12:37:14.000000', 17, 5),
+       ('01JDHN25RRF7N26STHREJWA546', 262, 75, '2024-11-26 15:05:42.000000', -55, 24),
+       ('01JDHNCWHG8TKV9VJC97VBK4PA', 88, 16, '2024-11-25 18:48:49.000000', -42, -14),
+       ('01JDHNCZF8NERVS4JQ6VG3BF3E', 188, 41, '2024-11-26 03:37:05.000000', 42, 35),
+       ('01JDHPZVD0BSC7XCQF3DG5K816', 155, 19, '2024-11-25 23:37:10.000000', 48, -36),
+       ('01JDHQNGRG7EGJ54DBQ69MGQMM', 216, 63, '2024-11-26 06:11:20.000000', 35, -17),
+       ('01JDHQV8BGBD3VVQ1F6Q5GHG55', 234, 52, '2024-11-26 15:46:46.000000', -8, -47),
+       ('01JDHQVE70SFDYBVXE2793EYH2', 134, 39, '2024-11-26 02:16:19.000000', -6, -26),
+       ('01JDHQZYR0ERVMJD4GHWKWQCZJ', 112, 25, '2024-11-26 00:51:27.000000', -46, -8),
+       ('01JDHR42JR1JMBRERWAZZTA293', 104, 46, '2024-11-26 02:37:09.000000', 46, 10),
+       ('01JDHRZW7GPADFNP0VED7Y36KD', 301, 97, '2024-11-26 12:21:48.000000', 36, -23),
+       ('01JDHS6K2G1WFD0YRYMYNAJT1M', 63, 37, '2024-11-26 10:51:31.000000', -55, -54),
+       ('01JDHSDBW0GTGW0Y9Q2HMYMXTQ', 268, 49, '2024-11-25 21:25:23.000000', -45, -21),
+       ('01JDHSK3F0G6XXHKRC0HG191TJ', 102, 24, '2024-11-25 20:24:20.000000', 46, 50),
+       ('01JDHT1GD0TDG7JV2NS3TSQTSB', 179, 43, '2024-11-25 21:08:31.000000', 51, -30),
+       ('01JDHT2QF0QR1XPHJVM786KX28', 73, 5, '2024-11-26 02:17:34.000000', -15, 10),
+       ('01JDHT2QF0VND31ZCFWE9RDZFQ', 242, 49, '2024-11-26 08:56:57.000000', 12, -18),
+       ('01JDHT3ZG8H57WR28B5ET0085H', 88, 15, '2024-11-25 23:55:07.000000', -60, -26),
+       ('01JDHTES6RQH1HBQ78GBE6MWN8', 361, 51, '2024-11-25 23:51:45.000000', 19, -52),
+       ('01JDHTJP6RAJM5T4D1WT1T050R', 245, 51, '2024-11-26 05:12:25.000000', -8, -34),
+       ('01JDHTPJ7GB6WA5F06NYBPDV46', 72, 27, '2024-11-26 01:20:28.000000', 8, 0),
+       ('01JDHTV0T0WYQMZ23FFHDRXA2A', 284, 70, '2024-11-26 11:15:27.000000', -10, -3),
+       ('01JDHTV8M0WJGVE9AA2KT3FAZG', 153, 46, '2024-11-26 11:18:24.000000', -34, 33),
+       ('01JDHTY8AG3JF1JK1YEEXR6F4T', 51, 34, '2024-11-25 17:47:08.000000', -35, -50),
+       ('01JDHV2ZP8386B50HZTR48HREX', 101, 22, '2024-11-26 01:28:49.000000', -9, 5),
+       ('01JDHV7H6GJAVPY0K7XA82TW1S', 161, 47, '2024-11-26 02:19:30.000000', -43, 20),
+       ('01JDHV9VDGKTCHE5MA3815XW03', 74, 27, '2024-11-25 19:20:44.000000', -24, -53),
+       ('01JDHVDMGG7ZKXGAVCK62ZK4H6', 128, 35, '2024-11-26 08:29:10.000000', -32, -14),
+       ('01JDHVQYK0BM3GPDABKJBJWNP0', 138, 23, '2024-11-26 06:02:10.000000', 9, 15),
+       ('01JDHVRC8GBQDJ7NCA4T0RW21Q', 236, 74, '2024-11-26 01:24:56.000000', -35, 55),
+       ('01JDHW1R28N9KJ7WVB689FKSND', 258, 95, '2024-11-26 00:50:37.000000', 33, -16),
+       ('01JDHW4D10THAYK3AP6GZZS50M', 215, 41, '2024-11-25 19:53:50.000000', -12, 18),
+       ('01JDHW7KJ8G6KE13YXAN7KSWAN', 240, 38, '2024-11-26 07:32:13.000000', -25, -51),
+       ('01JDHWY76GT6K8X9J9BBDQCY5A', 177, 47, '2024-11-25 19:08:51.000000', -6, -28),
+       ('01JDHWZR10JQNH8WADGXE2B5BG', 267, 53, '2024-11-26 07:03:11.000000', 20, 20),
+       ('01JDHX1XBRC0KHEA4HSWYVZT7F', 153, 29, '2024-11-26 02:18:07.000000', -23, -39),
+       ('01JDHX788GEJJ5TQFQX14M05SB', 110, 14, '2024-11-26 02:18:12.000000', -41, -2);
+
+INSERT INTO initial_ride_distances (ride_id, pickup_distance, loaded_distance)
+VALUES ('01JDJ37JJ0QPX4D87D911C62H7', 40, 34),
+       ('01JDJ39YQG5BYSPFGY8Y5SNTKM', 94, 25),
+       ('01JDJ39YQGEWSXD2XNYTBJTWTV', 99, 27),
+       ('01JDJ3TG105GTSDHN29PNRY84C', 10, 21),
+       ('01JDJ434CRV70HMDV8BFNG9XCR', 69, 9),
+       ('01JDJ4EQFR4Q6X096NB05QG6SA', 93, 39),
+       ('01JDJ4SD98HQ5QAG6H60CRKE5G', 85, 14),
+       ('01JDJ4V7W8VRS3R40YWTFJ8M1H', 78, 15),
+       ('01JDJ50ZF8TF13HZFWJWEX6AGA', 116, 17),
+       ('01JDJ534T0GPZEGHQNKC1NSEXM', 21, 41),
+       ('01JDJ534T0Z78E7JWHZQ823X7Z', 14, 21),
+       ('01JDJ5PSQ0CPT6SR129P9J73FM', 57, 12),
+       ('01JDJ5SY9RS1BBNFCX7KVWMK8P', 88, 33),
+       ('01JDJ60H7R30NWRVP3DFYDN1TE', 112, 22),
+       ('01JDJ63NTG3KPH9Z0AZATYPBM6', 115, 38),
+       ('01JDJ63SQGPFRT7TFC7AA0YHMX', 116, 36),
+       ('01JDJ6DS2862D04WYCX819MMRY', 79, 17),
+       ('01JDJ6DS28TNZ1QEQAQT0KJ4E4', 17, 34),
+       ('01JDJ6VND0SEM7MESF382RYJ33', 39, 36),
+       ('01JDJ77CD0GD58KY0RDNGT5KSS', 121, 26),
+       ('01JDJ7N6S89K8RXHWM4VENT56T', 36, 29),
+       ('01JDJ7RQ30SZMZXPRY7JEFBWRA', 13, 23),
+       ('01JDJ7WC90JCAB6TRTPKQ30Q46', 65, 14),
+       ('01JDJ80C6RMTDDPKDEYNADKJPD', 122, 30),
+       ('01JDJ81K8R93S77MRBX6XZNF5S', 117, 30),
+       ('01JDJ81K8R9WFRTJR52KG5B8CN', 54, 29),
+       ('01JDJ81K8RYVE3V9HKBJVX78XE', 85, 48),
+       ('01JDJ878X880GENAK0FJXBVKPX', 128, 39),
+       ('01JDJ8GRM08TF1HYD2NWCF1FQG', 39, 43),
+       ('01JDJ8H0E0Q9GMYM1P8AQ8XRS7', 34, 36),
+       ('01JDJ8W5VGJXXF4WJWKRFXZ6F0', 109, 37),
+       ('01JDJ95218CHNMFXMRTH9T2EJM', 19, 13),
+       ('01JDJ95218Q1MMZJM12X6KDRRV', 91, 26),
+       ('01JDJ95218Y729AHATGCD9D566', 19, 5),
+       ('01JDJ97SXRK6K2X37A9RJ2M98A', 112, 15),
+       ('01JDJ984NGK343AE13A11TV0KQ', 56, 18),
+       ('01JDJ9C6HR1GVEEC7GQXSHY227', 22, 20),
+       ('01JDJ9C6HRJ5ZJ538PEQNQ25FZ', 88, 22),
+       ('01JDJ9C6HRQAHKYE2V34A3C598', 92, 30),
+       ('01JDJ9EXF0QB7399N8MNXWS5E0', 45, 34),
+       ('01JDJ9FZMRT9772GEH75AXG6KQ', 102, 5),
+       ('01JDJ9KQRGFMK4JZFDFVA2ZZT1', 21, 35),
+       ('01JDJ9KQRGNQ5TSX1EHW3EGJV2', 72, 16),
+       ('01JDJ9KWMRKGBV54F533D6VEFX', 124, 18),
+       ('01JDJ9MM2R7Y6478PETN9A1FDP', 28, 5),
+       ('01JDJ9MM2RDEE0712DFD22EGWT', 28, 36),
+       ('01JDJ9MM2RRTX7WMFDCTMGF3RH', 33, 23),
+       ('01JDJ9NMA0JDJXD2AWKRJKSPF8', 60, 20),
+       ('01JDJ9PCQ87R9SZPH9VAXH2823', 90, 23),
+       ('01JDJ9PCQ8MAKK4K4CBPSQNK84', 98, 33),
+       ('01JDJ9PVC03NZJQ6T47RXQ7NKF', 41, 20),
+       ('01JDJ9PVC06MNYV48S8F16K0KE', 93, 27),
+       ('01JDJ9PVC0QE3S3JEZDHH54AF4', 100, 33),
+       ('01JDJ9TEKGS53D9WZJB8975CRR', 50, 18),
+       ('01JDJ9WF20GCVE3WQY8G5DESPE', 98, 32),
+       ('01JDJA436GYZANHEGCMVFK3EN5', 4, 40),
+       ('01JDJA4920MJHH546NE1DYCEN9', 70, 15),
+       ('01JDJA9ST8R2TVWHWEHRQQ59RF', 30, 19),
+       ('01JDJAD1AR4C6VZBX7KABV4Q0Q', 69, 12),
+       ('01JDJAJ0GGN0YGK3DBAVMQQ1DN', 82, 27),
+       ('01JDJAJ0GGRSC4R8J3ZH3CE2MT', 30, 16),
+       ('01JDJAK0QRYP1T8RXTPHX8TZ7C', 111, 30),
+       ('01JDJAN810V5KR911MKEJJEBT5', 16, 23),
+       ('01JDJAN810Y7MZAK8CX8WWFKQ8', 52, 25),
+       ('01JDJAT860X5XP5FHWJ722KM0D', 39, 21),
+       ('01JDJAVZV8GF322ARYJCC92VDE', 76, 17),
+       ('01JDJAZWV8J4CBCAPZXCM9MCGF', 73, 29),
+       ('01JDJAZWV8SZVSNRWG7JERP593', 37, 30),
+       ('01JDJB0JARJH768K114TH86FR9', 73, 10),
+       ('01JDJB6NMRK9AMY66GW0G285T7', 82, 31),
+       ('01JDJBAGP81XSEH4RXTB6PSJEB', 49, 5),
+       ('01JDJBAGP86G0NADP1TRHT14XE', 35, 26),
+       ('01JDJBAGP87ZGGMA2N19EBA3TT', 52, 22),
+       ('01JDJBAGP87ZTGGBD7ABC7ZDJV', 47, 27),
+       ('01JDJBAKM0XM9XCNT5WGAKWVBW', 72, 24),
+       ('01JDJBBZJ8D3R65EY6NYY11NSJ', 117, 22),
+       ('01JDJBCE705BD9EDENDYQ80M6K', 4, 27),
+       ('01JDJBCE70BMR0J63M7B9PF6RA', 86, 34),
+       ('01JDJBCE70KZHNA23W1JQVK6Y6', 65, 5),
+       ('01JDJBFEWRASR6ZNRDNGMEDF5Q', 45, 23),
+       ('01JDJBPMCGEGNCMEQF4JBTGSBY', 105, 37),
+       ('01JDJBPT805MFXQVAY0SQ6XV5R', 63, 8),
+       ('01JDJBYP6G3FDJ58GC89HADSSG', 61, 14),
+       ('01JDJBZJGRFYTNZ3NSSN8Z2PR2', 67, 33),
+       ('01JDJC196RNC4CNMY84P2XFC95', 60, 13),
+       ('01JDJC3PBG3BGH51E9V12RM9ZJ', 50, 19),
+       ('01JDJC4KN0J63YS4YJ0W09ZCH1', 71, 30),
+       ('01JDJC62H0S7TK0DSNCJA1HZPT', 48, 21),
+       ('01JDJC7GDREFWRN99Q3VJ48KS3', 64, 21),
+       ('01JDJC9QQ0S26NBPQDJ3T0T60T', 25, 14),
+       ('01JDJCEC5002X761ZQZR088E9T', 131, 18),
+       ('01JDJCGGGGECWPB4HCNBRRDN6N', 57, 18),
+       ('01JDJCJXN82FTJCXV8FQRJM4V8', 104, 28),
+       ('01JDJCJXN8MTA19BCC48K1EDQA', 61, 16),
+       ('01JDJCNNHRSHGWGK0P356ME4KB', 56, 13),
+       ('01JDJCPMSRKN8AHVF9S48FNPP1', 102, 17),
+       ('01JDJCRAGGS4ZG3GM9RT3N6RTY', 32, 36),
+       ('01JDJCZGZGJHQP36CBD9K7PVB2', 55, 20),
+       ('01JDJCZSRRFY3TMKNYBQ6C6YDG', 44, 21),
+       ('01JDJD1KCG8TK97X5NPEGDMS36', 115, 36),
+       ('01JDJD2FPR33YKWHAETVS3SDYH', 46, 31),
+       ('01JDJD41GGCRHBZWV958PYSS9Y', 57, 18),
+       ('01JDJD65W0DJN91SJ52QBNSXSN', 62, 28),
+       ('01JDJD9AERR77SYNTHF1XE8YDT', 67, 33),
+       ('01JDJDKKJ0VAXTP830WFRE2CQ4', 120, 21),
+       ('01JDJDNRWR76YKHF20PDJZSFQC', 25, 16),
+       ('01JDJDRYERQAT0PZH33YDD6FD3', 37, 39),
+       ('01JDJDSF20W7KKDS1N2SJQ0D51', 101, 35),
+       ('01JDJDY0J8B1QZVKWGMC99ETS1', 78, 24),
+       ('01JDJDY0J8FF5CZ4Y38S2X3B58', 53, 34),
+       ('01JDJDY0J8GQ4V15572P10BDKG', 95, 17),
+       ('01JDJDY0J8RMRA9PVTT3EM75CS', 43, 24),
+       ('01JDJDYRZG0QVDEZPTV8K2SSXF', 86, 25),
+       ('01JDJE33N0FRRP4SMG00JCEMSJ', 81, 22),
+       ('01JDJE33N0RXNKJNBFT323WT9R', 37, 30),
+       ('01JDJE8RA89W3N0A2Q4DEF7FPC', 21, 42),
+       ('01JDJE9ES0088WF3RMYX2VQQ8Z', 53, 27),
+       ('01JDJEBS00Q9KHC9R14MBF5HAV', 33, 25),
+       ('01JDJEC6NGNNB0ANZBYYS6SXH2', 31, 48),
+       ('01JDJEE370W5H0SBKKX4PY01F7', 48, 31),
+       ('01JDJEVB1G08N1RRW8PWXXPES6', 49, 25),
+       ('01JDJEWA9G2QHBT26H9BSFQV94', 9, 34),
+       ('01JDJEWA9GEFX8JTFC5MYRFZMC', 51, 17),
+       ('01JDJEX5MGPMW1KEM5DE7NCQ8H', 82, 14),
+       ('01JDJF1Q4R0XMF4EA324503MD7', 14, 29),
+       ('01JDJF1R40151A8N8A7X4GA4JK', 49, 13),
+       ('01JDJF721GKAX781TXN1VJVHMT', 43, 5),
+       ('01JDJF9SY0AMMM76KR79PQZK88', 16, 9),
+       ('01JDJFBFMRPJZFG2PBE21R084E', 42, 36),
+       ('01JDJFZ3JGE1W291SC8E33RYZE', 56, 34),
+       ('01JDJFZ6G8XVA2EGV8WQTGA7GJ', 102, 26),
+       ('01JDJFZZWR9S0CB56NTDVHAM4X', 95, 39),
+       ('01JDJG2A3RY1RRMF4E4P4KA0NS', 83, 38),
+       ('01JDJG3P20Y1H45CER1PPMCB79', 20, 27),
+       ('01JDJG6JTR7EJGGHD7VN9QH375', 97, 34),
+       ('01JDJG6JTRD2R9WB2548YTSWH5', 66, 19),
+       ('01JDJG9DN04QBKZDX32XBY8A0F', 136, 43),
+       ('01JDJGB0E03KNGQWE6WEF0JXEW', 124, 32),
+       ('01JDJGBRV8840X2ZHTJJMAGECF', 89, 24),
+       ('01JDJGCM68A05PX33T1V67V846', 153, 34),
+       ('01JDJGCM68MWZFBD7V0HVWX44D', 12, 18),
+       ('01JDJGDKE8G2TSTC5Q74WSFAV4', 41, 19),
+       ('01JDJGDV88170PCN49DF2TBTTA', 96, 33),
+       ('01JDJGDX6RBDF9N9CXZ08D5984', 67, 27),
+       ('01JDJGDX6RT3E941RN3HQ5NC3C', 89, 26),
+       ('01JDJGFF0GJ4G7ESGGDF0EFDCP', 50, 25),
+       ('01JDJGGQ1RGX89KNQ80YGM13XB', 63, 49),
+       ('01JDJGGQ1RPTKDQ8MMNR4TXZRQ', 66, 30),
+       ('01JDJGJBS8A3C76XDXCXSTQXRW', 66, 27),
+       ('01JDJGP000S10N26MZ504J76TA', 153, 36),
+       ('01JDJGP5VGPQ51ZDMFN1EJ253X', 62, 20),
+       ('01JDJGR0EGG6NV63QWREG68239', 21, 30),
+       ('01JDJGX6F01MAJSTGPZPYX9VW3', 104, 36),
+       ('01JDJGYAK84GSS7VAJ2JNN3HZK', 68, 22),
+       ('01JDJHBW68DM1T9QAPNT8HQJNP', 90, 18),
+       ('01JDJHCMKG2SHPBE70RJCH85EP', 99, 34),
+       ('01JDJHCMKG43WV7N7MCX28RPMT', 75, 18),
+       ('01JDJHEYTGQHHE5B1SZCKKVK7J', 47, 35),
+       ('01JDJHMEKGNZC7RQR8XMGRXVZ4', 20, 31),
+       ('01JDJHQ1KR34MSEATHS760Z18F', 65, 34),
+       ('01JDJHVF70WZ8EGE450976RGA1', 60, 32),
+       ('01JDJHZ7ARRGZRMAVJ9Z42YHM2', 79, 7),
+       ('01JDJHZC70ST3JFK1EGJCMND3Z', 22, 15),
+       ('01JDJJ8TYG382F1PR3P31HT77C', 40, 11),
+       ('01JDJJAGN8VVDYFBXGMWT2GTCT', 70, 11),
+       ('01JDJJGVS8BNC6CK3XVDBTV0GT', 59, 35),
+       ('01JDJJMSRGNPS9Q6SM2QZDCH98', 83, 24),
+       ('01JDJJMXNGE73MYEJZPNVT7VM0', 84, 20),
+       ('01JDJJNBB09FE74X7PWF0SHD8W', 101, 32),
+       ('01JDJJXVSRZBSJPNMKG1EWHN4D', 66, 26),
+       ('01JDJK6F68JNWZ6QQ0BCB7DNY4', 13, 14),
+       ('01JDJK6F68Y8CYW6CN10CCQ1J6', 125, 21),
+       ('01JDJKBAF09BNJ3HGJH8238841', 42, 20),
+       ('01JDJKBAF0WX40F23E2PGFDWYA', 32, 13),
+       ('01JDJM06E0JMSA85NGFQJ1DJZR', 93, 17),
+       ('01JDJM1BHG716JNQ4RK3AMQQ1N', 88, 34),
+       ('01JDJM3XJG1GD124N1T4Z00CVP', 36, 0),
+       ('01JDJM6VAG5GHA624YNZQTVV5N', 46, 43),
+       ('01JDJM9V10NA7V9H467XGAX30K', 118, 20),
+       ('01JDJMA3T8AE0MG31Z8DEQKMMY', 98, 17),
+       ('01JDJMJW309P3VS3BB2J877YX3', 118, 15),
+       ('01JDJMM53GJ56XS6E2AZSZ2CKJ', 119, 39),
+       ('01JDJMM53GW78TZS8KPXA2F7KN', 33, 22),
+       ('01JDJMMVJ849YEYT3WXKTKN1FB', 54, 14),
+       ('01JDJMQ6RGMZ2CFHY3GRYG91EP', 91, 22),
+       ('01JDJMS67R0JZJ096K60Q2CMRN', 46, 14),
+       ('01JDJMWVDR2S3NPXX1VPWAWB4S', 142, 13),
+       ('01JDJN1A089TGRM77Q1TG4M40Z', 28, 21),
+       ('01JDJN1A08XT2A0QSPV0W5NXAN', 97, 39),
+       ('01JDJNEQP8TAKYPNJESN6AV25T', 39, 24),
+       ('01JDJNHW9035A7WM8YP5Z5TN4Z', 78, 41),
+       ('01JDJP6CH09483SJ9EGTNG6RQ7', 3, 23),
+       ('01JDJP7KK0PZECBNVQJX1HK06V', 68, 27),
+       ('01JDJPBBPRE1YVM495AB57FET1', 63, 15),
+       ('01JDJPDB605WYW2GB8ET9ER112', 32, 19),
+       ('01JDJPPF5RPVEDZZ8VNXXXRKCZ', 28, 36),
+       ('01JDJPPYSRSJDKXB72HREHEPS5', 70, 5),
+       ('01JDJPPYSRV0GNNRPVVNMMQ6T0', 41, 5),
+       ('01JDJQ0NB8FYA79T2VAVA0B9RJ', 96, 15),
+       ('01JDJQ54X0KJMRAPPNWWNFQQ23', 23, 29),
+       ('01JDJQ54X0W4PFR98J2ZKF0WPR', 128, 37),
+       ('01JDJQ7A7RYDG3YBEPS4QJJDXE', 36, 19),
+       ('01JDJQB0D0HM598VZ0BAG4D9Q0', 106, 25),
+       ('01JDJQB68GBXAXRASDY2022TYA', 27, 34),
+       ('01JDJQGR00VWHGTY813RWE7MKM', 121, 5),
+       ('01JDJQTHF8KDDW016DTPRR12D1', 26, 7),
+       ('01JDJQWKW8QCEYBG4BCA4KB5WX', 13, 7),
+       ('01JDJR6ZX8JTADJGW9F6P7WG4M', 39, 26),
+       ('01JDJRD748ZBZVQFAT7ZYFVWPF', 42, 33),
+       ('01JDJRGKH0RJCXEE06SHAZ43FP', 31, 8),
+       ('01JDJRHGTGVZZ26VD3NX7CZK9S', 156, 12),
+       ('01JDJRJNY0EFK5SNQZBYETNF3Q', 52, 9),
+       ('01JDJRW0RGGC9NVZ9RX7CSVT9A', 94, 38),
+       ('01JDJRWP8046EYVZJB60YRF9DP', 57, 33),
+       ('01JDJRWP80BYH4F6WPBN3SGXPC', 14, 13),
+       ('01JDJRWP80ZVM8MQZFNW4XTS7J', 81, 17),
+       ('01JDJRY07RNMAYV1RWCD7NW70Z', 77, 16),
+       ('01JDJRZQX07S7V7FEQB485EWDY', 68, 14),
+       ('01JDJRZQX07YGJ9QCY4W1N1CEW', 89, 24),
+       ('01JDJS747G93JK17135EPY6MED', 82, 5),
+       ('01JDJS747G9YBQFSE3WV62XSJR', 65, 22),
+       ('01JDJS747GD4685MK3KPHD3S59', 60, 16),
+       ('01JDJS747GGJNE05KZN24CWFGB', 36, 34),
+       ('01JDJS756R773PP33TJDG9M0J4', 125, 32),
+       ('01JDJSBTM04X0YCZK57HD80BQ6', 73, 15),
+       ('01JDJSBTM0FV1JQBDND3GW8DTR', 31, 14),
+       ('01JDJSF33RKMF6B7N9N04Y0S3K', 81, 27),
+       ('01JDJSZHFGANF6V7MPEVKY0XQH', 89, 39),
+       ('01JDJSZHFGTG9FV8S4NRWHNK8N', 5, 22),
+       ('01JDJSZHFGW1NQZE3MMJKP34SD', 53, 13),
+       ('01JDJT022RB981Q04ZKK661WMR', 105, 5),
+       ('01JDJT4GN872D9Z7MJ065NB22M', 88, 39),
+       ('01JDJT4TDRYHFJWWVB1YJ14WZ0', 43, 32),
+       ('01JDJTATT08CQR97K312NF4FF8', 52, 28),
+       ('01JDJTHHN060BRP6T939MNE8T5', 106, 5),
+       ('01JDJTHHN0P0K0E7AXZVBTF9YV', 1, 24),
+       ('01JDJTHHN0R0ADR6FKZ6SFG0H2', 43, 45),
+       ('01JDJTHHN0Y8MD24524S00W0QB', 113, 31),
+       ('01JDJTJXK86AS8BBSBNYMRZFHW', 63, 18),
+       ('01JDJTK3ERH9222M5K5W33P7EG', 56, 33),
+       ('01JDJTPXH04S74RVHK9QV3EC0N', 27, 30),
+       ('01JDJTTFS8J3CMV3DHH3AY9NB8', 32, 48),
+       ('01JDJTVGZRG7MV5VJVDKM8HHCH', 94, 25),
+       ('01JDJV6Z6GX1E1SY3Y2QN7TDMB', 8, 33),
+       ('01JDJVCQRRB8BFSMQSBN4KRCX9', 23, 22),
+       ('01JDJVDB9R2DN288VRJ0FXNQTS', 90, 27),
+       ('01JDJVEQ80WFS2GSNW76JRFJ2S', 82, 23),
+       ('01JDJVM9YR9KVAXYY46QEP9H57', 89, 25),
+       ('01JDJVV6N8WNG7Q15YR648SFMH', 87, 32),
+       ('01JDJVVFEGFKM0P48R5ZQ863GZ', 63, 50),
+       ('01JDJVVV5GG5DQNZHDMJFVFGQR', 23, 23),
+       ('01JDJWDKH022NX9K867S2YD397', 9, 18),
+       ('01JDJWHEJG49KJKEVT4VGTQD2K', 80, 5),
+       ('01JDJWKB40CG7KY9DKANF9360M', 90, 44),
+       ('01JDJWSP80J3Y4YFZ3MDA4B15Y', 75, 46),
+       ('01JDJWVFVRFHA6KR3A81ACX8JR', 22, 5),
+       ('01JDJWZSJ0DNJAV6YXTW5EPHK8', 87, 25),
+       ('01JDJX3JN04YPM1BXJ198QZ0MR', 86, 5),
+       ('01JDJX5D80S693PY22KHPY9H6R', 87, 40),
+       ('01JDJX5TXGFN6N2X5QG97H5XAW', 61, 8),
+       ('01JDJXEN4RA1D6K3DGDKFY9DAT', 111, 11),
+       ('01JDJXXZC8XHRF8YMS491ERH9H', 73, 35),
+       ('01JDJXZ7DGEZN59N03KDKVHH44', 72, 21),
+       ('01JDJY0S78TF3TC0SBHF2Q4K2E', 129, 17),
+       ('01JDJY2HVR8MHJDZPTVPPB1HRV', 41, 30),
+       ('01JDJY6XGGSK8MMHZGESX2QX9S', 17, 24),
+       ('01JDJY8Q48J9P7S5BWR2FB76GW', 47, 28),
+       ('01JDJYEWCRFXRZAQ8QCY8GTKJ9', 53, 35),
+       ('01JDJYP7R02XPQ82GYHSZPZ4HV', 28, 27),
+       ('01JDJYY3PGR2CSWQKY6NY89AHP', 19, 27),
+       ('01JDJZ8AV8N9EK32G15MSD27KC', 86, 37),
+       ('01JDJZBDFGYZHW1HRHN6D8SGAN', 90, 6),
+       ('01JDJZC2Z00622CS1QNW5P5KR2', 41, 18),
+       ('01JDJZC2Z05BFZRN5314J1JM7W', 63, 21),
+       ('01JDJZC2Z06G88QTSTNKK87ZEQ', 7, 5),
+       ('01JDJZC9SRT11RSCJXQQJEVNV2', 126, 25),
+       ('01JDJZDDY0NBJ3WNQ0FTTWBR92', 62, 30),
+       ('01JDJZJB5854KKD6V7T1JEWNE4', 75, 29),
+       ('01JDJZMS984W3A91G22M9WRBC6', 60, 33),
+       ('01JDJZQ8CGSETV5NG6YDZ5Z79S', 129, 24),
+       ('01JDJZWV38AQ1XS58JKG5M93DD', 59, 16),
+       ('01JDJZWV38BZP7YBSWJTSHHFC0', 54, 39),
+       ('01JDJZWV38CC39WD58WMKCHYGH', 70, 28),
+       ('01JDJZZ4B00BAQ0JPXGBES78F4', 94, 5),
+       ('01JDK03B3G5D3D0WTMKX3PJZA8', 79, 22),
+       ('01JDK04R1071N3V54M5WNXJ1FW', 106, 8),
+       ('01JDK04R10NAXFZ7PA1AHHPM7Q', 62, 37),
+       ('01JDK06BS8D5JQQ3T8FRTCHX3M', 20, 24),
+       ('01JDK06BS8MX5FPXBW4XPYWSV9', 45, 27),
+       ('01JDK06BS8QEXFCJCGNJW30M4Q', 81, 23),
+       ('01JDK06BS8WKDKZNVJ62VKZD8V', 68, 16),
+       ('01JDK0B9ZRT2B46S3SCQ9C213S', 27, 26),
+       ('01JDK0QEN8Z5HKJPM7BEFD3R8E', 101, 14),
+       ('01JDK0T2MR3FBVBA3TEBZY5AN2', 46, 30),
+       ('01JDK0W05GMTWAT7DAFC7H9313', 60, 18),
+       ('01JDK0XWQ064FAPPW8VXAHEQJ0', 52, 21),
+       ('01JDK0YXXGXC6RQ3QG6VRK6YZB', 20, 43),
+       ('01JDK120HRTH1ESNB2JA1VMNQ1', 55, 27),
+       ('01JDK15JT08RQXYHYE6PRQM9BM', 82, 6),
+       ('01JDK17K8GHZYPX3RH9EJ2A5GW', 41, 5),
+       ('01JDK17M7RJEYH7N6G2AJE66TS', 85, 40),
+       ('01JDK18W90J5HNCFF698J1RFG3', 46, 21),
+       ('01JDK19800NJWT832R1JACH4F8', 47, 11),
+       ('01JDK1ATS00D6BG8TS8DAJB6HQ', 58, 19),
+       ('01JDK1B2K0QS0SR91NF5X791BV', 14, 16),
+       ('01JDK1B5GR573YWJTZVR7VQQ4C', 53, 23),
+       ('01JDK1D40R5JA1F3ZV8WNR2XX0', 45, 17),
+       ('01JDK1EMV8FZ1A60YE7GBKJXQ0', 171, 32),
+       ('01JDK1EMV8K4JXDX8Y10S6ETJ5', 131, 26),
+       ('01JDK1SCK826WAK77G3P9MDJAP', 47, 37),
+       ('01JDK1TSGR4F75GK08FR01MPCB', 33, 9),
+       ('01JDK1TSGRMGFB9VA8DE03F57T', 66, 42),
+       ('01JDK1YSEG65NR4ZAQC8Y4RB3V', 199, 35),
+       ('01JDK24STR7RA66RBD3HFFN6YF', 26, 23),
+       ('01JDK25DBR3M5WAMR0HDA9W1HM', 59, 17),
+       ('01JDK25DBR9Y45Q7HJ93TPPJ94', 38, 23),
+       ('01JDK263TGXTPB44CB7WZ7M7XZ', 83, 25),
+       ('01JDK29TZ09VT0GCGE9RXYRPD5', 36, 23),
+       ('01JDK2FWAGRAQSD8S2GSME1KY1', 75, 32),
+       ('01JDK2HD509GVY96PHCERRR408', 30, 23),
+       ('01JDK2N95R2XJH6QKJHX1WPTGX', 59, 20),
+       ('01JDK2SYK06W705MF4R4EWQZ0R', 124, 29),
+       ('01JDK2SYK0E0KT58TA7W6QRRAZ', 51, 15),
+       ('01JDK2SYK0K521PY3A1XGGDFMJ', 37, 21),
+       ('01JDK2T3F89WF48BB1X4W4ES07', 96, 14),
+       ('01JDK2ZGAGPG4QA1F7DNKTGJ4M', 81, 23),
+       ('01JDK318Z0JKJJ23G2EKQEYVMP', 132, 34),
+       ('01JDK31DV8DPX6109S12CXHEJP', 40, 11),
+       ('01JDK31DV8S5JJ0W2P68KG4RFV', 40, 5),
+       ('01JDK31DV8Z15952FW9GM563BD', 110, 42),
+       ('01JDK33P3R516XFJCH15A4PWF4', 62, 25),
+       ('01JDK37K3R36RTBNJDB86C5PT5', 111, 31),
+       ('01JDK37K3RHYWXN34BANYNWDGY', 9, 19),
+       ('01JDK37K3RV9TEN3TVW61KKV3J', 68, 5),
+       ('01JDK399SRYDZVASZEDYBHFPHA', 84, 24),
+       ('01JDK3FWQR13XDX3KBDYC7N8D7', 109, 12),
+       ('01JDK3FWQR8GGS0AHFN947M48Z', 125, 33),
+       ('01JDK3NCGR2JSNR5DFBB2G5J7D', 26, 31),
+       ('01JDK3PASG5KVQEWAHBGB1T2K3', 79, 15),
+       ('01JDK3PNH88XNGBVZPKSV6QVTZ', 85, 39),
+       ('01JDK3PNH8BJKY2B5FNXQ51JVV', 110, 18),
+       ('01JDK3PNH8K36KVE4TNBKMRPZ5', 100, 27),
+       ('01JDK3PNH8PYEKG3TTXTJSRJCR', 26, 7),
+       ('01JDK3R5CGRAA1AHG3CPER1PTG', 63, 40),
+       ('01JDK3SAG0H4M78AV8FAF3QRMX', 144, 24),
+       ('01JDK3WE3G2BFXFED1XX5XSY3Q', 58, 32),
+       ('01JDK42Z30RHZVXXBS6N7GSCNS', 153, 25),
+       ('01JDK45ZRRBNK5WPKZJ43D18FQ', 140, 16),
+       ('01JDK47Y8R2KSM4H4993Q3K001', 35, 27),
+       ('01JDK47Y8RE07Q14XE5Q3MMJGM', 37, 26),
+       ('01JDK49SV0598JVNZXZJAK3N57', 50, 20),
+       ('01JDK4E4GGCF5RMN0MVG0FQV8C', 42, 28),
+       ('01JDK4MKHGZCTZXQMAB8V1P5AH', 32, 15),
+       ('01JDK4V1K8E7048R9X9N3JAQEA', 163, 38),
+       ('01JDK51DPGCCWJW38YM61GDVSN', 74, 12),
+       ('01JDK51KJ0ENDZBWQCH0CGQ08C', 134, 22),
+       ('01JDK5BVP0V721VV25CTHWMYTK', 71, 5),
+       ('01JDK5KZEGMS0MY1WYWNZJ4ZNW', 21, 31),
+       ('01JDK5QMMG25DKQSCNFS19HST6', 161, 18),
+       ('01JDK5WKT89G7W210NDS98E2SN', 72, 32),
+       ('01JDK60MQ83KP4AC66TM1QQVQ7', 120, 12),
+       ('01JDK65TQR5FV9VRXJN2ZX1YA1', 53, 10),
+       ('01JDK66Q20VP7RA2WXQV1V0TSG', 47, 30),
+       ('01JDK6A1G8TD4NQTQAFF5CJ6H7', 25, 21),
+       ('01JDK6CSCR8R28G4W32CMQ8VVX', 87, 25),
+       ('01JDK6TJSRXJF5EZ201XNDV6FP', 72, 18),
+       ('01JDK751RGXHMF0FF0D0RXW1KC', 94, 31),
+       ('01JDK7CNX0KWWXJR79WYW97H9D', 91, 15),
+       ('01JDK7CVRG9HP35KT3KA2KJX83', 61, 33),
+       ('01JDK7DYXGB3TT7CZZEEP7059C', 35, 5),
+       ('01JDK7KASG0TSMCV0S2GCDAV5C', 87, 7),
+       ('01JDK840Z8E7SKTTP56MAGVDQH', 35, 0),
+       ('01JDK84TBRMP0S69DENNV11JTH', 77, 30),
+       ('01JDK84TBRPSPWF8DJK8GDGH61', 44, 33),
+       ('01JDK8GFD84YP294SW1Z9ESVGG', 20, 6),
+       ('01JDK8GFD8YJHJPFQQ4QJV19K0', 10, 11),
+       ('01JDK8H2Y8WH3EJYY4Q1X0H00H', 97, 17),
+       ('01JDK8PDV09M68QSW7PHJDBG76', 16, 19),
+       ('01JDK8R3HRQGVDE5R8F4A543JS', 29, 33),
+       ('01JDK8Z73099BS1JVSXS82TMTD', 10, 14),
+       ('01JDK91V2GR8T4C0R40BT5X8CP', 41, 43),
+       ('01JDK9CFWR64XYM592VF3XG3KD', 103, 27),
+       ('01JDK9HTSG818897CFYGRS65BA', 73, 39),
+       ('01JDK9MCTGGCWST9N89P1QZNHX', 122, 33),
+       ('01JDK9PM3RRQ8QPM0W63A02RSS', 77, 14),
+       ('01JDKAA728ATNC30E01QYPFKT4', 61, 24),
+       ('01JDKACQ4R21RFMWSSJ35QPCDT', 109, 19),
+       ('01JDKACQ4RVEKY9ABNN0JFMS5T', 45, 22),
+       ('01JDKATQCG7EXK21N0GY6HNE8H', 90, 34),
+       ('01JDKB5A889FNSKJG0NTNHR22N', 51, 39),
+       ('01JDKB88ZGNWD1DQRKX67XEW4G', 51, 40),
+       ('01JDKBJK20PSSNYZKHS1895J7Z', 37, 26),
+       ('01JDKBYYJ8311C3KG83E8DDHSR', 53, 31),
+       ('01JDKBYYJ8Y01N7RRH3TB1KGBD', 39, 24),
+       ('01JDKC9AK8TV8F7DM00H2MCSPX', 49, 15),
+       ('01JDKCFAZG01K93CR36WHVEDF8', 27, 20),
+       ('01JDKCFAZGGB5HQKQFKZMNZR11', 125, 19),
+       ('01JDKCY4KRDRRBECE1Z4PNP72Q', 68, 20),
+       ('01JDKCY4KRK7T84RQYFKEAS9KJ', 66, 28),
+       ('01JDKCY4KRSW2BKGN4ER018ZEX', 115, 23),
+       ('01JDKCY4KRY4KRHRK23TY67TEG', 56, 12),
+       ('01JDKD061G3F4Y0KR85WBKNG1Q', 18, 21),
+       ('01JDKD061GEH0TTZ0T1Y5K1DZA', 27, 34),
+       ('01JDKD061GKMRWC92MF49KDH1G', 65, 40),
+       ('01JDKD061GRTD7ET4STA81K4BJ', 27, 19),
+       ('01JDKD31V0GF42SHPJZHWX9517', 36, 24),
+       ('01JDKDCTB0M9CYCXE4QQ766W7A', 79, 23),
+       ('01JDKDCTB0Y33J01N9TSA7JQHT', 28, 43),
+       ('01JDKDJJX8H09JWHXZ0RS97881', 55, 27),
+       ('01JDKDR2P83WGQ73WZ82FMFQZY', 55, 11),
+       ('01JDKDWS2RCQK5W9YD0TPHBKDX', 18, 32),
+       ('01JDKDZ67GTYXVA7RM098D9M3R', 60, 25),
+       ('01JDKE0YW09XNJQ4JPYTB550D7', 129, 39),
+       ('01JDKEDBBGDB39ZRDP5Z2FJV51', 110, 16),
+       ('01JDKEDBBGEPPP55RXS2DVVY1N', 128, 29),
+       ('01JDKEFJMRXDS9R901QWY0M6K9', 38, 20),
+       ('01JDKEFZB0D3VQYFZERCS6Y80B', 21, 30),
+       ('01JDKEHSY0JBXFT10H7YTBEWGW', 34, 31),
+       ('01JDKEWFQG3N14225SES0QQMJZ', 59, 18),
+       ('01JDKF00181JG3STF7FH89SNBP', 72, 20),
+       ('01JDKF0018BY1CECWYWVPH44JD', 71, 9),
+       ('01JDKF0018NQ2MFT1JP5N3KMZB', 93, 14),
+       ('01JDKF0018ZB8HN6YTJ7HTM1MS', 132, 22),
+       ('01JDKF0YA06WEG3GJQ05689XQG', 41, 41),
+       ('01JDKF0YA0HFFSACTGR3WVBH7Z', 48, 26),
+       ('01JDKF3Q5RKD5QECX656V41AXA', 62, 33),
+       ('01JDKF61CRP2FJSSHVCFJWQN6D', 61, 30),
+       ('01JDKF9GQ84E1584N9NXYN4W5Y', 88, 23),
+       ('01JDKFBH5RQKMS83MTBX8D0TN9', 108, 40),
+       ('01JDKFCT685YNAKG6FQ9EG2727', 68, 30),
+       ('01JDKFNEJ0TAKY65HK6HGJWD7Z', 21, 31),
+       ('01JDKFQ2A8TVYC2T2MGG2Z0KS9', 7, 21),
+       ('01JDKGFPD0HW3PG24CYAWHSS3P', 49, 44),
+       ('01JDKGGSJ0JRHCWMBQG11AKS6H', 94, 34),
+       ('01JDKGJBBR3Q9AY3P1D8GJRH17', 37, 5),
+       ('01JDKGJBBRRV6F3TDY5EK5YDAS', 77, 37),
+       ('01JDKGJTZR5MHTTYRX9SHW0M06', 64, 17),
+       ('01JDKGKGF8FS9B3GZM6ST0JW1M', 14, 12),
+       ('01JDKGP4ER73AW5X8X6C123XTZ', 70, 22),
+       ('01JDKGQS689DMEVQECNC52A0PG', 52, 21),
+       ('01JDKGWDM8QZ3GG80S3NAT9K36', 99, 20),
+       ('01JDKGYWQGV1Q9WF93NT4EQ9JE', 84, 26),
+       ('01JDKGYWQGY30ZA74PJANZY472', 84, 26),
+       ('01JDKGZN4R6SV6DW98S3KB6HXK', 40, 18),
+       ('01JDKH266GMAC36Y08F431KQY9', 55, 31),
+       ('01JDKH6JTG0XNSC800NKJTR3Z3', 128, 14),
+       ('01JDKHGN3015XJ4MY4QXBJZP5X', 53, 31),
+       ('01JDKHSN5R8D52TR6H0PEN5ZGH', 33, 43),
+       ('01JDKJ30ZGMRMR14MGBYJ5KSAD', 35, 6),
+       ('01JDKJ31YRCB0MNKHRA4DYXR4R', 73, 21),
+       ('01JDKJ31YRJRBMDZ8D8P9RW0VX', 49, 24),
+       ('01JDKJ6M70EVXHETJA0EX2DAV6', 82, 31),
+       ('01JDKJGDP8Y9AXG5WC9T1PDYSZ', 56, 5),
+       ('01JDKJKS3R3T6TJ4RAXDXAH507', 33, 19),
+       ('01JDKJMEK8HWYYDVBVKYT4Y189', 38, 25),
+       ('01JDKJQACR4D72CTMWVXQ9TX6D', 21, 18),
+       ('01JDKJQACRM5ZTBCKBG620WF0Q', 95, 24),
+       ('01JDKJW5NG7QCDYPX54TE302H9', 142, 7),
+       ('01JDKK40MR2ZWGMCY2YGYPWK3H', 15, 27),
+       ('01JDKK8RZRG6XWHZVD9XQ0HYCX', 22, 15),
+       ('01JDKKGNXG5BD2K0YNGFWAN10K', 57, 8),
+       ('01JDKKGWR8VH11VPFB9XYK102Z', 86, 44),
+       ('01JDKKHN5G1S9WP6G5NWZYGJ5T', 96, 27),
+       ('01JDKKHN5GDQ41QPSWWCVYYKSA', 46, 21),
+       ('01JDKKSVVR1JPMWS340TWZ8X59', 79, 12),
+       ('01JDKKT5M8QGT81PAKM1G5C5V1', 127, 7),
+       ('01JDKKW53GM4JXF948ST1RMQZF', 91, 47),
+       ('01JDKM528G3GF7PNXEV75X4ZMF', 98, 26),
+       ('01JDKMDGRRCBNK8VGS0BXB5K39', 41, 29),
+       ('01JDKMFBBR4X91TXAEPWQNEY3J', 13, 37),
+       ('01JDKMFBBR8DKWCTAHXKFDB3RF', 70, 25),
+       ('01JDKMFP3GRRPG55XJ3Y5WVVCW', 76, 13),
+       ('01JDKMHPJ0AGKWTE9ZV9XMDD0M', 21, 15),
+       ('01JDKMKQ0G1PQFYQRW0ZSXCRT6', 53, 28),
+       ('01JDKMT158ET8NQ6V7F78V0D9M', 29, 38),
+       ('01JDKMTJQR6A6F6PB472QSBYCD', 34, 38),
+       ('01JDKMTXFG4T1086R6P350TGKT', 123, 26),
+       ('01JDKN0C98QKQ6Z2RQYE31N78T', 89, 18),
+       ('01JDKN1T600HTT3BA11R8F5E08', 57, 22),
+       ('01JDKN51PGDF5QZC2VNNAP0WQJ', 67, 30),
+       ('01JDKN51PGRA37973CM4377Z30', 75, 13),
+       ('01JDKNGCZGMVVK9ACBV02RGFS9', 54, 16),
+       ('01JDKNNK00FZCP7F1NFE1NQZ26', 91, 27),
+       ('01JDKNP4JGMTZGHPH459BA2TSB', 65, 42),
+       ('01JDKNSHYGCHHJ14QQGKBTSP63', 79, 21),
+       ('01JDKP1MQR7KEVDBA1HH26N56D', 40, 15),
+       ('01JDKP1MQRP7PT46YV6NXRF6AR', 66, 30),
+       ('01JDKP48Q8P99CYCDGR40WMD44', 44, 24),
+       ('01JDKP77EGS86B3JVDNF7S9K5E', 123, 31),
+       ('01JDKP8BJRD8CX3JEJ9P4PNYXQ', 30, 23),
+       ('01JDKPAMTGJA800GR2Q0CXZB6Q', 104, 9),
+       ('01JDKPF7A020699WSJT003HY0G', 19, 23),
+       ('01JDKPF7A042WYZBY4TJ9214RQ', 93, 11),
+       ('01JDKPK968Y9F4MJT4BW4GCAVC', 77, 49),
+       ('01JDKPSCG8941VBCHJE7P3JV28', 85, 17),
+       ('01JDKPSMA8KW9Q4PP641G7P9MQ', 173, 21),
+       ('01JDKPWQXRMZNXXX2HS5XJZAP8', 93, 35),
+       ('01JDKPWZQRYZRTSEXJR0DKVENE', 129, 22),
+       ('01JDKPZAY0BSJ74VZ4FSBSG9GN', 87, 20),
+       ('01JDKPZAY0X3ZRXN6WDP3K0QC8', 43, 13),
+       ('01JDKPZTJ0PK3ZW0W8PNSENGTJ', 47, 11),
+       ('01JDKQBVAG4T1D73F1EPXACBWE', 60, 26),
+       ('01JDKQQQ6RQ0YN3P40EBKHMRQ7', 67, 15),
+       ('01JDKQVCCR23YWVBFG3NP2J784', 83, 12),
+       ('01JDKQY3A079E4DCNWE49GKV6J', 102, 18),
+       ('01JDKQYGZGEBP6GMSFCN7JJBER', 62, 7),
+       ('01JDKQYXNR3HH15VFXF6ED0HJ1', 30, 26),
+       ('01JDKR8H9G48Q70GEGVVX5A9JV', 50, 22),
+       ('01JDKR8H9GQ4JTKJ35DDA9A2TG', 109, 29),
+       ('01JDKRA9Y0ZJ4TR9HH5C6BA6S4', 47, 29),
+       ('01JDKRENJR4TA2PZS01TPXB9S2', 27, 10),
+       ('01JDKRENJRMVJRZHAGVPDE3G5X', 125, 30),
+       ('01JDKRFNT030Q01MPB3XWPBS31', 66, 8),
+       ('01JDKRFNT0DDPSZYSW9HHCRR8S', 111, 25),
+       ('01JDKRPQCRCFTTYPG86E5ZEQWY', 60, 28),
+       ('01JDKRWC206NH9JH4WSEQW523B', 37, 20),
+       ('01JDKRWC20AVPVFQQAY8H7KBXB', 43, 15),
+       ('01JDKS0WK0TG8E4F90BG9H4P6C', 67, 13),
+       ('01JDKS12EG2M9PSP8H2WCHKMW7', 111, 18),
+       ('01JDKS1D687TK37649KBWEASZQ', 119, 30),
+       ('01JDKS7DJGCSQ7B490YF6RZMQH', 110, 37),
+       ('01JDKSBDG8D33JNXJDV9EF3KKA', 91, 34),
+       ('01JDKSPD280MCX21AKBB0R7XBE', 60, 21),
+       ('01JDKSTDZ8TWHW7161JGK0GRHH', 47, 21),
+       ('01JDKSX2Y0P7BCDR6MVKFPCD3V', 1, 31),
+       ('01JDKTGD38T1ZNNTN7DV3B10W3', 21, 34),
+       ('01JDKTH5GGJ23QH0PD0AXAN9SH', 43, 41),
+       ('01JDKTHN4G1FB785CB63FWE4QQ', 69, 8),
+       ('01JDKTHN4GA1W34K2B8S2EB9WB', 48, 20),
+       ('01JDKTHN4GYP9FFPG62XGVYBS2', 118, 26),
+       ('01JDKTHN4GZD7TTZPTM6ZYH4RN', 42, 36),
+       ('01JDKTKSG047A1Y2GADKMWG7CN', 64, 30),
+       ('01JDKV2E80Y8KVGWH4VRSFTAWZ', 135, 10),
+       ('01JDKVFJ5GQ7BTJ4VP6B15GAXJ', 159, 23),
+       ('01JDKVN9RGNA1QCT9QRNHESF6T', 74, 18),
+       ('01JDKVPNPRGWYD0HAAHSEHSY2M', 71, 30),
+       ('01JDKVXHE0MM7KTG12BZA6WZ09', 30, 20),
+       ('01JDKVXHE0RBY0A74M4C33YJBB', 120, 18),
+       ('01JDKVXHE0Z586XERQXH08VR1V', 29, 21),
+       ('01JDKW7NN090QZBBVDA6MXVETJ', 121, 37),
+       ('01JDKWDP1876ZS56T2SXCVBTSJ', 37, 20),
+       ('01JDKWKKFR6WHG6BDBKSMGV8D2', 80, 23),
+       ('01JDKWPBC80WPN117WMGB7CP4H', 44, 32),
+       ('01JDKWPBC85SWWRARFMT46KAFV', 33, 24),
+       ('01JDKWPBC8KZBWN3JM9SRVKMT8', 34, 21),
+       ('01JDKWQAM8P3YHCJA51F97N21Q', 30, 29),
+       ('01JDKWVQ88W1ES1A7ZCRQKPM52', 37, 33),
+       ('01JDKX2PWGR8Y8P5C2JKG3FXH8', 28, 39),
+       ('01JDKX42TRKCFH3GSAEEBRB5EX', 114, 30),
+       ('01JDKX4RA8SXYYQX7GW53JKE4S', 75, 40),
+       ('01JDKXAYJ06X5H784HPX4FA9PE', 111, 27),
+       ('01JDKXBF58GAX63RYH60EP837X', 73, 17),
+       ('01JDKXBF58YG7SGCSNYPB8SSZR', 55, 25),
+       ('01JDKXDVAR4P766V9CMS570KJZ', 40, 16),
+       ('01JDKXDVARFN1TPJY0YW6815AE', 65, 18),
+       ('01JDKXNFF8N62M4C7ZKWWBE5N2', 62, 29),
+       ('01JDKXR7BRFTQH8WS21YXJYXK2', 87, 12),
+       ('01JDKXWC5RMTBC17SE6PB9YPCD', 22, 46),
+       ('01JDKXWE48RBBKVB3MCF3W19HN', 60, 42),
+       ('01JDKXZ428T7NA7NB0BP20PCNC', 35, 30),
+       ('01JDKXZWFG66CTFSQK2W7E1DNK', 82, 31),
+       ('01JDKXZWFGES2ZDPGYBHJ5PCFZ', 66, 37),
+       ('01JDKXZWFGXBN589DER4B5DESE', 45, 50),
+       ('01JDKY5QZGR3E1BKRH81Z4WH36', 78, 23),
+       ('01JDKYDC400NT6YEJPA6DA2N7J', 85, 38),
+       ('01JDKYNNR0PA1S92E1CRB5FZFS', 24, 40),
+       ('01JDKYNQPGN6B8YRQS6T8K8MWM', 61, 21),
+       ('01JDKYWX68F7VENZSJ3J4D7DR6', 15, 25),
+       ('01JDKYZAB09BT22RKBHC83KBNC', 60, 24),
+       ('01JDKZ2RP849BZPEVJF5VMCN8D', 44, 19),
+       ('01JDKZ5CNR6CXSAV3SXKK3Z0YA', 103, 26),
+       ('01JDKZ5CNRR3483DR5HKEATZM4', 41, 14),
+       ('01JDKZ6530M772PSGZKWPDCYA9', 95, 27),
+       ('01JDKZ8H8GKQRVYTJP6MA3M603', 25, 35),
+       ('01JDKZAES8V1A187D9YVQ77P3H', 51, 14),
+       ('01JDKZEHMRQG03KNGP96BQ1T9G', 66, 32),
+       ('01JDKZEHMRRJD9A9PEZ5ESTCDS', 53, 36),
+       ('01JDKZKJS0A239M84ARJVPNFEM', 139, 30),
+       ('01JDKZNH90EAMNHWPZ5M4005HX', 26, 37),
+       ('01JDKZNH90X81BRPBGF4BJAZV9', 102, 16),
+       ('01JDKZNN606FGGTJPBHNP38YYH', 170, 8),
+       ('01JDKZZGKR4FHSBEPAB6GNEDXD', 165, 9),
+       ('01JDKZZGKRPSJB0XRBVA50YMRP', 36, 30),
+       ('01JDM02S3GVRYN0BBVRJKP6V90', 26, 18),
+       ('01JDM03CMG7KSR2BMANSS77WYM', 135, 33),
+       ('01JDM04708TQZ047MQEFNC6TGY', 48, 30),
+       ('01JDM07CJ8ZDVSSYH691CXZ07W', 136, 28),
+       ('01JDM0ARZ02AER5WY3M5HB4XFN', 97, 8),
+       ('01JDM0JKY8ZQ0SCEZX3QDGFB62', 65, 12),
+       ('01JDM0TSN83Z2PAWG5FFECNSGC', 55, 18),
+       ('01JDM0TSN89Y82ST3N1SWJ80ZE', 69, 15),
+       ('01JDM0TSN8BRGG783QCY98D1CP', 106, 45),
+       ('01JDM0TSN8RV247THXQ86A5Y43', 90, 13),
+       ('01JDM0TSN8RYAWDY1XJCEGZA0K', 73, 33),
+       ('01JDM0TSN8V128KQJYJTKW5CF7', 88, 44),
+       ('01JDM0WDDG753F3C4JJHAR5SBC', 63, 32),
+       ('01JDM0X3W81BS74NJ2V2CS6N24', 38, 21),
+       ('01JDM0X3W85N5YCX4ZDZNK90YP', 56, 25),
+       ('01JDM0Y80GBJWKMK2VGY0SPNG3', 27, 14),
+       ('01JDM0ZB5GDK739546NFAWYG94', 51, 20),
+       ('01JDM0ZB5GFRB05T0ATCVYWWFY', 59, 21),
+       ('01JDM0ZB5GSF94GSXKY4Z29BHR', 64, 29),
+       ('01JDM0ZE382A2Y0X3DYXQZQB67', 124, 18),
+       ('01JDM1A1Y86428SGHYKD56FFR1', 8, 39),
+       ('01JDM1A7SREHPRJYB0KVGR3EQQ', 87, 24),
+       ('01JDM1GPTRNKA388NP9Y1ZRKR4', 145, 17),
+       ('01JDM1S3CG9QADQ2X5BRB4DTQH', 36, 34),
+       ('01JDM224EGMJN5SG8HJ4P06ZYD', 74, 19),
+       ('01JDM224EGTBW5T23P125QKCJZ', 49, 38),
+       ('01JDM227C83TBMYPHK6SZD2QNH', 79, 17),
+       ('01JDM25WJ8W5VZVNSMS8JF9MB0', 48, 25),
+       ('01JDM28BNG5G1JJB6YXCTNNY2C', 110, 31),
+       ('01JDM28EK89N4H7HT7RY263FYW', 43, 27),
+       ('01JDM2AG101AM4RS2A0N89FGRT', 73, 28),
+       ('01JDM2FG602QTAP56A383NSCYX', 54, 23),
+       ('01JDM2FG60C3PAHSACYHN2QMAM', 52, 20),
+       ('01JDM2K89RE8ARPC5252843N53', 26, 20),
+       ('01JDM2KN00JSYG3YC866HDZZJN', 115, 36),
+       ('01JDM2MN78VW1B4BF255NYNNJS', 11, 10),
+       ('01JDM2VF000H8ZZ71EXQP7KC4C', 81, 25),
+       ('01JDM2XDG0RTAGFDAY6QGYVXWR', 112, 34),
+       ('01JDM35YY0P8RT6239H4A6P0T6', 114, 22),
+       ('01JDM3AGE8Q7ZAVTSSDDTCDVWS', 137, 30),
+       ('01JDM3AY3R3N39GX4PPGDJC4FR', 86, 30),
+       ('01JDM3BAT06GZJPNGMQFDPNKRJ', 56, 28),
+       ('01JDM3CB184209Y9R5NKSQ4D2T', 18, 21),
+       ('01JDM3CWKR9641S85TT52PPR64', 154, 26),
+       ('01JDM3CZHGBNM9TR4XS0K0JFK1', 72, 39),
+       ('01JDM3EJAGZ5XG9D6ZJG56ZKXP', 72, 33),
+       ('01JDM3FKH0ZKY3P1B02GV11JG3', 35, 19),
+       ('01JDM3GYG0WK4RC0VWQPHXAVXY', 46, 36),
+       ('01JDM3RBSRGKJSBRE5BQ7DTEHP', 84, 11),
+       ('01JDM3WTC8CC84CGMX7FHSFRN5', 36, 30),
+       ('01JDM48N989AVJ1Z4C42EQEPS1', 60, 24),
+       ('01JDM48N98TGDWM75NS8HXVKFT', 43, 37),
+       ('01JDM49JJRQKK4ZD9093YBSN9C', 89, 25),
+       ('01JDM4AHTRX1C8VC1NBA0SS6E7', 69, 27),
+       ('01JDM4D6SGAJK50JPKEHX5P1KB', 68, 16),
+       ('01JDM4D6SGB5WWPVHBP7995G7N', 31, 20),
+       ('01JDM4JHP84QSH2EN7F825AAQF', 53, 23),
+       ('01JDM4JHP8MG1NXYYQ2EJN89F0', 101, 24),
+       ('01JDM4KPSRC6PEPPER6A8K539T', 65, 5),
+       ('01JDM4MN2GME13S5MR28RBC17X', 95, 36),
+       ('01JDM4RTVR1DCY1RVR83PAN6T1', 99, 26),
+       ('01JDM4WTSGDSWGKQHB9JMNGDQZ', 86, 42),
+       ('01JDM4YGG86QA9ZW7P319HEFJ4', 106, 38),
+       ('01JDM538V8F99RMAS712C4SFDW', 51, 9),
+       ('01JDM5A9ERB58BWX38Q0A9VSMC', 60, 18),
+       ('01JDM5C41RW2S4C4YYZKXCN61M', 69, 18),
+       ('01JDM5CDT8V23CC269CKFGMCXZ', 55, 12),
+       ('01JDM5DG007XAR9DNRPKSWEDTD', 22, 38),
+       ('01JDM5DG00JQSSZXN20PPQETQR', 43, 15),
+       ('01JDM5DG00KZDNHCBBD83K59XT', 24, 18),
+       ('01JDM5DG00N787TCCD10J4JPGH', 37, 21),
+       ('01JDM5DG00TE3MKSW6G3T596M7', 39, 25),
+       ('01JDM5EH6GA8BH0CYNEW62KD30', 96, 20),
+       ('01JDM5FY40ZCRQAV13482B27R3', 46, 27),
+       ('01JDM5G5Y0D6C1Y7JSGMJA6CGQ', 74, 28),
+       ('01JDM5PK0G6NFHEH3ZQBCWF33A', 79, 13),
+       ('01JDM5X030DKHWEGKHX39PEYWM', 123, 24),
+       ('01JDM60N905RR304V6D42G63F1', 96, 9),
+       ('01JDM60P88J93TZ9T3D8DM2Z88', 22, 42),
+       ('01JDM63E4R3059F2P10FGZ6B93', 86, 29),
+       ('01JDM66520R0AKVP2KQVGPMPE6', 41, 15),
+       ('01JDM6BFYRZ56Q3E87YZGRR1C4', 184, 13),
+       ('01JDM6HS4855R6DSHX4NN37RWZ', 153, 26),
+       ('01JDM6J6SRF4RBND71B6JQ8KQ7', 70, 29),
+       ('01JDM6J6SRZ1B2QDE0MN9ZVZCH', 101, 31),
+       ('01JDM6TDG03865AK8FM0XJNVSQ', 83, 16),
+       ('01JDM6V4Y0F2GS0SJYN3HTJXQT', 25, 30),
+       ('01JDM6Y1PR354TPRF9WYX2KZGB', 110, 36),
+       ('01JDM6Y1PRNDYVR43SSF0V0AMT', 103, 7),
+       ('01JDM71WR8H7FPAMQ6K8DNTPKM', 82, 26),
+       ('01JDM75E186HK10CT07YZ58AWY', 58, 14),
+       ('01JDM75E188VE1W3B7Q08105Q3', 39, 15),
+       ('01JDM75E18FMENJFK85RN868XA', 80, 23),
+       ('01JDM76ZV07QRZ7RTPCHXVK43Y', 53, 37),
+       ('01JDM76ZV0D09YKTPE56HZSDH2', 51, 6),
+       ('01JDM79WKRD8SJR4NAAKXSZE2E', 36, 40),
+       ('01JDM7BV3RERRD60VKQ56AER0E', 27, 35),
+       ('01JDM7CPERZY5RQ2NQGYBY3WXW', 108, 10),
+       ('01JDM7N30G7SG80WQ7YQEB3G6B', 65, 24),
+       ('01JDM7XAP0R9MZRQQGWFX5M72E', 118, 27),
+       ('01JDM88A80HENCSX20JW622YGH', 64, 32),
+       ('01JDM8ABNR798DS0K1TJHMVAKK', 64, 38),
+       ('01JDM8ABNRAQNKEXPKVFQDEXQZ', 95, 32),
+       ('01JDM8ABNRMZBT61DECRZYZ2J3', 75, 24),
+       ('01JDM8CWQG43F3MC9VHREVHQ7W', 89, 18),
+       ('01JDM8DR2GKP9ZAEM5NG1ZD44R', 49, 17),
+       ('01JDM8H6DR13X92H84850QXB8B', 49, 39),
+       ('01JDM8KDQ044QFDBG5BRQQ3FW4', 47, 39),
+       ('01JDM8P4M8JGQCFY6XB27ZS80J', 44, 7),
+       ('01JDM8WCTGACVDCSEQDRRV9E9A', 123, 23),
+       ('01JDM8WCTGBR0EVPFB3SGW11MW', 29, 16),
+       ('01JDM97FA8JM6S62MY9H5A0N9P', 63, 19),
+       ('01JDM9JEW8Z1V5SXGN0JFKHX06', 52, 43),
+       ('01JDM9R7EGY8QPXQFRVJF529ZQ', 56, 29),
+       ('01JDM9SJDG22TQDN5JT3Q6GQJ6', 29, 23),
+       ('01JDM9WK38VQNFJFYC1R42HDWE', 148, 29),
+       ('01JDM9Z4503XAABWEWQH3X4SF8', 59, 12),
+       ('01JDMA2FJG9AVFZ5F6DQ0BJ0TC', 110, 17),
+       ('01JDMA2FJGKT5VPXMYM0GYJV6H', 107, 26),
+       ('01JDMA2FJGSR17KHVRGSHEDZZR', 41, 17),
+       ('01JDMAC36882QDQ5GXR2XB1MYJ', 44, 25),
+       ('01JDMADXS88FZSJ294GFDMCC81', 2, 19),
+       ('01JDMADXS8CD2X1YV447N2E758', 83, 20),
+       ('01JDMAK9N8EYH1DA48SSDZBQMA', 128, 31),
+       ('01JDMAYQW0KHCZXVYKMT451QDK', 10, 28),
+       ('01JDMB6FXG4TRT794VSA5A4ZXM', 99, 40),
+       ('01JDMB7N1009C2ANA65N464S08', 100, 23),
+       ('01JDMB9FM0CGDTY1FMH282QH51', 60, 41),
+       ('01JDMBEGR8NP89NEJV1N3QFS0Y', 40, 36),
+       ('01JDMBEGR8QFSBK82VC3B97YVZ', 64, 25),
+       ('01JDMC5XS0FQVZPE3MJK2158TY', 123, 17),
+       ('01JDMC67HGCJ08SPW0MHA70PH6', 27, 34),
+       ('01JDMC67HGG2KQ81RXXZW58J9D', 62, 15),
+       ('01JDMC8YER45VF0G6ZS6XKQZJ4', 34, 43),
+       ('01JDMCNDW0NWRPQEGYT5VD3AD0', 49, 27),
+       ('01JDMDGB6G4FSZ18M9AZWA8RSE', 98, 25),
+       ('01JDMDM3A83EGC4C8S1ZV5GBT7', 69, 16),
+       ('01JDME2E9RXHRHW5X2KJAGFRJ4', 65, 25),
+       ('01JDMECEKRVX632ZGFT32VBWJZ', 55, 28),
+       ('01JDMEDSJRC0VCPWKHSTDQDEF1', 51, 40),
+       ('01JDMEE96R34MVDAKSYM3550Z0', 143, 27),
+       ('01JDMEFCBR5NBEKKG13721F70A', 29, 16),
+       ('01JDMEFCBRJHKS8GMHHNXM5F5D', 50, 7),
+       ('01JDMF18M8Q2YMG7MDFCKWW6ND', 40, 38),
+       ('01JDMF9S30014M1SM51RKYWCRX', 101, 11),
+       ('01JDMFDT00FJTAFR1QMM5YHH8H', 44, 28),
+       ('01JDMFDT00K4XJ4TMTZR13XXQE', 35, 30),
+       ('01JDMFG938JC8RCPVF7KQJAETA', 45, 42),
+       ('01JDMFG938MEJ3YW4XDX0AXDHM', 102, 6),
+       ('01JDMFHXTR7FVPY9SWXY7KTS4G', 74, 20),
+       ('01JDMGDZ9GWJ6MRN5CVSPEBJ24', 94, 43),
+       ('01JDMGFG408SA4ZRYMJT8V5R3E', 32, 12),
+       ('01JDMGX4MR1NSA3ZQZ3WSA8EJ4', 81, 41),
+       ('01JDMGXGBR6ZE0GHY6KBQ4VFVT', 72, 26),
+       ('01JDMGY6TGNXA3SXQRQB5E1YHQ', 76, 10),
+       ('01JDMGY6TGVCS9DR51JS8GQ0KK', 10, 18),
+       ('01JDMHGKP8XKH6WG4FNT9T40JH', 73, 39),
+       ('01JDMHQ4NRT45YRGV9X42CCVVF', 6, 22),
+       ('01JDMJFD1GVBM0J43C7QQF0CYC', 97, 26),
+       ('01JDMJTJF06HD3S17H4VWJAZKP', 151, 34),
+       ('01JDMK1YSGNJEX0P1EDRETCDGC', 47, 20),
+       ('01JDMK1YSGQ4KB9GAD7C1613GQ', 33, 16),
+       ('01JDMKMBN85Z63KG60G06AGCQ4', 59, 9),
+       ('01JDMKXGM8V8BVDYCQXGBG404E', 57, 24),
+       ('01JDMN4210X5C956XQE6155VY3', 110, 51),
+       ('01JDMN7M98M4D0BQ8H0SDH14FZ', 83, 10),
+       ('01JDMP8B58XBZJ5S7A1NW9DSZV', 107, 26);
+
+UPDATE chairs c
+  JOIN initial_chair_distances d ON d.chair_id = c.id
+SET c.total_distance            = d.total_distance,
+    c.loaded_distance           = d.loaded_distance,
+    c.total_distance_updated_at = d.total_distance_updated_at,
+    c.latitude                  = d.latitude,
+    c.longitude                 = d.longitude;
+
+UPDATE rides r
+  JOIN initial_ride_distances d ON d.ride_id = r.id
+SET r.pickup_distance = d.pickup_distance,
+    r.loaded_distance = d.loaded_distance,
+    r.updated_at      = r.updated_at;
+
+DROP TABLE initial_chair_distances;
+DROP TABLE initial_ride_distances;